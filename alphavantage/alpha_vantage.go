@@ -1,16 +1,19 @@
 package alphavantage
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/marketdata"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/pratyush934/tradealpha/server/util"
 
@@ -29,6 +32,18 @@ type QuoteResponse struct {
 		Volume    string `json:"06. volume"`
 		Timestamp string `json:"07. latest trading day"`
 	} `json:"Global Quote"`
+	// set when Alpha Vantage could not be reached and the quote is an
+	// earlier answer
+	CachedAt *time.Time `json:"cachedAt,omitempty"`
+}
+
+// OverviewResponse represents the OVERVIEW API response, only the fields
+// cached on a stock are kept
+type OverviewResponse struct {
+	Symbol   string `json:"Symbol"`
+	Name     string `json:"Name"`
+	Sector   string `json:"Sector"`
+	Currency string `json:"Currency"`
 }
 
 // IntradayResponse represents the TIME_SERIES_INTRADAY API response
@@ -44,6 +59,7 @@ type IntradayResponse struct {
 		Close  string `json:"4. close"`
 		Volume string `json:"5. volume"`
 	} `json:"Time Series (1min)"` // Adjust for other intervals if needed
+	CachedAt *time.Time `json:"cachedAt,omitempty"`
 }
 
 // SearchResponse represents the SYMBOL_SEARCH API response
@@ -62,59 +78,78 @@ type SearchResponse struct {
 }
 
 // FetchQuote retrieves the current stock quote for a symbol
-func FetchQuote(symbol string, logger *zerolog.Logger) (*QuoteResponse, error) {
-	url := fmt.Sprintf("%s?function=GLOBAL_QUOTE&symbol=%s&apikey=%s", AAlphaVantageBaseURL, symbol, os.Getenv("ALPHA_VANTAGE_KEY"))
-	resp, err := http.Get(url)
+func (c *Client) FetchQuote(ctx context.Context, symbol string, logger *zerolog.Logger) (*QuoteResponse, error) {
+	resp, err := c.get(ctx, c.quoteURL(symbol))
 	if err != nil {
 		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch quote from Alpha Vantage")
-		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to fetch stock quote", err)
+		return nil, fetchError(err, "Failed to fetch stock quote")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		logger.Error().Int("status", resp.StatusCode).Str("symbol", symbol).Msg("Alpha Vantage API returned non-200 status")
-		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Alpha Vantage API error", nil)
-	}
-
 	var quote QuoteResponse
 	if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
 		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse quote response")
 		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to parse stock quote", err)
 	}
+	quote.CachedAt = CachedAt(resp)
 
 	if quote.GlobalQuote.Symbol == "" {
 		logger.Error().Str("symbol", symbol).Msg("Invalid symbol or no data returned")
-		return nil, util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Invalid stock symbol", nil)
+		return nil, util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Invalid stock symbol", marketdata.ErrUnknownSymbol)
 	}
 
 	return &quote, nil
 }
 
+func (c *Client) quoteURL(symbol string) string {
+	return fmt.Sprintf("%s?function=GLOBAL_QUOTE&symbol=%s&apikey=%s", c.baseURL, symbol, c.apiKey)
+}
+
+// FetchOverview retrieves the company overview (name and sector) for a symbol
+func (c *Client) FetchOverview(ctx context.Context, symbol string, logger *zerolog.Logger) (*OverviewResponse, error) {
+	url := fmt.Sprintf("%s?function=OVERVIEW&symbol=%s&apikey=%s", c.baseURL, symbol, c.apiKey)
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch overview from Alpha Vantage")
+		return nil, fetchError(err, "Failed to fetch stock overview")
+	}
+	defer resp.Body.Close()
+
+	var overview OverviewResponse
+	if err := json.NewDecoder(resp.Body).Decode(&overview); err != nil {
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse overview response")
+		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to parse stock overview", err)
+	}
+
+	if overview.Symbol == "" {
+		logger.Error().Str("symbol", symbol).Msg("Invalid symbol or no data returned")
+		return nil, util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Invalid stock symbol", nil)
+	}
+
+	return &overview, nil
+}
+
 // FetchIntraday retrieves intraday time series data for a symbol
-func FetchIntraday(symbol, interval string, logger *zerolog.Logger) (*IntradayResponse, error) {
+func (c *Client) FetchIntraday(ctx context.Context, symbol, interval string, logger *zerolog.Logger) (*IntradayResponse, error) {
 	if !isValidInterval(interval) {
 		logger.Error().Str("interval", interval).Msg("Invalid interval for intraday data")
 		return nil, util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Invalid interval", nil)
 	}
 
-	url := fmt.Sprintf("%s?function=TIME_SERIES_INTRADAY&symbol=%s&interval=%s&apikey=%s&extended_hours=true", AAlphaVantageBaseURL, symbol, interval, os.Getenv("ALPHA_VANTAGE_KEY"))
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("%s?function=TIME_SERIES_INTRADAY&symbol=%s&interval=%s&apikey=%s&extended_hours=true", c.baseURL, symbol, interval, c.apiKey)
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch intraday data from Alpha Vantage")
-		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to fetch intraday data", err)
+		return nil, fetchError(err, "Failed to fetch intraday data")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		logger.Error().Int("status", resp.StatusCode).Str("symbol", symbol).Msg("Alpha Vantage API returned non-200 status")
-		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Alpha Vantage API error", nil)
-	}
-
 	var intraday IntradayResponse
 	if err := json.NewDecoder(resp.Body).Decode(&intraday); err != nil {
 		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse intraday response")
 		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to parse intraday data", err)
 	}
+	intraday.CachedAt = CachedAt(resp)
 
 	if intraday.MetaData.Symbol == "" {
 		logger.Error().Str("symbol", symbol).Msg("Invalid symbol or no data returned")
@@ -125,23 +160,22 @@ func FetchIntraday(symbol, interval string, logger *zerolog.Logger) (*IntradayRe
 }
 
 // SearchSymbol searches for stocks by keyword
-func SearchSymbol(keyword string, logger *zerolog.Logger) (*SearchResponse, error) {
+func (c *Client) SearchSymbol(ctx context.Context, keyword string, logger *zerolog.Logger) (*SearchResponse, error) {
 	// Check if API key is set
-	apiKey := os.Getenv("ALPHA_VANTAGE_KEY")
-	if apiKey == "" {
-		logger.Error().Str("keyword", keyword).Msg("ALPHA_VANTAGE_KEY environment variable not set")
+	if c.apiKey == "" {
+		logger.Error().Str("keyword", keyword).Msg("market_data.api_key is not configured")
 		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "API key not configured", nil)
 	}
 
 	// Construct URL and log it (with API key masked)
-	url := fmt.Sprintf("%s?function=SYMBOL_SEARCH&keywords=%s&apikey=%s", AAlphaVantageBaseURL, keyword, apiKey)
-	logger.Info().Str("url", strings.Replace(url, apiKey, "****", -1)).Msg("Sending request to Alpha Vantage")
+	url := fmt.Sprintf("%s?function=SYMBOL_SEARCH&keywords=%s&apikey=%s", c.baseURL, keyword, c.apiKey)
+	logger.Info().Str("url", strings.Replace(url, c.apiKey, "****", -1)).Msg("Sending request to Alpha Vantage")
 
 	// Make HTTP request
-	resp, err := http.Get(url)
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		logger.Error().Err(err).Str("keyword", keyword).Msg("Failed to search symbols")
-		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to search symbols", err)
+		return nil, fetchError(err, "Failed to search symbols")
 	}
 	defer resp.Body.Close()
 
@@ -149,31 +183,10 @@ func SearchSymbol(keyword string, logger *zerolog.Logger) (*SearchResponse, erro
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Error().Err(err).Str("keyword", keyword).Msg("Failed to read response body")
-		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to read API response", err)
+		return nil, fetchError(err, "Failed to read API response")
 	}
 	logger.Info().Str("response", string(body)).Msg("Alpha Vantage raw response")
 
-	// Check HTTP status code
-	if resp.StatusCode != http.StatusOK {
-		logger.Error().Int("status", resp.StatusCode).Str("keyword", keyword).Msg("Alpha Vantage API returned non-200 status")
-		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Alpha Vantage API error", nil)
-	}
-
-	// Check for API error messages in JSON response
-	var rawResponse map[string]interface{}
-	if err := json.Unmarshal(body, &rawResponse); err != nil {
-		logger.Error().Err(err).Str("keyword", keyword).Msg("Failed to parse raw response")
-		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to parse search results", err)
-	}
-	if info, exists := rawResponse["Information"]; exists {
-		logger.Error().Str("keyword", keyword).Str("error", fmt.Sprintf("%v", info)).Msg("Alpha Vantage API error response")
-		return nil, util.NewAppError(http.StatusBadGateway, types.StatusBadGateway, fmt.Sprintf("Alpha Vantage API error: %v", info), nil)
-	}
-	if note, exists := rawResponse["Note"]; exists {
-		logger.Error().Str("keyword", keyword).Str("error", fmt.Sprintf("%v", note)).Msg("Alpha Vantage API rate limit exceeded")
-		return nil, util.NewAppError(http.StatusTooManyRequests, types.StatusTooManyRequests, fmt.Sprintf("Alpha Vantage API rate limit: %v", note), nil)
-	}
-
 	// Parse response into SearchResponse struct
 	var search SearchResponse
 	if err := json.Unmarshal(body, &search); err != nil {
@@ -203,7 +216,7 @@ func isValidInterval(interval string) bool {
 	return false
 }
 
-func SearchStockHandler(logger *zerolog.Logger) echo.HandlerFunc {
+func (client *Client) SearchStockHandler(logger *zerolog.Logger) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		keyword := c.QueryParam("query")
 		if keyword == "" {
@@ -211,7 +224,7 @@ func SearchStockHandler(logger *zerolog.Logger) echo.HandlerFunc {
 			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Query parameter is required", nil)
 		}
 
-		searchResult, err := SearchSymbol(keyword, logger)
+		searchResult, err := client.SearchSymbol(c.Request().Context(), keyword, logger)
 		if err != nil {
 			return err // AppError already set
 		}
@@ -223,27 +236,7 @@ func SearchStockHandler(logger *zerolog.Logger) echo.HandlerFunc {
 	}
 }
 
-func GetStockQuoteHandler(logger *zerolog.Logger) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		symbol := c.Param("symbol")
-		if symbol == "" {
-			logger.Error().Msg("Missing symbol parameter for stock quote")
-			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Symbol parameter is required", nil)
-		}
-
-		quote, err := FetchQuote(symbol, logger)
-		if err != nil {
-			return err // AppError already set
-		}
-
-		return c.JSON(http.StatusOK, map[string]interface{}{
-			"message": types.StatusOK,
-			"quote":   quote,
-		})
-	}
-}
-
-func GetIntradayDataHandler(logger *zerolog.Logger) echo.HandlerFunc {
+func (client *Client) GetIntradayDataHandler(logger *zerolog.Logger) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		symbol := c.Param("symbol")
 		interval := c.QueryParam("interval")
@@ -252,7 +245,7 @@ func GetIntradayDataHandler(logger *zerolog.Logger) echo.HandlerFunc {
 			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Symbol and interval parameters are required", nil)
 		}
 
-		intraday, err := FetchIntraday(symbol, interval, logger)
+		intraday, err := client.FetchIntraday(c.Request().Context(), symbol, interval, logger)
 		if err != nil {
 			return err // AppError already set
 		}
@@ -279,40 +272,31 @@ type DailyResponse struct {
 		Close  string `json:"4. close"`
 		Volume string `json:"5. volume"`
 	} `json:"Time Series (Daily)"`
+	CachedAt *time.Time `json:"cachedAt,omitempty"`
 }
 
-func GetDailyDataHandler(logger *zerolog.Logger) echo.HandlerFunc {
+func (client *Client) GetDailyDataHandler(logger *zerolog.Logger) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		symbol := c.Param("symbol")
 		if symbol == "" {
 			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "symbol is required", nil)
 		}
 
-		apiKey := os.Getenv("ALPHA_VANTAGE_KEY") // Replace with env variable or config
-		url := fmt.Sprintf("https://www.alphavantage.co/query?function=TIME_SERIES_DAILY&symbol=%s&apikey=%s", symbol, apiKey)
+		url := fmt.Sprintf("%s?function=TIME_SERIES_DAILY&symbol=%s&apikey=%s", client.baseURL, symbol, client.apiKey)
 
-		resp, err := http.Get(url)
+		resp, err := client.get(c.Request().Context(), url)
 		if err != nil {
 			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch daily data from Alpha Vantage")
-			return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to fetch daily data", err)
+			return fetchError(err, "failed to fetch daily data")
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			logger.Error().Str("symbol", symbol).Int("status", resp.StatusCode).Msg("Alpha Vantage API returned non-200 status")
-			return util.NewAppError(http.StatusBadGateway, types.StatusBadGateway, "Alpha Vantage API error", nil)
-		}
-
 		var dailyData DailyResponse
 		if err := json.NewDecoder(resp.Body).Decode(&dailyData); err != nil {
 			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse daily data response")
 			return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to parse daily data", err)
 		}
-
-		if _, ok := dailyData.TimeSeries["Error Message"]; ok {
-			logger.Error().Str("symbol", symbol).Msg("Invalid symbol or API error")
-			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "invalid symbol or API error", nil)
-		}
+		dailyData.CachedAt = CachedAt(resp)
 
 		logger.Info().Str("symbol", symbol).Msg("Successfully fetched daily data")
 		return c.JSON(http.StatusOK, dailyData)
@@ -335,79 +319,136 @@ type DailyMover struct {
 	PercentageChange float64 `json:"percentageChange"`
 }
 
-func FetchDailyMovers(logger *zerolog.Logger) ([]DailyMover, error) {
+// FetchDailyMovers scans popularStocks at refresh priority, two calls a
+// symbol. Once the budget is spent, or Alpha Vantage cannot be reached, the
+// symbols scanned so far are returned.
+func (c *Client) FetchDailyMovers(ctx context.Context, logger *zerolog.Logger) ([]DailyMover, error) {
+	ctx = WithPriority(ctx, PriorityRefresh)
+
 	var movers []DailyMover
 	for _, symbol := range popularStocks {
-		url := fmt.Sprintf("https://www.alphavantage.co/query?function=TIME_SERIES_DAILY&symbol=%s&outputsize=compact&apikey=%s", symbol, os.Getenv("ALPHA_VANTAGE_KEY"))
-		resp, err := http.Get(url)
+		// a cancelled request or a spent deadline stops the whole scan, the
+		// remaining symbols would only fail the same way
+		if err := ctx.Err(); err != nil {
+			return nil, fetchError(err, "failed to fetch market movers")
+		}
 
+		mover, err := c.fetchDailyMover(ctx, symbol)
 		if err != nil {
-			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch daily data")
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fetchError(ctxErr, "failed to fetch market movers")
+			}
+			if errors.Is(err, marketdata.ErrUnavailable) {
+				if len(movers) == 0 {
+					return nil, fetchError(err, "failed to fetch market movers")
+				}
+				logger.Warn().Err(err).Int("scanned", len(movers)).Msg("market data unavailable, returning the movers scanned so far")
+				break
+			}
+			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch daily mover")
 			continue
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			logger.Error().Str("symbol", symbol).Int("status", resp.StatusCode).Msg("Alpha Vantage API error")
+		if mover == nil {
+			logger.Warn().Str("symbol", symbol).Msg("Insufficient daily data")
 			continue
 		}
+		movers = append(movers, *mover)
+	}
 
-		var dailyData DailyResponse
-		if err := json.NewDecoder(resp.Body).Decode(&dailyData); err != nil {
-			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse daily data")
-			continue
-		}
+	// Sort by percentage change (descending for gainers, ascending for losers)
+	sort.Slice(movers, func(i, j int) bool {
+		return movers[i].PercentageChange > movers[j].PercentageChange
+	})
 
-		if len(dailyData.TimeSeries) < 2 {
-			logger.Warn().Str("symbol", symbol).Msg("Insufficient daily data")
-			continue
-		}
+	return movers, nil
+}
 
-		// Get latest and previous day's close prices
-		var latestDate, prevDate string
-		for date := range dailyData.TimeSeries {
-			if latestDate == "" || date > latestDate {
-				prevDate = latestDate
-				latestDate = date
-			} else if prevDate == "" || date > prevDate {
-				prevDate = date
-			}
-		}
+// fetchDailyMover compares the last two daily closes of symbol. It returns
+// nil without an error when there are fewer than two.
+func (c *Client) fetchDailyMover(ctx context.Context, symbol string) (*DailyMover, error) {
+	url := fmt.Sprintf("%s?function=TIME_SERIES_DAILY&symbol=%s&outputsize=compact&apikey=%s", c.baseURL, symbol, c.apiKey)
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var dailyData DailyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dailyData); err != nil {
+		return nil, fmt.Errorf("parse daily data: %w", err)
+	}
 
-		latestClose, _ := strconv.ParseFloat(dailyData.TimeSeries[latestDate].Close, 64)
-		prevClose, _ := strconv.ParseFloat(dailyData.TimeSeries[prevDate].Close, 64)
-		percentageChange := ((latestClose - prevClose) / prevClose) * 100
+	if len(dailyData.TimeSeries) < 2 {
+		return nil, nil
+	}
 
-		// Fetch stock name (using OVERVIEW for simplicity)
-		overviewURL := fmt.Sprintf("https://www.alphavantage.co/query?function=OVERVIEW&symbol=%s&apikey=%s", symbol, os.Getenv("ALPHA_VANTAGE_KEY"))
-		overviewResp, err := http.Get(overviewURL)
-		if err != nil {
-			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch overview")
-			continue
+	// Get latest and previous day's close prices
+	var latestDate, prevDate string
+	for date := range dailyData.TimeSeries {
+		if latestDate == "" || date > latestDate {
+			prevDate = latestDate
+			latestDate = date
+		} else if prevDate == "" || date > prevDate {
+			prevDate = date
 		}
+	}
 
-		var overview struct {
-			Name string `json:"Name"`
-		}
-		if err := json.NewDecoder(overviewResp.Body).Decode(&overview); err != nil {
-			logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse overview")
-			continue
-		}
+	latestClose, _ := strconv.ParseFloat(dailyData.TimeSeries[latestDate].Close, 64)
+	prevClose, _ := strconv.ParseFloat(dailyData.TimeSeries[prevDate].Close, 64)
+	percentageChange := ((latestClose - prevClose) / prevClose) * 100
 
-		movers = append(movers, DailyMover{
-			Symbol:           symbol,
-			Name:             overview.Name,
-			Price:            latestClose,
-			PercentageChange: percentageChange,
-		})
+	// Fetch stock name (using OVERVIEW for simplicity)
+	overviewURL := fmt.Sprintf("%s?function=OVERVIEW&symbol=%s&apikey=%s", c.baseURL, symbol, c.apiKey)
+	overviewResp, err := c.get(ctx, overviewURL)
+	if err != nil {
+		return nil, err
+	}
+	defer overviewResp.Body.Close()
 
-		overviewResp.Body.Close()
-		resp.Body.Close()
+	var overview struct {
+		Name string `json:"Name"`
+	}
+	if err := json.NewDecoder(overviewResp.Body).Decode(&overview); err != nil {
+		return nil, fmt.Errorf("parse overview: %w", err)
 	}
 
-	// Sort by percentage change (descending for gainers, ascending for losers)
-	sort.Slice(movers, func(i, j int) bool {
-		return movers[i].PercentageChange > movers[j].PercentageChange
-	})
+	return &DailyMover{
+		Symbol:           symbol,
+		Name:             overview.Name,
+		Price:            latestClose,
+		PercentageChange: percentageChange,
+	}, nil
+}
 
-	return movers, nil
+// fetchError is the AppError for a call to Alpha Vantage that did not get
+// data back. A spent budget is a 429 with the wait in the message and in
+// Retry-After, and an open circuit a 503. Running out of time is a 504 so a
+// slow provider can be told apart from a broken one, which is a 502. An
+// error message is a 400 when the request was at fault and a 502 when the
+// provider refused it.
+func fetchError(err error, message string) *util.AppError {
+	var quota *QuotaExhaustedError
+	if errors.As(err, &quota) {
+		appError := util.NewAppError(http.StatusTooManyRequests, types.StatusTooManyRequests, message+": "+quota.Error(), err)
+		appError.RetryAfter = quota.RetryAfter
+		return appError
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return util.NewAppError(http.StatusServiceUnavailable, types.StatusServiceUnavailable, message+": market data is temporarily unavailable", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return util.NewAppError(http.StatusGatewayTimeout, types.StatusGatewayTimeout, message, err)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.InvalidRequest() {
+			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, message+": invalid symbol or parameters", err)
+		}
+		return util.NewAppError(http.StatusBadGateway, types.StatusBadGateway, message+": "+apiErr.Message, err)
+	}
+	var unavailable *unavailableError
+	if errors.As(err, &unavailable) {
+		return util.NewAppError(http.StatusBadGateway, types.StatusBadGateway, message, err)
+	}
+	return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, message, err)
 }
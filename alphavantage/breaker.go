@@ -0,0 +1,109 @@
+package alphavantage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/marketdata"
+)
+
+// States of the circuit breaker.
+const (
+	CircuitClosed   = "closed"    // calls go through
+	CircuitOpen     = "open"      // calls fail fast until the cooldown is over
+	CircuitHalfOpen = "half_open" // one call is probing whether the provider is back
+)
+
+// ErrCircuitOpen is returned instead of calling a provider that has failed
+// too often in a row, while no cached answer can stand in.
+var ErrCircuitOpen = fmt.Errorf("alpha vantage circuit is open, calls are paused after repeated failures: %w", marketdata.ErrUnavailable)
+
+// breaker stops calls to a provider after threshold failures in a row. Once
+// cooldown has passed a single call is let through: success closes the
+// circuit, failure opens it for another cooldown.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: max(threshold, 1), cooldown: cooldown, state: CircuitClosed}
+}
+
+// allow reports whether a call may be made now. Every call allowed has to
+// end in success, failure or release.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// success records a call the provider answered.
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// failure records a call the provider did not answer.
+func (b *breaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = now
+	}
+	b.probing = false
+}
+
+// release ends a call that said nothing about the provider, such as one its
+// caller gave up on, so that another can probe.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// current is the state as of now, with when an open circuit lets a probe
+// through.
+func (b *breaker) current(now time.Time) (string, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen {
+		if retryAt := b.openedAt.Add(b.cooldown); now.Before(retryAt) {
+			return CircuitOpen, retryAt
+		}
+		return CircuitHalfOpen, time.Time{}
+	}
+	return b.state, time.Time{}
+}
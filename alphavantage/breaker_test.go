@@ -0,0 +1,166 @@
+package alphavantage
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+)
+
+func TestBreakerStates(t *testing.T) {
+	b := newBreaker(3, time.Minute)
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	state := func(at time.Time, want string) {
+		t.Helper()
+		if got, _ := b.current(at); got != want {
+			t.Fatalf("state at %v = %s, want %s", at.Sub(t0), got, want)
+		}
+	}
+	call := func(at time.Time, want bool) {
+		t.Helper()
+		if got := b.allow(at); got != want {
+			t.Fatalf("allow at %v = %v, want %v", at.Sub(t0), got, want)
+		}
+	}
+
+	// only failures in a row count
+	for _, failed := range []bool{true, true, false, true, true} {
+		call(t0, true)
+		if failed {
+			b.failure(t0)
+		} else {
+			b.success()
+		}
+	}
+	state(t0, CircuitClosed)
+
+	call(t0, true)
+	b.failure(t0)
+	state(t0, CircuitOpen)
+	if _, retryAt := b.current(t0); !retryAt.Equal(t0.Add(time.Minute)) {
+		t.Errorf("retry at %v, want after the cooldown", retryAt.Sub(t0))
+	}
+	call(t0.Add(59*time.Second), false)
+
+	// one probe at a time once the cooldown is over
+	t1 := t0.Add(time.Minute)
+	state(t1, CircuitHalfOpen)
+	call(t1, true)
+	call(t1, false)
+	// a probe that said nothing lets another through
+	b.release()
+	call(t1, true)
+
+	// a failed probe opens the circuit for another cooldown
+	b.failure(t1)
+	state(t1, CircuitOpen)
+	call(t1.Add(59*time.Second), false)
+
+	t2 := t1.Add(time.Minute)
+	call(t2, true)
+	b.success()
+	state(t2, CircuitClosed)
+	call(t2, true)
+	call(t2, true)
+}
+
+func TestBreakerThresholdAtLeastOne(t *testing.T) {
+	b := newBreaker(0, time.Minute)
+	now := time.Now()
+	b.allow(now)
+	b.failure(now)
+	if state, _ := b.current(now); state != CircuitOpen {
+		t.Errorf("state after one failure = %s, want open", state)
+	}
+}
+
+// flakyServer answers GLOBAL_QUOTE for IBM while up, and 500 otherwise.
+type flakyServer struct {
+	up   atomic.Bool
+	hits atomic.Int32
+}
+
+func newFlakyServer(t *testing.T) (*flakyServer, string) {
+	t.Helper()
+	flaky := &flakyServer{}
+	body := fixture(t, "global_quote.json")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flaky.hits.Add(1)
+		if !flaky.up.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return flaky, server.URL
+}
+
+func resilientClient(url string, retries, threshold int, cooldown, fallbackMaxAge time.Duration) *Client {
+	return New(config.MarketDataConfig{
+		BaseURL:          url,
+		APIKey:           "key",
+		Timeout:          config.Duration(time.Second),
+		QueueSize:        1,
+		MaxWait:          config.Duration(time.Second),
+		Retries:          retries,
+		RetryBackoff:     config.Duration(time.Millisecond),
+		RetryMaxBackoff:  config.Duration(2 * time.Millisecond),
+		BreakerThreshold: threshold,
+		BreakerCooldown:  config.Duration(cooldown),
+		FallbackMaxAge:   config.Duration(fallbackMaxAge),
+	})
+}
+
+func TestClientCircuit(t *testing.T) {
+	server, url := newFlakyServer(t)
+	cooldown := 100 * time.Millisecond
+	client := resilientClient(url, 0, 2, cooldown, 0)
+
+	steps := []struct {
+		name      string
+		up        bool
+		wait      time.Duration
+		wantErr   error // nil for a quote
+		wantHits  int32
+		wantState string
+	}{
+		{"first failure", false, 0, marketdata.ErrUnavailable, 1, CircuitClosed},
+		{"threshold reached", false, 0, marketdata.ErrUnavailable, 2, CircuitOpen},
+		{"failing fast", true, 0, ErrCircuitOpen, 2, CircuitOpen},
+		{"probe fails", false, cooldown, marketdata.ErrUnavailable, 3, CircuitOpen},
+		{"still failing fast", true, 0, ErrCircuitOpen, 3, CircuitOpen},
+		{"probe succeeds", true, cooldown, nil, 4, CircuitClosed},
+		{"closed again", true, 0, nil, 5, CircuitClosed},
+	}
+	for _, step := range steps {
+		time.Sleep(step.wait)
+		server.up.Store(step.up)
+		if step.wait > 0 && client.CircuitState() != CircuitHalfOpen {
+			t.Errorf("%s: state after the cooldown = %s, want half_open", step.name, client.CircuitState())
+		}
+
+		_, err := client.Quote(t.Context(), "IBM")
+		switch {
+		case step.wantErr == nil && err != nil:
+			t.Errorf("%s: %v", step.name, err)
+		case step.wantErr != nil && !errors.Is(err, step.wantErr):
+			t.Errorf("%s: err = %v, want %v", step.name, err, step.wantErr)
+		case step.wantErr != ErrCircuitOpen && errors.Is(err, ErrCircuitOpen):
+			t.Errorf("%s: failed fast, want the call made", step.name)
+		}
+		if hits := server.hits.Load(); hits != step.wantHits {
+			t.Errorf("%s: %d calls reached the server, want %d", step.name, hits, step.wantHits)
+		}
+		if state := client.CircuitState(); state != step.wantState {
+			t.Errorf("%s: state = %s, want %s", step.name, state, step.wantState)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package alphavantage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/marketdata"
+)
+
+// Priority orders calls waiting for budget: every interactive call waiting
+// goes before any refresh, and refreshes before backfill.
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota // a user is waiting on the answer
+	PriorityRefresh                     // keeping cached values current
+	PriorityBackfill                    // history nobody is waiting on
+	priorities
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityRefresh:
+		return "refresh"
+	case PriorityBackfill:
+		return "backfill"
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+type priorityCtxKey struct{}
+
+// WithPriority marks the calls made with ctx. Calls default to
+// PriorityInteractive.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityCtxKey{}, priority)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityCtxKey{}).(Priority); ok && priority >= 0 && priority < priorities {
+		return priority
+	}
+	return PriorityInteractive
+}
+
+// Scopes of a QuotaExhaustedError.
+const (
+	QuotaMinute   = "minute"   // the per minute budget, or the wait for it, ran out
+	QuotaDay      = "day"      // the per day budget is spent
+	QuotaQueue    = "queue"    // too many calls are already waiting
+	QuotaUpstream = "upstream" // Alpha Vantage itself answered with a rate limit message
+)
+
+// QuotaExhaustedError is returned instead of making a call the budget has no
+// room for. RetryAfter is when trying again may work.
+type QuotaExhaustedError struct {
+	Scope      string
+	RetryAfter time.Duration
+	Err        error // the caller's ctx error when it gave up waiting, or the provider's *APIError
+}
+
+func (e *QuotaExhaustedError) Error() string {
+	return fmt.Sprintf("alpha vantage %s quota exhausted, retry in %s", e.Scope, e.RetryAfter.Round(time.Second))
+}
+
+func (e *QuotaExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *QuotaExhaustedError) Is(target error) bool {
+	return target == marketdata.ErrUnavailable
+}
+
+type waiter struct {
+	priority Priority
+	ready    chan struct{}
+	err      error // set before ready is closed when the call may not go ahead
+}
+
+// scheduler hands out the calls the per minute and per day budgets allow.
+// Calls that have to wait queue by priority, first come first served
+// within one.
+type scheduler struct {
+	perMinute int // 0 is no limit
+	perDay    int // 0 is no limit
+	queueSize int
+	maxWait   time.Duration
+
+	mu          sync.Mutex
+	recent      []time.Time // calls let through in the last minute
+	day         string
+	today       int
+	pausedUntil time.Time
+	queues      [priorities][]*waiter
+	timer       *time.Timer
+	now         func() time.Time
+}
+
+func newScheduler(perMinute, perDay, queueSize int, maxWait time.Duration) *scheduler {
+	return &scheduler{perMinute: perMinute, perDay: perDay, queueSize: queueSize, maxWait: maxWait, now: time.Now}
+}
+
+// acquire returns once a call may be made, or with a *QuotaExhaustedError
+// when the budget will not have room before ctx is done. A ctx without a
+// deadline waits at most maxWait.
+func (s *scheduler) acquire(ctx context.Context) error {
+	priority := priorityFrom(ctx)
+	if _, ok := ctx.Deadline(); !ok && s.maxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.maxWait)
+		defer cancel()
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	s.rollDay(now)
+
+	if s.perDay > 0 && s.today >= s.perDay {
+		s.mu.Unlock()
+		return &QuotaExhaustedError{Scope: QuotaDay, RetryAfter: untilTomorrow(now)}
+	}
+	if !s.queuedAhead(priority) && s.minuteFree(now) {
+		s.take(now)
+		s.mu.Unlock()
+		return nil
+	}
+	if s.queued() >= s.queueSize {
+		s.mu.Unlock()
+		return &QuotaExhaustedError{Scope: QuotaQueue, RetryAfter: s.nextFree(now).Sub(now)}
+	}
+	// no point queueing for a slot that frees up after the caller gives up
+	if deadline, ok := ctx.Deadline(); ok && s.nextFree(now).After(deadline) {
+		s.mu.Unlock()
+		return &QuotaExhaustedError{Scope: s.waitScope(now), RetryAfter: s.nextFree(now).Sub(now)}
+	}
+
+	w := &waiter{priority: priority, ready: make(chan struct{})}
+	s.queues[priority] = append(s.queues[priority], w)
+	s.arm(now)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return w.err
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// handed a slot while giving up, it has been counted so use it
+			return w.err
+		default:
+		}
+		s.remove(w)
+		now := s.now()
+		return &QuotaExhaustedError{Scope: s.waitScope(now), RetryAfter: s.nextFree(now).Sub(now), Err: ctx.Err()}
+	}
+}
+
+// throttled pauses every call for pause after Alpha Vantage answered with a
+// rate limit message, the provider's count is what matters.
+func (s *scheduler) throttled(now time.Time, pause time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pausedUntil = now.Add(pause)
+	s.arm(now)
+}
+
+// depth is the number of calls waiting for budget.
+func (s *scheduler) depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queued()
+}
+
+// remainingToday is what is left of the per day budget, -1 without one.
+func (s *scheduler) remainingToday() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.perDay == 0 {
+		return -1
+	}
+	s.rollDay(s.now())
+	return max(0, s.perDay-s.today)
+}
+
+// dispatch lets waiting calls through while the budget allows and arms the
+// timer for the next slot, s.mu must be held.
+func (s *scheduler) dispatch() {
+	now := s.now()
+	s.rollDay(now)
+
+	for s.queued() > 0 {
+		if s.perDay > 0 && s.today >= s.perDay {
+			err := &QuotaExhaustedError{Scope: QuotaDay, RetryAfter: untilTomorrow(now)}
+			for p := range s.queues {
+				for _, w := range s.queues[p] {
+					w.err = err
+					close(w.ready)
+				}
+				s.queues[p] = nil
+			}
+			return
+		}
+		if !s.minuteFree(now) {
+			s.arm(now)
+			return
+		}
+		w := s.pop()
+		s.take(now)
+		close(w.ready)
+	}
+}
+
+// arm schedules dispatch for when the next slot frees up, s.mu must be held.
+func (s *scheduler) arm(now time.Time) {
+	if s.queued() == 0 {
+		return
+	}
+	wait := s.nextFree(now).Sub(now)
+	if s.timer == nil {
+		s.timer = time.AfterFunc(wait, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.dispatch()
+		})
+		return
+	}
+	s.timer.Reset(wait)
+}
+
+func (s *scheduler) minuteFree(now time.Time) bool {
+	if now.Before(s.pausedUntil) {
+		return false
+	}
+	if s.perMinute == 0 {
+		return true
+	}
+	s.recent = trimMinute(s.recent, now)
+	return len(s.recent) < s.perMinute
+}
+
+// waitScope is what a call that cannot wait long enough ran out of: the
+// pause Alpha Vantage asked for, or the per minute budget.
+func (s *scheduler) waitScope(now time.Time) string {
+	if now.Before(s.pausedUntil) {
+		return QuotaUpstream
+	}
+	return QuotaMinute
+}
+
+// nextFree is when the per minute budget next has room.
+func (s *scheduler) nextFree(now time.Time) time.Time {
+	at := now
+	if s.perMinute > 0 {
+		s.recent = trimMinute(s.recent, now)
+		if len(s.recent) >= s.perMinute {
+			at = s.recent[len(s.recent)-s.perMinute].Add(time.Minute)
+		}
+	}
+	if s.pausedUntil.After(at) {
+		at = s.pausedUntil
+	}
+	return at
+}
+
+func (s *scheduler) take(now time.Time) {
+	s.today++
+	if s.perMinute > 0 {
+		s.recent = append(s.recent, now)
+	}
+}
+
+func (s *scheduler) rollDay(now time.Time) {
+	if day := now.UTC().Format(time.DateOnly); day != s.day {
+		s.day = day
+		s.today = 0
+	}
+}
+
+func (s *scheduler) queuedAhead(priority Priority) bool {
+	for p := PriorityInteractive; p <= priority; p++ {
+		if len(s.queues[p]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scheduler) queued() int {
+	total := 0
+	for _, queue := range s.queues {
+		total += len(queue)
+	}
+	return total
+}
+
+func (s *scheduler) pop() *waiter {
+	for p := range s.queues {
+		if len(s.queues[p]) > 0 {
+			w := s.queues[p][0]
+			s.queues[p] = s.queues[p][1:]
+			return w
+		}
+	}
+	return nil
+}
+
+func (s *scheduler) remove(w *waiter) {
+	queue := s.queues[w.priority]
+	for i, queued := range queue {
+		if queued == w {
+			s.queues[w.priority] = append(queue[:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func trimMinute(times []time.Time, now time.Time) []time.Time {
+	keep := times[:0]
+	for _, t := range times {
+		if now.Sub(t) < time.Minute {
+			keep = append(keep, t)
+		}
+	}
+	return keep
+}
+
+func untilTomorrow(now time.Time) time.Duration {
+	utc := now.UTC()
+	return time.Date(utc.Year(), utc.Month(), utc.Day()+1, 0, 0, 0, 0, time.UTC).Sub(utc)
+}
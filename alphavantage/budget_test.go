@@ -0,0 +1,282 @@
+package alphavantage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/marketdata"
+)
+
+// fakeClock is what a scheduler under test takes as the time. The timer it
+// arms still runs on the real clock, the tests dispatch by hand instead.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func testScheduler(t *testing.T, perMinute, perDay, queueSize int, maxWait time.Duration, start time.Time) (*scheduler, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: start}
+	s := newScheduler(perMinute, perDay, queueSize, maxWait)
+	s.now = clock.Now
+	t.Cleanup(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+	})
+	return s, clock
+}
+
+// release lets through what the budget has room for at the clock's time.
+func release(s *scheduler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dispatch()
+}
+
+type queued struct {
+	name string
+	err  error
+}
+
+// enqueue calls acquire in the background and returns once the call waits
+// in the queue. Its outcome is sent on done under name.
+func enqueue(t *testing.T, s *scheduler, ctx context.Context, name string, done chan<- queued) {
+	t.Helper()
+	before := s.depth()
+	go func() {
+		err := s.acquire(ctx)
+		done <- queued{name, err}
+	}()
+	for deadline := time.Now().Add(time.Second); s.depth() == before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never queued", name)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func next(t *testing.T, done <-chan queued) queued {
+	t.Helper()
+	select {
+	case got := <-done:
+		return got
+	case <-time.After(time.Second):
+		t.Fatal("no queued call was let through")
+		return queued{}
+	}
+}
+
+func quotaError(t *testing.T, err error, scope string, retryAfter time.Duration) *QuotaExhaustedError {
+	t.Helper()
+	var quota *QuotaExhaustedError
+	if !errors.As(err, &quota) {
+		t.Fatalf("err = %v, want a QuotaExhaustedError", err)
+	}
+	if quota.Scope != scope || quota.RetryAfter != retryAfter {
+		t.Errorf("quota %s retry in %v, want %s retry in %v", quota.Scope, quota.RetryAfter, scope, retryAfter)
+	}
+	if !errors.Is(err, marketdata.ErrUnavailable) {
+		t.Errorf("err = %v does not match marketdata.ErrUnavailable", err)
+	}
+	return quota
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	s, clock := testScheduler(t, 1, 0, 10, 0, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	if err := s.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan queued, 5)
+	enqueue(t, s, WithPriority(ctx, PriorityBackfill), "backfill 1", done)
+	enqueue(t, s, WithPriority(ctx, PriorityRefresh), "refresh", done)
+	enqueue(t, s, ctx, "interactive 1", done)
+	enqueue(t, s, WithPriority(ctx, PriorityBackfill), "backfill 2", done)
+	enqueue(t, s, WithPriority(ctx, PriorityInteractive), "interactive 2", done)
+
+	// one call a minute, each to the most urgent that waited longest
+	for _, want := range []string{"interactive 1", "interactive 2", "refresh", "backfill 1", "backfill 2"} {
+		clock.Advance(time.Minute)
+		release(s)
+		if got := next(t, done); got.name != want || got.err != nil {
+			t.Errorf("let through %s (err %v), want %s", got.name, got.err, want)
+		}
+	}
+	if depth := s.depth(); depth != 0 {
+		t.Errorf("%d calls still queued", depth)
+	}
+}
+
+func TestSchedulerPerMinute(t *testing.T) {
+	// the wait for a slot is held against real deadlines, start from now
+	s, clock := testScheduler(t, 2, 0, 10, 0, time.Now())
+	short := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		t.Cleanup(cancel)
+		return ctx
+	}
+
+	steps := []struct {
+		advance   time.Duration
+		wantRetry time.Duration // zero when the call goes through
+	}{
+		{0, 0},
+		{20 * time.Second, 0},
+		{10 * time.Second, 30 * time.Second},
+		// the first call has left the minute
+		{30 * time.Second, 0},
+		{0, 20 * time.Second},
+		{20 * time.Second, 0},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		err := s.acquire(short())
+		if step.wantRetry == 0 {
+			if err != nil {
+				t.Errorf("call %d: %v", i+1, err)
+			}
+			continue
+		}
+		quotaError(t, err, QuotaMinute, step.wantRetry)
+	}
+}
+
+func TestSchedulerPerDay(t *testing.T) {
+	s, clock := testScheduler(t, 0, 3, 10, 0, time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := s.acquire(ctx); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	if left := s.remainingToday(); left != 0 {
+		t.Errorf("remainingToday = %d, want 0", left)
+	}
+	quotaError(t, s.acquire(ctx), QuotaDay, time.Hour)
+
+	// a new UTC day, a new budget
+	clock.Advance(time.Hour)
+	if err := s.acquire(ctx); err != nil {
+		t.Fatalf("call on the next day: %v", err)
+	}
+	if left := s.remainingToday(); left != 2 {
+		t.Errorf("remainingToday = %d, want 2", left)
+	}
+
+	if left := newScheduler(1, 0, 1, 0).remainingToday(); left != -1 {
+		t.Errorf("remainingToday without a daily budget = %d, want -1", left)
+	}
+}
+
+func TestSchedulerDayRunsOutWhileQueued(t *testing.T) {
+	s, clock := testScheduler(t, 1, 2, 10, 0, time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	if err := s.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan queued, 2)
+	enqueue(t, s, ctx, "first", done)
+	enqueue(t, s, ctx, "second", done)
+
+	clock.Advance(time.Minute)
+	release(s)
+	// both are answered by the one dispatch, in no telling order
+	got := make(map[string]error)
+	for i := 0; i < 2; i++ {
+		call := next(t, done)
+		got[call.name] = call.err
+	}
+	if err := got["first"]; err != nil {
+		t.Errorf("first: %v", err)
+	}
+	quotaError(t, got["second"], QuotaDay, 59*time.Minute)
+}
+
+func TestSchedulerCancelledWhileQueued(t *testing.T) {
+	s, clock := testScheduler(t, 1, 0, 10, 0, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	if err := s.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan queued, 1)
+	enqueue(t, s, ctx, "cancelled", done)
+	clock.Advance(15 * time.Second)
+	cancel()
+
+	got := next(t, done)
+	quotaError(t, got.err, QuotaMinute, 45*time.Second)
+	if !errors.Is(got.err, context.Canceled) {
+		t.Errorf("err = %v, want the caller's context.Canceled", got.err)
+	}
+	if depth := s.depth(); depth != 0 {
+		t.Errorf("the cancelled call is still queued, depth %d", depth)
+	}
+
+	// the slot it waited for goes to the next caller
+	clock.Advance(45 * time.Second)
+	if err := s.acquire(context.Background()); err != nil {
+		t.Errorf("call after the cancelled one: %v", err)
+	}
+}
+
+func TestSchedulerRefusesToQueue(t *testing.T) {
+	s, clock := testScheduler(t, 1, 0, 1, 50*time.Millisecond, time.Now())
+	ctx := context.Background()
+	if err := s.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// without a deadline a call waits maxWait at most, the slot is a minute off
+	quotaError(t, s.acquire(ctx), QuotaMinute, time.Minute)
+
+	long, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	done := make(chan queued, 1)
+	enqueue(t, s, long, "waiting", done)
+	quotaError(t, s.acquire(long), QuotaQueue, time.Minute)
+
+	clock.Advance(time.Minute)
+	release(s)
+	if got := next(t, done); got.err != nil {
+		t.Errorf("queued call: %v", got.err)
+	}
+}
+
+func TestSchedulerThrottled(t *testing.T) {
+	s, _ := testScheduler(t, 0, 0, 10, time.Second, time.Now())
+	s.throttled(s.now(), 30*time.Second)
+	quotaError(t, s.acquire(context.Background()), QuotaUpstream, 30*time.Second)
+
+	// on the real clock the armed timer lets a queued call through
+	live := newScheduler(0, 0, 10, time.Second)
+	live.throttled(time.Now(), 20*time.Millisecond)
+	start := time.Now()
+	if err := live.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 15*time.Millisecond {
+		t.Errorf("let through after %v, before the pause was over", waited)
+	}
+}
@@ -0,0 +1,114 @@
+package alphavantage
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxCached bounds the responses kept for fallback, the oldest go first.
+const maxCached = 1000
+
+type cachedResponse struct {
+	body     []byte
+	storedAt time.Time
+}
+
+// responseCache keeps the last good answer to every request, keyed without
+// the API key, to stand in while the provider cannot be reached.
+type responseCache struct {
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+func newResponseCache(maxAge time.Duration) *responseCache {
+	return &responseCache{maxAge: maxAge, entries: make(map[string]cachedResponse)}
+}
+
+func (c *responseCache) put(key string, body []byte, now time.Time) {
+	if c.maxAge <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCached {
+		c.evictOldest()
+	}
+	c.entries[key] = cachedResponse{body: body, storedAt: now}
+}
+
+// response rebuilds the cached answer to key as a 200 carrying the Date,
+// Age and stale Warning headers an HTTP cache would serve it with.
+func (c *responseCache) response(key string, now time.Time) (*http.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	age := now.Sub(entry.storedAt)
+	if age > c.maxAge {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	header := http.Header{}
+	header.Set("Date", entry.storedAt.UTC().Format(http.TimeFormat))
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	header.Set("Warning", staleWarning)
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(entry.body)),
+	}, true
+}
+
+const staleWarning = `110 - "Response is Stale"`
+
+// evictOldest drops the entry stored first, c.mu must be held.
+func (c *responseCache) evictOldest() {
+	var oldest string
+	var at time.Time
+	for key, entry := range c.entries {
+		if oldest == "" || entry.storedAt.Before(at) {
+			oldest, at = key, entry.storedAt
+		}
+	}
+	delete(c.entries, oldest)
+}
+
+// cacheKey is the request url without the API key, so rotating the key
+// keeps what was cached.
+func cacheKey(url string) string {
+	parsed, err := neturl.Parse(url)
+	if err != nil {
+		return url
+	}
+	query := parsed.Query()
+	query.Del("apikey")
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// CachedAt is when a response served from cache was first received, nil
+// for one fresh from the provider.
+func CachedAt(resp *http.Response) *time.Time {
+	if resp.Header.Get("Warning") != staleWarning {
+		return nil
+	}
+	at, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return nil
+	}
+	return &at
+}
@@ -0,0 +1,145 @@
+package alphavantage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/marketdata"
+)
+
+func TestResponseCache(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := newResponseCache(time.Hour)
+	cache.put("quote", []byte(`{"price":1}`), t0)
+
+	resp, ok := cache.response("quote", t0.Add(90*time.Second))
+	if !ok {
+		t.Fatal("nothing cached")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != `{"price":1}` {
+		t.Errorf("response = %d %s, want the stored 200", resp.StatusCode, body)
+	}
+	if age := resp.Header.Get("Age"); age != "90" {
+		t.Errorf("Age = %q, want 90", age)
+	}
+	if at := CachedAt(resp); at == nil || !at.Equal(t0) {
+		t.Errorf("CachedAt = %v, want %v", at, t0)
+	}
+
+	if _, ok := cache.response("other", t0); ok {
+		t.Error("answered a request never cached")
+	}
+	if _, ok := cache.response("quote", t0.Add(time.Hour+time.Second)); ok {
+		t.Error("answered past maxAge")
+	}
+	if _, ok := cache.response("quote", t0); ok {
+		t.Error("an expired answer was kept")
+	}
+
+	off := newResponseCache(0)
+	off.put("quote", []byte(`{}`), t0)
+	if _, ok := off.response("quote", t0); ok {
+		t.Error("stored with the fallback turned off")
+	}
+}
+
+func TestResponseCacheEvictsOldest(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := newResponseCache(time.Hour)
+	for i := 0; i < maxCached; i++ {
+		cache.put(fmt.Sprintf("request %d", i), nil, t0.Add(time.Duration(i)*time.Millisecond))
+	}
+	cache.put("one more", nil, t0.Add(time.Minute))
+
+	if len(cache.entries) != maxCached {
+		t.Errorf("%d entries, want %d", len(cache.entries), maxCached)
+	}
+	if _, ok := cache.response("request 0", t0.Add(time.Minute)); ok {
+		t.Error("the oldest entry was kept")
+	}
+	if _, ok := cache.response("one more", t0.Add(time.Minute)); !ok {
+		t.Error("the newest entry was dropped")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	a := cacheKey("https://example.com/query?function=GLOBAL_QUOTE&symbol=IBM&apikey=old")
+	b := cacheKey("https://example.com/query?apikey=new&symbol=IBM&function=GLOBAL_QUOTE")
+	if a != b {
+		t.Errorf("keys differ by the api key: %s and %s", a, b)
+	}
+	if other := cacheKey("https://example.com/query?function=GLOBAL_QUOTE&symbol=MSFT&apikey=old"); other == a {
+		t.Errorf("another symbol has the same key %s", other)
+	}
+	if CachedAt(&http.Response{Header: http.Header{}}) != nil {
+		t.Error("CachedAt set on a fresh response")
+	}
+}
+
+func TestClientFallback(t *testing.T) {
+	server, url := newFlakyServer(t)
+	client := resilientClient(url, 2, 10, time.Minute, time.Hour)
+
+	server.up.Store(true)
+	fresh, err := client.Quote(t.Context(), "IBM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.CachedAt != nil {
+		t.Errorf("a fresh quote has CachedAt %v", fresh.CachedAt)
+	}
+
+	// the retries run out, then the last good answer stands in
+	server.up.Store(false)
+	stale, err := client.Quote(t.Context(), "IBM")
+	if err != nil {
+		t.Fatalf("no fallback: %v", err)
+	}
+	if stale.CachedAt == nil || !stale.Price.Equal(fresh.Price) {
+		t.Errorf("fallback quote %+v, want the cached %+v with CachedAt", stale, fresh)
+	}
+	if hits := server.hits.Load(); hits != 1+3 {
+		t.Errorf("%d calls reached the server, want the first and three attempts", hits)
+	}
+
+	if _, err := client.Quote(marketdata.WithoutFallback(t.Context()), "IBM"); !errors.Is(err, marketdata.ErrUnavailable) {
+		t.Errorf("without fallback err = %v, want ErrUnavailable", err)
+	}
+	if _, err := client.Quote(t.Context(), "MSFT"); !errors.Is(err, marketdata.ErrUnavailable) {
+		t.Errorf("nothing cached err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestClientFallbackWhileOpen(t *testing.T) {
+	server, url := newFlakyServer(t)
+	client := resilientClient(url, 0, 1, time.Minute, time.Hour)
+
+	server.up.Store(true)
+	if _, err := client.Quote(t.Context(), "IBM"); err != nil {
+		t.Fatal(err)
+	}
+	server.up.Store(false)
+	if _, err := client.Quote(t.Context(), "IBM"); err != nil {
+		t.Fatalf("no fallback: %v", err)
+	}
+	if state := client.CircuitState(); state != CircuitOpen {
+		t.Fatalf("state = %s, want open", state)
+	}
+
+	// the open circuit answers from cache without calling
+	quote, err := client.Quote(t.Context(), "IBM")
+	if err != nil || quote.CachedAt == nil {
+		t.Errorf("quote while open = %+v, %v, want the cached one", quote, err)
+	}
+	if hits := server.hits.Load(); hits != 2 {
+		t.Errorf("%d calls reached the server, want 2", hits)
+	}
+	if _, err := client.Quote(marketdata.WithoutFallback(t.Context()), "IBM"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("without fallback err = %v, want ErrCircuitOpen", err)
+	}
+}
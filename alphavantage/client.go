@@ -0,0 +1,311 @@
+package alphavantage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/metrics"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const upstreamName = "alphavantage"
+
+var (
+	functionKey = attribute.Key("alphavantage.function")
+	outcomeKey  = attribute.Key("alphavantage.outcome")
+	priorityKey = attribute.Key("alphavantage.priority")
+	attemptsKey = attribute.Key("alphavantage.attempts")
+	fallbackKey = attribute.Key("alphavantage.fallback")
+)
+
+// APIError is a message Alpha Vantage answered with in place of data, under
+// Key: "Error Message" for a request it cannot serve such as an unknown
+// symbol, "Information" for one it refuses such as a premium endpoint.
+type APIError struct {
+	Key     string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return "alpha vantage: " + e.Message
+}
+
+// InvalidRequest reports whether the request itself was at fault.
+func (e *APIError) InvalidRequest() bool {
+	return e.Key == "Error Message"
+}
+
+// Is matches marketdata.ErrUnknownSymbol when the request was at fault,
+// which for a quote is the symbol.
+func (e *APIError) Is(target error) bool {
+	return target == marketdata.ErrUnknownSymbol && e.InvalidRequest()
+}
+
+// unavailableError is a call that got no usable answer: none at all, one
+// cut short or a status other than 200.
+type unavailableError struct {
+	status int
+	err    error
+}
+
+func (e *unavailableError) Error() string {
+	if e.status != 0 {
+		return fmt.Sprintf("alpha vantage returned status %d", e.status)
+	}
+	return e.err.Error()
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+func (e *unavailableError) Is(target error) bool {
+	return target == marketdata.ErrUnavailable
+}
+
+// retryable reports whether trying again may get an answer: the connection
+// failed, the call timed out or the provider was overloaded.
+func (e *unavailableError) retryable() bool {
+	return e.status == 0 || e.status == http.StatusRequestTimeout ||
+		e.status == http.StatusTooManyRequests || e.status >= http.StatusInternalServerError
+}
+
+// get sends a GET for url and hands back the response with its body read
+// into memory. Only a 200 carrying data is returned: a spent budget or a
+// throttle fails with a *QuotaExhaustedError and an error message with an
+// *APIError. Calls that get no answer are retried with jittered exponential
+// backoff, every attempt waiting for budget like any other call, and count
+// towards opening the circuit. Once retries run out, or while the circuit is
+// open, the last good answer to the same request stands in when one is
+// cached and marketdata.FallbackAllowed(ctx); CachedAt tells such a
+// response apart.
+func (c *Client) get(ctx context.Context, url string) (resp *http.Response, err error) {
+	function := functionOf(url)
+
+	// the url is left off the span, it carries the key
+	ctx, span := tracing.Tracer().Start(ctx, upstreamName+" "+function,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(http.MethodGet),
+			functionKey.String(function),
+			priorityKey.String(priorityFrom(ctx).String()),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	var body []byte
+	attempts := 0
+	for {
+		if !c.circuit.allow(time.Now()) {
+			err = ErrCircuitOpen
+			break
+		}
+		attempts++
+		resp, body, err = c.attempt(ctx, span, url, function)
+
+		var unavailable *unavailableError
+		var apiErr *APIError
+		switch {
+		case err == nil, errors.As(err, &apiErr):
+			c.circuit.success()
+		case errors.As(err, &unavailable) && ctx.Err() == nil:
+			c.circuit.failure(time.Now())
+		default:
+			// a spent budget or a caller giving up says nothing about the
+			// provider
+			c.circuit.release()
+		}
+
+		if err == nil || unavailable == nil || !unavailable.retryable() || ctx.Err() != nil || attempts > c.retries {
+			break
+		}
+		metrics.UpstreamRetried(upstreamName, function)
+		if !sleep(ctx, c.backoff(attempts)) {
+			break
+		}
+	}
+	span.SetAttributes(attemptsKey.Int(attempts))
+
+	if err == nil {
+		c.responses.put(cacheKey(url), body, time.Now())
+		return resp, nil
+	}
+
+	var unavailable *unavailableError
+	if (errors.Is(err, ErrCircuitOpen) || errors.As(err, &unavailable)) && ctx.Err() == nil && marketdata.FallbackAllowed(ctx) {
+		if cached, ok := c.responses.response(cacheKey(url), time.Now()); ok {
+			metrics.UpstreamFallback(upstreamName, function)
+			span.SetAttributes(fallbackKey.Bool(true))
+			span.RecordError(err)
+			return cached, nil
+		}
+	}
+	return nil, err
+}
+
+// attempt makes one call: it waits for the call budget, then sends the GET
+// bound to ctx and cut off after the configured timeout, whichever comes
+// first. The body is read here, so that throttles and error messages can
+// be told apart from data.
+func (c *Client) attempt(ctx context.Context, span trace.Span, url, function string) (*http.Response, []byte, error) {
+	// the wait for budget is not part of the call's own timeout
+	if err := c.budget.acquire(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	started := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	span.SetAttributes(semconv.ServerAddress(req.URL.Hostname()))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// the url carries the key, keep it out of logs and the status page
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) && c.apiKey != "" {
+			urlErr.URL = strings.ReplaceAll(urlErr.URL, c.apiKey, "****")
+		}
+		c.failed(function, started, err)
+		return nil, nil, &unavailableError{err: err}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		c.failed(function, started, err)
+		return nil, nil, &unavailableError{err: fmt.Errorf("read alpha vantage response: %w", err)}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	outcome, callErr := classify(resp.StatusCode, body)
+	now := time.Now()
+	if outcome == metrics.OutcomeError {
+		// the provider answered, a bad symbol says nothing about reaching it
+		c.usage.record(now, nil)
+	} else {
+		c.usage.record(now, callErr)
+	}
+	metrics.ObserveUpstream(upstreamName, function, outcome, time.Since(started))
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode), outcomeKey.String(outcome))
+
+	if outcome == metrics.OutcomeThrottled {
+		pause := time.Minute
+		if throttle := callErr.(*APIError); throttle.Key == "Information" {
+			// the per day limit, nothing gets through before midnight UTC
+			pause = untilTomorrow(now)
+		}
+		c.budget.throttled(now, pause)
+		return nil, nil, &QuotaExhaustedError{Scope: QuotaUpstream, RetryAfter: pause, Err: callErr}
+	}
+	if callErr != nil {
+		return nil, nil, callErr
+	}
+	return resp, body, nil
+}
+
+// failed records a call that got no usable response. The caller hanging up
+// says nothing about the provider, so that is not counted.
+func (c *Client) failed(function string, started time.Time, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	c.usage.record(time.Now(), err)
+	metrics.ObserveUpstream(upstreamName, function, metrics.OutcomeHTTPFailure, time.Since(started))
+}
+
+// classify sorts a response into a metrics outcome. Alpha Vantage answers
+// throttles and errors with a 200 and a body holding only a "Note",
+// "Information" or "Error Message" key. A "Note" is always the per minute
+// limit; the per day limit comes as "Information", told apart from other
+// information by its wording.
+func classify(status int, body []byte) (string, error) {
+	if status != http.StatusOK {
+		return metrics.OutcomeHTTPFailure, &unavailableError{status: status}
+	}
+
+	var notes struct {
+		Note         string `json:"Note"`
+		Information  string `json:"Information"`
+		ErrorMessage string `json:"Error Message"`
+	}
+	if err := json.Unmarshal(body, &notes); err != nil {
+		// not an object, the caller reports the parse error
+		return metrics.OutcomeOK, nil
+	}
+
+	switch {
+	case notes.Note != "":
+		return metrics.OutcomeThrottled, &APIError{Key: "Note", Message: notes.Note}
+	case notes.Information != "" && isRateLimit(notes.Information):
+		return metrics.OutcomeThrottled, &APIError{Key: "Information", Message: notes.Information}
+	case notes.Information != "":
+		return metrics.OutcomeError, &APIError{Key: "Information", Message: notes.Information}
+	case notes.ErrorMessage != "":
+		return metrics.OutcomeError, &APIError{Key: "Error Message", Message: notes.ErrorMessage}
+	}
+	return metrics.OutcomeOK, nil
+}
+
+func isRateLimit(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "rate limit") || strings.Contains(message, "call frequency")
+}
+
+// backoff is how long to wait before the given retry: doubling from
+// retryBackoff up to retryMaxBackoff, with the upper half jittered so that
+// callers failing together do not retry together.
+func (c *Client) backoff(retry int) time.Duration {
+	wait := c.retryMaxBackoff
+	if shift := retry - 1; shift < 32 {
+		wait = min(c.retryBackoff<<shift, c.retryMaxBackoff)
+	}
+	half := wait / 2
+	return half + rand.N(half+1)
+}
+
+// sleep waits for d, or returns false when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// functionOf is the Alpha Vantage function a request url calls, used as a
+// metrics label.
+func functionOf(url string) string {
+	parsed, err := neturl.Parse(url)
+	if err != nil {
+		return "unknown"
+	}
+	if function := parsed.Query().Get("function"); function != "" {
+		return function
+	}
+	return "unknown"
+}
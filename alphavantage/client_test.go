@@ -0,0 +1,101 @@
+package alphavantage
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/metrics"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		fixture string
+		body    string
+		outcome string
+		key     string // of the *APIError, "" for none
+		wantErr error
+	}{
+		{name: "quote", status: http.StatusOK, fixture: "global_quote.json", outcome: metrics.OutcomeOK},
+		{name: "per minute limit", status: http.StatusOK, fixture: "note.json", outcome: metrics.OutcomeThrottled, key: "Note"},
+		{name: "per day limit", status: http.StatusOK, fixture: "information_daily_limit.json", outcome: metrics.OutcomeThrottled, key: "Information"},
+		{name: "premium endpoint", status: http.StatusOK, fixture: "information_premium.json", outcome: metrics.OutcomeError, key: "Information"},
+		{name: "error message", status: http.StatusOK, fixture: "error_message.json", outcome: metrics.OutcomeError, key: "Error Message", wantErr: marketdata.ErrUnknownSymbol},
+		{name: "not an object", status: http.StatusOK, body: "[]", outcome: metrics.OutcomeOK},
+		{name: "server error", status: http.StatusBadGateway, body: "bad gateway", outcome: metrics.OutcomeHTTPFailure, wantErr: marketdata.ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(tt.body)
+			if tt.fixture != "" {
+				body = fixture(t, tt.fixture)
+			}
+
+			outcome, err := classify(tt.status, body)
+			if outcome != tt.outcome {
+				t.Errorf("outcome = %q, want %q", outcome, tt.outcome)
+			}
+			var apiErr *APIError
+			switch {
+			case tt.key != "":
+				if !errors.As(err, &apiErr) || apiErr.Key != tt.key {
+					t.Errorf("err = %v, want an APIError under %q", err, tt.key)
+				}
+			case tt.wantErr == nil && err != nil:
+				t.Errorf("err = %v, want none", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want one matching %v", err, tt.wantErr)
+			}
+			if tt.key != "" && tt.wantErr == nil && errors.Is(err, marketdata.ErrUnknownSymbol) {
+				t.Errorf("err = %v matches ErrUnknownSymbol", err)
+			}
+		})
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	c := &Client{retryBackoff: 100 * time.Millisecond, retryMaxBackoff: time.Second}
+
+	tests := []struct {
+		retry int
+		want  time.Duration // the most it waits, at least half of it
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		// past the width of a shift the cap still holds
+		{40, time.Second},
+	}
+	for _, tt := range tests {
+		low, high := tt.want, time.Duration(0)
+		for i := 0; i < 1000; i++ {
+			wait := c.backoff(tt.retry)
+			low, high = min(low, wait), max(high, wait)
+		}
+		if low < tt.want/2 || high > tt.want {
+			t.Errorf("retry %d waited %v to %v, want within %v to %v", tt.retry, low, high, tt.want/2, tt.want)
+		}
+		// jittered, a thousand draws from the upper half are not all the same
+		if low == high {
+			t.Errorf("retry %d always waited %v", tt.retry, low)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package alphavantage
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+)
+
+// Client calls Alpha Vantage with one key and endpoint. Every call it makes
+// shares its call budget, retries, circuit breaker, fallback cache and
+// usage counters, so main builds one and hands it to whatever needs market
+// data.
+type Client struct {
+	apiKey     string
+	baseURL    string
+	timeout    time.Duration
+	httpClient *http.Client
+	budget     *scheduler
+
+	retries         int
+	retryBackoff    time.Duration
+	retryMaxBackoff time.Duration
+	circuit         *breaker
+	responses       *responseCache
+	usage           usageTracker
+}
+
+// New builds a Client with the key, endpoint, per-call timeout, call
+// budget, retries, circuit breaker and fallback cache of cfg.
+func New(cfg config.MarketDataConfig) *Client {
+	return &Client{
+		apiKey:     cfg.APIKey,
+		baseURL:    cfg.BaseURL,
+		timeout:    cfg.Timeout.Std(),
+		httpClient: &http.Client{},
+		budget:     newScheduler(cfg.CallsPerMinute, cfg.CallsPerDay, cfg.QueueSize, cfg.MaxWait.Std()),
+
+		retries:         cfg.Retries,
+		retryBackoff:    cfg.RetryBackoff.Std(),
+		retryMaxBackoff: cfg.RetryMaxBackoff.Std(),
+		circuit:         newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown.Std()),
+		responses:       newResponseCache(cfg.FallbackMaxAge.Std()),
+	}
+}
+
+// QueueDepth is the number of calls waiting for budget.
+func (c *Client) QueueDepth() int {
+	return c.budget.depth()
+}
+
+// CircuitState is the state of the circuit breaker, CircuitClosed while
+// calls go through.
+func (c *Client) CircuitState() string {
+	state, _ := c.circuit.current(time.Now())
+	return state
+}
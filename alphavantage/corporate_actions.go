@@ -0,0 +1,69 @@
+package alphavantage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+)
+
+// AdjustedDailyResponse is the part of TIME_SERIES_DAILY_ADJUSTED that
+// carries corporate actions: the dividend that went ex and the split
+// coefficient of every day.
+type AdjustedDailyResponse struct {
+	MetaData struct {
+		Symbol string `json:"2. Symbol"`
+	} `json:"Meta Data"`
+	TimeSeries map[string]struct {
+		Dividend         string `json:"7. dividend amount"`
+		SplitCoefficient string `json:"8. split coefficient"`
+	} `json:"Time Series (Daily)"`
+}
+
+// CorporateAction is a day of the adjusted series on which a split or a
+// cash dividend went ex, or both.
+type CorporateAction struct {
+	ExDate   string          // YYYY-MM-DD
+	Split    decimal.Decimal // shares per share before it, 0 without a split
+	Dividend decimal.Decimal // cash per share, 0 without a dividend
+}
+
+// FetchCorporateActions reads the splits and dividends of symbol from its
+// full adjusted daily history, oldest first.
+func (c *Client) FetchCorporateActions(ctx context.Context, symbol string, logger *zerolog.Logger) ([]CorporateAction, error) {
+	url := fmt.Sprintf("%s?function=TIME_SERIES_DAILY_ADJUSTED&symbol=%s&outputsize=full&apikey=%s", c.baseURL, symbol, c.apiKey)
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to fetch adjusted daily data from Alpha Vantage")
+		return nil, fetchError(err, "Failed to fetch corporate actions")
+	}
+	defer resp.Body.Close()
+
+	var adjusted AdjustedDailyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&adjusted); err != nil {
+		logger.Error().Err(err).Str("symbol", symbol).Msg("Failed to parse adjusted daily response")
+		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to parse corporate actions", err)
+	}
+
+	var actions []CorporateAction
+	for day, values := range adjusted.TimeSeries {
+		action := CorporateAction{ExDate: day}
+		if split, err := decimal.NewFromString(values.SplitCoefficient); err == nil && split.IsPositive() && !split.Equal(decimal.NewFromInt(1)) {
+			action.Split = split
+		}
+		if dividend, err := decimal.NewFromString(values.Dividend); err == nil && dividend.IsPositive() {
+			action.Dividend = dividend
+		}
+		if !action.Split.IsZero() || !action.Dividend.IsZero() {
+			actions = append(actions, action)
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].ExDate < actions[j].ExDate })
+	return actions, nil
+}
@@ -0,0 +1,85 @@
+package alphavantage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRateResponse represents the CURRENCY_EXCHANGE_RATE API response
+type ExchangeRateResponse struct {
+	Rate struct {
+		From          string `json:"1. From_Currency Code"`
+		To            string `json:"3. To_Currency Code"`
+		Rate          string `json:"5. Exchange Rate"`
+		LastRefreshed string `json:"6. Last Refreshed"`
+	} `json:"Realtime Currency Exchange Rate"`
+}
+
+// FXDailyResponse represents the FX_DAILY API response, only the closes
+// are kept
+type FXDailyResponse struct {
+	TimeSeries map[string]struct {
+		Close string `json:"4. close"`
+	} `json:"Time Series FX (Daily)"`
+}
+
+// FetchExchangeRate retrieves what one unit of from buys in to now.
+func (c *Client) FetchExchangeRate(ctx context.Context, from, to string, logger *zerolog.Logger) (decimal.Decimal, error) {
+	url := fmt.Sprintf("%s?function=CURRENCY_EXCHANGE_RATE&from_currency=%s&to_currency=%s&apikey=%s", c.baseURL, from, to, c.apiKey)
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		logger.Error().Err(err).Str("from", from).Str("to", to).Msg("Failed to fetch exchange rate from Alpha Vantage")
+		return decimal.Zero, fetchError(err, "Failed to fetch exchange rate")
+	}
+	defer resp.Body.Close()
+
+	var exchange ExchangeRateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&exchange); err != nil {
+		logger.Error().Err(err).Str("from", from).Str("to", to).Msg("Failed to parse exchange rate response")
+		return decimal.Zero, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to parse exchange rate", err)
+	}
+
+	rate, err := decimal.NewFromString(exchange.Rate.Rate)
+	if err != nil || !rate.IsPositive() {
+		logger.Error().Str("from", from).Str("to", to).Msg("Invalid currency pair or no data returned")
+		return decimal.Zero, util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Invalid currency pair", err)
+	}
+	return rate, nil
+}
+
+// FetchFXDaily retrieves the daily closing rates of from in to, by
+// YYYY-MM-DD day.
+func (c *Client) FetchFXDaily(ctx context.Context, from, to string, logger *zerolog.Logger) (map[string]decimal.Decimal, error) {
+	url := fmt.Sprintf("%s?function=FX_DAILY&from_symbol=%s&to_symbol=%s&outputsize=full&apikey=%s", c.baseURL, from, to, c.apiKey)
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		logger.Error().Err(err).Str("from", from).Str("to", to).Msg("Failed to fetch daily exchange rates from Alpha Vantage")
+		return nil, fetchError(err, "Failed to fetch daily exchange rates")
+	}
+	defer resp.Body.Close()
+
+	var daily FXDailyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&daily); err != nil {
+		logger.Error().Err(err).Str("from", from).Str("to", to).Msg("Failed to parse daily exchange rates response")
+		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Failed to parse daily exchange rates", err)
+	}
+	if len(daily.TimeSeries) == 0 {
+		logger.Error().Str("from", from).Str("to", to).Msg("Invalid currency pair or no data returned")
+		return nil, util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Invalid currency pair", nil)
+	}
+
+	rates := make(map[string]decimal.Decimal, len(daily.TimeSeries))
+	for day, values := range daily.TimeSeries {
+		if rate, err := decimal.NewFromString(values.Close); err == nil && rate.IsPositive() {
+			rates[day] = rate
+		}
+	}
+	return rates, nil
+}
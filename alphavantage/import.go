@@ -0,0 +1,74 @@
+package alphavantage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/shopspring/decimal"
+)
+
+// ParseDaily reads bars from a TIME_SERIES_DAILY body saved to a file, the
+// format DailyResponse models. Days whose values do not parse or fail
+// Validate are returned as RowErrors, in date order like the bars.
+func ParseDaily(r io.Reader) ([]marketdata.Bar, []marketdata.RowError, error) {
+	var daily DailyResponse
+	if err := json.NewDecoder(r).Decode(&daily); err != nil {
+		return nil, nil, fmt.Errorf("parse daily data: %w", err)
+	}
+	symbol := strings.ToUpper(strings.TrimSpace(daily.MetaData.Symbol))
+	if symbol == "" {
+		return nil, nil, errors.New(`file has no "Meta Data" symbol`)
+	}
+	if len(daily.TimeSeries) == 0 {
+		return nil, nil, errors.New(`file has no "Time Series (Daily)"`)
+	}
+
+	days := make([]string, 0, len(daily.TimeSeries))
+	for day := range daily.TimeSeries {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	var bars []marketdata.Bar
+	var rejected []marketdata.RowError
+	now := time.Now()
+	for _, day := range days {
+		values := daily.TimeSeries[day]
+		bar := marketdata.Bar{Symbol: symbol, Day: day}
+
+		var err error
+		for _, price := range []struct {
+			name, value string
+			into        *decimal.Decimal
+		}{
+			{"open", values.Open, &bar.Open}, {"high", values.High, &bar.High},
+			{"low", values.Low, &bar.Low}, {"close", values.Close, &bar.Close},
+		} {
+			if *price.into, err = decimal.NewFromString(price.value); err != nil {
+				err = fmt.Errorf("%s %q is not a number", price.name, price.value)
+				break
+			}
+		}
+		if err == nil && values.Volume != "" {
+			if bar.Volume, err = strconv.ParseInt(values.Volume, 10, 64); err != nil {
+				err = fmt.Errorf("volume %q is not a whole number", values.Volume)
+			}
+		}
+		if err == nil {
+			err = bar.Validate(now)
+		}
+		if err != nil {
+			rejected = append(rejected, marketdata.RowError{Day: day, Message: err.Error()})
+			continue
+		}
+		bars = append(bars, bar)
+	}
+	return bars, rejected, nil
+}
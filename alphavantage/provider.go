@@ -0,0 +1,77 @@
+package alphavantage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+// Name, Quote and CachedQuote make a Client a marketdata.Provider serving
+// GLOBAL_QUOTE. Its errors are the AppErrors FetchQuote returns, which
+// match the marketdata ones.
+func (c *Client) Name() string {
+	return upstreamName
+}
+
+func (c *Client) Quote(ctx context.Context, symbol string) (*marketdata.Quote, error) {
+	logger := zerolog.Ctx(ctx)
+	if logger.GetLevel() == zerolog.Disabled {
+		logger = &log.Logger
+	}
+
+	quote, err := c.FetchQuote(ctx, symbol, logger)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeQuote(quote, c.Name())
+}
+
+// CachedQuote is the last GLOBAL_QUOTE received for symbol, when it is
+// recent enough to stand in for a live one.
+func (c *Client) CachedQuote(symbol string) (*marketdata.Quote, bool) {
+	resp, ok := c.responses.response(cacheKey(c.quoteURL(symbol)), time.Now())
+	if !ok {
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	var quote QuoteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil || quote.GlobalQuote.Symbol == "" {
+		return nil, false
+	}
+	quote.CachedAt = CachedAt(resp)
+
+	normalized, err := normalizeQuote(&quote, c.Name())
+	if err != nil {
+		return nil, false
+	}
+	return normalized, true
+}
+
+func normalizeQuote(quote *QuoteResponse, source string) (*marketdata.Quote, error) {
+	price, err := decimal.NewFromString(quote.GlobalQuote.Price)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s price %q: %w", quote.GlobalQuote.Symbol, quote.GlobalQuote.Price, err)
+	}
+
+	normalized := &marketdata.Quote{
+		Symbol:     quote.GlobalQuote.Symbol,
+		Price:      price,
+		TradingDay: quote.GlobalQuote.Timestamp,
+		Source:     source,
+		CachedAt:   quote.CachedAt,
+	}
+	if quote.GlobalQuote.Volume != "" {
+		if normalized.Volume, err = strconv.ParseInt(quote.GlobalQuote.Volume, 10, 64); err != nil {
+			return nil, fmt.Errorf("parse %s volume %q: %w", quote.GlobalQuote.Symbol, quote.GlobalQuote.Volume, err)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,92 @@
+package alphavantage
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/shopspring/decimal"
+)
+
+// testClient calls an httptest server answering GLOBAL_QUOTE with the
+// fixture answers names for the symbol.
+func testClient(t *testing.T, answers map[string]string) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := answers[r.URL.Query().Get("symbol")]
+		if r.URL.Query().Get("function") != "GLOBAL_QUOTE" || r.URL.Query().Get("apikey") != "key" || !ok {
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture(t, name))
+	}))
+	t.Cleanup(server.Close)
+
+	return New(config.MarketDataConfig{
+		BaseURL:          server.URL,
+		APIKey:           "key",
+		Timeout:          config.Duration(time.Second),
+		QueueSize:        1,
+		MaxWait:          config.Duration(time.Second),
+		BreakerThreshold: 5,
+		BreakerCooldown:  config.Duration(time.Minute),
+	})
+}
+
+func TestQuote(t *testing.T) {
+	client := testClient(t, map[string]string{
+		"IBM":  "global_quote.json",
+		"ZZZZ": "global_quote_empty.json",
+		"BAD!": "error_message.json",
+	})
+
+	quote, err := client.Quote(t.Context(), "IBM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := marketdata.Quote{Symbol: "IBM", Price: decimal.RequireFromString("287.15"), Volume: 3871935, TradingDay: "2025-10-16", Source: "alphavantage"}
+	if quote.Symbol != want.Symbol || !quote.Price.Equal(want.Price) || quote.Volume != want.Volume ||
+		quote.TradingDay != want.TradingDay || quote.Source != want.Source || quote.CachedAt != nil {
+		t.Fatalf("quote = %+v, want %+v", quote, want)
+	}
+
+	for _, symbol := range []string{"ZZZZ", "BAD!"} {
+		if _, err := client.Quote(t.Context(), symbol); !errors.Is(err, marketdata.ErrUnknownSymbol) {
+			t.Errorf("%s: err = %v, want ErrUnknownSymbol", symbol, err)
+		}
+	}
+}
+
+func TestQuoteThrottled(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		retryAfter func(time.Time) time.Duration
+	}{
+		{"note.json", func(time.Time) time.Duration { return time.Minute }},
+		{"information_daily_limit.json", untilTomorrow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			client := testClient(t, map[string]string{"IBM": tt.fixture})
+
+			want := tt.retryAfter(time.Now())
+			_, err := client.Quote(t.Context(), "IBM")
+			var quota *QuotaExhaustedError
+			if !errors.As(err, &quota) || quota.Scope != QuotaUpstream {
+				t.Fatalf("err = %v, want an upstream QuotaExhaustedError", err)
+			}
+			if !errors.Is(err, marketdata.ErrUnavailable) {
+				t.Errorf("err = %v does not match ErrUnavailable", err)
+			}
+			if quota.RetryAfter > want || quota.RetryAfter < want-time.Minute {
+				t.Errorf("RetryAfter = %s, want about %s", quota.RetryAfter, want)
+			}
+		})
+	}
+}
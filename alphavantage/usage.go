@@ -0,0 +1,105 @@
+package alphavantage
+
+import (
+	"sync"
+	"time"
+)
+
+// Usage is what the status page shows about calls made to Alpha Vantage.
+// Days are counted in UTC.
+type Usage struct {
+	CallsToday      int64      `json:"callsToday"`
+	FailuresToday   int64      `json:"failuresToday"`
+	CallsLastMinute int        `json:"callsLastMinute"`
+	LastSuccess     *time.Time `json:"lastSuccess,omitempty"`
+	LastFailure     *time.Time `json:"lastFailure,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	// what the call budget has left, -1 without a per day budget
+	RemainingToday int `json:"remainingToday"`
+	Queued         int `json:"queued"`
+	// the circuit breaker, and when an open one lets a call through again
+	Circuit        string     `json:"circuit"`
+	CircuitRetryAt *time.Time `json:"circuitRetryAt,omitempty"`
+}
+
+type usageTracker struct {
+	mu          sync.Mutex
+	day         string
+	calls       int64
+	failures    int64
+	recent      []time.Time
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+}
+
+// record counts one call. err is nil for a call that got a 200 back.
+func (u *usageTracker) record(now time.Time, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if day := now.UTC().Format(time.DateOnly); day != u.day {
+		u.day = day
+		u.calls = 0
+		u.failures = 0
+	}
+
+	u.calls++
+	u.recent = append(trimMinute(u.recent, now), now)
+
+	if err != nil {
+		u.failures++
+		u.lastFailure = now
+		u.lastError = err.Error()
+		return
+	}
+	u.lastSuccess = now
+}
+
+// CurrentUsage returns the call counters as of now.
+func (c *Client) CurrentUsage() Usage {
+	usage := &c.usage
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+
+	now := time.Now()
+	usage.recent = trimMinute(usage.recent, now)
+
+	out := Usage{
+		LastError:       usage.lastError,
+		CallsLastMinute: len(usage.recent),
+		RemainingToday:  c.budget.remainingToday(),
+		Queued:          c.budget.depth(),
+	}
+	out.Circuit, out.CircuitRetryAt = c.circuitStatus(now)
+	if usage.day == now.UTC().Format(time.DateOnly) {
+		out.CallsToday = usage.calls
+		out.FailuresToday = usage.failures
+	}
+	if !usage.lastSuccess.IsZero() {
+		at := usage.lastSuccess
+		out.LastSuccess = &at
+	}
+	if !usage.lastFailure.IsZero() {
+		at := usage.lastFailure
+		out.LastFailure = &at
+	}
+	return out
+}
+
+// Reachable reports whether the last call to Alpha Vantage got through. It
+// is true before the first call.
+func (c *Client) Reachable() bool {
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+
+	return !c.usage.lastFailure.After(c.usage.lastSuccess)
+}
+
+func (c *Client) circuitStatus(now time.Time) (string, *time.Time) {
+	state, retryAt := c.circuit.current(now)
+	if retryAt.IsZero() {
+		return state, nil
+	}
+	return state, &retryAt
+}
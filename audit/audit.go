@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/rs/zerolog/log"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin             = "auth.login"
+	ActionTokenRefresh      = "auth.token_refresh"
+	ActionTwoFactorEnable   = "auth.2fa_enable"
+	ActionTwoFactorDisable  = "auth.2fa_disable"
+	ActionAPIKeyCreate      = "api_key.create"
+	ActionAPIKeyRevoke      = "api_key.revoke"
+	ActionTradeCreate       = "trade.create"
+	ActionTransactionUpdate = "transaction.update"
+	ActionTransactionDelete = "transaction.delete"
+	ActionPortfolioDelete   = "portfolio.delete"
+	ActionFeeScheduleUpdate = "portfolio.fee_schedule"
+	ActionMarginChange      = "portfolio.margin"
+	ActionWatchlistDelete   = "watchlist.delete"
+	ActionCashWithdraw      = "cash.withdraw"
+	ActionCurrencyChange    = "cash.currency_change"
+	ActionAccountDelete     = "account.delete"
+	ActionAccountDeactivate = "account.deactivate"
+	ActionAccountReactivate = "account.reactivate"
+	ActionAdminSuspend      = "admin.suspend"
+	ActionAdminUnsuspend    = "admin.unsuspend"
+	ActionAdminRoleChange   = "admin.role_change"
+	ActionAdminAuditExport  = "admin.audit_export"
+	ActionAdminPriceImport  = "admin.price_import"
+	ActionAdminCorpAction   = "admin.corporate_action"
+	ActionAdminCorpSync     = "admin.corporate_action_sync"
+)
+
+var auditService *service.AuditService
+
+// SetService hands the package the service entries are written through.
+func SetService(s *service.AuditService) {
+	auditService = s
+}
+
+// Record writes one audit entry for the request in c. before and after are
+// reduced to the fields that actually changed. A failure to write is logged
+// but never fails the request that is being audited.
+func Record(c echo.Context, action, targetType, targetId string, before, after interface{}) {
+	entry := models.AuditLogModel{
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		RequestId:  requestId(c),
+		ClientIP:   c.RealIP(),
+	}
+
+	if actorId, ok := c.Get("userId").(string); ok {
+		entry.ActorId = actorId
+	}
+	if role, ok := c.Get("role").(int); ok {
+		entry.ActorRole = role
+	}
+	if via, ok := c.Get("authVia").(string); ok {
+		entry.AuthVia = via
+	}
+
+	entry.Before, entry.After = Diff(before, after)
+
+	// the action is already done, a client hanging up must not lose its trail
+	ctx := context.WithoutCancel(c.Request().Context())
+	if err := auditService.Record(ctx, &entry); err != nil {
+		log.Error().Err(err).Str("action", action).Str("target_id", targetId).Msg("not able to write the audit log")
+	}
+}
+
+// RecordActor is Record for requests that are not authenticated yet, such as
+// the login itself.
+func RecordActor(c echo.Context, actorId, action, targetType, targetId string, before, after interface{}) {
+	c.Set("userId", actorId)
+	Record(c, action, targetType, targetId, before, after)
+}
+
+// Diff marshals before and after and keeps only the top level keys whose
+// values differ. Either side may be nil (creation or deletion).
+func Diff(before, after interface{}) (string, string) {
+	beforeMap := toMap(before)
+	afterMap := toMap(after)
+
+	if beforeMap == nil || afterMap == nil {
+		return marshal(beforeMap), marshal(afterMap)
+	}
+
+	changedBefore := make(map[string]interface{})
+	changedAfter := make(map[string]interface{})
+
+	for key, value := range beforeMap {
+		if other, ok := afterMap[key]; !ok || !reflect.DeepEqual(value, other) {
+			changedBefore[key] = value
+		}
+	}
+	for key, value := range afterMap {
+		if other, ok := beforeMap[key]; !ok || !reflect.DeepEqual(value, other) {
+			changedAfter[key] = value
+		}
+	}
+
+	return marshal(changedBefore), marshal(changedAfter)
+}
+
+func toMap(value interface{}) map[string]interface{} {
+	if value == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return map[string]interface{}{"value": string(raw)}
+	}
+	return out
+}
+
+func marshal(value map[string]interface{}) string {
+	if value == nil {
+		return ""
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// requestId prefers the id ErrorHandleMiddleWare stored on the context.
+func requestId(c echo.Context) string {
+	if id, ok := c.Get("requestId").(string); ok && id != "" {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
@@ -0,0 +1,586 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is the single typed view of every setting the server reads. It is
+// built by Load from defaults, an optional YAML/TOML file, the environment and
+// command line flags, in that order of precedence (flags win).
+type Config struct {
+	Server     ServerConfig     `yaml:"server" toml:"server"`
+	Database   DatabaseConfig   `yaml:"database" toml:"database"`
+	Auth       AuthConfig       `yaml:"auth" toml:"auth"`
+	MarketData MarketDataConfig `yaml:"market_data" toml:"market_data"`
+	SMTP       SMTPConfig       `yaml:"smtp" toml:"smtp"`
+	Log        LogConfig        `yaml:"log" toml:"log"`
+	Metrics    MetricsConfig    `yaml:"metrics" toml:"metrics"`
+	Tracing    TracingConfig    `yaml:"tracing" toml:"tracing"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit" toml:"rate_limit"`
+	Trading    TradingConfig    `yaml:"trading" toml:"trading"`
+
+	// Args holds what is left on the command line after the flags, e.g.
+	// "migrate up".
+	Args []string `yaml:"-" toml:"-"`
+}
+
+type ServerConfig struct {
+	Addr            string   `yaml:"addr" toml:"addr"`
+	BaseURL         string   `yaml:"base_url" toml:"base_url"`
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	DrainDelay      Duration `yaml:"drain_delay" toml:"drain_delay"` // not ready before shutting down
+	// TrustedProxies are the CIDRs whose X-Forwarded-For is believed when
+	// working out the client IP. Without any, the peer address is used.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+type DatabaseConfig struct {
+	Driver          string   `yaml:"driver" toml:"driver"`
+	DSN             string   `yaml:"dsn" toml:"dsn"`
+	Host            string   `yaml:"host" toml:"host"`
+	Port            int      `yaml:"port" toml:"port"` // 0 picks the driver's default port
+	User            string   `yaml:"user" toml:"user"`
+	Password        string   `yaml:"password" toml:"password"`
+	PasswordFile    string   `yaml:"password_file" toml:"password_file"`
+	Name            string   `yaml:"name" toml:"name"`
+	Params          string   `yaml:"params" toml:"params"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	QueryTimeout    Duration `yaml:"query_timeout" toml:"query_timeout"` // per statement, 0 only follows the caller's ctx
+	AutoMigrate     bool     `yaml:"auto_migrate" toml:"auto_migrate"`
+}
+
+type AuthConfig struct {
+	JWTSecret     string   `yaml:"jwt_secret" toml:"jwt_secret"`
+	JWTSecretFile string   `yaml:"jwt_secret_file" toml:"jwt_secret_file"`
+	TokenTTL      Duration `yaml:"token_ttl" toml:"token_ttl"`
+}
+
+// Quote providers market_data.providers can list.
+const (
+	ProviderAlphaVantage = "alphavantage"
+	ProviderFinnhub      = "finnhub"
+	ProviderCSV          = "csv"
+)
+
+type MarketDataConfig struct {
+	// quote providers in the order they are asked, the first to have a
+	// quote serves it
+	Providers []string `yaml:"providers" toml:"providers"`
+
+	// Alpha Vantage, which also serves search, intraday and daily data
+	BaseURL    string   `yaml:"base_url" toml:"base_url"`
+	APIKey     string   `yaml:"api_key" toml:"api_key"`
+	APIKeyFile string   `yaml:"api_key_file" toml:"api_key_file"`
+	Timeout    Duration `yaml:"timeout" toml:"timeout"` // per call
+	// budget of the key, 0 is no limit; free keys get 5 a minute and 25 a day
+	CallsPerMinute int `yaml:"calls_per_minute" toml:"calls_per_minute"`
+	CallsPerDay    int `yaml:"calls_per_day" toml:"calls_per_day"`
+	// calls waiting for budget, and how long one waits when its caller set
+	// no deadline
+	QueueSize int      `yaml:"queue_size" toml:"queue_size"`
+	MaxWait   Duration `yaml:"max_wait" toml:"max_wait"`
+	// retries of a call that failed on the network or with a 5xx, backing
+	// off from RetryBackoff up to RetryMaxBackoff with jitter
+	Retries         int      `yaml:"retries" toml:"retries"`
+	RetryBackoff    Duration `yaml:"retry_backoff" toml:"retry_backoff"`
+	RetryMaxBackoff Duration `yaml:"retry_max_backoff" toml:"retry_max_backoff"`
+	// consecutive failures that open the circuit, and how long it stays open
+	// before one call is let through to probe
+	BreakerThreshold int      `yaml:"breaker_threshold" toml:"breaker_threshold"`
+	BreakerCooldown  Duration `yaml:"breaker_cooldown" toml:"breaker_cooldown"`
+	// how old a cached response may be to stand in for a failed call, 0
+	// serves none
+	FallbackMaxAge Duration `yaml:"fallback_max_age" toml:"fallback_max_age"`
+
+	Finnhub FinnhubConfig `yaml:"finnhub" toml:"finnhub"`
+	CSV     CSVConfig     `yaml:"csv" toml:"csv"`
+}
+
+// FinnhubConfig is the Finnhub quote provider, calls share
+// market_data.timeout.
+type FinnhubConfig struct {
+	BaseURL    string `yaml:"base_url" toml:"base_url"`
+	APIKey     string `yaml:"api_key" toml:"api_key"`
+	APIKeyFile string `yaml:"api_key_file" toml:"api_key_file"`
+}
+
+// CSVConfig is the quote provider reading a file, see
+// marketdata.CSVProvider for its columns.
+type CSVConfig struct {
+	Path string `yaml:"path" toml:"path"`
+}
+
+type SMTPConfig struct {
+	Host         string   `yaml:"host" toml:"host"`
+	Port         int      `yaml:"port" toml:"port"`
+	Username     string   `yaml:"username" toml:"username"`
+	Password     string   `yaml:"password" toml:"password"`
+	PasswordFile string   `yaml:"password_file" toml:"password_file"`
+	From         string   `yaml:"from" toml:"from"`
+	Timeout      Duration `yaml:"timeout" toml:"timeout"` // per message, dial to QUIT
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"`
+}
+
+// MetricsConfig guards /metrics. Without a token it is open, which is fine
+// when the port is only reachable from the scraper.
+type MetricsConfig struct {
+	Token     string `yaml:"token" toml:"token"`
+	TokenFile string `yaml:"token_file" toml:"token_file"`
+}
+
+// TracingConfig picks where spans go. "otlp" sends them to a collector,
+// "stdout" writes them as JSON to File (or standard output) and works
+// offline, "none" turns tracing off.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint"` // OTLP/HTTP URL, empty uses the OTEL_EXPORTER_OTLP_* variables
+	File        string  `yaml:"file" toml:"file"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"` // share of new traces kept, callers' decisions are followed
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+}
+
+// RateLimitConfig sets the limit every route gets by default and the
+// routes, keyed by method and route template ("POST /login"), that get
+// their own. A route policy is given in full, it does not inherit from the
+// default. API keys are also held to the limit stored on each key.
+type RateLimitConfig struct {
+	Enabled       bool                       `yaml:"enabled" toml:"enabled"`
+	SweepInterval Duration                   `yaml:"sweep_interval" toml:"sweep_interval"` // how often idle keys are dropped
+	Default       RateLimitPolicy            `yaml:"default" toml:"default"`
+	Routes        map[string]RateLimitPolicy `yaml:"routes" toml:"routes"`
+}
+
+type RateLimitPolicy struct {
+	Algorithm string   `yaml:"algorithm" toml:"algorithm"`
+	Requests  int      `yaml:"requests" toml:"requests"`
+	Window    Duration `yaml:"window" toml:"window"`
+	Burst     int      `yaml:"burst" toml:"burst"` // token bucket only, 0 means requests
+	Key       string   `yaml:"key" toml:"key"`     // user (falls back to ip when anonymous) or ip
+}
+
+// TradingConfig sets how trades are booked. QuantityPlaces is how many
+// decimal places a share quantity may have, 0 only allows whole shares.
+type TradingConfig struct {
+	QuantityPlaces int `yaml:"quantity_places" toml:"quantity_places"`
+	// margin accounts: the shares of the positions' value the equity must
+	// cover to open a position and to keep it, the yearly rate of the value
+	// of short positions charged daily for borrowing the shares, and whether
+	// a margin call the equity does not meet closes positions
+	InitialMargin     float64 `yaml:"initial_margin" toml:"initial_margin"`
+	MaintenanceMargin float64 `yaml:"maintenance_margin" toml:"maintenance_margin"`
+	BorrowRate        float64 `yaml:"borrow_rate" toml:"borrow_rate"`
+	AutoLiquidate     bool    `yaml:"auto_liquidate" toml:"auto_liquidate"`
+}
+
+// Duration accepts Go duration strings ("30s", "5m") in YAML, TOML and env.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func Defaults() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			BaseURL:         "http://localhost:8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			ShutdownTimeout: Duration(20 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Driver:          DriverMySQL,
+			Host:            "127.0.0.1",
+			User:            "root",
+			Name:            "tradealpha",
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			QueryTimeout:    Duration(5 * time.Second),
+		},
+		Auth: AuthConfig{
+			TokenTTL: Duration(30 * time.Minute),
+		},
+		MarketData: MarketDataConfig{
+			Providers:      []string{ProviderAlphaVantage},
+			BaseURL:        "https://www.alphavantage.co/query",
+			Timeout:        Duration(10 * time.Second),
+			CallsPerMinute: 5,
+			CallsPerDay:    25,
+			QueueSize:      100,
+			MaxWait:        Duration(20 * time.Second),
+
+			Retries:          2,
+			RetryBackoff:     Duration(500 * time.Millisecond),
+			RetryMaxBackoff:  Duration(5 * time.Second),
+			BreakerThreshold: 5,
+			BreakerCooldown:  Duration(30 * time.Second),
+			FallbackMaxAge:   Duration(24 * time.Hour),
+
+			Finnhub: FinnhubConfig{
+				BaseURL: "https://finnhub.io/api/v1",
+			},
+		},
+		SMTP: SMTPConfig{
+			Port:    587,
+			Timeout: Duration(15 * time.Second),
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:       true,
+			SweepInterval: Duration(time.Minute),
+			Default: RateLimitPolicy{
+				Algorithm: RateLimitTokenBucket,
+				Requests:  120,
+				Window:    Duration(time.Minute),
+				Key:       RateLimitByUser,
+			},
+			Routes: map[string]RateLimitPolicy{
+				"POST /login":                 {Algorithm: RateLimitSlidingWindow, Requests: 10, Window: Duration(time.Minute), Key: RateLimitByIP},
+				"GET /api/verify-email":       {Algorithm: RateLimitSlidingWindow, Requests: 10, Window: Duration(time.Minute), Key: RateLimitByIP},
+				"POST /api/2fa/verify":        {Algorithm: RateLimitSlidingWindow, Requests: 5, Window: Duration(time.Minute), Key: RateLimitByUser},
+				"POST /api/2fa/confirm":       {Algorithm: RateLimitSlidingWindow, Requests: 5, Window: Duration(time.Minute), Key: RateLimitByUser},
+				"POST /api/users/me/withdraw": {Algorithm: RateLimitSlidingWindow, Requests: 10, Window: Duration(time.Minute), Key: RateLimitByUser},
+			},
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingNone,
+			SampleRatio: 1,
+			ServiceName: "tradealpha",
+		},
+		Trading: TradingConfig{
+			QuantityPlaces:    6,
+			InitialMargin:     0.5,
+			MaintenanceMargin: 0.25,
+			BorrowRate:        0.03,
+		},
+	}
+}
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+
+	RateLimitByUser = "user"
+	RateLimitByIP   = "ip"
+)
+
+// MaxQuantityPlaces is the most decimal places the quantity columns hold.
+const MaxQuantityPlaces = 8
+
+const (
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+)
+
+// MySQLDSN builds the go-sql-driver DSN from the individual fields, an
+// explicit DSN always wins.
+func (d DatabaseConfig) MySQLDSN() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	params := d.Params
+	if params == "" {
+		params = "charset=utf8mb4&parseTime=True&loc=Local"
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?%s", d.User, d.Password, d.Host, d.portOr(3306), d.Name, params)
+}
+
+// PostgresDSN builds a postgres:// URL for pgx, an explicit DSN always wins.
+func (d DatabaseConfig) PostgresDSN() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     fmt.Sprintf("%s:%d", d.Host, d.portOr(5432)),
+		Path:     "/" + d.Name,
+		RawQuery: d.Params,
+	}
+	if u.RawQuery == "" {
+		u.RawQuery = "sslmode=prefer"
+	}
+	return u.String()
+}
+
+// SQLiteDSN is the file path given as database.dsn. Without one the
+// database lives in memory and is gone when the process exits; database.name
+// is not used because its default is meant for a server database.
+func (d DatabaseConfig) SQLiteDSN() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	return ":memory:"
+}
+
+func (d DatabaseConfig) portOr(def int) int {
+	if d.Port == 0 {
+		return def
+	}
+	return d.Port
+}
+
+// Fingerprint is a short hash of the effective configuration, so two
+// instances can be compared at a glance. Secrets are left out: a weak
+// password could otherwise be brute forced from the hash.
+func (c Config) Fingerprint() string {
+	c.Database.Password = ""
+	c.Auth.JWTSecret = ""
+	c.MarketData.APIKey = ""
+	c.MarketData.Finnhub.APIKey = ""
+	c.SMTP.Password = ""
+	c.Metrics.Token = ""
+	c.Args = nil
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Validate reports every problem at once so a bad deploy fails with a full
+// list instead of one error per restart.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Server.Addr == "" {
+		problems = append(problems, "server.addr must not be empty")
+	}
+	if _, err := url.ParseRequestURI(c.Server.BaseURL); err != nil {
+		problems = append(problems, fmt.Sprintf("server.base_url %q is not a valid URL", c.Server.BaseURL))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "server.shutdown_timeout must be positive")
+	}
+	if c.Server.DrainDelay < 0 {
+		problems = append(problems, "server.drain_delay must not be negative")
+	}
+
+	switch c.Database.Driver {
+	case DriverMySQL, DriverPostgres:
+		if c.Database.DSN == "" && (c.Database.Host == "" || c.Database.User == "" || c.Database.Name == "") {
+			problems = append(problems, "database: set database.dsn or database.host, database.user and database.name")
+		}
+	case DriverSQLite:
+		// an empty dsn is a valid in-memory database
+	default:
+		problems = append(problems, fmt.Sprintf("database.driver %q is not supported (use mysql, postgres or sqlite)", c.Database.Driver))
+	}
+	if c.Database.MaxIdleConns > c.Database.MaxOpenConns && c.Database.MaxOpenConns > 0 {
+		problems = append(problems, "database.max_idle_conns must not exceed database.max_open_conns")
+	}
+	if c.Database.QueryTimeout < 0 {
+		problems = append(problems, "database.query_timeout must not be negative")
+	}
+
+	if len(c.Auth.JWTSecret) < 32 {
+		problems = append(problems, "auth.jwt_secret must be at least 32 bytes (set TRADEALPHA_JWT_SECRET or auth.jwt_secret_file)")
+	}
+	if c.Auth.TokenTTL <= 0 {
+		problems = append(problems, "auth.token_ttl must be positive")
+	}
+
+	if _, err := url.ParseRequestURI(c.MarketData.BaseURL); err != nil {
+		problems = append(problems, fmt.Sprintf("market_data.base_url %q is not a valid URL", c.MarketData.BaseURL))
+	}
+	if c.MarketData.Timeout <= 0 {
+		problems = append(problems, "market_data.timeout must be positive")
+	}
+	if c.MarketData.CallsPerMinute < 0 || c.MarketData.CallsPerDay < 0 {
+		problems = append(problems, "market_data.calls_per_minute and calls_per_day must not be negative")
+	}
+	if c.MarketData.QueueSize < 0 {
+		problems = append(problems, "market_data.queue_size must not be negative")
+	}
+	if c.MarketData.MaxWait <= 0 {
+		problems = append(problems, "market_data.max_wait must be positive")
+	}
+	if c.Server.WriteTimeout > 0 && c.MarketData.MaxWait >= c.Server.WriteTimeout {
+		// the response could not be written by the time the call got through
+		problems = append(problems, "market_data.max_wait must be shorter than server.write_timeout")
+	}
+	if c.MarketData.Retries < 0 {
+		problems = append(problems, "market_data.retries must not be negative")
+	}
+	if c.MarketData.Retries > 0 && (c.MarketData.RetryBackoff <= 0 || c.MarketData.RetryMaxBackoff < c.MarketData.RetryBackoff) {
+		problems = append(problems, "market_data.retry_backoff must be positive and no longer than retry_max_backoff")
+	}
+	if c.MarketData.BreakerThreshold < 1 {
+		problems = append(problems, "market_data.breaker_threshold must be at least 1")
+	}
+	if c.MarketData.BreakerCooldown <= 0 {
+		problems = append(problems, "market_data.breaker_cooldown must be positive")
+	}
+	if c.MarketData.FallbackMaxAge < 0 {
+		problems = append(problems, "market_data.fallback_max_age must not be negative")
+	}
+	problems = append(problems, c.MarketData.validateProviders()...)
+
+	if c.SMTP.Host != "" && c.SMTP.From == "" {
+		problems = append(problems, "smtp.from is required when smtp.host is set")
+	}
+	if c.SMTP.Host != "" && c.SMTP.Timeout <= 0 {
+		problems = append(problems, "smtp.timeout must be positive")
+	}
+
+	for _, cidr := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			problems = append(problems, fmt.Sprintf("server.trusted_proxies: %q is not a CIDR", cidr))
+		}
+	}
+
+	if c.RateLimit.SweepInterval <= 0 {
+		problems = append(problems, "rate_limit.sweep_interval must be positive")
+	}
+	if c.RateLimit.Enabled {
+		problems = append(problems, c.RateLimit.Default.problems("rate_limit.default")...)
+		routes := make([]string, 0, len(c.RateLimit.Routes))
+		for route := range c.RateLimit.Routes {
+			routes = append(routes, route)
+		}
+		sort.Strings(routes)
+		for _, route := range routes {
+			policy := c.RateLimit.Routes[route]
+			if method, path, ok := strings.Cut(route, " "); !ok || method == "" || !strings.HasPrefix(path, "/") {
+				problems = append(problems, fmt.Sprintf("rate_limit.routes: %q must look like \"POST /login\"", route))
+			}
+			problems = append(problems, policy.problems(fmt.Sprintf("rate_limit.routes[%q]", route))...)
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingNone, TracingStdout:
+	case TracingOTLP:
+		if c.Tracing.Endpoint != "" {
+			if _, err := url.ParseRequestURI(c.Tracing.Endpoint); err != nil {
+				problems = append(problems, fmt.Sprintf("tracing.endpoint %q is not a valid URL", c.Tracing.Endpoint))
+			}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("tracing.exporter %q is not supported (use none, otlp or stdout)", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "tracing.sample_ratio must be between 0 and 1")
+	}
+
+	if c.Trading.QuantityPlaces < 0 || c.Trading.QuantityPlaces > MaxQuantityPlaces {
+		problems = append(problems, fmt.Sprintf("trading.quantity_places must be between 0 and %d", MaxQuantityPlaces))
+	}
+	if c.Trading.MaintenanceMargin <= 0 || c.Trading.MaintenanceMargin > c.Trading.InitialMargin || c.Trading.InitialMargin > 1 {
+		problems = append(problems, "trading.maintenance_margin must be positive and no more than trading.initial_margin, which is at most 1")
+	}
+	if c.Trading.BorrowRate < 0 {
+		problems = append(problems, "trading.borrow_rate must not be negative")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log.level %q must be one of debug, info, warn, error", c.Log.Level))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+}
+
+func (p RateLimitPolicy) problems(name string) []string {
+	var problems []string
+	switch p.Algorithm {
+	case RateLimitTokenBucket, RateLimitSlidingWindow:
+	default:
+		problems = append(problems, fmt.Sprintf("%s.algorithm %q must be token_bucket or sliding_window", name, p.Algorithm))
+	}
+	if p.Requests <= 0 {
+		problems = append(problems, name+".requests must be positive")
+	}
+	if p.Window <= 0 {
+		problems = append(problems, name+".window must be positive")
+	}
+	if p.Burst < 0 {
+		problems = append(problems, name+".burst must not be negative")
+	}
+	switch p.Key {
+	case RateLimitByUser, RateLimitByIP:
+	default:
+		problems = append(problems, fmt.Sprintf("%s.key %q must be user or ip", name, p.Key))
+	}
+	return problems
+}
+
+func (m MarketDataConfig) validateProviders() []string {
+	var problems []string
+	if len(m.Providers) == 0 {
+		problems = append(problems, "market_data.providers must list at least one provider")
+	}
+
+	seen := make(map[string]bool, len(m.Providers))
+	for _, provider := range m.Providers {
+		if seen[provider] {
+			problems = append(problems, fmt.Sprintf("market_data.providers lists %q twice", provider))
+			continue
+		}
+		seen[provider] = true
+
+		switch provider {
+		case ProviderAlphaVantage:
+		case ProviderFinnhub:
+			if _, err := url.ParseRequestURI(m.Finnhub.BaseURL); err != nil {
+				problems = append(problems, fmt.Sprintf("market_data.finnhub.base_url %q is not a valid URL", m.Finnhub.BaseURL))
+			}
+			if m.Finnhub.APIKey == "" {
+				problems = append(problems, "market_data.finnhub.api_key is required when finnhub is a provider")
+			}
+		case ProviderCSV:
+			if m.CSV.Path == "" {
+				problems = append(problems, "market_data.csv.path is required when csv is a provider")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("market_data.providers: unknown provider %q, use %s, %s or %s",
+				provider, ProviderAlphaVantage, ProviderFinnhub, ProviderCSV))
+		}
+	}
+	return problems
+}
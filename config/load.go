@@ -0,0 +1,283 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment variable Load reads.
+const EnvPrefix = "TRADEALPHA_"
+
+// Load builds the configuration from defaults, then the file named by
+// -config or TRADEALPHA_CONFIG, then the environment (a .env file is loaded
+// first if present), then the flags in args. Secrets given as *_file paths
+// are read last and the result is validated.
+func Load(args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load .env: %w", err)
+	}
+
+	cfg := Defaults()
+
+	fset := flag.NewFlagSet("tradealpha", flag.ContinueOnError)
+	configPath := fset.String("config", os.Getenv(EnvPrefix+"CONFIG"), "path to a YAML or TOML config file")
+	addr := fset.String("addr", "", "listen address, overrides server.addr")
+	dbDriver := fset.String("db-driver", "", "database driver, overrides database.driver")
+	dbDSN := fset.String("db-dsn", "", "database DSN, overrides database.dsn")
+	logLevel := fset.String("log-level", "", "log level, overrides log.level")
+
+	if err := fset.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		if err := loadFile(&cfg, *configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	setString(&cfg.Server.Addr, *addr)
+	setString(&cfg.Database.Driver, *dbDriver)
+	setString(&cfg.Database.DSN, *dbDSN)
+	setString(&cfg.Log.Level, *logLevel)
+	cfg.Args = fset.Args()
+
+	if err := resolveSecretFiles(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, cfg)
+	case ".toml":
+		err = toml.Unmarshal(raw, cfg)
+	default:
+		return fmt.Errorf("config file %s: unknown extension, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+type envBinding struct {
+	name string
+	set  func(string) error
+}
+
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	bindings := []envBinding{
+		{"SERVER_ADDR", stringSetter(&cfg.Server.Addr)},
+		{"BASE_URL", stringSetter(&cfg.Server.BaseURL)},
+		{"SERVER_READ_TIMEOUT", durationSetter(&cfg.Server.ReadTimeout)},
+		{"SERVER_WRITE_TIMEOUT", durationSetter(&cfg.Server.WriteTimeout)},
+		{"SHUTDOWN_TIMEOUT", durationSetter(&cfg.Server.ShutdownTimeout)},
+		{"DRAIN_DELAY", durationSetter(&cfg.Server.DrainDelay)},
+		{"TRUSTED_PROXIES", listSetter(&cfg.Server.TrustedProxies)},
+
+		{"DB_DRIVER", stringSetter(&cfg.Database.Driver)},
+		{"DB_DSN", stringSetter(&cfg.Database.DSN)},
+		{"DB_HOST", stringSetter(&cfg.Database.Host)},
+		{"DB_PORT", intSetter(&cfg.Database.Port)},
+		{"DB_USER", stringSetter(&cfg.Database.User)},
+		{"DB_PASSWORD", stringSetter(&cfg.Database.Password)},
+		{"DB_PASSWORD_FILE", stringSetter(&cfg.Database.PasswordFile)},
+		{"DB_NAME", stringSetter(&cfg.Database.Name)},
+		{"DB_PARAMS", stringSetter(&cfg.Database.Params)},
+		{"DB_MAX_OPEN_CONNS", intSetter(&cfg.Database.MaxOpenConns)},
+		{"DB_MAX_IDLE_CONNS", intSetter(&cfg.Database.MaxIdleConns)},
+		{"DB_QUERY_TIMEOUT", durationSetter(&cfg.Database.QueryTimeout)},
+		{"DB_AUTO_MIGRATE", boolSetter(&cfg.Database.AutoMigrate)},
+
+		{"JWT_SECRET", stringSetter(&cfg.Auth.JWTSecret)},
+		{"JWT_SECRET_FILE", stringSetter(&cfg.Auth.JWTSecretFile)},
+		{"TOKEN_TTL", durationSetter(&cfg.Auth.TokenTTL)},
+
+		{"MARKET_DATA_PROVIDERS", listSetter(&cfg.MarketData.Providers)},
+		{"ALPHA_VANTAGE_URL", stringSetter(&cfg.MarketData.BaseURL)},
+		{"ALPHA_VANTAGE_KEY", stringSetter(&cfg.MarketData.APIKey)},
+		{"ALPHA_VANTAGE_KEY_FILE", stringSetter(&cfg.MarketData.APIKeyFile)},
+		{"MARKET_DATA_TIMEOUT", durationSetter(&cfg.MarketData.Timeout)},
+		{"MARKET_DATA_CALLS_PER_MINUTE", intSetter(&cfg.MarketData.CallsPerMinute)},
+		{"MARKET_DATA_CALLS_PER_DAY", intSetter(&cfg.MarketData.CallsPerDay)},
+		{"MARKET_DATA_QUEUE_SIZE", intSetter(&cfg.MarketData.QueueSize)},
+		{"MARKET_DATA_MAX_WAIT", durationSetter(&cfg.MarketData.MaxWait)},
+		{"MARKET_DATA_RETRIES", intSetter(&cfg.MarketData.Retries)},
+		{"MARKET_DATA_RETRY_BACKOFF", durationSetter(&cfg.MarketData.RetryBackoff)},
+		{"MARKET_DATA_RETRY_MAX_BACKOFF", durationSetter(&cfg.MarketData.RetryMaxBackoff)},
+		{"MARKET_DATA_BREAKER_THRESHOLD", intSetter(&cfg.MarketData.BreakerThreshold)},
+		{"MARKET_DATA_BREAKER_COOLDOWN", durationSetter(&cfg.MarketData.BreakerCooldown)},
+		{"MARKET_DATA_FALLBACK_MAX_AGE", durationSetter(&cfg.MarketData.FallbackMaxAge)},
+		{"FINNHUB_URL", stringSetter(&cfg.MarketData.Finnhub.BaseURL)},
+		{"FINNHUB_KEY", stringSetter(&cfg.MarketData.Finnhub.APIKey)},
+		{"FINNHUB_KEY_FILE", stringSetter(&cfg.MarketData.Finnhub.APIKeyFile)},
+		{"MARKET_DATA_CSV_PATH", stringSetter(&cfg.MarketData.CSV.Path)},
+
+		{"SMTP_HOST", stringSetter(&cfg.SMTP.Host)},
+		{"SMTP_PORT", intSetter(&cfg.SMTP.Port)},
+		{"SMTP_USERNAME", stringSetter(&cfg.SMTP.Username)},
+		{"SMTP_PASSWORD", stringSetter(&cfg.SMTP.Password)},
+		{"SMTP_PASSWORD_FILE", stringSetter(&cfg.SMTP.PasswordFile)},
+		{"SMTP_FROM", stringSetter(&cfg.SMTP.From)},
+		{"SMTP_TIMEOUT", durationSetter(&cfg.SMTP.Timeout)},
+
+		{"LOG_LEVEL", stringSetter(&cfg.Log.Level)},
+
+		{"METRICS_TOKEN", stringSetter(&cfg.Metrics.Token)},
+		{"METRICS_TOKEN_FILE", stringSetter(&cfg.Metrics.TokenFile)},
+
+		{"RATE_LIMIT_ENABLED", boolSetter(&cfg.RateLimit.Enabled)},
+		{"RATE_LIMIT_SWEEP_INTERVAL", durationSetter(&cfg.RateLimit.SweepInterval)},
+		{"RATE_LIMIT_ALGORITHM", stringSetter(&cfg.RateLimit.Default.Algorithm)},
+		{"RATE_LIMIT_REQUESTS", intSetter(&cfg.RateLimit.Default.Requests)},
+		{"RATE_LIMIT_WINDOW", durationSetter(&cfg.RateLimit.Default.Window)},
+		{"RATE_LIMIT_BURST", intSetter(&cfg.RateLimit.Default.Burst)},
+		{"RATE_LIMIT_KEY", stringSetter(&cfg.RateLimit.Default.Key)},
+
+		{"TRACING_EXPORTER", stringSetter(&cfg.Tracing.Exporter)},
+		{"TRACING_ENDPOINT", stringSetter(&cfg.Tracing.Endpoint)},
+		{"TRACING_FILE", stringSetter(&cfg.Tracing.File)},
+		{"TRACING_SAMPLE_RATIO", floatSetter(&cfg.Tracing.SampleRatio)},
+		{"TRACING_SERVICE_NAME", stringSetter(&cfg.Tracing.ServiceName)},
+
+		{"TRADING_QUANTITY_PLACES", intSetter(&cfg.Trading.QuantityPlaces)},
+		{"TRADING_INITIAL_MARGIN", floatSetter(&cfg.Trading.InitialMargin)},
+		{"TRADING_MAINTENANCE_MARGIN", floatSetter(&cfg.Trading.MaintenanceMargin)},
+		{"TRADING_BORROW_RATE", floatSetter(&cfg.Trading.BorrowRate)},
+		{"TRADING_AUTO_LIQUIDATE", boolSetter(&cfg.Trading.AutoLiquidate)},
+	}
+
+	for _, b := range bindings {
+		value, ok := lookup(EnvPrefix + b.name)
+		if !ok && b.name == "ALPHA_VANTAGE_KEY" {
+			// the key predates the prefix, keep reading the old name
+			value, ok = lookup(b.name)
+		}
+		if !ok {
+			continue
+		}
+		if err := b.set(value); err != nil {
+			return fmt.Errorf("env %s%s: %w", EnvPrefix, b.name, err)
+		}
+	}
+	return nil
+}
+
+func resolveSecretFiles(cfg *Config) error {
+	secrets := []struct {
+		name string
+		file string
+		dst  *string
+	}{
+		{"database.password_file", cfg.Database.PasswordFile, &cfg.Database.Password},
+		{"auth.jwt_secret_file", cfg.Auth.JWTSecretFile, &cfg.Auth.JWTSecret},
+		{"market_data.api_key_file", cfg.MarketData.APIKeyFile, &cfg.MarketData.APIKey},
+		{"market_data.finnhub.api_key_file", cfg.MarketData.Finnhub.APIKeyFile, &cfg.MarketData.Finnhub.APIKey},
+		{"smtp.password_file", cfg.SMTP.PasswordFile, &cfg.SMTP.Password},
+		{"metrics.token_file", cfg.Metrics.TokenFile, &cfg.Metrics.Token},
+	}
+
+	for _, s := range secrets {
+		if s.file == "" {
+			continue
+		}
+		raw, err := os.ReadFile(s.file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+		*s.dst = strings.TrimSpace(string(raw))
+	}
+	return nil
+}
+
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+func stringSetter(dst *string) func(string) error {
+	return func(value string) error {
+		*dst = value
+		return nil
+	}
+}
+
+func intSetter(dst *int) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*dst = parsed
+		return nil
+	}
+}
+
+func boolSetter(dst *bool) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		*dst = parsed
+		return nil
+	}
+}
+
+// listSetter splits a comma separated value, "" clears the list.
+func listSetter(dst *[]string) func(string) error {
+	return func(value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+		return nil
+	}
+}
+
+func floatSetter(dst *float64) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*dst = parsed
+		return nil
+	}
+}
+
+func durationSetter(dst *Duration) func(string) error {
+	return func(value string) error {
+		return dst.UnmarshalText([]byte(value))
+	}
+}
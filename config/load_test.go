@@ -0,0 +1,259 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv(EnvPrefix+"JWT_SECRET", testSecret)
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Defaults()
+	want.Auth.JWTSecret = testSecret
+	if !reflect.DeepEqual(*cfg, want) {
+		t.Errorf("Load with only a secret set = %+v, want the defaults", *cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "tradealpha.yaml", `
+server:
+  addr: ":7000"
+  base_url: https://file.example.com
+database:
+  driver: sqlite
+  dsn: file.db
+log:
+  level: warn
+market_data:
+  calls_per_minute: 75
+`)
+	t.Setenv(EnvPrefix+"CONFIG", file)
+	t.Setenv(EnvPrefix+"JWT_SECRET", testSecret)
+	t.Setenv(EnvPrefix+"BASE_URL", "https://env.example.com")
+	t.Setenv(EnvPrefix+"LOG_LEVEL", "error")
+
+	cfg, err := Load([]string{"-log-level", "debug", "serve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"file over default", cfg.Server.Addr, ":7000"},
+		{"file over default, nested", cfg.MarketData.CallsPerMinute, 75},
+		{"env over file", cfg.Server.BaseURL, "https://env.example.com"},
+		{"flag over env", cfg.Log.Level, "debug"},
+		{"default kept", cfg.MarketData.CallsPerDay, 25},
+		{"arguments left", cfg.Args, []string{"serve"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	file := writeFile(t, "tradealpha.toml", `
+[server]
+addr = ":7001"
+
+[rate_limit.default]
+requests = 30
+`)
+	t.Setenv(EnvPrefix+"JWT_SECRET", testSecret)
+
+	cfg, err := Load([]string{"-config", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":7001" || cfg.RateLimit.Default.Requests != 30 || cfg.RateLimit.Default.Window != Duration(time.Minute) {
+		t.Errorf("server.addr = %q, rate_limit.default = %+v", cfg.Server.Addr, cfg.RateLimit.Default)
+	}
+
+	if _, err := Load([]string{"-config", writeFile(t, "tradealpha.json", "{}")}); err == nil || !strings.Contains(err.Error(), "unknown extension") {
+		t.Errorf("json config file: err = %v, want unknown extension", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(Config) bool
+		wantErr string
+	}{
+		{
+			name:  "string",
+			env:   map[string]string{"TRADEALPHA_DB_DRIVER": "postgres"},
+			check: func(c Config) bool { return c.Database.Driver == "postgres" },
+		},
+		{
+			name:  "integer",
+			env:   map[string]string{"TRADEALPHA_DB_MAX_OPEN_CONNS": "7"},
+			check: func(c Config) bool { return c.Database.MaxOpenConns == 7 },
+		},
+		{
+			name:  "duration",
+			env:   map[string]string{"TRADEALPHA_TOKEN_TTL": "1h30m"},
+			check: func(c Config) bool { return c.Auth.TokenTTL == Duration(90*time.Minute) },
+		},
+		{
+			name:  "boolean",
+			env:   map[string]string{"TRADEALPHA_RATE_LIMIT_ENABLED": "false"},
+			check: func(c Config) bool { return !c.RateLimit.Enabled },
+		},
+		{
+			name:  "float",
+			env:   map[string]string{"TRADEALPHA_TRADING_BORROW_RATE": "0.05"},
+			check: func(c Config) bool { return c.Trading.BorrowRate == 0.05 },
+		},
+		{
+			name:  "list",
+			env:   map[string]string{"TRADEALPHA_MARKET_DATA_PROVIDERS": " finnhub, ,csv "},
+			check: func(c Config) bool { return reflect.DeepEqual(c.MarketData.Providers, []string{"finnhub", "csv"}) },
+		},
+		{
+			name:  "empty list",
+			env:   map[string]string{"TRADEALPHA_TRUSTED_PROXIES": ""},
+			check: func(c Config) bool { return c.Server.TrustedProxies == nil },
+		},
+		{
+			name:  "unprefixed alpha vantage key",
+			env:   map[string]string{"ALPHA_VANTAGE_KEY": "old"},
+			check: func(c Config) bool { return c.MarketData.APIKey == "old" },
+		},
+		{
+			name:  "prefixed alpha vantage key wins",
+			env:   map[string]string{"ALPHA_VANTAGE_KEY": "old", "TRADEALPHA_ALPHA_VANTAGE_KEY": "new"},
+			check: func(c Config) bool { return c.MarketData.APIKey == "new" },
+		},
+		{
+			name:  "other unprefixed names are ignored",
+			env:   map[string]string{"DB_DRIVER": "postgres"},
+			check: func(c Config) bool { return c.Database.Driver == DriverMySQL },
+		},
+		{
+			name:    "bad integer",
+			env:     map[string]string{"TRADEALPHA_DB_PORT": "five"},
+			wantErr: `env TRADEALPHA_DB_PORT: "five" is not an integer`,
+		},
+		{
+			name:    "bad boolean",
+			env:     map[string]string{"TRADEALPHA_DB_AUTO_MIGRATE": "sometimes"},
+			wantErr: `env TRADEALPHA_DB_AUTO_MIGRATE: "sometimes" is not a boolean`,
+		},
+		{
+			name:    "bad duration",
+			env:     map[string]string{"TRADEALPHA_SMTP_TIMEOUT": "soon"},
+			wantErr: "env TRADEALPHA_SMTP_TIMEOUT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Defaults()
+			err := applyEnv(&cfg, func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("env %v not applied", tt.env)
+			}
+		})
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	t.Setenv(EnvPrefix+"JWT_SECRET", "too short, the file wins")
+	t.Setenv(EnvPrefix+"JWT_SECRET_FILE", writeFile(t, "jwt", testSecret+"\n"))
+	t.Setenv(EnvPrefix+"DB_PASSWORD_FILE", writeFile(t, "db", "  hunter2\n"))
+	t.Setenv(EnvPrefix+"SMTP_PASSWORD_FILE", writeFile(t, "smtp", "mail"))
+	t.Setenv(EnvPrefix+"METRICS_TOKEN_FILE", writeFile(t, "metrics", "scrape"))
+	t.Setenv(EnvPrefix+"ALPHA_VANTAGE_KEY_FILE", writeFile(t, "av", "demo"))
+	t.Setenv(EnvPrefix+"FINNHUB_KEY_FILE", writeFile(t, "finnhub", "fh"))
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{cfg.Auth.JWTSecret, cfg.Database.Password, cfg.SMTP.Password, cfg.Metrics.Token, cfg.MarketData.APIKey, cfg.MarketData.Finnhub.APIKey}
+	want := []string{testSecret, "hunter2", "mail", "scrape", "demo", "fh"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("secrets = %q, want %q", got, want)
+	}
+
+	t.Setenv(EnvPrefix+"SMTP_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := Load(nil); err == nil || !strings.HasPrefix(err.Error(), "smtp.password_file: ") {
+		t.Errorf("missing secret file: err = %v, want it named", err)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := Defaults()
+	cfg.Server.Addr = ""
+	cfg.Database.Driver = "oracle"
+	cfg.Auth.JWTSecret = "short"
+	cfg.MarketData.Retries = -1
+	cfg.RateLimit.Default.Algorithm = "leaky_bucket"
+	cfg.Tracing.SampleRatio = 2
+	cfg.Log.Level = "verbose"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted a broken configuration")
+	}
+	for _, want := range []string{
+		"server.addr must not be empty",
+		`database.driver "oracle" is not supported`,
+		"auth.jwt_secret must be at least 32 bytes",
+		"market_data.retries must not be negative",
+		`rate_limit.default.algorithm "leaky_bucket"`,
+		"tracing.sample_ratio must be between 0 and 1",
+		`log.level "verbose"`,
+	} {
+		if !strings.Contains(err.Error(), "\n  - "+want) {
+			t.Errorf("problem %q missing from:\n%v", want, err)
+		}
+	}
+	if got := strings.Count(err.Error(), "\n  - "); got != 7 {
+		t.Errorf("got %d problems, want 7:\n%v", got, err)
+	}
+
+	cfg = Defaults()
+	cfg.Auth.JWTSecret = testSecret
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("defaults with a secret: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
+	"github.com/pratyush934/tradealpha/server/dto"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+)
+
+/*
+CreateAPIKey - Issue a new key, the plaintext is only returned here
+GetAPIKeys - List the caller's keys
+RevokeAPIKey - Revoke a key
+GetAPIKeyUsage - Usage log of a key
+*/
+
+func CreateAPIKey(c echo.Context) error {
+	userId := c.Get("userId").(string)
+
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+
+	var keyDto dto.APIKeyDTO
+	if err := c.Bind(&keyDto); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the api key", err)
+	}
+
+	plain, key, err := services.APIKeys.Create(c.Request().Context(), userId, keyDto.Name, keyDto.Scopes, keyDto.RateLimit, keyDto.ExpiresInDays)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to create the api key")
+	}
+
+	audit.Record(c, audit.ActionAPIKeyCreate, "api_key", key.Id, nil, key)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message": "store this key now, it will not be shown again",
+		"key":     plain,
+		"apiKey":  key,
+	})
+}
+
+func GetAPIKeys(c echo.Context) error {
+	userId := c.Get("userId").(string)
+
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+
+	keys, err := services.APIKeys.ListByUser(c.Request().Context(), userId)
+	if err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the api keys", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"apiKeys": keys,
+	})
+}
+
+func RevokeAPIKey(c echo.Context) error {
+	key, err := getOwnedAPIKey(c)
+	if err != nil {
+		return err
+	}
+
+	if err := services.APIKeys.Revoke(c.Request().Context(), key.Id); err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to revoke the api key", err)
+	}
+
+	audit.Record(c, audit.ActionAPIKeyRevoke, "api_key", key.Id, nil, nil)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "api key revoked",
+	})
+}
+
+func GetAPIKeyUsage(c echo.Context) error {
+	key, err := getOwnedAPIKey(c)
+	if err != nil {
+		return err
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offSet, _ := strconv.Atoi(c.QueryParam("offSet"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	usage, err := services.APIKeys.Usage(c.Request().Context(), key.Id, limit, offSet)
+	if err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the api key usage", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"apiKey": key,
+		"usage":  usage,
+	})
+}
+
+func getOwnedAPIKey(c echo.Context) (*models.APIKeyModel, error) {
+	userId := c.Get("userId").(string)
+
+	if userId == "" {
+		return nil, util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+
+	key, err := services.APIKeys.Get(c.Request().Context(), userId, c.Param("id"))
+	if err != nil {
+		return nil, serviceError(err, http.StatusNotFound, types.StatusNotFound, "api key not found")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog"
+)
+
+/*
+GetAuditLogs - Filtered, paged audit log for admins
+ExportAuditLogs - Same filters, streamed as csv or json
+*/
+
+// auditExportFlush is how many csv rows are written between flushes.
+const auditExportFlush = 500
+
+func GetAuditLogs(c echo.Context) error {
+	filter, err := auditFilterFromQuery(c)
+	if err != nil {
+		return err
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	logs, total, err := services.Audit.Query(c.Request().Context(), filter)
+	if err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to query the audit log", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"total":  total,
+		"limit":  filter.Limit,
+		"offSet": filter.Offset,
+		"logs":   logs,
+	})
+}
+
+// ExportAuditLogs writes the entries as it reads them, a batch at a time,
+// so an export of any size is complete and never held in memory. A failure
+// after the first row has gone out can only cut the body short, it is
+// logged and the export is not recorded.
+func ExportAuditLogs(c echo.Context) error {
+	filter, err := auditFilterFromQuery(c)
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("audit-%s", time.Now().UTC().Format("20060102T150405Z"))
+	res := c.Response()
+
+	var rows int
+	var write func(models.AuditLogModel) error
+	var finish func() error
+
+	if c.QueryParam("format") == "json" {
+		res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		res.Header().Set(echo.HeaderContentDisposition, "attachment; filename=\""+fileName+".json\"")
+		encoder := json.NewEncoder(res)
+		write = func(entry models.AuditLogModel) error {
+			separator := ","
+			if rows == 0 {
+				res.WriteHeader(http.StatusOK)
+				separator = "["
+			}
+			if _, err := res.Write([]byte(separator)); err != nil {
+				return err
+			}
+			return encoder.Encode(entry)
+		}
+		finish = func() error {
+			if rows == 0 {
+				res.WriteHeader(http.StatusOK)
+				_, err := res.Write([]byte("[]\n"))
+				return err
+			}
+			_, err := res.Write([]byte("]\n"))
+			return err
+		}
+	} else {
+		res.Header().Set(echo.HeaderContentType, "text/csv")
+		res.Header().Set(echo.HeaderContentDisposition, "attachment; filename=\""+fileName+".csv\"")
+		writer := csv.NewWriter(res)
+		header := []string{"id", "createdAt", "actorId", "actorRole", "authVia", "action", "targetType", "targetId", "before", "after", "requestId", "clientIp"}
+		write = func(entry models.AuditLogModel) error {
+			if rows == 0 {
+				res.WriteHeader(http.StatusOK)
+				if err := writer.Write(header); err != nil {
+					return err
+				}
+			}
+			if err := writer.Write([]string{
+				entry.Id,
+				entry.CreatedAt.UTC().Format(time.RFC3339),
+				entry.ActorId,
+				strconv.Itoa(entry.ActorRole),
+				entry.AuthVia,
+				entry.Action,
+				entry.TargetType,
+				entry.TargetId,
+				entry.Before,
+				entry.After,
+				entry.RequestId,
+				entry.ClientIP,
+			}); err != nil {
+				return err
+			}
+			// hand each batch to the client instead of buffering it
+			if (rows+1)%auditExportFlush == 0 {
+				writer.Flush()
+				res.Flush()
+			}
+			return writer.Error()
+		}
+		finish = func() error {
+			if rows == 0 {
+				res.WriteHeader(http.StatusOK)
+				if err := writer.Write(header); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		}
+	}
+
+	err = services.Audit.Export(c.Request().Context(), filter, func(entry models.AuditLogModel) error {
+		if err := write(entry); err != nil {
+			return err
+		}
+		rows++
+		return nil
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		if rows == 0 && !res.Committed {
+			return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to query the audit log", err)
+		}
+		zerolog.Ctx(c.Request().Context()).Error().Err(err).Int("rows", rows).Msg("audit export cut short")
+		return nil
+	}
+
+	audit.Record(c, audit.ActionAdminAuditExport, "audit_log", "", nil, map[string]interface{}{"rows": rows})
+	return nil
+}
+
+func auditFilterFromQuery(c echo.Context) (models.AuditLogFilter, error) {
+	filter := models.AuditLogFilter{
+		ActorId:    c.QueryParam("actorId"),
+		Action:     c.QueryParam("action"),
+		TargetType: c.QueryParam("targetType"),
+		TargetId:   c.QueryParam("targetId"),
+		RequestId:  c.QueryParam("requestId"),
+	}
+
+	filter.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	filter.Offset, _ = strconv.Atoi(c.QueryParam("offSet"))
+
+	for param, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, param+" must be an RFC3339 timestamp", err)
+		}
+		*dst = &parsed
+	}
+
+	return filter, nil
+}
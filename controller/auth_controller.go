@@ -1,13 +1,14 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
 	"github.com/pratyush934/tradealpha/server/dto"
-	"github.com/pratyush934/tradealpha/server/jwtpackage"
 	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/service"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/pratyush934/tradealpha/server/util"
 	"github.com/rs/zerolog/log"
@@ -23,61 +24,67 @@ func LoginController(c echo.Context) error {
 		})
 	}
 
-	email := login.Email
-
-	candidate, err := models.GetUserByEmail(email)
+	user, created, err := services.Users.Login(c.Request().Context(), models.User{
+		Name:         login.Name,
+		Email:        login.Email,
+		ProfileImage: login.Image,
+		OAuthId:      login.OAuthId,
+		Provider:     login.Provider,
+	})
 
-	if err == nil {
+	if errors.Is(err, service.ErrSuspended) {
+		return util.NewAppError(http.StatusForbidden, types.StatusForbidden, "account is suspended: "+user.SuspensionReason, nil)
+	}
 
-		err2 := models.UpdateLastLogin(email, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Please check the Login")
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Not able to log the user in", err)
+	}
 
-		if err2 != nil {
-			log.Error().Err(err).Msg("Please check the UpdateLastLogin")
-			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Please check the err2", err2)
-		}
+	token, err := auth.CreateToken(user)
 
-		token, err3 := jwtpackage.CreateToken(candidate)
+	if err != nil {
+		log.Error().Err(err).Msg("not able to generate token")
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Not able to create the token", err)
+	}
 
-		if err3 != nil {
-			log.Error().Err(err3).Msg("not able to generate token , err3")
-			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Please check the err3", err3)
-		}
+	audit.RecordActor(c, user.Id, audit.ActionLogin, "user", user.Id, nil, nil)
 
+	if !created {
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"message": "the user already exist, Login Successful",
 			"email":   token,
 		})
 	}
 
-	newUser := models.User{
-		Name:         login.Name,
-		Email:        login.Email,
-		ProfileImage: login.Image,
-		OAuthId:      login.OAuthId,
-		Provider:     login.Provider,
-	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"user":  user,
+		"token": token,
+	})
+}
 
-	user, err := newUser.CreateUser()
+// RefreshToken exchanges a still valid session token for a fresh one, the
+// user row is re-read so role and 2FA changes are picked up.
+func RefreshToken(c echo.Context) error {
+	userId := c.Get("userId").(string)
 
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"message": "Not able to create the user",
-			"Error":   err,
-		})
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
 	}
 
-	token, err := jwtpackage.CreateToken(user)
-
+	user, err := services.Users.GetSummary(c.Request().Context(), userId)
 	if err != nil {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the user", err)
+	}
 
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"message": "Not able to create the token",
-			"Error":   err,
-		})
+	token, err := auth.CreateToken(user)
+	if err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to create the token", err)
 	}
 
+	audit.Record(c, audit.ActionTokenRefresh, "user", user.Id, nil, nil)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"user":  user,
 		"token": token,
 	})
 }
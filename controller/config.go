@@ -0,0 +1,16 @@
+package controller
+
+import (
+	"github.com/pratyush934/tradealpha/server/jwtpackage"
+	"github.com/pratyush934/tradealpha/server/service"
+)
+
+var services *service.Services
+var auth *jwtpackage.Auth
+
+// SetServices hands the controllers the services they delegate to and the
+// auth that signs their tokens, it must run before the server starts.
+func SetServices(s *service.Services, a *jwtpackage.Auth) {
+	services = s
+	auth = a
+}
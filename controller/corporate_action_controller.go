@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
+	"github.com/pratyush934/tradealpha/server/dto"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+)
+
+/*
+GetCorporateActions - Splits and dividends of a symbol
+CreateCorporateAction - Admin enters a split or dividend, applied at once when it has taken effect
+SyncCorporateActions - Admin pulls the splits and dividends of a symbol from the provider
+*/
+
+func GetCorporateActions(c echo.Context) error {
+	actions, err := services.Actions.List(c.Request().Context(), c.Param("symbol"))
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to list the corporate actions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": types.StatusOK,
+		"actions": actions,
+	})
+}
+
+func CreateCorporateAction(c echo.Context) error {
+	var body dto.CorporateActionDTO
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "invalid request body", err)
+	}
+
+	action := models.CorporateActionModel{
+		Symbol: body.Symbol,
+		Type:   body.Type,
+		Ratio:  body.Ratio,
+		Amount: body.Amount,
+	}
+	var err error
+	if action.ExDate, err = time.Parse(time.DateOnly, body.ExDate); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "exDate must be YYYY-MM-DD", nil)
+	}
+	if body.PayDate != "" {
+		payDate, err := time.Parse(time.DateOnly, body.PayDate)
+		if err != nil {
+			return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "payDate must be YYYY-MM-DD", nil)
+		}
+		action.PayDate = &payDate
+	}
+
+	if err := services.Actions.Create(c.Request().Context(), &action); err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to record the corporate action")
+	}
+
+	audit.Record(c, audit.ActionAdminCorpAction, "corporate_action", action.Id, nil, map[string]interface{}{
+		"symbol": action.Symbol,
+		"type":   action.Type,
+		"exDate": body.ExDate,
+		"ratio":  action.Ratio,
+		"amount": action.Amount,
+	})
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"message": types.StatusCreated,
+		"action":  action,
+	})
+}
+
+func SyncCorporateActions(c echo.Context) error {
+	symbol := c.Param("symbol")
+
+	added, err := services.Actions.Sync(c.Request().Context(), symbol)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to sync the corporate actions")
+	}
+
+	audit.Record(c, audit.ActionAdminCorpSync, "stock", symbol, nil, map[string]interface{}{"added": len(added)})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": types.StatusOK,
+		"added":   added,
+	})
+}
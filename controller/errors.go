@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+	"gorm.io/gorm"
+)
+
+type errorStatus struct {
+	status int
+	code   string
+}
+
+// serviceErrors are the business rule errors whose message is shown to the
+// caller as is.
+var serviceErrors = map[error]errorStatus{
+	service.ErrSuspended:                {http.StatusForbidden, types.StatusForbidden},
+	service.ErrInsufficientBalance:      {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrInsufficientHoldings:     {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrVerificationMismatch:     {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrAlreadyReversed:          {http.StatusConflict, types.StatusConflict},
+	service.ErrReversalNotCorrectable:   {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrDividendNotCorrectable:   {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrBorrowFeeNotCorrectable:  {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrInsufficientBuyingPower:  {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrMarginAccountExists:      {http.StatusConflict, types.StatusConflict},
+	service.ErrMarginPositionsOpen:      {http.StatusConflict, types.StatusConflict},
+	service.ErrHoldingsOpen:             {http.StatusConflict, types.StatusConflict},
+	service.ErrPortfolioChanged:         {http.StatusConflict, types.StatusConflict},
+	service.ErrCorporateActionExists:    {http.StatusConflict, types.StatusConflict},
+	service.ErrPortfolioHasTransactions: {http.StatusConflict, types.StatusConflict},
+	service.ErrAlreadyInWatchList:       {http.StatusConflict, types.StatusConflict},
+	service.ErrSelfSuspend:              {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrTwoFactorEnabled:         {http.StatusConflict, types.StatusConflict},
+	service.ErrTwoFactorDisabled:        {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrTwoFactorNotEnrolled:     {http.StatusBadRequest, types.StatusBadRequest},
+	service.ErrInvalidTOTP:              {http.StatusUnauthorized, types.StatusUnauthorized},
+	service.ErrTOTPReplayed:             {http.StatusUnauthorized, types.StatusUnauthorized},
+	service.ErrInvalidRecoveryCode:      {http.StatusUnauthorized, types.StatusUnauthorized},
+	marketdata.ErrUnknownSymbol:         {http.StatusBadRequest, types.StatusBadRequest},
+	marketdata.ErrUnavailable:           {http.StatusServiceUnavailable, types.StatusServiceUnavailable},
+}
+
+// serviceError turns an error from the service layer into an AppError.
+// Business rule and validation errors keep their own status and message. A
+// missing record and someone else's record are the same 404, so ids cannot
+// be probed. A spent market data budget is a 503 with its Retry-After;
+// anything else gets status and message.
+func serviceError(err error, status int, code, message string) error {
+	var appError *util.AppError
+	if errors.As(err, &appError) {
+		return appError
+	}
+
+	var validation *service.ValidationError
+	if errors.As(err, &validation) {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, validation.Message, nil)
+	}
+
+	var quota *alphavantage.QuotaExhaustedError
+	if errors.As(err, &quota) {
+		appError := util.NewAppError(http.StatusServiceUnavailable, types.StatusServiceUnavailable, marketdata.ErrUnavailable.Error(), err)
+		appError.RetryAfter = quota.RetryAfter
+		return appError
+	}
+
+	for target, mapped := range serviceErrors {
+		if errors.Is(err, target) {
+			return util.NewAppError(mapped.status, mapped.code, target.Error(), err)
+		}
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrNotOwner) {
+		return util.NewAppError(http.StatusNotFound, types.StatusNotFound, message, err)
+	}
+
+	return util.NewAppError(status, code, message, err)
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+func TestServiceError(t *testing.T) {
+	quota := &alphavantage.QuotaExhaustedError{Scope: alphavantage.QuotaMinute, RetryAfter: 12500 * time.Millisecond}
+
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantMessage    string
+		wantRetryAfter string
+	}{
+		{"business rule", fmt.Errorf("buy: %w", service.ErrInsufficientBalance), http.StatusBadRequest, service.ErrInsufficientBalance.Error(), ""},
+		{"missing record", gorm.ErrRecordNotFound, http.StatusNotFound, "could not load", ""},
+		{"someone else's record", service.ErrNotOwner, http.StatusNotFound, "could not load", ""},
+		{"provider down", fmt.Errorf("quote: %w", marketdata.ErrUnavailable), http.StatusServiceUnavailable, marketdata.ErrUnavailable.Error(), ""},
+		{"budget spent", fmt.Errorf("quote: %w", quota), http.StatusServiceUnavailable, marketdata.ErrUnavailable.Error(), "13"},
+		{"anything else", errors.New("boom"), http.StatusInternalServerError, "could not load", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zerolog.Nop()
+			e := echo.New()
+			e.Use(util.ErrorHandleMiddleWare(&logger))
+			e.GET("/", func(c echo.Context) error {
+				return serviceError(tt.err, http.StatusInternalServerError, types.StatusInternalServerError, "could not load")
+			})
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			if want := fmt.Sprintf("%q", tt.wantMessage); !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("body %s does not carry %s", rec.Body.String(), want)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -33,14 +32,12 @@ func AddNotification(c echo.Context) error {
 		ReadStatus: notification.ReadStatus,
 	}
 
-	createNotification, err := newNotification.CreateNotification()
-
-	if err != nil {
+	if err := services.Notifications.Create(c.Request().Context(), &newNotification); err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to create notification in AddNotification", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"notification": createNotification,
+		"notification": newNotification,
 	})
 
 }
@@ -54,10 +51,10 @@ func GetNotification(c echo.Context) error {
 
 	noticeId := c.Param("id")
 
-	notificationByNotificationId, err := models.GetNotificationByNotificationId(noticeId)
+	notificationByNotificationId, err := services.Notifications.Get(c.Request().Context(), userId, noticeId)
 
 	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the notificationById", err)
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to get the notificationById")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -74,8 +71,8 @@ func DeleteNotification(c echo.Context) error {
 
 	noticeId := c.Param("id")
 
-	if err := models.DeleteNotificationByNId(noticeId); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to delete the notification", err)
+	if err := services.Notifications.Delete(c.Request().Context(), userId, noticeId); err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to delete the notification")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -84,18 +81,6 @@ func DeleteNotification(c echo.Context) error {
 
 }
 
-func NotifyOnTransaction(transaction *models.TransactionModel) error {
-	notification := models.NotificationModel{
-		UserId:     transaction.UserId,
-		Message:    fmt.Sprintf("Transaction %s for %s: %d shares at $%.2f", transaction.Type, transaction.StockId, transaction.Quantity, transaction.Price),
-		ReadStatus: false,
-	}
-	if _, err := notification.CreateNotification(); err != nil {
-		return err
-	}
-	return nil
-}
-
 //func UpdateNotification(c echo.Context) error {
 //
 //	userId := c.Get("userId").(string)
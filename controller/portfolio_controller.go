@@ -4,10 +4,12 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
 	"github.com/pratyush934/tradealpha/server/dto"
 	"github.com/pratyush934/tradealpha/server/models"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/shopspring/decimal"
 )
 
 func CreatePortfolio(c echo.Context) error {
@@ -27,20 +29,19 @@ func CreatePortfolio(c echo.Context) error {
 		UserId:         userId,
 		Name:           portfolio.Name,
 		Title:          portfolio.Title,
-		TotalValue:     0,
+		TotalValue:     decimal.Zero,
 		Description:    portfolio.Description,
+		BaseCurrency:   portfolio.BaseCurrency,
 		Transaction:    make([]models.TransactionModel, 0),
 		PortFolioStock: make([]models.PortFolioStock, 0),
 	}
 
-	createPortfolio, err := newPortFolio.CreatePortfolio()
-
-	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to create portfolio", err)
+	if err := services.Portfolios.Create(c.Request().Context(), &newPortFolio); err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to create portfolio")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"portfolio": createPortfolio,
+		"portfolio": newPortFolio,
 	})
 }
 
@@ -57,10 +58,10 @@ func GetPortFolioById(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "please provide portfolioId", nil)
 	}
 
-	portFolioById, err := models.GetPortFolioById(portId)
+	portFolioById, err := services.Portfolios.Get(c.Request().Context(), userId, portId)
 
 	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able te get the protFolio by Id", err)
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able te get the protFolio by Id")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -88,18 +89,8 @@ func UpdatePortFolioStock(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the portfolio, UpdatePortFolio", err)
 	}
 
-	portFolioById, err := models.GetPortFolioById(portId)
-
-	if err != nil || portFolioById == nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able te get the protFolio by Id", err)
-	}
-
-	portFolioById.Title = portfolio.Title
-	portFolioById.Name = portfolio.Name
-	portFolioById.Description = portfolio.Description
-
-	if err := models.UpdatePortFolio(*portFolioById); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the Update portfolio", err)
+	if err := services.Portfolios.UpdateDetails(c.Request().Context(), userId, portId, portfolio.Name, portfolio.Title, portfolio.Description, portfolio.BaseCurrency); err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to get the Update portfolio")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -108,6 +99,11 @@ func UpdatePortFolioStock(c echo.Context) error {
 
 }
 
+// DeletePortFolio deletes the caller's portfolio with its holdings and fee
+// schedule. A portfolio that has ever had a transaction is refused with 409
+// Conflict: transactions are immutable, reversals included, and would be
+// left pointing at nothing. Before the service layer this deleted rows by id
+// from port_folio_stocks and never touched the portfolio itself.
 func DeletePortFolio(c echo.Context) error {
 
 	userId := c.Get("userId").(string)
@@ -118,10 +114,13 @@ func DeletePortFolio(c echo.Context) error {
 
 	portId := c.Param("id")
 
-	if err := models.DeletePortfolioById(portId); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to delete the portfolio by portId", err)
+	before, err := services.Portfolios.Delete(c.Request().Context(), userId, portId)
+	if err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to delete the portfolio by portId")
 	}
 
+	audit.Record(c, audit.ActionPortfolioDelete, "portfolio", portId, before, nil)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "PortFolioDelete successfully",
 	})
@@ -133,10 +132,9 @@ func UpdatePortFolioTotalValue(c echo.Context) error {
 	if userId == "" {
 		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
 	}
-	portId := c.Param("id") // Assume param is portfolio ID, adjust if needed
-	err := models.UpdateTotalValue(portId)
-	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to update total value", err)
+	portId := c.Param("id")
+	if _, err := services.Portfolios.Metrics(c.Request().Context(), userId, portId); err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to update total value")
 	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "Total value updated",
@@ -153,17 +151,10 @@ func GetPortfolioMetrics(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "portfolio ID is required", nil)
 	}
 
-	portfolio, err := models.GetPortFolioById(portId)
+	// the metrics are revalued before they are returned
+	portfolio, err := services.Portfolios.Metrics(c.Request().Context(), userId, portId)
 	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "failed to fetch portfolio", err)
-	}
-	if portfolio.UserId != userId {
-		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "user not authorized for this portfolio", nil)
-	}
-
-	// Ensure metrics are up-to-date
-	if err := models.UpdateTotalValue(portId); err != nil {
-		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to update portfolio metrics", err)
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "failed to update portfolio metrics")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -171,5 +162,141 @@ func GetPortfolioMetrics(c echo.Context) error {
 		"total_value":      portfolio.TotalValue,
 		"unrealized_gains": portfolio.UnRealizedGains,
 		"realized_gains":   portfolio.RealizedGains,
+		"base_currency":    portfolio.BaseCurrency,
+	})
+}
+
+// GetPortfolioValuation prices every holding in its own currency and in the
+// portfolio's base currency, without storing the totals.
+func GetPortfolioValuation(c echo.Context) error {
+	userId := c.Get("userId").(string)
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+	portId := c.Param("id")
+	if portId == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "portfolio ID is required", nil)
+	}
+
+	valuation, err := services.Portfolios.Valuation(c.Request().Context(), userId, portId)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "failed to value the portfolio")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":   types.StatusOK,
+		"valuation": valuation,
+	})
+}
+
+func GetFeeSchedule(c echo.Context) error {
+	userId := c.Get("userId").(string)
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+	portId := c.Param("id")
+	if portId == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "portfolio ID is required", nil)
+	}
+
+	schedule, err := services.Portfolios.FeeSchedule(c.Request().Context(), userId, portId)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "failed to get the fee schedule")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":     types.StatusOK,
+		"feeSchedule": schedule,
+	})
+}
+
+func UpdateFeeSchedule(c echo.Context) error {
+	userId := c.Get("userId").(string)
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+	portId := c.Param("id")
+	if portId == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "portfolio ID is required", nil)
+	}
+
+	var body dto.FeeScheduleDTO
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the fee schedule", err)
+	}
+
+	before, err := services.Portfolios.SetFeeSchedule(c.Request().Context(), userId, portId, &models.FeeScheduleModel{
+		Flat:            body.Flat,
+		PerShare:        body.PerShare,
+		Rate:            body.Rate,
+		MinCommission:   body.MinCommission,
+		MaxCommission:   body.MaxCommission,
+		SellRate:        body.SellRate,
+		SellPerShare:    body.SellPerShare,
+		SellPerShareMax: body.SellPerShareMax,
+	})
+	if err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to update the fee schedule")
+	}
+	after, err := services.Portfolios.FeeSchedule(c.Request().Context(), userId, portId)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "failed to get the fee schedule")
+	}
+
+	audit.Record(c, audit.ActionFeeScheduleUpdate, "portfolio", portId, before, after)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":     types.StatusOK,
+		"feeSchedule": after,
+	})
+}
+
+func GetMarginStatus(c echo.Context) error {
+	userId := c.Get("userId").(string)
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+	portId := c.Param("id")
+	if portId == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "portfolio ID is required", nil)
+	}
+
+	status, err := services.Portfolios.Margin(c.Request().Context(), userId, portId)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "failed to get the margin status")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": types.StatusOK,
+		"margin":  status,
+	})
+}
+
+func UpdateMargin(c echo.Context) error {
+	userId := c.Get("userId").(string)
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+	portId := c.Param("id")
+	if portId == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "portfolio ID is required", nil)
+	}
+
+	var body dto.MarginDTO
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the margin flag", err)
+	}
+
+	before, err := services.Portfolios.SetMargin(c.Request().Context(), userId, portId, body.Margin)
+	if err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to change the margin account")
+	}
+
+	audit.Record(c, audit.ActionMarginChange, "portfolio", portId,
+		map[string]bool{"margin": before.Margin}, map[string]bool{"margin": body.Margin})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": types.StatusOK,
+		"margin":  body.Margin,
 	})
 }
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+)
+
+/*
+ImportPrices - Loads daily price history from an uploaded csv or Alpha Vantage json file
+*/
+
+const priceImportLimit = 32 << 20
+
+// ImportPrices reads the file from a multipart "file" field or, for any
+// other content type, the request body. The query says how to read it:
+// format (csv or alphavantage, csv by default) and, for csv, columns
+// (field=header pairs such as "date=Date,close=Adj Close"), dateFormat (a Go
+// time layout) and symbol (for files without a symbol column).
+func ImportPrices(c echo.Context) error {
+	columns, err := marketdata.ParseColumns(c.QueryParam("columns"))
+	if err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, err.Error(), nil)
+	}
+	opts := service.PriceImport{
+		Format: c.QueryParam("format"),
+		CSV: marketdata.BarCSV{
+			Columns:    columns,
+			DateLayout: c.QueryParam("dateFormat"),
+			Symbol:     c.QueryParam("symbol"),
+		},
+	}
+	if opts.Format == "" {
+		opts.Format = service.ImportFormatCSV
+	}
+
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, priceImportLimit)
+
+	var body io.Reader = req.Body
+	if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return importReadError(err)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to read the uploaded file", err)
+		}
+		defer file.Close()
+		body = file
+	}
+
+	// read up front, so an oversized upload is a 413 rather than a parse error
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return importReadError(err)
+	}
+	if len(data) == 0 {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "the file is empty", nil)
+	}
+
+	report, err := services.Prices.Import(req.Context(), bytes.NewReader(data), opts)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to import the prices")
+	}
+
+	audit.Record(c, audit.ActionAdminPriceImport, "price_bar", "", nil, map[string]interface{}{
+		"format":   opts.Format,
+		"accepted": report.Accepted,
+		"rejected": report.Rejected,
+		"symbols":  report.Symbols,
+	})
+
+	return c.JSON(http.StatusOK, report)
+}
+
+func importReadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return util.NewAppError(http.StatusRequestEntityTooLarge, types.StatusRequestEntityTooLarge, "the file is larger than 32 MiB", err)
+	}
+	if errors.Is(err, http.ErrMissingFile) {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "file is required", err)
+	}
+	return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to read the upload", err)
+}
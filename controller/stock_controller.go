@@ -2,7 +2,6 @@ package controller
 
 import (
 	"net/http"
-	"os"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
@@ -11,7 +10,6 @@ import (
 	"github.com/pratyush934/tradealpha/server/models"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/pratyush934/tradealpha/server/util"
-	"github.com/rs/zerolog"
 )
 
 func CreateStock(c echo.Context) error {
@@ -32,14 +30,15 @@ func CreateStock(c echo.Context) error {
 		Name:           stockDto.Name,
 		Sector:         stockDto.Sector,
 		Price:          stockDto.Price,
+		Currency:       stockDto.Currency,
 		PortFolioStock: make([]models.PortFolioStock, 0),
 		Transaction:    make([]models.TransactionModel, 0),
 	}
 
-	stock, err := newStock.CreateStock()
+	stock := &newStock
 
-	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to create the stock", err)
+	if err := services.Stocks.Create(c.Request().Context(), stock); err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to create the stock")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -57,7 +56,7 @@ func GetStockById(c echo.Context) error {
 
 	stockId := c.Param("id")
 
-	stockById, err := models.GetStockById(stockId)
+	stockById, err := services.Stocks.Get(c.Request().Context(), stockId)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the stockById", err)
@@ -83,7 +82,7 @@ func GetStockBySector(c echo.Context) error {
 	limit, _ := strconv.Atoi(limitStr)
 	offSet, _ := strconv.Atoi(offSetStr)
 
-	stockBySector, err := models.GetStockBySector(sector, limit, offSet)
+	stockBySector, err := services.Stocks.ListBySector(c.Request().Context(), sector, limit, offSet)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the stockBySector", err)
@@ -107,7 +106,7 @@ func GetAllStocks(c echo.Context) error {
 	limit, _ := strconv.Atoi(limitStr)
 	offSet, _ := strconv.Atoi(offSetStr)
 
-	getAllStocks, err := models.GetAllStocks(limit, offSet)
+	getAllStocks, err := services.Stocks.List(c.Request().Context(), limit, offSet)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get all the stocks", err)
@@ -134,16 +133,7 @@ func UpdateStock(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the stockDTO", nil)
 	}
 
-	stockById, err := models.GetStockById(stockId)
-
-	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the stockById", err)
-	}
-	stockById.Name = stockDTO.Name
-	stockById.Price = stockDTO.Price
-	stockById.Sector = stockDTO.Sector
-
-	if err := models.UpdateStock(*stockById); err != nil {
+	if err := services.Stocks.Update(c.Request().Context(), stockId, stockDTO.Name, stockDTO.Sector, stockDTO.Price); err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the UpdateStock", err)
 	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -160,7 +150,7 @@ func DeleteStock(c echo.Context) error {
 
 	stockId := c.Param("stockId")
 
-	if err := models.DeleteStock(stockId); err != nil {
+	if err := services.Stocks.Delete(c.Request().Context(), stockId); err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to delete the stock", err)
 	}
 
@@ -170,6 +160,42 @@ func DeleteStock(c echo.Context) error {
 
 }
 
+// GetStockQuote returns the latest quote of a symbol with the provider that
+// served it.
+func GetStockQuote(c echo.Context) error {
+	symbol := c.Param("symbol")
+	if symbol == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Symbol parameter is required", nil)
+	}
+
+	quote, err := services.Stocks.Quote(c.Request().Context(), symbol)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "Failed to fetch stock quote")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": types.StatusOK,
+		"quote":   quote,
+	})
+}
+
+// GetStockHistory returns the stored daily bars of a symbol, adjusted for
+// splits unless adjusted=false.
+func GetStockHistory(c echo.Context) error {
+	adjusted := c.QueryParam("adjusted") != "false"
+
+	bars, err := services.Prices.History(c.Request().Context(), c.Param("symbol"), c.QueryParam("from"), c.QueryParam("to"), adjusted)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "Failed to fetch the price history")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":  types.StatusOK,
+		"adjusted": adjusted,
+		"bars":     bars,
+	})
+}
+
 func GetStockBySymbol(c echo.Context) error {
 	userId := c.Get("userId").(string)
 
@@ -179,7 +205,7 @@ func GetStockBySymbol(c echo.Context) error {
 
 	stockSymbol := c.Param("symbol")
 
-	stockBySymbol, err := models.GetStockBySymbol(stockSymbol)
+	stockBySymbol, err := services.Stocks.GetBySymbol(c.Request().Context(), stockSymbol)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the stock by Symbol", err)
@@ -196,8 +222,7 @@ func FetchAndCacheStockHandler(c echo.Context) error {
 		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
 	}
 	symbol := c.Param("symbol")
-	logger := *c.Get("logger").(*zerolog.Logger)
-	stock, err := models.FetchAndCacheStock(symbol, &logger)
+	stock, err := services.Stocks.FetchAndCache(c.Request().Context(), symbol)
 	if err != nil {
 		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to fetch and cache stock", err)
 	}
@@ -213,10 +238,9 @@ func GetDailyMoversHandler(c echo.Context) error {
 	//	return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
 	//}
 
-	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
-	movers, err := alphavantage.FetchDailyMovers(&logger)
+	movers, err := services.Stocks.DailyMovers(c.Request().Context())
 	if err != nil {
-		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to fetch market movers", err)
+		return err // AppError already set
 	}
 
 	// Split into gainers (top 10 positive) and losers (top 10 negative)
@@ -1,15 +1,15 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
 	"github.com/pratyush934/tradealpha/server/dto"
-	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/service"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/pratyush934/tradealpha/server/util"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
 func CreateTransaction(c echo.Context) error {
@@ -26,63 +26,16 @@ func CreateTransaction(c echo.Context) error {
 	if err := c.Bind(&transaction); err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the transaction", err)
 	}
-	if transaction.Quantity <= 0 || (transaction.Type != "buy" && transaction.Type != "sell") {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "invalid quantity or type", nil)
-	}
-	newTransaction := models.TransactionModel{
-		UserId:      userId,
-		StockId:     stockId,
-		PortFolioId: portId,
-		Quantity:    transaction.Quantity,
-		Status:      transaction.Status,
-		Type:        transaction.Type,
-	}
 
-	logger := *c.Get("logger").(*zerolog.Logger)
-
-	createTransaction, err := newTransaction.CreateTransaction(&logger)
-	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to create transaction", err)
-	}
-	if err := NotifyOnTransaction(createTransaction); err != nil {
-		log.Error().Err(err).Msg("Failed to create notification")
-	}
-	// Update PortfolioStock
-	portStocks, err := models.GetPortfolioStockByStockIdAndPortfolioId(stockId, portId)
+	// Place rebuilds the holding (quantity, lots, average price, realized
+	// gains) from the trade history in the same DB transaction
+	createTransaction, err := services.Trades.Place(c.Request().Context(), userId, portId, stockId, transaction.Quantity, transaction.Amount, transaction.Type)
 	if err != nil {
-		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to fetch portfolio stock", err)
-	}
-	var portStock *models.PortFolioStock
-	if len(*portStocks) == 0 {
-		portStock = &models.PortFolioStock{
-			StockId:      stockId,
-			PortFolioId:  portId,
-			Quantity:     transaction.Quantity,
-			AveragePrice: createTransaction.Price,
-		}
-		if _, err := portStock.CreatePortFolioStock(); err != nil {
-			return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to create portfolio stock", err)
-		}
-	} else {
-		portStock = &(*portStocks)[0]
-		if transaction.Type == "buy" {
-			newQty := portStock.Quantity + transaction.Quantity
-			portStock.AveragePrice = (portStock.AveragePrice*float64(portStock.Quantity) + createTransaction.Price*float64(transaction.Quantity)) / float64(newQty)
-			portStock.Quantity = newQty
-		} else { // sell
-			if portStock.Quantity < transaction.Quantity {
-				return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "insufficient stock quantity", nil)
-			}
-			portStock.Quantity -= transaction.Quantity
-		}
-		if err := models.UpdatePortfolioStock(portStock); err != nil {
-			return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to update portfolio stock", err)
-		}
-	}
-	// Update portfolio total value
-	if err := models.UpdateTotalValue(portId); err != nil {
-		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "failed to update portfolio total value", err)
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to create transaction")
 	}
+
+	audit.Record(c, audit.ActionTradeCreate, "transaction", createTransaction.Id, nil, createTransaction)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"transaction": createTransaction,
 	})
@@ -95,7 +48,7 @@ func GetTransactionByUserId(c echo.Context) error {
 		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
 	}
 
-	transactionsByUserId, err := models.GetTransactionsByUserId(userId)
+	transactionsByUserId, err := services.Trades.ListByUser(c.Request().Context(), userId)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the transaction", err)
@@ -119,10 +72,10 @@ func GetPortFolioTransactionById(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "transactionId is empty", nil)
 	}
 
-	transactionById, err := models.GetTransactionById(transactionId)
+	transactionById, err := services.Trades.Get(c.Request().Context(), userId, transactionId)
 
 	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the transactionId", err)
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to get the transactionId")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -143,7 +96,7 @@ func GetPortFolioTransactionByStockId(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "transactionId is empty", nil)
 	}
 
-	transactionById, err := models.GetTransactionsByStockId(stockId)
+	transactionById, err := services.Trades.ListByStock(c.Request().Context(), userId, stockId)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the transactionId", err)
@@ -167,10 +120,10 @@ func GetPortFolioTransactionByPortFolioId(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "transactionId is empty", nil)
 	}
 
-	transactionById, err := models.GetTransactionsByPortfolioId(portId)
+	transactionById, err := services.Trades.ListByPortfolio(c.Request().Context(), userId, portId)
 
 	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the transactionId", err)
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to get the transactionId")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -178,69 +131,93 @@ func GetPortFolioTransactionByPortFolioId(c echo.Context) error {
 	})
 }
 
+// DeleteTransactionByUserId no longer erases history, it books a reversal
+// entry that cancels the transaction and rebuilds the holding.
 func DeleteTransactionByUserId(c echo.Context) error {
-	userId := c.Get("userId").(string)
-
-	if userId == "" {
-		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	userId, transactionId, err := transactionParams(c)
+	if err != nil {
+		return err
 	}
 
-	transactionId := c.Param("transId")
-
-	if transactionId == "" {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "transactionId is empty", nil)
+	reversal, err := services.Trades.Reverse(c.Request().Context(), userId, transactionId, c.QueryParam("note"))
+	if err != nil {
+		return correctionError(err)
 	}
 
-	if err := models.DeleteTransactionById(transactionId); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to delete the transactions", err)
-	}
+	audit.Record(c, audit.ActionTransactionDelete, "transaction", transactionId, nil, reversal)
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "DeletedSuccessfully",
+		"message":  "transaction reversed",
+		"reversal": reversal,
 	})
 }
 
+// UpdateTransaction corrects an executed transaction with a reversal plus a
+// replacement entry booked at the original trade date.
 func UpdateTransaction(c echo.Context) error {
-	userId := c.Get("userId").(string)
-
-	if userId == "" {
-		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	userId, transactionId, err := transactionParams(c)
+	if err != nil {
+		return err
 	}
 
-	transactionId := c.Param("transId")
+	var transaction dto.TransactionCorrectionDTO
 
-	if transactionId == "" {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "transactionId is empty", nil)
+	if err := c.Bind(&transaction); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the transaction", err)
 	}
 
-	var transaction dto.TransactionDTO
-
-	if err := c.Bind(&transaction); err != nil {
-		return util.NewAppError(http.StatusNotFound, types.StatusNotFound, "not able to bind the transaction", err)
+	original, reversal, replacement, err := services.Trades.Correct(c.Request().Context(), userId, transactionId,
+		transaction.Quantity, transaction.Price, transaction.Type, transaction.Note)
+	if err != nil {
+		return correctionError(err)
 	}
 
-	transactionById, err := models.GetTransactionById(transactionId)
+	audit.Record(c, audit.ActionTransactionUpdate, "transaction", original.Id, original, replacement)
 
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":     "transaction corrected",
+		"reversal":    reversal,
+		"replacement": replacement,
+	})
+}
+
+func GetTransactionChain(c echo.Context) error {
+	userId, transactionId, err := transactionParams(c)
 	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the transactionById", err)
+		return err
 	}
 
-	if transactionById.UserId != userId {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not the correct user", nil)
+	chain, err := services.Trades.Chain(c.Request().Context(), userId, transactionId)
+	if err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to get the correction chain")
 	}
 
-	transactionById.Type = transaction.Type
-	transactionById.Status = transaction.Status
-	transactionById.Quantity = transaction.Quantity
-	transactionById.Price = transaction.Price
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"chain": chain,
+	})
+}
+
+func transactionParams(c echo.Context) (string, string, error) {
+	userId := c.Get("userId").(string)
 
-	if err := models.UpdateTransaction(transactionById); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to update the transaction", err)
+	if userId == "" {
+		return "", "", util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "updated transaction",
-	})
+	transactionId := c.Param("transId")
+
+	if transactionId == "" {
+		return "", "", util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "transactionId is empty", nil)
+	}
+
+	return userId, transactionId, nil
+}
+
+func correctionError(err error) error {
+	if errors.Is(err, service.ErrInsufficientHoldings) {
+		return util.NewAppError(http.StatusConflict, types.StatusConflict, "the correction would leave a negative position", err)
+	}
+	return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to correct the transaction")
 }
 
 func GetTransactionsByStockId(c echo.Context) error {
@@ -253,7 +230,7 @@ func GetTransactionsByStockId(c echo.Context) error {
 
 	stockId := c.Param("stockId")
 
-	byStockId, err := models.GetTransactionsByStockId(stockId)
+	byStockId, err := services.Trades.ListByStock(c.Request().Context(), userId, stockId)
 
 	if err != nil {
 		return util.NewAppError(http.StatusOK, types.StatusBadRequest, "not able to get transaction by stockId", err)
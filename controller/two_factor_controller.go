@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
+	"github.com/pratyush934/tradealpha/server/dto"
+	"github.com/pratyush934/tradealpha/server/jwtpackage"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+)
+
+/*
+EnrollTwoFactor - Create a pending TOTP secret and provisioning URI
+ConfirmTwoFactor - Verify the first code, enable 2FA and issue recovery codes
+VerifyTwoFactor - Step-up, exchange a code for a token carrying mfa_at
+DisableTwoFactor - Turn 2FA off (needs a valid code)
+RegenerateRecoveryCodes - Replace the recovery codes
+*/
+
+const totpIssuer = "TradeAlpha"
+
+func EnrollTwoFactor(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	secret, err := services.Users.EnrollTwoFactor(c.Request().Context(), user)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to store the secret")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"secret":          secret,
+		"provisioningUri": util.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	})
+}
+
+func ConfirmTwoFactor(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var body dto.TwoFactorDTO
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the code", err)
+	}
+
+	codes, err := services.Users.ConfirmTwoFactor(c.Request().Context(), user, body.Code)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to enable two factor")
+	}
+
+	audit.Record(c, audit.ActionTwoFactorEnable, "user", user.Id, nil, nil)
+	token, err := auth.CreateStepUpToken(user)
+	if err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to create the token", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":       "two factor enabled, store the recovery codes now",
+		"recoveryCodes": codes,
+		"token":         token,
+	})
+}
+
+func VerifyTwoFactor(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var body dto.TwoFactorDTO
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the code", err)
+	}
+
+	if err := checkSecondFactor(c, user, body); err != nil {
+		return err
+	}
+
+	token, err := auth.CreateStepUpToken(user)
+	if err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to create the token", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":     token,
+		"expiresIn": int(jwtpackage.StepUpWindow.Seconds()),
+	})
+}
+
+func DisableTwoFactor(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var body dto.TwoFactorDTO
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the code", err)
+	}
+
+	if err := checkSecondFactor(c, user, body); err != nil {
+		return err
+	}
+
+	if err := services.Users.DisableTwoFactor(c.Request().Context(), user.Id); err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to disable two factor", err)
+	}
+
+	audit.Record(c, audit.ActionTwoFactorDisable, "user", user.Id, nil, nil)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "two factor disabled",
+	})
+}
+
+func RegenerateRecoveryCodes(c echo.Context) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var body dto.TwoFactorDTO
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the code", err)
+	}
+
+	if err := checkSecondFactor(c, user, body); err != nil {
+		return err
+	}
+
+	codes, err := services.Users.RegenerateRecoveryCodes(c.Request().Context(), user.Id)
+	if err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to create recovery codes", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"recoveryCodes": codes,
+	})
+}
+
+// checkSecondFactor accepts either a TOTP code (rejecting replays) or an
+// unused recovery code.
+func checkSecondFactor(c echo.Context, user *models.User, body dto.TwoFactorDTO) error {
+	err := services.Users.CheckSecondFactor(c.Request().Context(), user, body.Code, body.RecoveryCode)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to check the two factor code")
+	}
+	return nil
+}
+
+func currentUser(c echo.Context) (*models.User, error) {
+	userId := c.Get("userId").(string)
+
+	if userId == "" {
+		return nil, util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+
+	user, err := services.Users.GetSummary(c.Request().Context(), userId)
+	if err != nil {
+		return nil, util.NewAppError(http.StatusNotFound, types.StatusNotFound, "not able to get the user", err)
+	}
+	return user, nil
+}
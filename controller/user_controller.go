@@ -3,10 +3,11 @@ package controller
 import (
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
 	"github.com/pratyush934/tradealpha/server/dto"
+	"github.com/pratyush934/tradealpha/server/jwtpackage"
 	"github.com/pratyush934/tradealpha/server/models"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/pratyush934/tradealpha/server/util"
@@ -20,7 +21,7 @@ func GetUsersById(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Please add user id as it is not there", nil)
 	}
 
-	byId, err := models.GetUserById(id)
+	byId, err := services.Users.Get(c.Request().Context(), id)
 
 	if err != nil {
 		return util.NewAppError(http.StatusNotFound, types.StatusNotFound, "Not able to get the user via id", err)
@@ -41,7 +42,7 @@ func GetUserByEmail(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Please add the email", nil)
 	}
 
-	byEmail, err := models.GetUserByEmail(email)
+	byEmail, err := services.Users.GetByEmail(c.Request().Context(), email)
 
 	if err != nil {
 		return util.NewAppError(http.StatusNotFound, types.StatusNotFound, "Not able to get the user via email", err)
@@ -53,27 +54,27 @@ func GetUserByEmail(c echo.Context) error {
 	})
 }
 
+// DeleteUser schedules the caller's account for hard deletion after
+// models.AccountDeletionGrace, reactivating before then cancels it.
 func DeleteUser(c echo.Context) error {
 
-	role := c.Get("role").(int)
-
-	if role != 2 {
-		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not an admin", nil)
-	}
-
 	userId := c.Get("userId").(string)
 
 	if userId == "" {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the userId", nil)
 	}
 
-	if err := models.DeleteUserById(userId); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to delete the user", nil)
+	deleteAt, err := services.Users.ScheduleDeletion(c.Request().Context(), userId)
+	if err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to delete the user", err)
 	}
 
+	audit.Record(c, audit.ActionAccountDelete, "user", userId, nil, map[string]interface{}{"deleteAt": deleteAt})
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "User deleted",
-		"status":  types.StatusOK,
+		"message":  "User scheduled for deletion",
+		"deleteAt": deleteAt,
+		"status":   types.StatusOK,
 	})
 }
 
@@ -94,7 +95,7 @@ func GetAllUsersByAdmin(c echo.Context) error {
 	limit, _ = strconv.Atoi(limitStr)
 	offSet, _ = strconv.Atoi(offSetStr)
 
-	allUsers, err := models.GetAllUsers(limit, offSet)
+	allUsers, err := services.Users.List(c.Request().Context(), limit, offSet)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to fetch the user", err)
@@ -112,7 +113,7 @@ func GetUserPortfolios(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the userId in GetUserPortfolios", nil)
 	}
 
-	portfolio, err := models.GetPortFolioByUserId(userId)
+	portfolio, err := services.Portfolios.ListByUser(c.Request().Context(), userId)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the portfolio", err)
@@ -130,7 +131,7 @@ func GetUserTransactions(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the userId in GetUserTransaction", nil)
 	}
 
-	transactionsByUserId, err := models.GetTransactionsByUserId(userId)
+	transactionsByUserId, err := services.Trades.ListByUser(c.Request().Context(), userId)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the transactionsById", err)
@@ -149,7 +150,7 @@ func GetUserNotifications(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the userId", nil)
 	}
 
-	notificationByUserId, err := models.GetNotificationByUserId(userId)
+	notificationByUserId, err := services.Notifications.ListByUser(c.Request().Context(), userId)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the notification with userid", err)
@@ -160,15 +161,44 @@ func GetUserNotifications(c echo.Context) error {
 	})
 }
 
+// UpdateUserVerificationStatus mails a signed, expiring verification link
+// to the caller, the flag itself is only flipped by VerifyEmail.
 func UpdateUserVerificationStatus(c echo.Context) error {
+	userId := c.Get("userId").(string)
 	email := c.Get("email").(string)
 
-	if email == "" {
+	if userId == "" || email == "" {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the email", nil)
 	}
 
-	if err := models.UpdateUserVerificationStatus(email, true); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to update verification", err)
+	token, err := auth.CreatePurposeToken(userId, email, jwtpackage.PurposeEmailVerification, jwtpackage.EmailVerificationTTL)
+	if err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to create the verification token", err)
+	}
+
+	if err := services.Users.SendVerification(c.Request().Context(), email, token); err != nil {
+		return util.NewAppError(http.StatusBadGateway, types.StatusBadGateway, "not able to send the verification email", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "verification email sent",
+	})
+}
+
+func VerifyEmail(c echo.Context) error {
+	token := c.QueryParam("token")
+
+	if token == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "token is required", nil)
+	}
+
+	userId, email, err := auth.ParsePurposeToken(token, jwtpackage.PurposeEmailVerification)
+	if err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "verification link is invalid or expired", err)
+	}
+
+	if err := services.Users.MarkVerified(c.Request().Context(), userId, email); err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to update verification")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -176,6 +206,78 @@ func UpdateUserVerificationStatus(c echo.Context) error {
 	})
 }
 
+func DeactivateAccount(c echo.Context) error {
+	userId := c.Get("userId").(string)
+
+	if userId == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the userId", nil)
+	}
+
+	if err := services.Users.Deactivate(c.Request().Context(), userId); err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to deactivate the account", err)
+	}
+
+	audit.Record(c, audit.ActionAccountDeactivate, "user", userId, nil, nil)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "account deactivated",
+	})
+}
+
+func ReactivateAccount(c echo.Context) error {
+	userId := c.Get("userId").(string)
+
+	if userId == "" {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the userId", nil)
+	}
+
+	if err := services.Users.Reactivate(c.Request().Context(), userId); err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to reactivate the account", err)
+	}
+
+	audit.Record(c, audit.ActionAccountReactivate, "user", userId, nil, nil)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "account reactivated",
+	})
+}
+
+func SuspendUserByAdmin(c echo.Context) error {
+	targetId := c.Param("id")
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the reason", err)
+	}
+
+	if err := services.Users.Suspend(c.Request().Context(), c.Get("userId").(string), targetId, body.Reason); err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to suspend the user")
+	}
+
+	audit.Record(c, audit.ActionAdminSuspend, "user", targetId, nil, map[string]interface{}{"reason": body.Reason})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "user suspended",
+	})
+}
+
+func UnsuspendUserByAdmin(c echo.Context) error {
+	targetId := c.Param("id")
+
+	if err := services.Users.Unsuspend(c.Request().Context(), targetId); err != nil {
+		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to unsuspend the user", err)
+	}
+
+	audit.Record(c, audit.ActionAdminUnsuspend, "user", targetId, nil, nil)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "user unsuspended",
+	})
+}
+
 /*
 GetAddresses - List user addresses
 AddAddress - Create new user address
@@ -191,7 +293,7 @@ func GetUserAddress(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the userid", nil)
 	}
 
-	address, err := models.GetAddressByUserId(userid)
+	address, err := services.Users.Addresses(c.Request().Context(), userid)
 
 	if err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the address", nil)
@@ -226,15 +328,13 @@ func AddAddress(c echo.Context) error {
 		Country: addressModel.Country,
 	}
 
-	address, err := newAddress.CreateAddress()
-
-	if err != nil {
+	if err := services.Users.AddAddress(c.Request().Context(), &newAddress); err != nil {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to create address", nil)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": types.StatusOK,
-		"address": address,
+		"address": newAddress,
 	})
 }
 
@@ -263,27 +363,17 @@ func UpdateAddress(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "please provide addressId", nil)
 	}
 
-	existingAddress, err := models.GetAddressByAddressId(updateRequest.AddressID)
+	updatedAdd, err := services.Users.UpdateAddress(c.Request().Context(), userid, models.AddressModel{
+		Id:      updateRequest.AddressID,
+		Street:  updateRequest.StreetName,
+		ZipCode: updateRequest.ZipCode,
+		City:    updateRequest.City,
+		State:   updateRequest.State,
+		Country: updateRequest.Country,
+	})
 
 	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the existing address", err)
-	}
-
-	if existingAddress.UserId != userid {
-		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to update the userid", nil)
-	}
-
-	existingAddress.Street = updateRequest.StreetName
-	existingAddress.ZipCode = updateRequest.ZipCode
-	existingAddress.City = updateRequest.City
-	existingAddress.State = updateRequest.State
-	existingAddress.Country = updateRequest.Country
-	existingAddress.UpdatedAt = time.Now()
-
-	var updatedAdd *models.AddressModel
-
-	if updatedAdd, err = models.UpdateAddress(existingAddress); err != nil {
-		return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "not able to update the address", err)
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to update the address")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -304,12 +394,95 @@ func DeleteAddress(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "address id not provided", nil)
 	}
 
-	if err := models.DeleteAddressByAddressId(id); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to delete the address", err)
+	if err := services.Users.DeleteAddress(c.Request().Context(), userid, id); err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to delete the address")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": types.StatusOK,
+	})
+
+}
+
+func WithdrawCash(c echo.Context) error {
+	userId := c.Get("userId").(string)
+
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+
+	var cash dto.CashDTO
+	if err := c.Bind(&cash); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the amount", err)
+	}
+
+	if err := services.Users.Withdraw(c.Request().Context(), userId, cash.Amount); err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to withdraw")
 	}
 
+	audit.Record(c, audit.ActionCashWithdraw, "user", userId, nil, map[string]interface{}{"amount": cash.Amount})
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": types.StatusOK,
+		"amount":  cash.Amount,
 	})
+}
+
+// ChangeBaseCurrency converts the cash balance to the requested currency at
+// today's rate.
+func ChangeBaseCurrency(c echo.Context) error {
+	userId := c.Get("userId").(string)
+
+	if userId == "" {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "not able to get the userId", nil)
+	}
+
+	var body dto.CurrencyDTO
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the currency", err)
+	}
+
+	before, err := services.Users.ChangeBaseCurrency(c.Request().Context(), userId, body.Currency)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to change the currency")
+	}
+	after, err := services.Users.GetSummary(c.Request().Context(), userId)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to get the user")
+	}
+
+	audit.Record(c, audit.ActionCurrencyChange, "user", userId,
+		map[string]interface{}{"baseCurrency": before.BaseCurrency, "accountBalance": before.AccountBalance},
+		map[string]interface{}{"baseCurrency": after.BaseCurrency, "accountBalance": after.AccountBalance})
 
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":        types.StatusOK,
+		"baseCurrency":   after.BaseCurrency,
+		"accountBalance": after.AccountBalance,
+	})
+}
+
+func ChangeUserRoleByAdmin(c echo.Context) error {
+	targetId := c.Param("id")
+
+	var body struct {
+		RoleId int `json:"roleId"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to bind the role", err)
+	}
+
+	previousRole, err := services.Users.ChangeRole(c.Request().Context(), targetId, body.RoleId)
+	if err != nil {
+		return serviceError(err, http.StatusInternalServerError, types.StatusInternalServerError, "not able to change the role")
+	}
+
+	audit.Record(c, audit.ActionAdminRoleChange, "user", targetId,
+		map[string]interface{}{"roleId": previousRole},
+		map[string]interface{}{"roleId": body.RoleId})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "role updated",
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/audit"
 	"github.com/pratyush934/tradealpha/server/dto"
 	"github.com/pratyush934/tradealpha/server/models"
 	"github.com/pratyush934/tradealpha/server/types"
@@ -50,9 +51,9 @@ func CreateWatchListHandler(c echo.Context) error {
 		WatchListStock: make([]models.WatchListStockModel, 0),
 	}
 
-	watchListModel, err := newWatchDTO.Create()
+	watchListModel := &newWatchDTO
 
-	if err != nil {
+	if err := services.WatchLists.Create(c.Request().Context(), watchListModel); err != nil {
 		log.Error().Err(err).Msg("issue persist in the watchlist_controller while create")
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to create the stuff", err)
 	}
@@ -68,12 +69,13 @@ func GetWatchlistByIdHandler(c echo.Context) error {
 		return err
 	}
 
+	userId := c.Get("userId").(string)
 	watchId := c.Param("watchId")
 
-	watchListById, err := models.GetWatchListById(watchId)
+	watchListById, err := services.WatchLists.Get(c.Request().Context(), userId, watchId)
 
 	if err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the watchListById", err)
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to get the watchListById")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -87,16 +89,20 @@ func DeleteWatchlistHandler(c echo.Context) error {
 		return err
 	}
 
+	userId := c.Get("userId").(string)
 	watchId := c.Param("watchId")
 
 	if watchId == "" {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to get the watchId", nil)
 	}
 
-	if err := models.DeleteWatchList(watchId); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to delete the watchList", err)
+	before, err := services.WatchLists.Delete(c.Request().Context(), userId, watchId)
+	if err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to delete the watchList")
 	}
 
+	audit.Record(c, audit.ActionWatchlistDelete, "watchlist", watchId, before, nil)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "Deleted successfully",
 	})
@@ -114,8 +120,10 @@ func AddStockToWatchlistHandler(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadGateway, "not able to get the watchId or stockId", nil)
 	}
 
-	if err := models.AddStockToWatchlist(watchId, symbol); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to add the stock to watchlist", err)
+	userId := c.Get("userId").(string)
+
+	if err := services.WatchLists.AddStock(c.Request().Context(), userId, watchId, symbol); err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to add the stock to watchlist")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -136,8 +144,10 @@ func RemoveStockFromWatchlistHandler(c echo.Context) error {
 		return util.NewAppError(http.StatusBadRequest, types.StatusBadGateway, "not able to get the watchId or stockId", nil)
 	}
 
-	if err := models.RemoveStockFromWatchlist(watchId, symbol); err != nil {
-		return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "not able to add the stock to watchlist", err)
+	userId := c.Get("userId").(string)
+
+	if err := services.WatchLists.RemoveStock(c.Request().Context(), userId, watchId, symbol); err != nil {
+		return serviceError(err, http.StatusBadRequest, types.StatusBadRequest, "not able to add the stock to watchlist")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -1,16 +1,25 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/glebarez/sqlite"
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/metrics"
+	"github.com/pratyush934/tradealpha/server/tracing"
 	"github.com/rs/zerolog/log"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func InitDB() error {
+func InitDB(cfg config.DatabaseConfig) error {
 
-	db, err := connectingDB()
+	db, err := connectingDB(cfg)
 
 	if err != nil {
 		log.Error().Err(err).Msg("Not able to connect the DB, please Look at the db.go/InitDB")
@@ -20,14 +29,98 @@ func InitDB() error {
 	return nil
 }
 
-func connectingDB() (*gorm.DB, error) {
-	dsn := "root:Pratyush@123@tcp(127.0.0.1:3306)/tradealpha?charset=utf8mb4&parseTime=True&loc=Local"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// dialector picks the gorm driver for cfg.Driver. SQLite is the pure Go
+// build, so it needs no cgo or external service.
+func dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case config.DriverMySQL:
+		return mysql.Open(cfg.MySQLDSN()), nil
+	case config.DriverPostgres:
+		return postgres.Open(cfg.PostgresDSN()), nil
+	case config.DriverSQLite:
+		return sqlite.Open(cfg.SQLiteDSN()), nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+}
+
+func connectingDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dial, err := dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// TranslateError turns each driver's unique violation into
+	// gorm.ErrDuplicatedKey, which the services map to their own errors
+	db, err := gorm.Open(dial, &gorm.Config{TranslateError: true})
 
 	if err != nil {
 		log.Error().Err(err).Msg("Not able to connect the database")
 		return nil, err
 	}
 
+	if err := db.Use(metrics.GormPlugin{DBName: cfg.Driver}); err != nil {
+		log.Error().Err(err).Msg("Not able to register the database metrics")
+		return nil, err
+	}
+
+	if err := db.Use(tracing.GormPlugin{Driver: cfg.Driver}); err != nil {
+		log.Error().Err(err).Msg("Not able to register the database tracing")
+		return nil, err
+	}
+
+	if err := registerQueryTimeout(db, cfg.QueryTimeout.Std()); err != nil {
+		log.Error().Err(err).Msg("Not able to register the query timeout")
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Error().Err(err).Msg("Not able to get the sql.DB pool")
+		return nil, err
+	}
+
+	if cfg.Driver == config.DriverSQLite {
+		// SQLite allows one writer, and one connection that never expires
+		// keeps an in-memory database alive for the life of the process
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+
+		if err := db.Exec("PRAGMA foreign_keys = ON").Error; err != nil {
+			log.Error().Err(err).Msg("Not able to enable sqlite foreign keys")
+			return nil, err
+		}
+		return db, nil
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime.Std())
+
 	return db, nil
 }
+
+// Close closes the connection pool behind DB, waiting for queries that are
+// still running.
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Ping checks that the database answers, for the readiness probe.
+func Ping(ctx context.Context) error {
+	if DB == nil {
+		return errors.New("database is not connected")
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
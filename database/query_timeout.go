@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const queryCancelKey = "tradealpha:query_cancel"
+
+// queryDeadline is what start leaves for stop: the cancel of the timeout
+// and the context the statement had before it.
+type queryDeadline struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// registerQueryTimeout bounds every create, query, update and delete with
+// timeout, on top of whatever ctx the caller passed via WithContext.
+//
+// The deadline covers the statement with its preloads and associations but
+// not gorm's implicit transaction around it, since a tx begun on a ctx that
+// is later cancelled gets rolled back by database/sql. Row and Raw are left
+// alone because their rows are read after the callbacks have run.
+func registerQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	start := func(tx *gorm.DB) {
+		parent := tx.Statement.Context
+		ctx := parent
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(queryCancelKey, queryDeadline{parent: parent, cancel: cancel})
+	}
+
+	// the statement outlives the call when a chain runs more than one
+	// finisher, Count then Find for instance, so the caller's ctx goes back
+	// on it rather than leaving the cancelled one for the next call
+	stop := func(tx *gorm.DB) {
+		if deadline, ok := tx.InstanceGet(queryCancelKey); ok {
+			deadline := deadline.(queryDeadline)
+			deadline.cancel()
+			tx.Statement.Context = deadline.parent
+		}
+	}
+
+	const (
+		begin  = "gorm:begin_transaction"
+		commit = "gorm:commit_or_rollback_transaction"
+	)
+
+	callbacks := db.Callback()
+	steps := []func() error{
+		func() error {
+			return callbacks.Create().After(begin).Before("gorm:create").Register("tradealpha:create_timeout", start)
+		},
+		func() error {
+			return callbacks.Create().After("gorm:save_after_associations").Before(commit).Register("tradealpha:create_timeout_done", stop)
+		},
+		func() error {
+			return callbacks.Query().Before("gorm:query").Register("tradealpha:query_timeout", start)
+		},
+		func() error {
+			return callbacks.Query().After("gorm:preload").Register("tradealpha:query_timeout_done", stop)
+		},
+		func() error {
+			return callbacks.Update().After(begin).Before("gorm:update").Register("tradealpha:update_timeout", start)
+		},
+		func() error {
+			return callbacks.Update().After("gorm:save_after_associations").Before(commit).Register("tradealpha:update_timeout_done", stop)
+		},
+		func() error {
+			return callbacks.Delete().After(begin).Before("gorm:delete").Register("tradealpha:delete_timeout", start)
+		},
+		func() error {
+			return callbacks.Delete().After("gorm:delete").Before(commit).Register("tradealpha:delete_timeout_done", stop)
+		},
+	}
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
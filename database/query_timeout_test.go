@@ -0,0 +1,74 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type timeoutRow struct {
+	Id   int
+	Kind string
+}
+
+func timeoutDB(t *testing.T, timeout time.Duration) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	if err := registerQueryTimeout(db, timeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&timeoutRow{}); err != nil {
+		t.Fatal(err)
+	}
+	rows := []timeoutRow{{Id: 1, Kind: "a"}, {Id: 2, Kind: "a"}, {Id: 3, Kind: "b"}}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQueryTimeoutChainedFinishers(t *testing.T) {
+	db := timeoutDB(t, 5*time.Second)
+
+	var deadlines []bool
+	if err := db.Callback().Query().After("tradealpha:query_timeout").Before("gorm:query").Register("test:deadline", func(tx *gorm.DB) {
+		_, ok := tx.Statement.Context.Deadline()
+		deadlines = append(deadlines, ok)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	query := db.Model(&timeoutRow{}).Where("kind = ?", "a")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if _, ok := query.Statement.Context.Deadline(); ok {
+		t.Fatal("the timeout was left on the chain after Count")
+	}
+
+	var rows []timeoutRow
+	if err := query.Order("id").Find(&rows).Error; err != nil {
+		t.Fatalf("Find after Count: %v", err)
+	}
+	if total != 2 || len(rows) != 2 {
+		t.Errorf("total = %d, rows = %d, want 2 and 2", total, len(rows))
+	}
+	if len(deadlines) != 2 || !deadlines[0] || !deadlines[1] {
+		t.Errorf("deadline set on each finisher = %v, want [true true]", deadlines)
+	}
+}
@@ -0,0 +1,8 @@
+package dto
+
+type APIKeyDTO struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+	RateLimit     int      `json:"rateLimit"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+import "github.com/shopspring/decimal"
+
+type CashDTO struct {
+	Amount decimal.Decimal `json:"amount"`
+}
+
+type CurrencyDTO struct {
+	Currency string `json:"currency"`
+}
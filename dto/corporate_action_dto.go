@@ -0,0 +1,14 @@
+package dto
+
+import "github.com/shopspring/decimal"
+
+// CorporateActionDTO is a split or dividend entered by an admin. Dates are
+// YYYY-MM-DD.
+type CorporateActionDTO struct {
+	Symbol  string          `json:"symbol"`
+	Type    string          `json:"type"`
+	ExDate  string          `json:"exDate"`
+	PayDate string          `json:"payDate"`
+	Ratio   decimal.Decimal `json:"ratio"`
+	Amount  decimal.Decimal `json:"amount"`
+}
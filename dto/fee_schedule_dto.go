@@ -0,0 +1,16 @@
+package dto
+
+import "github.com/shopspring/decimal"
+
+// FeeScheduleDTO sets what trades in a portfolio cost, amounts in its base
+// currency and rates as fractions of the trade value.
+type FeeScheduleDTO struct {
+	Flat            decimal.Decimal `json:"flat"`
+	PerShare        decimal.Decimal `json:"perShare"`
+	Rate            decimal.Decimal `json:"rate"`
+	MinCommission   decimal.Decimal `json:"minCommission"`
+	MaxCommission   decimal.Decimal `json:"maxCommission"`
+	SellRate        decimal.Decimal `json:"sellRate"`
+	SellPerShare    decimal.Decimal `json:"sellPerShare"`
+	SellPerShareMax decimal.Decimal `json:"sellPerShareMax"`
+}
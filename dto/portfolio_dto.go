@@ -16,7 +16,13 @@ type PortFolio struct {
 */
 
 type PortFolioDTO struct {
-	Name        string `json:"name"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Name         string `json:"name"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	BaseCurrency string `json:"baseCurrency"`
+}
+
+// MarginDTO makes a portfolio a margin account or an ordinary one.
+type MarginDTO struct {
+	Margin bool `json:"margin"`
 }
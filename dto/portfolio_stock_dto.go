@@ -1,6 +1,8 @@
 package dto
 
+import "github.com/shopspring/decimal"
+
 type PortFolioStockDTO struct {
-	Quantity     int     `json:"quantity"`
-	AveragePrice float64 `json:"averagePrice"`
+	Quantity     decimal.Decimal `json:"quantity"`
+	AveragePrice decimal.Decimal `json:"averagePrice"`
 }
@@ -1,7 +1,10 @@
 package dto
 
+import "github.com/shopspring/decimal"
+
 type StockDTO struct {
-	Name   string  `json:"name"`
-	Sector string  `json:"sector"`
-	Price  float64 `json:"price"`
+	Name     string          `json:"name"`
+	Sector   string          `json:"sector"`
+	Price    decimal.Decimal `json:"price"`
+	Currency string          `json:"currency"`
 }
@@ -1,8 +1,18 @@
 package dto
 
+import "github.com/shopspring/decimal"
+
 type TransactionDTO struct {
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"`
-	Status   string  `json:"status"`
-	Type     string  `json:"type"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Amount   decimal.Decimal `json:"amount"` // a notional order, in the portfolio's base currency, instead of a quantity
+	Price    decimal.Decimal `json:"price"`
+	Status   string          `json:"status"`
+	Type     string          `json:"type"`
+}
+
+type TransactionCorrectionDTO struct {
+	Quantity decimal.Decimal `json:"quantity"`
+	Price    decimal.Decimal `json:"price"`
+	Type     string          `json:"type"`
+	Note     string          `json:"note"`
 }
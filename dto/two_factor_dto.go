@@ -0,0 +1,6 @@
+package dto
+
+type TwoFactorDTO struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
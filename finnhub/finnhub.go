@@ -0,0 +1,145 @@
+package finnhub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/metrics"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultBaseURL = "https://finnhub.io/api/v1"
+
+	upstreamName = "finnhub"
+)
+
+// Provider serves quotes from Finnhub's /quote endpoint as a
+// marketdata.Provider.
+type Provider struct {
+	baseURL string
+	apiKey  string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// New builds a Provider that gives every call timeout.
+func New(cfg config.FinnhubConfig, timeout time.Duration) *Provider {
+	return &Provider{
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:  cfg.APIKey,
+		timeout: timeout,
+		client:  &http.Client{},
+	}
+}
+
+func (p *Provider) Name() string {
+	return upstreamName
+}
+
+// quoteResponse is the part of the /quote body a Quote needs. Finnhub has
+// no volume on a quote and answers a symbol it does not know with zeros
+// rather than an error. Error is set instead when the call was refused,
+// which some plans are with a 200.
+type quoteResponse struct {
+	Current   decimal.Decimal `json:"c"`
+	Timestamp int64           `json:"t"`
+	Error     string          `json:"error"`
+}
+
+func (p *Provider) Quote(ctx context.Context, symbol string) (quote *marketdata.Quote, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, upstreamName+" quote",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(http.MethodGet)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	body, err := p.get(ctx, "quote", neturl.Values{"symbol": {strings.ToUpper(symbol)}})
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeQuote(symbol, body)
+}
+
+// NormalizeQuote turns a /quote body into a Quote.
+func NormalizeQuote(symbol string, body []byte) (*marketdata.Quote, error) {
+	var raw quoteResponse
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("parse finnhub quote: %w", err)
+	}
+	if raw.Error != "" {
+		return nil, fmt.Errorf("finnhub quote: %s: %w", raw.Error, marketdata.ErrUnavailable)
+	}
+	if !raw.Current.IsPositive() || raw.Timestamp == 0 {
+		return nil, fmt.Errorf("finnhub: %s: %w", symbol, marketdata.ErrUnknownSymbol)
+	}
+
+	return &marketdata.Quote{
+		Symbol:     strings.ToUpper(symbol),
+		Price:      raw.Current,
+		TradingDay: time.Unix(raw.Timestamp, 0).UTC().Format(time.DateOnly),
+		Source:     upstreamName,
+	}, nil
+}
+
+// get calls the endpoint named by function with query and returns the body
+// of a 200. Anything else, the key being refused included, is
+// ErrUnavailable: the composite provider should move on to the next vendor.
+func (p *Provider) get(ctx context.Context, function string, query neturl.Values) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/"+function+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// in a header, so the key stays out of urls in errors and logs
+	req.Header.Set("X-Finnhub-Token", p.apiKey)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	started := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		metrics.ObserveUpstream(upstreamName, function, metrics.OutcomeHTTPFailure, time.Since(started))
+		return nil, fmt.Errorf("finnhub %s: %w: %w", function, marketdata.ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		metrics.ObserveUpstream(upstreamName, function, metrics.OutcomeHTTPFailure, time.Since(started))
+		return nil, fmt.Errorf("finnhub %s: %w: %w", function, marketdata.ErrUnavailable, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		metrics.ObserveUpstream(upstreamName, function, metrics.OutcomeThrottled, time.Since(started))
+	case resp.StatusCode != http.StatusOK:
+		metrics.ObserveUpstream(upstreamName, function, metrics.OutcomeHTTPFailure, time.Since(started))
+	default:
+		metrics.ObserveUpstream(upstreamName, function, metrics.OutcomeOK, time.Since(started))
+		return body, nil
+	}
+
+	var payload struct {
+		Error string `json:"error"`
+	}
+	message := fmt.Sprintf("status %d", resp.StatusCode)
+	if json.Unmarshal(body, &payload) == nil && payload.Error != "" {
+		message += ": " + payload.Error
+	}
+	return nil, fmt.Errorf("finnhub %s: %s: %w", function, message, marketdata.ErrUnavailable)
+}
@@ -0,0 +1,92 @@
+package finnhub
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/shopspring/decimal"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestNormalizeQuote(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    *marketdata.Quote
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			fixture: "quote.json",
+			want: &marketdata.Quote{
+				Symbol:     "AAPL",
+				Price:      decimal.RequireFromString("227.52"),
+				TradingDay: "2025-10-16",
+				Source:     "finnhub",
+			},
+		},
+		{name: "unknown symbol answered with zeros", fixture: "quote_unknown.json", wantErr: marketdata.ErrUnknownSymbol},
+		{name: "error payload", fixture: "quote_error.json", wantErr: marketdata.ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := NormalizeQuote("aapl", fixture(t, tt.fixture))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if quote.Symbol != tt.want.Symbol || !quote.Price.Equal(tt.want.Price) ||
+				quote.TradingDay != tt.want.TradingDay || quote.Source != tt.want.Source {
+				t.Fatalf("quote = %+v, want %+v", quote, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeQuoteMalformed(t *testing.T) {
+	_, err := NormalizeQuote("AAPL", []byte("<html>"))
+	if err == nil || errors.Is(err, marketdata.ErrUnknownSymbol) {
+		t.Fatalf("err = %v, want a parse error", err)
+	}
+}
+
+func TestQuoteRefused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Finnhub-Token") != "key" || r.URL.Query().Get("symbol") != "AAPL" {
+			t.Errorf("request %s with token %q", r.URL, r.Header.Get("X-Finnhub-Token"))
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write(fixture(t, "quote_error.json"))
+	}))
+	defer server.Close()
+
+	provider := New(config.FinnhubConfig{BaseURL: server.URL + "/", APIKey: "key"}, time.Second)
+	_, err := provider.Quote(t.Context(), "aapl")
+	if !errors.Is(err, marketdata.ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+	if !strings.Contains(err.Error(), "status 403: You don't have access") {
+		t.Fatalf("err = %v, want the status and message", err)
+	}
+}
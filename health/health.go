@@ -0,0 +1,192 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Version is the release being run, set at build time with
+// -ldflags "-X github.com/pratyush934/tradealpha/server/health.Version=v1.2.3".
+var Version = "dev"
+
+// checkTimeout bounds a single readiness check, a probe that hangs is as bad
+// as one that fails.
+const checkTimeout = 2 * time.Second
+
+// Check reports why a dependency is not usable, nil when it is.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker backs /healthz, /readyz and /status. Checks, status sections and
+// queues are registered by main once the pieces they look at exist.
+type Checker struct {
+	started     time.Time
+	fingerprint string
+
+	mu       sync.Mutex
+	checks   []namedCheck
+	sections map[string]func() interface{}
+	queues   map[string]func() int
+}
+
+func New(fingerprint string) *Checker {
+	return &Checker{
+		started:     time.Now(),
+		fingerprint: fingerprint,
+		sections:    make(map[string]func() interface{}),
+		queues:      make(map[string]func() int),
+	}
+}
+
+// AddCheck adds a dependency that has to be fine for /readyz to pass.
+func (h *Checker) AddCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// AddSection adds a block to /status, report is called on every request.
+func (h *Checker) AddSection(name string, report func() interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sections[name] = report
+}
+
+// AddQueue adds a queue whose depth is shown on /status.
+func (h *Checker) AddQueue(name string, depth func() int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queues[name] = depth
+}
+
+// CheckResult is the outcome of one readiness check.
+type CheckResult struct {
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Run runs every check concurrently, each under its own timeout, and
+// reports whether all of them passed.
+func (h *Checker) Run(ctx context.Context) (bool, map[string]CheckResult) {
+	h.mu.Lock()
+	checks := append([]namedCheck(nil), h.checks...)
+	h.mu.Unlock()
+
+	results := make(map[string]CheckResult, len(checks))
+	var (
+		wg    sync.WaitGroup
+		resMu sync.Mutex
+	)
+
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			started := time.Now()
+			err := c.check(checkCtx)
+
+			result := CheckResult{OK: err == nil, Duration: time.Since(started).Round(time.Millisecond).String()}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			resMu.Lock()
+			results[c.name] = result
+			resMu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	ok := true
+	for _, r := range results {
+		ok = ok && r.OK
+	}
+	return ok, results
+}
+
+// Liveness only says the process is serving requests, it never looks at
+// dependencies so a database outage does not get every instance restarted.
+func (h *Checker) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "ok",
+	})
+}
+
+// Readiness answers 503 while any check fails, so the instance is taken out
+// of rotation until it recovers.
+func (h *Checker) Readiness(c echo.Context) error {
+	ok, results := h.Run(c.Request().Context())
+
+	status, code := http.StatusOK, "ready"
+	if !ok {
+		status, code = http.StatusServiceUnavailable, "not ready"
+	}
+
+	return c.JSON(status, map[string]interface{}{
+		"status": code,
+		"checks": results,
+	})
+}
+
+// Status is the operator view: build, uptime, configuration, readiness and
+// every registered section and queue.
+func (h *Checker) Status(c echo.Context) error {
+	ok, results := h.Run(c.Request().Context())
+
+	h.mu.Lock()
+	sections := make(map[string]interface{}, len(h.sections))
+	for name, report := range h.sections {
+		sections[name] = report()
+	}
+	queues := make(map[string]int, len(h.queues))
+	for name, depth := range h.queues {
+		queues[name] = depth()
+	}
+	h.mu.Unlock()
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"build":             buildInfo(),
+		"startedAt":         h.started,
+		"uptime":            time.Since(h.started).Round(time.Second).String(),
+		"configFingerprint": h.fingerprint,
+		"ready":             ok,
+		"checks":            results,
+		"sections":          sections,
+		"queues":            queues,
+	})
+}
+
+func buildInfo() map[string]string {
+	info := map[string]string{"version": Version}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info["goVersion"] = build.GoVersion
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info["commit"] = setting.Value
+		case "vcs.time":
+			info["commitTime"] = setting.Value
+		case "vcs.modified":
+			info["dirty"] = setting.Value
+		}
+	}
+	return info
+}
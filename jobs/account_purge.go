@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/rs/zerolog"
+)
+
+// AccountPurge hard deletes accounts whose deletion grace period has passed,
+// once per interval. Stopping it cancels a purge that is in flight and waits
+// for it to return.
+func AccountPurge(logger *zerolog.Logger, users *service.UserService, interval time.Duration) *Job {
+	return NewJob("account-purge", interval, logger, func(ctx context.Context, now time.Time) {
+		purged, err := users.PurgeDue(ctx, now)
+		if err != nil {
+			logger.Error().Err(err).Msg("account purge failed")
+			return
+		}
+		if purged > 0 {
+			logger.Info().Int("purged", purged).Msg("hard deleted accounts past their grace period")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/rs/zerolog"
+)
+
+// CorporateActions applies the splits and dividends that have taken effect
+// since they were recorded, such as a dividend reaching its pay date, once
+// per interval.
+func CorporateActions(logger *zerolog.Logger, actions *service.CorporateActionService, interval time.Duration) *Job {
+	return NewJob("corporate-actions", interval, logger, func(ctx context.Context, now time.Time) {
+		applied, err := actions.ProcessDue(ctx, now)
+		if err != nil {
+			logger.Error().Err(err).Int("applied", applied).Msg("applying corporate actions failed")
+			return
+		}
+		if applied > 0 {
+			logger.Info().Int("applied", applied).Msg("applied corporate actions that took effect")
+		}
+	})
+}
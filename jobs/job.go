@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Job runs fn once per interval between Start and Stop, or once a day at a
+// fixed time for a daily job. It is a lifecycle.Component and reports
+// whether its loop is alive for the readiness and status endpoints.
+type Job struct {
+	name     string
+	interval time.Duration
+	daily    bool
+	at       time.Duration // past midnight UTC, for a daily job
+	logger   *zerolog.Logger
+	fn       func(ctx context.Context, now time.Time)
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	exited  chan struct{}
+	running bool
+	lastRun time.Time
+}
+
+// JobStatus is what the status page shows about a job.
+type JobStatus struct {
+	Running  bool       `json:"running"`
+	Interval string     `json:"interval"`
+	At       string     `json:"at,omitempty"`
+	LastRun  *time.Time `json:"lastRun,omitempty"`
+}
+
+func NewJob(name string, interval time.Duration, logger *zerolog.Logger, fn func(ctx context.Context, now time.Time)) *Job {
+	return &Job{name: name, interval: interval, logger: logger, fn: fn}
+}
+
+// NewDailyJob runs fn every day at the given time past midnight UTC. Unlike
+// an interval, the time does not move with restarts, so a process restarted
+// more often than a day still runs it.
+func NewDailyJob(name string, at time.Duration, logger *zerolog.Logger, fn func(ctx context.Context, now time.Time)) *Job {
+	return &Job{name: name, interval: 24 * time.Hour, daily: true, at: at, logger: logger, fn: fn}
+}
+
+func (j *Job) Name() string {
+	return j.name
+}
+
+func (j *Job) Start(context.Context) error {
+	ctx, cancel := context.WithCancel(j.logger.WithContext(context.Background()))
+	exited := make(chan struct{})
+
+	j.mu.Lock()
+	j.cancel = cancel
+	j.exited = exited
+	j.running = true
+	j.mu.Unlock()
+
+	go func() {
+		defer close(exited)
+		defer j.setRunning(false)
+		j.loop(ctx)
+	}()
+	return nil
+}
+
+// Stop cancels a run that is in flight and waits for it to return.
+func (j *Job) Stop(ctx context.Context) error {
+	j.mu.Lock()
+	cancel, exited := j.cancel, j.exited
+	j.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	select {
+	case <-exited:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *Job) Running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.running
+}
+
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := JobStatus{Running: j.running, Interval: j.interval.String()}
+	if j.daily {
+		status.At = time.Time{}.Add(j.at).Format("15:04") + " UTC"
+	}
+	if !j.lastRun.IsZero() {
+		at := j.lastRun
+		status.LastRun = &at
+	}
+	return status
+}
+
+func (j *Job) setRunning(running bool) {
+	j.mu.Lock()
+	j.running = running
+	j.mu.Unlock()
+}
+
+func (j *Job) loop(ctx context.Context) {
+	if j.daily {
+		j.dailyLoop(ctx)
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			j.runOnce(ctx, now)
+		}
+	}
+}
+
+func (j *Job) dailyLoop(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(nextDaily(time.Now(), j.at)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			j.runOnce(ctx, now)
+		}
+	}
+}
+
+// nextDaily is the first time after now that is at past midnight UTC.
+func nextDaily(now time.Time, at time.Duration) time.Time {
+	next := now.UTC().Truncate(24 * time.Hour).Add(at)
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
+
+// runOnce keeps a panicking run from killing the loop. Every run is a trace
+// of its own.
+func (j *Job) runOnce(ctx context.Context, now time.Time) {
+	ctx, span := tracing.Tracer().Start(ctx, "job "+j.name, trace.WithNewRoot())
+	defer span.End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			j.logger.Error().Interface("panic", r).Str("job", j.name).Msg("job run panicked")
+		}
+	}()
+
+	j.fn(ctx, now)
+
+	j.mu.Lock()
+	j.lastRun = now
+	j.mu.Unlock()
+}
@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNextDaily(t *testing.T) {
+	at := 21*time.Hour + 30*time.Minute
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"earlier that day", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 21, 30, 0, 0, time.UTC)},
+		{"just started", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 21, 30, 0, 0, time.UTC)},
+		{"on the minute", time.Date(2024, 3, 1, 21, 30, 0, 0, time.UTC), time.Date(2024, 3, 2, 21, 30, 0, 0, time.UTC)},
+		{"later that day", time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC), time.Date(2024, 3, 2, 21, 30, 0, 0, time.UTC)},
+		{"across the month", time.Date(2024, 2, 29, 22, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 21, 30, 0, 0, time.UTC)},
+		// the day is the UTC one whatever the local zone
+		{"local evening", time.Date(2024, 3, 1, 19, 0, 0, 0, newYork), time.Date(2024, 3, 2, 21, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := nextDaily(tt.now, at); !got.Equal(tt.want) {
+			t.Errorf("%s: next run %v, want %v", tt.name, got.UTC(), tt.want)
+		}
+	}
+}
+
+func TestDailyJobRuns(t *testing.T) {
+	// the next run is a second or two off, on the second
+	now := time.Now().UTC()
+	at := now.Sub(now.Truncate(24*time.Hour)).Truncate(time.Second) + time.Second
+
+	logger := zerolog.Nop()
+	ran := make(chan time.Time, 1)
+	job := NewDailyJob("test", at, &logger, func(_ context.Context, now time.Time) { ran <- now })
+	if err := job.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer job.Stop(context.Background())
+
+	select {
+	case got := <-ran:
+		if want := nextDaily(now, at); got.Before(want) {
+			t.Errorf("ran at %v, before %v", got, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the job did not run at its time")
+	}
+
+	status := job.Status()
+	wantAt := time.Time{}.Add(at).Format("15:04") + " UTC"
+	if status.At != wantAt || status.Interval != "24h0m0s" || !status.Running {
+		t.Errorf("status = %+v, want running daily at %s", status, wantAt)
+	}
+}
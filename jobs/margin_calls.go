@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/rs/zerolog"
+)
+
+// MarginCalls charges the borrow fees of short positions and checks every
+// margin account against its maintenance requirement once a day, at the
+// given time past midnight UTC. The fees are those of the UTC day it runs
+// on, each charged once however often it runs.
+func MarginCalls(logger *zerolog.Logger, margin *service.MarginService, at time.Duration) *Job {
+	return NewDailyJob("margin-calls", at, logger, func(ctx context.Context, now time.Time) {
+		calls, err := margin.CheckDue(ctx, now)
+		if err != nil {
+			logger.Error().Err(err).Int("margin_calls", calls).Msg("checking margin accounts failed")
+			return
+		}
+		if calls > 0 {
+			logger.Warn().Int("margin_calls", calls).Msg("margin accounts below their maintenance requirement")
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/ratelimit"
+	"github.com/rs/zerolog"
+)
+
+// RateLimitEviction drops the rate limit keys that are back to their full
+// allowance, so callers seen once do not stay in memory for good.
+func RateLimitEviction(logger *zerolog.Logger, store *ratelimit.MemoryStore, interval time.Duration) *Job {
+	return NewJob("rate-limit-eviction", interval, logger, func(_ context.Context, now time.Time) {
+		if evicted := store.Evict(now); evicted > 0 {
+			logger.Debug().Int("evicted", evicted).Int("left", store.Len()).Msg("dropped idle rate limit keys")
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package jwtpackage
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+)
+
+var inactiveAllowedRoutes = make(map[string]bool)
+var inactiveMu sync.RWMutex
+
+// AllowInactive lets a deactivated (but not suspended) account reach a route,
+// e.g. the reactivation endpoint.
+func AllowInactive(method, path string) {
+	inactiveMu.Lock()
+	defer inactiveMu.Unlock()
+	inactiveAllowedRoutes[method+" "+path] = true
+}
+
+func isInactiveAllowed(c echo.Context) bool {
+	inactiveMu.RLock()
+	defer inactiveMu.RUnlock()
+	return inactiveAllowedRoutes[c.Request().Method+" "+c.Path()]
+}
+
+// loadSessionUser rejects single purpose tokens and returns the user behind
+// a session token once its account state has been checked.
+func (a *Auth) loadSessionUser(c echo.Context, claims jwt.MapClaims) (*models.User, error) {
+	if _, ok := claims["purpose"]; ok {
+		return nil, util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "Token is not a session token", nil)
+	}
+
+	userId, _ := claims["id"].(string)
+	user, err := a.services.Users.GetSummary(c.Request().Context(), userId)
+	if err != nil {
+		return nil, util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "user of the token does not exist", err)
+	}
+
+	if err := checkAccount(c, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func checkAccount(c echo.Context, user *models.User) error {
+	if user.IsSuspended() {
+		return util.NewAppError(http.StatusForbidden, types.StatusForbidden, "account is suspended: "+user.SuspensionReason, nil)
+	}
+	if user.IsDeactivated() && !isInactiveAllowed(c) {
+		return util.NewAppError(http.StatusForbidden, types.StatusForbidden, "account is deactivated, reactivate it first", nil)
+	}
+	return nil
+}
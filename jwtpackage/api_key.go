@@ -0,0 +1,169 @@
+package jwtpackage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/ratelimit"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	HeaderAPIKey   = "X-API-Key"
+	apiKeyScheme   = "ApiKey"
+	keyRateWindow  = time.Minute
+	authViaJWT     = "jwt"
+	authViaAPIKey  = "api_key"
+	contextAPIKey  = "apiKey"
+	contextAuthVia = "authVia"
+)
+
+/*
+	1. AuthMiddleWare - accepts either a Bearer JWT or a personal API key
+	2. RequireScope  - restricts API key callers to keys carrying a scope
+*/
+
+func (a *Auth) AuthMiddleWare() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			plain := getAPIKeyFromHeader(c)
+			if plain == "" {
+				if err := a.setClaimsFromJWT(c); err != nil {
+					return err
+				}
+				c.Set(contextAuthVia, authViaJWT)
+				return next(c)
+			}
+
+			now := time.Now()
+			key, err := a.services.APIKeys.Authenticate(c.Request().Context(), plain, now)
+			if err != nil {
+				return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, err.Error(), nil)
+			}
+
+			if err := a.limiter.Allow(c, "api_key", "api_key:"+key.Id, keyLimit(key)); err != nil {
+				return err
+			}
+
+			user, err := a.services.Users.GetSummary(c.Request().Context(), key.UserId)
+			if err != nil {
+				return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "api key owner not found", err)
+			}
+
+			if err := checkAccount(c, user); err != nil {
+				return err
+			}
+
+			// an api key cannot present a second factor
+			if user.TwoFactorEnabled && isSensitive(c) {
+				return util.NewAppError(http.StatusForbidden, types.StatusForbidden, "this action needs an interactive session with two factor", nil)
+			}
+
+			c.Set("userId", user.Id)
+			c.Set("email", user.Email)
+			c.Set("name", user.Name)
+			c.Set("role", user.RoleId)
+			c.Set(contextAPIKey, key)
+			c.Set(contextAuthVia, authViaAPIKey)
+
+			err = next(c)
+
+			a.recordKeyUsage(c, key, now, err)
+
+			return err
+		}
+	}
+}
+
+// RequireScope only checks API key callers; a JWT session carries the full
+// permissions of its user.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, ok := c.Get(contextAPIKey).(*models.APIKeyModel)
+			if ok && !key.HasScope(scope) {
+				return util.NewAppError(http.StatusForbidden, types.StatusForbidden, "api key is missing scope "+scope, nil)
+			}
+			return next(c)
+		}
+	}
+}
+
+func getAPIKeyFromHeader(c echo.Context) string {
+	if key := c.Request().Header.Get(HeaderAPIKey); key != "" {
+		return key
+	}
+
+	str := c.Request().Header.Get("Authorization")
+	newStr := strings.Split(str, " ")
+	if len(newStr) == 2 && newStr[0] == apiKeyScheme {
+		return newStr[1]
+	}
+	return ""
+}
+
+func (a *Auth) setClaimsFromJWT(c echo.Context) error {
+	token, err := a.GetToken(c)
+	if err != nil {
+		log.Error().Err(err).Msg("There is an issue in the AuthMiddleWare")
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "Not able to GetToken in the middleware", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "Token is not valid", nil)
+	}
+
+	c.Set("userId", claims["id"])
+	c.Set("email", claims["email"])
+	c.Set("name", claims["name"])
+	c.Set("role", roleFromClaims(claims))
+
+	user, err := a.loadSessionUser(c, claims)
+	if err != nil {
+		return err
+	}
+	return checkStepUp(c, user, claims)
+}
+
+// keyLimit is the per minute limit stored on the key.
+func keyLimit(key *models.APIKeyModel) ratelimit.Limit {
+	requests := key.RateLimit
+	if requests <= 0 {
+		requests = models.DefaultAPIKeyRateLimit
+	}
+	return ratelimit.Limit{Algorithm: ratelimit.SlidingWindow, Requests: requests, Window: keyRateWindow}
+}
+
+func (a *Auth) recordKeyUsage(c echo.Context, key *models.APIKeyModel, now time.Time, handlerErr error) {
+	status := c.Response().Status
+	var appError *util.AppError
+	if handlerErr != nil {
+		status = http.StatusInternalServerError
+		if errors.As(handlerErr, &appError) {
+			status = appError.Status
+		}
+	}
+
+	usage := &models.APIKeyUsageModel{
+		APIKeyId: key.Id,
+		Method:   c.Request().Method,
+		Path:     c.Path(),
+		Status:   status,
+		ClientIP: c.RealIP(),
+	}
+
+	// the request has been served, its usage row counts even if the client left
+	if err := a.services.APIKeys.RecordUsage(context.WithoutCancel(c.Request().Context()), usage, now); err != nil {
+		log.Error().Err(err).Str("api_key_id", key.Id).Msg("not able to record api key usage")
+	}
+}
@@ -0,0 +1,109 @@
+package jwtpackage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/models"
+)
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	db := migratedDB(t)
+	_, svc, e := testAuth(t, db)
+	ctx := context.Background()
+	user := testUser(t, svc, "grace@example.com", false)
+
+	newKey := func(scopes ...string) (string, *models.APIKeyModel) {
+		plain, key, err := svc.APIKeys.Create(ctx, user.Id, "ci", scopes, 0, 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plain, key
+	}
+
+	reader, _ := newKey("portfolio:read")
+	trader, _ := newKey("trade:write")
+	everything, _ := newKey("*")
+	revoked, revokedKey := newKey("*")
+	if err := svc.APIKeys.Revoke(ctx, revokedKey.Id); err != nil {
+		t.Fatal(err)
+	}
+	expired, expiredKey := newKey("*")
+	if err := db.Model(expiredKey).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		want          int
+	}{
+		{"unknown key", "/api/portfolios", "ApiKey ta_00000000_unknown", http.StatusUnauthorized},
+		{"revoked key", "/api/portfolios", "ApiKey " + revoked, http.StatusUnauthorized},
+		{"expired key", "/api/portfolios", "ApiKey " + expired, http.StatusUnauthorized},
+		{"unscoped route", "/api/portfolios", "ApiKey " + reader, http.StatusOK},
+		{"missing scope", "/api/trades", "ApiKey " + reader, http.StatusForbidden},
+		{"scope held", "/api/trades", "ApiKey " + trader, http.StatusOK},
+		{"wildcard scope", "/api/trades", "ApiKey " + everything, http.StatusOK},
+		{"wrong scheme", "/api/trades", "Token " + everything, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(e, http.MethodGet, tt.path, tt.authorization); got != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// the key header works the same as the authorization scheme
+	req := httptest.NewRequest(http.MethodGet, "/api/trades", nil)
+	req.Header.Set(HeaderAPIKey, reader)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("GET /api/trades with %s = %d, want 403", HeaderAPIKey, rec.Code)
+	}
+}
+
+func TestAPIKeyUsageAndLimit(t *testing.T) {
+	_, svc, e := testAuth(t, migratedDB(t))
+	ctx := context.Background()
+	user := testUser(t, svc, "grace@example.com", false)
+
+	plain, key, err := svc.APIKeys.Create(ctx, user.Id, "ci", []string{"portfolio:read"}, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusForbidden, http.StatusTooManyRequests} {
+		path := []string{"/api/portfolios", "/api/trades", "/api/portfolios"}[i]
+		if got := serve(e, http.MethodGet, path, "ApiKey "+plain); got != want {
+			t.Errorf("request %d to %s = %d, want %d", i+1, path, got, want)
+		}
+	}
+
+	// a refused scope is still a use of the key, a refused rate is not
+	usage, err := svc.APIKeys.Usage(ctx, key.Id, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[int]string, len(usage))
+	for _, row := range usage {
+		statuses[row.Status] = row.Path
+	}
+	if len(usage) != 2 || statuses[http.StatusOK] != "/api/portfolios" || statuses[http.StatusForbidden] != "/api/trades" {
+		t.Errorf("usage = %+v, want the 200 and the 403", usage)
+	}
+
+	stored, err := svc.APIKeys.Get(ctx, user.Id, key.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.LastUsedAt == nil {
+		t.Error("last use was not recorded")
+	}
+}
@@ -1,42 +1,85 @@
 package jwtpackage
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/config"
 	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/ratelimit"
+	"github.com/pratyush934/tradealpha/server/service"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/pratyush934/tradealpha/server/util"
 )
 
-var privateKey = []byte("iampratyushiamprayushiampratyushiampratyush")
+// Auth signs and checks tokens with the configured secret. Its middlewares
+// load the users and API keys behind a request through services, holding
+// every API key to its own limit with limiter on top of the limits of the
+// routes it calls.
+type Auth struct {
+	secret   []byte
+	tokenTTL time.Duration
+	services *service.Services
+	limiter  *ratelimit.Limiter
+}
+
+func New(cfg config.AuthConfig, services *service.Services, limiter *ratelimit.Limiter) *Auth {
+	return &Auth{
+		secret:   []byte(cfg.JWTSecret),
+		tokenTTL: cfg.TokenTTL.Std(),
+		services: services,
+		limiter:  limiter,
+	}
+}
 
 /*
 	1. CreateToken
-	2. GetTokenFromHeader
-	3. GetToken
+	2. CreateStepUpToken
+	3. GetTokenFromHeader
+	4. GetToken
 */
 
-func CreateToken(u *models.User) (string, error) {
+func (a *Auth) CreateToken(u *models.User) (string, error) {
+	return a.createToken(u, 0)
+}
+
+// CreateStepUpToken is issued after a successful second factor, its mfa_at
+// claim is what ValidateUserMiddleWare checks on sensitive routes.
+func (a *Auth) CreateStepUpToken(u *models.User) (string, error) {
+	return a.createToken(u, time.Now().Unix())
+}
 
-	ttl := 1800
+// createToken signs the session claims with HS256 and the configured
+// secret. exp is the registered expiry claim the parser enforces, and role
+// is the numeric role id the admin middleware compares. Tokens from before
+// this format, ES256 with an eat claim, fail to parse and their holders
+// have to log in again.
+func (a *Auth) createToken(u *models.User, mfaAt int64) (string, error) {
 
-	claims := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+	mapClaims := jwt.MapClaims{
 		"id":    u.Id,
 		"name":  u.Name,
 		"email": u.Email,
-		"role":  u.Role,
-		"iat":   time.Now(),
-		"eat":   time.Now().Add(time.Duration(ttl) * time.Second).Unix(),
-	})
+		"role":  u.RoleId,
+		"mfa":   u.TwoFactorEnabled,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(a.tokenTTL).Unix(),
+	}
+
+	if mfaAt > 0 {
+		mapClaims["mfa_at"] = mfaAt
+	}
+
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims)
 
-	return claims.SignedString(privateKey)
+	return claims.SignedString(a.secret)
 }
 
-func GetToken(c echo.Context) (*jwt.Token, error) {
+func (a *Auth) GetToken(c echo.Context) (*jwt.Token, error) {
 	header, err := GetTokenFromHeader(c)
 	if err != nil {
 		return nil, util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Not able to get TokenFromHeader jwt.go/GetToken", err)
@@ -44,9 +87,9 @@ func GetToken(c echo.Context) (*jwt.Token, error) {
 
 	parse, err := jwt.Parse(header, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, err
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
 		}
-		return privateKey, nil
+		return a.secret, nil
 	})
 
 	if err != nil {
@@ -10,11 +10,11 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func ValidateUserMiddleWare() echo.MiddlewareFunc {
+func (a *Auth) ValidateUserMiddleWare() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 
-			token, err := GetToken(c)
+			token, err := a.GetToken(c)
 			if err != nil {
 				log.Error().Err(err).Msg("There is an issue in the ValidateUserMiddleware")
 				return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Not able to GetToken in the middleware", err)
@@ -24,7 +24,16 @@ func ValidateUserMiddleWare() echo.MiddlewareFunc {
 				c.Set("userId", claims["id"])
 				c.Set("email", claims["email"])
 				c.Set("name", claims["name"])
-				c.Set("role", claims["role"])
+				c.Set("role", roleFromClaims(claims))
+
+				user, err := a.loadSessionUser(c, claims)
+				if err != nil {
+					return err
+				}
+
+				if err := checkStepUp(c, user, claims); err != nil {
+					return err
+				}
 			} else {
 				return util.NewAppError(http.StatusInternalServerError, types.StatusInternalServerError, "Not able to set claims in context", nil)
 			}
@@ -34,11 +43,11 @@ func ValidateUserMiddleWare() echo.MiddlewareFunc {
 	}
 }
 
-func ValidateAdminMiddleWare() echo.MiddlewareFunc {
+func (a *Auth) ValidateAdminMiddleWare() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 
-			token, err := GetToken(c)
+			token, err := a.GetToken(c)
 			if err != nil {
 				log.Error().Err(err).Msg("there is an issue in the ValidateAdminMiddleware")
 				return util.NewAppError(http.StatusBadRequest, types.StatusBadRequest, "Not able to GetToken in the middleware", err)
@@ -46,18 +55,24 @@ func ValidateAdminMiddleWare() echo.MiddlewareFunc {
 
 			claims, ok := token.Claims.(jwt.MapClaims)
 
-			role := claims["role"].(int64)
-
 			if !ok || !token.Valid {
 				return util.NewAppError(http.StatusNotFound, types.StatusNotFound, "Token is not valid", nil)
 			}
 
+			user, err := a.loadSessionUser(c, claims)
+			if err != nil {
+				return err
+			}
+
+			// trust the stored role over the claim so a demotion applies at once
+			role := user.RoleId
+
 			if role != 2 {
 				return util.NewAppError(http.StatusUnauthorized, types.StatusUnauthorized, "Not authorized as the user is not admin", nil)
 			}
 
 			c.Set("userId", claims["id"])
-			c.Set("role", claims["role"])
+			c.Set("role", role)
 			c.Set("name", claims["name"])
 			c.Set("email", claims["email"])
 
@@ -65,3 +80,17 @@ func ValidateAdminMiddleWare() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// roleFromClaims normalises the role claim, which comes back from the JSON
+// decoder as a float64, into the int the controllers expect.
+func roleFromClaims(claims jwt.MapClaims) int {
+	switch role := claims["role"].(type) {
+	case float64:
+		return int(role)
+	case int64:
+		return int(role)
+	case int:
+		return role
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+package jwtpackage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	PurposeEmailVerification = "email_verification"
+	EmailVerificationTTL     = 24 * time.Hour
+)
+
+// CreatePurposeToken signs a short-lived token that is only valid for one
+// purpose, so a verification link can never be used as a session token.
+func (a *Auth) CreatePurposeToken(userId, email, purpose string, ttl time.Duration) (string, error) {
+	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":      userId,
+		"email":   email,
+		"purpose": purpose,
+		"iat":     time.Now().Unix(),
+		"exp":     time.Now().Add(ttl).Unix(),
+	})
+	return claims.SignedString(a.secret)
+}
+
+func (a *Auth) ParsePurposeToken(tokenStr, purpose string) (userId, email string, err error) {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return a.secret, nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return "", "", fmt.Errorf("token is not valid")
+	}
+
+	if p, _ := claims["purpose"].(string); p != purpose {
+		return "", "", fmt.Errorf("token is not a %s token", purpose)
+	}
+
+	userId, _ = claims["id"].(string)
+	email, _ = claims["email"].(string)
+	return userId, email, nil
+}
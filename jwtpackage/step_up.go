@@ -0,0 +1,51 @@
+package jwtpackage
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+)
+
+// StepUpWindow is how long a second factor stays fresh for sensitive routes.
+const StepUpWindow = 5 * time.Minute
+
+var sensitiveRoutes = make(map[string]bool)
+var sensitiveMu sync.RWMutex
+
+// MarkSensitive tags a route (as registered with echo, e.g. "/api/users/:id")
+// so that users with 2FA enabled need a fresh step-up token to call it.
+func MarkSensitive(method, path string) {
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+	sensitiveRoutes[method+" "+path] = true
+}
+
+func isSensitive(c echo.Context) bool {
+	sensitiveMu.RLock()
+	defer sensitiveMu.RUnlock()
+	return sensitiveRoutes[c.Request().Method+" "+c.Path()]
+}
+
+// checkStepUp uses the user row rather than the mfa claim, which may
+// predate enrollment.
+func checkStepUp(c echo.Context, user *models.User, claims jwt.MapClaims) error {
+	if !isSensitive(c) {
+		return nil
+	}
+
+	if !user.TwoFactorEnabled {
+		return nil
+	}
+
+	mfaAt, ok := claims["mfa_at"].(float64)
+	if !ok || time.Since(time.Unix(int64(mfaAt), 0)) > StepUpWindow {
+		return util.NewAppError(http.StatusForbidden, types.StatusForbidden, "two factor step-up required for this action", nil)
+	}
+	return nil
+}
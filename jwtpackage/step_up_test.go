@@ -0,0 +1,190 @@
+package jwtpackage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/mailer"
+	"github.com/pratyush934/tradealpha/server/migrations"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/ratelimit"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// migratedDB is an in-memory SQLite database brought up by the migrations.
+func migratedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	migrator, err := migrations.New(db, migrations.All())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// testAuth is an Auth on services over db, and a router with plain,
+// sensitive and scoped routes behind its middleware.
+func testAuth(t *testing.T, db *gorm.DB) (*Auth, *service.Services, *echo.Echo) {
+	t.Helper()
+
+	cfg := config.Defaults()
+	cfg.Auth.JWTSecret = "0123456789abcdef0123456789abcdef"
+	svc := service.New(repository.NewGorm(db), nil, nil, mailer.New(config.SMTPConfig{}), cfg.Trading, cfg.Server)
+	auth := New(cfg.Auth, svc, ratelimit.FromConfig(cfg.RateLimit, ratelimit.NewMemoryStore()))
+
+	logger := zerolog.Nop()
+	e := echo.New()
+	e.Use(util.ErrorHandleMiddleWare(&logger))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	api := e.Group("/api", auth.AuthMiddleWare())
+	api.GET("/portfolios", ok)
+	api.DELETE("/portfolios/:id", ok)
+	api.GET("/trades", ok, RequireScope("trade:write"))
+	MarkSensitive(http.MethodDelete, "/api/portfolios/:id")
+
+	return auth, svc, e
+}
+
+func testUser(t *testing.T, svc *service.Services, email string, twoFactor bool) *models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	user, _, err := svc.Users.Login(ctx, models.User{Name: "Ada", Email: email, Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !twoFactor {
+		return user
+	}
+
+	secret, err := svc.Users.EnrollTwoFactor(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.TwoFactorSecret = secret
+	code, err := util.TOTPCode(secret, util.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Users.ConfirmTwoFactor(ctx, user, code); err != nil {
+		t.Fatal(err)
+	}
+	if user, err = svc.Users.Get(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func serve(e *echo.Echo, method, path, authorization string) int {
+	req := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set(echo.HeaderAuthorization, authorization)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestStepUpOnSensitiveRoutes(t *testing.T) {
+	auth, svc, e := testAuth(t, migratedDB(t))
+	user := testUser(t, svc, "ada@example.com", true)
+
+	session, err := auth.CreateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := auth.CreateStepUpToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := auth.createToken(user, time.Now().Add(-StepUpWindow-time.Minute).Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"plain route without step-up", http.MethodGet, "/api/portfolios", session, http.StatusOK},
+		{"sensitive route without step-up", http.MethodDelete, "/api/portfolios/p1", session, http.StatusForbidden},
+		{"sensitive route with a stale step-up", http.MethodDelete, "/api/portfolios/p1", stale, http.StatusForbidden},
+		{"sensitive route with a fresh step-up", http.MethodDelete, "/api/portfolios/p1", fresh, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(e, tt.method, tt.path, "Bearer "+tt.token); got != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStepUpWithoutTwoFactor(t *testing.T) {
+	auth, svc, e := testAuth(t, migratedDB(t))
+	user := testUser(t, svc, "grace@example.com", false)
+
+	session, err := auth.CreateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing to step up with, the session is enough
+	if got := serve(e, http.MethodDelete, "/api/portfolios/p1", "Bearer "+session); got != http.StatusOK {
+		t.Errorf("sensitive route = %d, want 200", got)
+	}
+}
+
+func TestAPIKeyOnSensitiveRoutes(t *testing.T) {
+	_, svc, e := testAuth(t, migratedDB(t))
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		email     string
+		twoFactor bool
+		want      int
+	}{
+		{"ada@example.com", true, http.StatusForbidden},
+		{"grace@example.com", false, http.StatusOK},
+	} {
+		user := testUser(t, svc, tt.email, tt.twoFactor)
+		plain, _, err := svc.APIKeys.Create(ctx, user.Id, "ci", []string{"portfolio:write"}, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := serve(e, http.MethodGet, "/api/portfolios", "ApiKey "+plain); got != http.StatusOK {
+			t.Errorf("two factor %v: plain route = %d, want 200", tt.twoFactor, got)
+		}
+		if got := serve(e, http.MethodDelete, "/api/portfolios/p1", "ApiKey "+plain); got != tt.want {
+			t.Errorf("two factor %v: sensitive route = %d, want %d", tt.twoFactor, got, tt.want)
+		}
+	}
+}
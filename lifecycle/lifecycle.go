@@ -0,0 +1,149 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Component is one long running part of the process: the HTTP server, a
+// scheduler, a poller, a stream hub or a connection pool.
+type Component interface {
+	Name() string
+	// Start brings the component up and must not block; ctx only bounds the
+	// start itself. A failure after Start has returned is reported with
+	// Manager.Fail.
+	Start(ctx context.Context) error
+	// Stop shuts the component down, giving up when ctx is done.
+	Stop(ctx context.Context) error
+}
+
+type funcComponent struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// Func builds a Component from plain functions, either of which may be nil.
+func Func(name string, start, stop func(ctx context.Context) error) Component {
+	return &funcComponent{name: name, start: start, stop: stop}
+}
+
+func (f *funcComponent) Name() string {
+	return f.name
+}
+
+func (f *funcComponent) Start(ctx context.Context) error {
+	if f.start == nil {
+		return nil
+	}
+	return f.start(ctx)
+}
+
+func (f *funcComponent) Stop(ctx context.Context) error {
+	if f.stop == nil {
+		return nil
+	}
+	return f.stop(ctx)
+}
+
+// Manager starts components in the order they were added and stops them in
+// reverse, so whatever serves traffic is added last and goes first, and the
+// pools it depends on are added first and closed last.
+type Manager struct {
+	logger          *zerolog.Logger
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+
+	components []Component
+	ready      atomic.Bool
+	failed     chan error
+}
+
+// New returns a Manager that gives its components shutdownTimeout in total
+// to stop. drainDelay is how long it reports not ready before stopping
+// anything, so load balancers can take the instance out first.
+func New(logger *zerolog.Logger, shutdownTimeout, drainDelay time.Duration) *Manager {
+	return &Manager{
+		logger:          logger,
+		shutdownTimeout: shutdownTimeout,
+		drainDelay:      drainDelay,
+		failed:          make(chan error, 1),
+	}
+}
+
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// Ready reports whether every component is up and the process is not
+// draining.
+func (m *Manager) Ready() bool {
+	return m.ready.Load()
+}
+
+// Fail reports that a running component broke, which shuts the process
+// down. Only the first failure is kept.
+func (m *Manager) Fail(name string, err error) {
+	select {
+	case m.failed <- fmt.Errorf("%s: %w", name, err):
+	default:
+	}
+}
+
+// Run starts every component, waits until ctx is done or one of them fails
+// and then shuts everything down. The error is the failure that caused the
+// shutdown, or the first problem stopping.
+func (m *Manager) Run(ctx context.Context) error {
+	for i, c := range m.components {
+		m.logger.Info().Str("component", c.Name()).Msg("starting")
+		if err := c.Start(ctx); err != nil {
+			m.logger.Error().Err(err).Str("component", c.Name()).Msg("not able to start, stopping what already runs")
+			_ = m.stop(m.components[:i])
+			return fmt.Errorf("start %s: %w", c.Name(), err)
+		}
+	}
+	m.ready.Store(true)
+	m.logger.Info().Int("components", len(m.components)).Msg("all components started")
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		m.logger.Info().Msg("shutdown requested, draining")
+	case runErr = <-m.failed:
+		m.logger.Error().Err(runErr).Msg("component failed, shutting down")
+	}
+	m.ready.Store(false)
+
+	if runErr == nil && m.drainDelay > 0 {
+		time.Sleep(m.drainDelay)
+	}
+
+	if err := m.stop(m.components); runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+// stop stops components in reverse order under one shared deadline. A
+// component that fails to stop is logged and the rest still get their turn.
+func (m *Manager) stop(components []Component) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if err := c.Stop(ctx); err != nil {
+			m.logger.Error().Err(err).Str("component", c.Name()).Msg("not able to stop cleanly")
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.Name(), err))
+			continue
+		}
+		m.logger.Info().Str("component", c.Name()).Msg("stopped")
+	}
+	return errors.Join(errs...)
+}
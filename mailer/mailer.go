@@ -0,0 +1,158 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/rs/zerolog/log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is what the rest of the server sends mail through, FakeMailer stands
+// in for SMTP during local development.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+func (s *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	var body strings.Builder
+	body.WriteString("From: " + s.From + "\r\n")
+	body.WriteString("To: " + msg.To + "\r\n")
+	body.WriteString("Subject: " + msg.Subject + "\r\n")
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	if err := s.send(ctx, msg.To, []byte(body.String())); err != nil {
+		log.Error().Err(err).Str("to", msg.To).Msg("not able to send mail via smtp")
+		return fmt.Errorf("send mail: %w", err)
+	}
+	return nil
+}
+
+// send is smtp.SendMail over a connection that is dialled with ctx and
+// closed as soon as ctx is done, so a stuck server cannot hold the caller.
+func (s *SMTPMailer) send(ctx context.Context, to string, body []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, s.Port))
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return withCtxErr(ctx, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return withCtxErr(ctx, err)
+		}
+	}
+	if s.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+				return withCtxErr(ctx, err)
+			}
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return withCtxErr(ctx, err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return withCtxErr(ctx, err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return withCtxErr(ctx, err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return withCtxErr(ctx, err)
+	}
+	if err := w.Close(); err != nil {
+		return withCtxErr(ctx, err)
+	}
+	return withCtxErr(ctx, client.Quit())
+}
+
+// withCtxErr reports the ctx error instead of the "use of closed network
+// connection" that closing the conn on cancel leaves behind.
+func withCtxErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// FakeMailer keeps every message in memory and logs it instead of sending.
+type FakeMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (f *FakeMailer) Send(ctx context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, msg)
+	log.Info().Str("to", msg.To).Str("subject", msg.Subject).Str("body", msg.Body).Msg("fake mailer captured a message")
+	return nil
+}
+
+func (f *FakeMailer) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]Message, len(f.sent))
+	copy(out, f.sent)
+	return out
+}
+
+// New picks the SMTP mailer when cfg has a host, otherwise mail goes to a
+// FakeMailer.
+func New(cfg config.SMTPConfig) Mailer {
+	if cfg.Host == "" {
+		return &FakeMailer{}
+	}
+	return &SMTPMailer{
+		Host:     cfg.Host,
+		Port:     strconv.Itoa(cfg.Port),
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+		Timeout:  cfg.Timeout.Std(),
+	}
+}
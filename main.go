@@ -1,30 +1,260 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/audit"
+	"github.com/pratyush934/tradealpha/server/config"
 	"github.com/pratyush934/tradealpha/server/controller"
+	"github.com/pratyush934/tradealpha/server/database"
+	"github.com/pratyush934/tradealpha/server/finnhub"
+	"github.com/pratyush934/tradealpha/server/health"
+	"github.com/pratyush934/tradealpha/server/jobs"
+	"github.com/pratyush934/tradealpha/server/jwtpackage"
+	"github.com/pratyush934/tradealpha/server/lifecycle"
+	"github.com/pratyush934/tradealpha/server/mailer"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/metrics"
+	"github.com/pratyush934/tradealpha/server/migrations"
+	"github.com/pratyush934/tradealpha/server/ratelimit"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/pratyush934/tradealpha/server/tracing"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/pratyush934/tradealpha/server/util"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
-func LoadDb() {
+// LoadDb connects to the database and, when database.auto_migrate is set,
+// brings the schema up to date. Without it the server only warns about
+// pending migrations so that schema changes stay an explicit deploy step.
+// The migrator is returned for the readiness check.
+func LoadDb(cfg *config.Config) *migrations.Migrator {
+	if err := database.InitDB(cfg.Database); err != nil {
+		os.Exit(1)
+	}
+
+	migrator, err := migrations.New(database.DB, migrations.All())
+	if err != nil {
+		log.Error().Err(err).Msg("Not able to build the migration set")
+		os.Exit(1)
+	}
+
+	if cfg.Database.AutoMigrate {
+		ran, err := migrator.Up(0)
+		if err != nil {
+			log.Error().Err(err).Msg("Not able to apply migrations")
+			os.Exit(1)
+		}
+		log.Info().Int("applied", ran).Msg("database schema is up to date")
+		return migrator
+	}
+
+	pending, err := migrator.Pending()
+	if err != nil {
+		log.Error().Err(err).Msg("Not able to read the migration status")
+		return migrator
+	}
+	if pending > 0 {
+		log.Warn().Int("pending", pending).Msg("database has pending migrations, run \"migrate up\"")
+	}
+	return migrator
+}
+
+// Migrate runs the migrate subcommand and exits.
+func Migrate(cfg *config.Config) {
+	if err := database.InitDB(cfg.Database); err != nil {
+		os.Exit(1)
+	}
+
+	migrator, err := migrations.New(database.DB, migrations.All())
+	if err == nil {
+		err = migrations.Run(migrator, cfg.Args[1:], os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+const importUsage = `usage: tradealpha [flags] import-prices [options] <file>...
+
+options:
+  -format csv|alphavantage   file format, csv by default
+  -columns field=header,...  csv columns holding symbol, date, open, high, low, close and volume
+  -date-format layout        Go time layout of the csv date column, 2006-01-02 by default
+  -symbol SYMBOL             symbol of every row of a csv file without a symbol column
+  -source NAME               source recorded on every bar, import by default`
+
+// ImportPrices runs the import-prices subcommand, loading daily price
+// history from files, and exits. Rejected rows are reported but only a file
+// that cannot be imported at all fails the command.
+func ImportPrices(cfg *config.Config) {
+	fset := flag.NewFlagSet("import-prices", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+	format := fset.String("format", service.ImportFormatCSV, "")
+	columns := fset.String("columns", "", "")
+	dateFormat := fset.String("date-format", "", "")
+	symbol := fset.String("symbol", "", "")
+	source := fset.String("source", "", "")
+	if err := fset.Parse(cfg.Args[1:]); err != nil || fset.NArg() == 0 {
+		if err == nil {
+			err = errors.New("missing file")
+		}
+		fmt.Fprintf(os.Stderr, "%v\n%s\n", err, importUsage)
+		os.Exit(2)
+	}
+	mapping, err := marketdata.ParseColumns(*columns)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err := database.InitDB(cfg.Database); err != nil {
+		os.Exit(1)
+	}
+	migrator, err := migrations.New(database.DB, migrations.All())
+	if err == nil {
+		var pending int
+		if pending, err = migrator.Pending(); err == nil && pending > 0 {
+			err = errors.New("database has pending migrations, run \"migrate up\" first")
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	prices := service.NewPriceService(repository.NewGorm(database.DB))
+	opts := service.PriceImport{
+		Format: *format,
+		CSV:    marketdata.BarCSV{Columns: mapping, DateLayout: *dateFormat, Symbol: *symbol},
+		Source: *source,
+	}
 
+	failed := false
+	for _, path := range fset.Args() {
+		report, err := importFile(prices, path, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: %d row(s), %d accepted, %d duplicate(s), %d rejected\n",
+			path, report.Rows, report.Accepted, report.Duplicates, report.Rejected)
+		for _, row := range report.Errors {
+			where := row.Day
+			if row.Row > 0 {
+				where = fmt.Sprintf("line %d", row.Row)
+			}
+			fmt.Printf("  %s: %s\n", where, row.Message)
+		}
+		if report.ErrorsOmitted > 0 {
+			fmt.Printf("  and %d more\n", report.ErrorsOmitted)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func importFile(prices *service.PriceService, path string, opts service.PriceImport) (*service.ImportReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return prices.Import(context.Background(), file, opts)
+}
+
+// Quotes builds the quote providers market_data.providers lists, asked in
+// that order. Alpha Vantage quotes go through market, the client every
+// other Alpha Vantage call shares its budget with.
+func Quotes(cfg config.MarketDataConfig, market *alphavantage.Client) marketdata.Provider {
+	providers := make([]marketdata.Provider, 0, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		switch name {
+		case config.ProviderAlphaVantage:
+			providers = append(providers, market)
+		case config.ProviderFinnhub:
+			providers = append(providers, finnhub.New(cfg.Finnhub, cfg.Timeout.Std()))
+		case config.ProviderCSV:
+			providers = append(providers, marketdata.NewCSVProvider(cfg.CSV.Path))
+		}
+	}
+	return marketdata.NewComposite(providers...)
+}
+
+// Services builds the repository and service layers on top of the open
+// database and hands them to the audit log.
+func Services(cfg *config.Config, quotes marketdata.Provider, market *alphavantage.Client) *service.Services {
+	svc := service.New(repository.NewGorm(database.DB), quotes, market, mailer.New(cfg.SMTP), cfg.Trading, cfg.Server)
+
+	audit.SetService(svc.Audit)
+
+	return svc
+}
+
+// Metrics registers the business gauges, read from the database on every
+// scrape.
+func Metrics(svc *service.Services, market *alphavantage.Client) {
+	metrics.Gauge("users_active", "Accounts that are not deactivated, suspended or scheduled for deletion.", func(ctx context.Context) (float64, error) {
+		count, err := svc.Users.CountActive(ctx)
+		return float64(count), err
+	})
+	metrics.Gauge("api_keys_active", "API keys that are neither revoked nor expired.", func(ctx context.Context) (float64, error) {
+		count, err := svc.APIKeys.CountUsable(ctx, time.Now())
+		return float64(count), err
+	})
+	metrics.Gauge("alphavantage_queue_depth", "Alpha Vantage calls waiting for budget.", func(context.Context) (float64, error) {
+		return float64(market.QueueDepth()), nil
+	})
+	metrics.Gauge("alphavantage_circuit_open", "1 while calls to Alpha Vantage are paused after repeated failures.", func(context.Context) (float64, error) {
+		if market.CircuitState() == alphavantage.CircuitOpen {
+			return 1, nil
+		}
+		return 0, nil
+	})
+	metrics.Gauge("notifications_unread", "Unread notifications across every user.", func(ctx context.Context) (float64, error) {
+		count, err := svc.Notifications.CountAllUnread(ctx)
+		return float64(count), err
+	})
 }
 
-func Server() {
+// Server builds the echo instance with every route, it is started by the
+// lifecycle manager in main.
+func Server(cfg *config.Config, logger *zerolog.Logger, checker *health.Checker, limiter *ratelimit.Limiter, auth *jwtpackage.Auth, market *alphavantage.Client) *echo.Echo {
 
 	e := echo.New()
+	e.Server.ReadTimeout = cfg.Server.ReadTimeout.Std()
+	e.Server.WriteTimeout = cfg.Server.WriteTimeout.Std()
+	e.IPExtractor = clientIP(cfg.Server.TrustedProxies)
 
-	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
-	e.Use(util.ErrorHandleMiddleWare(&logger))
+	// the limiter goes after the auth middleware of each route, so that it
+	// can count signed in users by their id
+	limit := limiter.Middleware()
+
+	// outside the error handler so the status it settles on is what gets
+	// counted, and so the request logger can pick up the trace id
+	e.Use(tracing.Middleware())
+	e.Use(metrics.Middleware())
+	e.Use(util.ErrorHandleMiddleWare(logger))
 
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "hello")
@@ -34,29 +264,277 @@ func Server() {
 		return util.NewAppError(http.StatusOK, types.StatusOK, "It is working bro", fmt.Errorf("first time , this is first time"))
 	})
 
-	e.POST("/login", controller.LoginController)
+	e.GET("/healthz", checker.Liveness)
+	e.GET("/readyz", checker.Readiness)
+	e.GET("/status", checker.Status, auth.ValidateAdminMiddleWare())
+	e.GET("/metrics", metrics.Handler(cfg.Metrics.Token))
+
+	e.POST("/login", controller.LoginController, limit)
+	e.POST("/api/auth/refresh", controller.RefreshToken, auth.ValidateUserMiddleWare(), limit)
+
+	e.GET("/api/stocks/search", market.SearchStockHandler(logger), limit)
+	e.GET("/api/stocks/:symbol/quote", controller.GetStockQuote, limit)
+	e.GET("/api/stocks/:symbol/intraday", market.GetIntradayDataHandler(logger), limit)
+	e.GET("/api/stocks/:symbol/daily", market.GetDailyDataHandler(logger), limit)
+	e.GET("/api/stocks/:symbol/history", controller.GetStockHistory, limit)
+	e.GET("/api/stocks/:symbol/corporate-actions", controller.GetCorporateActions, limit)
+	e.GET("/api/stocks/movers", controller.GetDailyMoversHandler, limit)
+
+	// API keys are managed from a JWT session only, a key cannot mint other keys
+	keys := e.Group("/api/keys", auth.ValidateUserMiddleWare(), limit)
+	keys.POST("", controller.CreateAPIKey)
+	keys.GET("", controller.GetAPIKeys)
+	keys.DELETE("/:id", controller.RevokeAPIKey)
+	keys.GET("/:id/usage", controller.GetAPIKeyUsage)
+
+	twoFactor := e.Group("/api/2fa", auth.ValidateUserMiddleWare(), limit)
+	twoFactor.POST("/enroll", controller.EnrollTwoFactor)
+	twoFactor.POST("/confirm", controller.ConfirmTwoFactor)
+	twoFactor.POST("/verify", controller.VerifyTwoFactor)
+	twoFactor.POST("/disable", controller.DisableTwoFactor)
+	twoFactor.POST("/recovery-codes", controller.RegenerateRecoveryCodes)
+
+	e.GET("/api/verify-email", controller.VerifyEmail, limit)
+
+	users := e.Group("/api/users", auth.ValidateUserMiddleWare(), limit)
+	users.POST("/me/withdraw", controller.WithdrawCash)
+	users.PUT("/me/currency", controller.ChangeBaseCurrency)
+	users.DELETE("/me", controller.DeleteUser)
+	users.POST("/me/verification", controller.UpdateUserVerificationStatus)
+	users.POST("/me/deactivate", controller.DeactivateAccount)
+	users.POST("/me/reactivate", controller.ReactivateAccount)
 
-	e.GET("/api/stocks/search", alphavantage.SearchStockHandler(&logger))
-	e.GET("/api/stocks/:symbol/quote", alphavantage.GetStockQuoteHandler(&logger))
-	e.GET("/api/stocks/:symbol/intraday", alphavantage.GetIntradayDataHandler(&logger))
-	e.GET("/api/stocks/:symbol/daily", alphavantage.GetDailyDataHandler(&logger))
-	e.GET("/api/portfolios/:id/metrics", controller.GetPortfolioMetrics)
-	e.GET("/api/stocks/movers", controller.GetDailyMoversHandler)
+	jwtpackage.AllowInactive(http.MethodPost, "/api/users/me/reactivate")
 
-	_ = e.Start(":8080")
+	admin := e.Group("/api/admin", auth.ValidateAdminMiddleWare(), limit)
+	admin.POST("/users/:id/suspend", controller.SuspendUserByAdmin)
+	admin.POST("/users/:id/unsuspend", controller.UnsuspendUserByAdmin)
+	admin.PUT("/users/:id/role", controller.ChangeUserRoleByAdmin)
+	admin.GET("/audit", controller.GetAuditLogs)
+	admin.GET("/audit/export", controller.ExportAuditLogs)
+	admin.POST("/prices/import", controller.ImportPrices)
+	admin.POST("/corporate-actions", controller.CreateCorporateAction)
+	admin.POST("/corporate-actions/:symbol/sync", controller.SyncCorporateActions)
 
+	jwtpackage.MarkSensitive(http.MethodPost, "/api/users/me/withdraw")
+	jwtpackage.MarkSensitive(http.MethodPut, "/api/users/me/currency")
+	jwtpackage.MarkSensitive(http.MethodDelete, "/api/users/me")
+	jwtpackage.MarkSensitive(http.MethodPost, "/api/v1/transactions")
+	jwtpackage.MarkSensitive(http.MethodPut, "/api/v1/transactions/:transId")
+	jwtpackage.MarkSensitive(http.MethodDelete, "/api/v1/transactions/:transId")
+
+	api := e.Group("/api/v1", auth.AuthMiddleWare(), limit)
+	api.GET("/portfolios", controller.GetUserPortfolios, jwtpackage.RequireScope("portfolio:read"))
+	api.GET("/portfolios/:id", controller.GetPortFolioById, jwtpackage.RequireScope("portfolio:read"))
+	api.GET("/portfolios/:id/valuation", controller.GetPortfolioValuation, jwtpackage.RequireScope("portfolio:read"))
+	api.GET("/portfolios/:id/metrics", controller.GetPortfolioMetrics, jwtpackage.RequireScope("portfolio:read"))
+	api.GET("/portfolios/:id/fees", controller.GetFeeSchedule, jwtpackage.RequireScope("portfolio:read"))
+	api.PUT("/portfolios/:id/fees", controller.UpdateFeeSchedule, jwtpackage.RequireScope("portfolio:write"))
+	api.GET("/portfolios/:id/margin", controller.GetMarginStatus, jwtpackage.RequireScope("portfolio:read"))
+	api.PUT("/portfolios/:id/margin", controller.UpdateMargin, jwtpackage.RequireScope("portfolio:write"))
+	api.GET("/transactions", controller.GetTransactionByUserId, jwtpackage.RequireScope("portfolio:read"))
+	api.POST("/transactions", controller.CreateTransaction, jwtpackage.RequireScope("trade:write"))
+	api.GET("/transactions/:transId", controller.GetPortFolioTransactionById, jwtpackage.RequireScope("portfolio:read"))
+	api.GET("/transactions/:transId/chain", controller.GetTransactionChain, jwtpackage.RequireScope("portfolio:read"))
+	api.PUT("/transactions/:transId", controller.UpdateTransaction, jwtpackage.RequireScope("trade:write"))
+	api.DELETE("/transactions/:transId", controller.DeleteTransactionByUserId, jwtpackage.RequireScope("trade:write"))
+	api.GET("/watchlists/:watchId", controller.GetWatchlistByIdHandler, jwtpackage.RequireScope("watchlist:read"))
+	api.GET("/notifications", controller.GetUserNotifications, jwtpackage.RequireScope("notification:read"))
+
+	return e
 }
 
-func Config() {
+// clientIP only believes X-Forwarded-For when the peer is one of the trusted
+// proxies, otherwise anyone could pick the address they are counted under.
+func clientIP(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
 
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range trustedProxies {
+		// validated with the rest of the configuration
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			options = append(options, echo.TrustIPRange(network))
+		}
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// HTTP serves e on addr. The port is bound during start so a busy port stops
+// the boot, and stopping drains in-flight requests.
+func HTTP(app *lifecycle.Manager, e *echo.Echo, addr string) lifecycle.Component {
+	start := func(context.Context) error {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		e.Listener = listener
+
+		go func() {
+			if err := e.Start(addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				app.Fail("http", err)
+			}
+		}()
+		return nil
+	}
+
+	return lifecycle.Func("http", start, e.Shutdown)
+}
+
+// Health registers what /readyz checks and what /status reports.
+func Health(cfg *config.Config, app *lifecycle.Manager, migrator *migrations.Migrator, svc *service.Services, market *alphavantage.Client, background []*jobs.Job) *health.Checker {
+	checker := health.New(cfg.Fingerprint())
+
+	checker.AddCheck("lifecycle", func(context.Context) error {
+		if !app.Ready() {
+			return errors.New("starting or shutting down")
+		}
+		return nil
+	})
+	checker.AddCheck("database", database.Ping)
+	checker.AddCheck("migrations", migrationsApplied(migrator))
+	checker.AddCheck("market_data", func(ctx context.Context) error {
+		if market.Reachable() {
+			return nil
+		}
+		// stale prices beat no prices, stay in rotation while some are cached
+		cached, err := svc.Stocks.List(ctx, 1, 0)
+		if err != nil {
+			return err
+		}
+		if len(cached) == 0 {
+			return errors.New("alpha vantage is unreachable and no prices are cached")
+		}
+		return nil
+	})
+	for _, job := range background {
+		checker.AddCheck(job.Name(), func(context.Context) error {
+			if !job.Running() {
+				return errors.New("scheduler is not running")
+			}
+			return nil
+		})
+	}
+
+	checker.AddSection("marketData", func() interface{} {
+		return market.CurrentUsage()
+	})
+	checker.AddQueue("alphavantage", market.QueueDepth)
+	checker.AddSection("jobs", func() interface{} {
+		statuses := make(map[string]jobs.JobStatus, len(background))
+		for _, job := range background {
+			statuses[job.Name()] = job.Status()
+		}
+		return statuses
+	})
+
+	return checker
+}
+
+// migrationsApplied passes once no migration is pending. The answer is kept
+// after the first pass, the status query creates the bookkeeping tables and
+// is too heavy to run on every probe.
+func migrationsApplied(migrator *migrations.Migrator) health.Check {
+	var applied atomic.Bool
+
+	return func(context.Context) error {
+		if applied.Load() {
+			return nil
+		}
+		pending, err := migrator.Pending()
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d migrations pending", pending)
+		}
+		applied.Store(true)
+		return nil
+	}
+}
+
+// Config loads the configuration, main hands each layer its own section.
+func Config() *config.Config {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Error().Err(err).Msg("Not able to load the configuration")
+		os.Exit(1)
+	}
+
+	if level, err := zerolog.ParseLevel(cfg.Log.Level); err == nil {
+		zerolog.SetGlobalLevel(level)
+	}
+
+	if cfg.MarketData.APIKey == "" {
+		log.Warn().Msg("market_data.api_key is empty, Alpha Vantage calls will fail")
+	}
+
+	return cfg
 }
 
 func main() {
-	err := godotenv.Load()
+	cfg := Config()
+
+	if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+		Migrate(cfg)
+	}
+	if len(cfg.Args) > 0 && cfg.Args[0] == "import-prices" {
+		ImportPrices(cfg)
+	}
+
+	tracer, err := tracing.Setup(context.Background(), cfg.Tracing, health.Version)
 	if err != nil {
-		log.Error().Err(err).Msg("Not able to load the dotenv")
+		log.Error().Err(err).Msg("Not able to set up tracing")
 		os.Exit(1)
 	}
 
-	Server()
+	migrator := LoadDb(cfg)
+	market := alphavantage.New(cfg.MarketData)
+	svc := Services(cfg, Quotes(cfg.MarketData, market), market)
+	Metrics(svc, market)
+
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app := lifecycle.New(&logger, cfg.Server.ShutdownTimeout.Std(), cfg.Server.DrainDelay.Std())
+	purge := jobs.AccountPurge(&logger, svc.Users, time.Hour)
+	corporateActions := jobs.CorporateActions(&logger, svc.Actions, time.Hour)
+	// after the US close, with or without daylight saving time
+	marginCalls := jobs.MarginCalls(&logger, svc.Margin, 21*time.Hour+30*time.Minute)
+
+	limits := ratelimit.NewMemoryStore()
+	limiter := ratelimit.FromConfig(cfg.RateLimit, limits)
+	auth := jwtpackage.New(cfg.Auth, svc, limiter)
+	controller.SetServices(svc, auth)
+	eviction := jobs.RateLimitEviction(&logger, limits, cfg.RateLimit.SweepInterval.Std())
+	metrics.Gauge("rate_limit_keys", "Callers the in-memory rate limiter is tracking.", func(context.Context) (float64, error) {
+		return float64(limits.Len()), nil
+	})
+
+	checker := Health(cfg, app, migrator, svc, market, []*jobs.Job{purge, corporateActions, marginCalls, eviction})
+	e := Server(cfg, &logger, checker, limiter, auth, market)
+
+	// started top to bottom, stopped bottom to top
+	app.Add(tracer)
+	app.Add(lifecycle.Func("database", nil, func(context.Context) error {
+		return database.Close()
+	}))
+	app.Add(purge)
+	app.Add(corporateActions)
+	app.Add(marginCalls)
+	app.Add(eviction)
+	app.Add(HTTP(app, e, cfg.Server.Addr))
+
+	if err := app.Run(ctx); err != nil {
+		logger.Error().Err(err).Msg("server stopped with an error")
+		os.Exit(1)
+	}
+	logger.Info().Msg("server stopped")
 }
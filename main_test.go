@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/controller"
+	"github.com/pratyush934/tradealpha/server/database"
+	"github.com/pratyush934/tradealpha/server/jwtpackage"
+	"github.com/pratyush934/tradealpha/server/lifecycle"
+	"github.com/pratyush934/tradealpha/server/migrations"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/ratelimit"
+	"github.com/pratyush934/tradealpha/server/service"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+)
+
+// testServer wires the router the way main does, on a SQLite file brought
+// up to date by the migrations rather than by AutoMigrate.
+func testServer(t *testing.T) (*echo.Echo, *service.Services) {
+	t.Helper()
+
+	cfg := config.Defaults()
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.DSN = filepath.Join(t.TempDir(), "tradealpha.db")
+	cfg.Auth.JWTSecret = "0123456789abcdef0123456789abcdef"
+	// nothing in these tests should reach out for a price
+	cfg.MarketData.BaseURL = "http://127.0.0.1:1/query"
+	cfg.MarketData.Retries = 0
+
+	if err := database.InitDB(cfg.Database); err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	migrator, err := migrations.New(database.DB, migrations.All())
+	if err != nil {
+		t.Fatalf("build migrations: %v", err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	logger := zerolog.Nop()
+	market := alphavantage.New(cfg.MarketData)
+	svc := Services(&cfg, Quotes(cfg.MarketData, market), market)
+	limiter := ratelimit.FromConfig(cfg.RateLimit, ratelimit.NewMemoryStore())
+	auth := jwtpackage.New(cfg.Auth, svc, limiter)
+	controller.SetServices(svc, auth)
+
+	app := lifecycle.New(&logger, time.Second, 0)
+	checker := Health(&cfg, app, migrator, svc, market, nil)
+	return Server(&cfg, &logger, checker, limiter, auth, market), svc
+}
+
+func call(t *testing.T, e *echo.Echo, method, path, token, body string) (int, map[string]interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var decoded map[string]interface{}
+	if strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code, decoded
+}
+
+func TestServerOnMigratedSQLite(t *testing.T) {
+	e, svc := testServer(t)
+
+	if code, _ := call(t, e, http.MethodGet, "/healthz", "", ""); code != http.StatusOK {
+		t.Fatalf("GET /healthz = %d, want 200", code)
+	}
+
+	code, body := call(t, e, http.MethodPost, "/login", "", `{"name":"Ada","email":"ada@example.com","provider":"google","oauth_id":"ada"}`)
+	if code != http.StatusOK && code != http.StatusCreated {
+		t.Fatalf("POST /login = %d %v", code, body)
+	}
+	// a first login signs the user up and hands the token back as "token"
+	token, _ := body["token"].(string)
+	if token == "" {
+		t.Fatalf("POST /login returned no token: %v", body)
+	}
+
+	if code, _ := call(t, e, http.MethodGet, "/api/v1/portfolios", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/v1/portfolios without a token = %d, want 401", code)
+	}
+
+	code, body = call(t, e, http.MethodGet, "/api/v1/portfolios", token, "")
+	if code != http.StatusOK {
+		t.Fatalf("GET /api/v1/portfolios = %d %v", code, body)
+	}
+	if list, _ := body["portfolio"].([]interface{}); len(list) != 0 {
+		t.Errorf("new user has %d portfolios, want 0", len(list))
+	}
+
+	user, err := svc.Users.GetByEmail(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	portfolio := models.PortFolio{UserId: user.Id, Name: "main", Title: "Main", Description: "long term", BaseCurrency: "USD", TotalValue: decimal.Zero}
+	if err := svc.Portfolios.Create(context.Background(), &portfolio); err != nil {
+		t.Fatalf("create portfolio: %v", err)
+	}
+
+	code, body = call(t, e, http.MethodGet, "/api/v1/portfolios", token, "")
+	if list, _ := body["portfolio"].([]interface{}); code != http.StatusOK || len(list) != 1 {
+		t.Fatalf("GET /api/v1/portfolios = %d %v, want the one portfolio", code, body)
+	}
+
+	code, body = call(t, e, http.MethodGet, "/api/v1/portfolios/"+portfolio.Id+"/metrics", token, "")
+	if code != http.StatusOK {
+		t.Fatalf("GET metrics = %d %v", code, body)
+	}
+	if body["total_value"] != "0" || body["base_currency"] != "USD" {
+		t.Errorf("metrics of an empty portfolio = %v", body)
+	}
+
+	if code, _ := call(t, e, http.MethodGet, "/api/v1/portfolios/"+portfolio.Id+"/metrics", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET metrics without a token = %d, want 401", code)
+	}
+}
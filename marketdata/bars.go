@@ -0,0 +1,232 @@
+package marketdata
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Bar is the open, high, low, close and volume of a symbol on one trading
+// day.
+type Bar struct {
+	Symbol string          `json:"symbol"`
+	Day    string          `json:"day"` // YYYY-MM-DD
+	Open   decimal.Decimal `json:"open"`
+	High   decimal.Decimal `json:"high"`
+	Low    decimal.Decimal `json:"low"`
+	Close  decimal.Decimal `json:"close"`
+	Volume int64           `json:"volume"`
+}
+
+// Validate reports the first thing wrong with b: a missing symbol, a day
+// that is not a past YYYY-MM-DD, a price that is not positive or a high and
+// low that do not bound the open and close.
+func (b Bar) Validate(now time.Time) error {
+	if b.Symbol == "" {
+		return errors.New("symbol is empty")
+	}
+	day, err := time.Parse(time.DateOnly, b.Day)
+	if err != nil {
+		return fmt.Errorf("day %q is not YYYY-MM-DD", b.Day)
+	}
+	if day.After(now) {
+		return fmt.Errorf("day %s is in the future", b.Day)
+	}
+	for _, price := range []decimal.Decimal{b.Open, b.High, b.Low, b.Close} {
+		if !price.IsPositive() {
+			return errors.New("prices must be positive numbers")
+		}
+	}
+	if b.High.LessThan(decimal.Max(b.Open, b.Close, b.Low)) || b.Low.GreaterThan(decimal.Min(b.Open, b.Close)) {
+		return errors.New("high and low do not bound the open and close")
+	}
+	if b.Volume < 0 {
+		return errors.New("volume is negative")
+	}
+	return nil
+}
+
+// RowError is a row of an import file that was rejected. Row counts from 1
+// for the first line of a CSV file, rows of a JSON file are named by Day.
+type RowError struct {
+	Row     int    `json:"row,omitempty"`
+	Day     string `json:"day,omitempty"`
+	Message string `json:"message"`
+}
+
+// Bar fields a CSV column can be mapped to.
+const (
+	ColumnSymbol = "symbol"
+	ColumnDate   = "date"
+	ColumnOpen   = "open"
+	ColumnHigh   = "high"
+	ColumnLow    = "low"
+	ColumnClose  = "close"
+	ColumnVolume = "volume"
+)
+
+// Columns maps bar fields to the header of the CSV column holding them.
+// Fields left out are read from the column named like the field.
+type Columns map[string]string
+
+// ParseColumns reads a mapping written as field=header pairs separated by
+// commas, such as "date=Date,close=Adj Close".
+func ParseColumns(s string) (Columns, error) {
+	columns := Columns{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, header, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		header = strings.TrimSpace(header)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("column mapping %q is not field=header", pair)
+		}
+		switch field {
+		case ColumnSymbol, ColumnDate, ColumnOpen, ColumnHigh, ColumnLow, ColumnClose, ColumnVolume:
+		default:
+			return nil, fmt.Errorf("column mapping names unknown field %q", field)
+		}
+		columns[field] = header
+	}
+	return columns, nil
+}
+
+func (c Columns) header(field string) string {
+	if header, ok := c[field]; ok {
+		return header
+	}
+	return field
+}
+
+// BarCSV says how to read bars from a CSV file.
+type BarCSV struct {
+	Columns Columns
+	// DateLayout is the time.Parse layout of the date column, YYYY-MM-DD
+	// when empty.
+	DateLayout string
+	// Symbol is used for every row when the file has no symbol column.
+	Symbol string
+}
+
+// ParseBarsCSV reads bars from a CSV file with a header row. Rows that
+// cannot be read or fail Validate are returned as RowErrors and the rest
+// still parsed; only a header that lacks a required column fails the whole
+// file.
+func ParseBarsCSV(r io.Reader, opts BarCSV) ([]Bar, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read header: %w", err)
+	}
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	index := make(map[string]int)
+	for _, field := range []string{ColumnSymbol, ColumnDate, ColumnOpen, ColumnHigh, ColumnLow, ColumnClose, ColumnVolume} {
+		i, ok := positions[strings.ToLower(opts.Columns.header(field))]
+		if ok {
+			index[field] = i
+			continue
+		}
+		switch {
+		case field == ColumnVolume:
+		case field == ColumnSymbol && opts.Symbol != "":
+		default:
+			return nil, nil, fmt.Errorf("header has no %q column for %s", opts.Columns.header(field), field)
+		}
+	}
+
+	layout := opts.DateLayout
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	symbol := strings.ToUpper(strings.TrimSpace(opts.Symbol))
+	now := time.Now()
+
+	var bars []Bar
+	var rejected []RowError
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rejected = append(rejected, RowError{Row: row, Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		field := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		bar, err := parseBar(field, layout)
+		if err != nil {
+			rejected = append(rejected, RowError{Row: row, Message: err.Error()})
+			continue
+		}
+		if bar.Symbol == "" {
+			bar.Symbol = symbol
+		}
+		if err := bar.Validate(now); err != nil {
+			rejected = append(rejected, RowError{Row: row, Message: err.Error()})
+			continue
+		}
+		bars = append(bars, bar)
+	}
+	return bars, rejected, nil
+}
+
+func parseBar(field func(string) string, layout string) (Bar, error) {
+	bar := Bar{Symbol: strings.ToUpper(field(ColumnSymbol))}
+
+	day, err := time.Parse(layout, field(ColumnDate))
+	if err != nil {
+		return bar, fmt.Errorf("date %q does not match %q", field(ColumnDate), layout)
+	}
+	bar.Day = day.Format(time.DateOnly)
+
+	prices := []struct {
+		name string
+		into *decimal.Decimal
+	}{
+		{ColumnOpen, &bar.Open}, {ColumnHigh, &bar.High}, {ColumnLow, &bar.Low}, {ColumnClose, &bar.Close},
+	}
+	for _, price := range prices {
+		if *price.into, err = decimal.NewFromString(field(price.name)); err != nil {
+			return bar, fmt.Errorf("%s %q is not a number", price.name, field(price.name))
+		}
+	}
+
+	if volume := field(ColumnVolume); volume != "" {
+		// exports often write volume as a float, 1.5e+06 or 1500000.0
+		v, err := strconv.ParseFloat(volume, 64)
+		if err != nil || v != math.Trunc(v) {
+			return bar, fmt.Errorf("volume %q is not a whole number", volume)
+		}
+		bar.Volume = int64(v)
+	}
+	return bar, nil
+}
@@ -0,0 +1,102 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pratyush934/tradealpha/server/metrics"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	sourceKey    = attribute.Key("marketdata.source")
+	failoversKey = attribute.Key("marketdata.failovers")
+	cachedKey    = attribute.Key("marketdata.cached")
+)
+
+// Composite asks its providers in priority order and returns the first quote
+// one of them has. A provider that fails, or does not know the symbol, is
+// skipped, so an outage only costs the time it takes to fail. Cached quotes
+// are only served once no provider has a live one.
+type Composite struct {
+	providers []Provider
+}
+
+func NewComposite(providers ...Provider) *Composite {
+	return &Composite{providers: providers}
+}
+
+func (c *Composite) Name() string {
+	return "composite"
+}
+
+// Quote returns the live quote of the first provider that has one, then the
+// cached quote of the first that kept one. When there is neither, the error
+// is that of the first provider that could not answer or, when every one of
+// them answered that the symbol is unknown, the first provider's.
+func (c *Composite) Quote(ctx context.Context, symbol string) (quote *Quote, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "marketdata.Quote")
+	defer func() { tracing.End(span, err) }()
+
+	var errs []error
+	live := WithoutFallback(ctx)
+	for _, provider := range c.providers {
+		quote, err := provider.Quote(live, symbol)
+		if err == nil {
+			c.served(ctx, span, symbol, quote, len(errs))
+			return quote, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if !errors.Is(err, ErrUnknownSymbol) {
+			loggerFrom(ctx).Warn().Err(err).Str("symbol", symbol).Str("provider", provider.Name()).Msg("Quote provider failed")
+		}
+		errs = append(errs, err)
+	}
+
+	if FallbackAllowed(ctx) {
+		for i, provider := range c.providers {
+			fallback, ok := provider.(Fallback)
+			if !ok || !errors.Is(errs[i], ErrUnavailable) {
+				continue
+			}
+			if quote, ok := fallback.CachedQuote(symbol); ok {
+				c.served(ctx, span, symbol, quote, len(errs))
+				return quote, nil
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil, ErrUnavailable
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrUnknownSymbol) {
+			return nil, err
+		}
+	}
+	return nil, errs[0]
+}
+
+// served records which provider a quote came from and after how many
+// others failed.
+func (c *Composite) served(ctx context.Context, span trace.Span, symbol string, quote *Quote, failovers int) {
+	span.SetAttributes(sourceKey.String(quote.Source), failoversKey.Int(failovers), cachedKey.Bool(quote.CachedAt != nil))
+	metrics.QuoteServed(quote.Source)
+	if failovers > 0 {
+		loggerFrom(ctx).Warn().Str("symbol", symbol).Str("source", quote.Source).
+			Int("failovers", failovers).Bool("cached", quote.CachedAt != nil).Msg("Quote served by a fallback provider")
+	}
+}
+
+func loggerFrom(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
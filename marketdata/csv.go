@@ -0,0 +1,151 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+// CSVProvider serves quotes from a file an operator maintains, such as an
+// end of day export, with a header row naming the columns: symbol and price
+// are required, volume and date (YYYY-MM-DD) optional. The file is read again
+// whenever it changes.
+type CSVProvider struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	quotes  map[string]Quote
+}
+
+func NewCSVProvider(path string) *CSVProvider {
+	return &CSVProvider{path: path}
+}
+
+func (p *CSVProvider) Name() string {
+	return "csv"
+}
+
+func (p *CSVProvider) Quote(_ context.Context, symbol string) (*Quote, error) {
+	quotes, err := p.load()
+	if err != nil {
+		return nil, fmt.Errorf("csv quotes %s: %w: %w", p.path, ErrUnavailable, err)
+	}
+
+	quote, ok := quotes[strings.ToUpper(symbol)]
+	if !ok {
+		return nil, fmt.Errorf("csv quotes %s: %s: %w", p.path, symbol, ErrUnknownSymbol)
+	}
+	return &quote, nil
+}
+
+// load returns the quotes in the file, parsing it again when its size or
+// modification time has changed. A file that no longer parses keeps the
+// quotes read last.
+func (p *CSVProvider) load() (map[string]Quote, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return nil, err
+	}
+	if p.quotes != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return p.quotes, nil
+	}
+
+	file, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	quotes, err := ParseCSV(file, p.Name())
+	if err != nil {
+		if p.quotes == nil {
+			return nil, err
+		}
+		log.Warn().Err(err).Str("path", p.path).Msg("CSV quotes no longer parse, serving the ones read last")
+		p.modTime, p.size = info.ModTime(), info.Size()
+		return p.quotes, nil
+	}
+	p.quotes, p.modTime, p.size = quotes, info.ModTime(), info.Size()
+	return quotes, nil
+}
+
+// ParseCSV reads quotes keyed by upper case symbol, with source set on every
+// one of them.
+func ParseCSV(r io.Reader, source string) (map[string]Quote, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["symbol"]; !ok {
+		return nil, errors.New("header has no symbol column")
+	}
+	if _, ok := columns["price"]; !ok {
+		return nil, errors.New("header has no price column")
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	quotes := make(map[string]Quote)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		symbol := strings.ToUpper(field(record, "symbol"))
+		if symbol == "" {
+			continue
+		}
+		price, err := decimal.NewFromString(field(record, "price"))
+		if err != nil || !price.IsPositive() {
+			return nil, fmt.Errorf("line %d: price %q is not a positive number", line, field(record, "price"))
+		}
+		quote := Quote{Symbol: symbol, Price: price, Source: source}
+
+		if volume := field(record, "volume"); volume != "" {
+			if quote.Volume, err = strconv.ParseInt(volume, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: volume %q is not an integer", line, volume)
+			}
+		}
+		if date := field(record, "date"); date != "" {
+			if _, err := time.Parse(time.DateOnly, date); err != nil {
+				return nil, fmt.Errorf("line %d: date %q is not YYYY-MM-DD", line, date)
+			}
+			quote.TradingDay = date
+		}
+		quotes[symbol] = quote
+	}
+	return quotes, nil
+}
@@ -0,0 +1,101 @@
+package marketdata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParseCSV(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "quotes.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	quotes, err := ParseCSV(file, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Quote{
+		"AAPL": {Symbol: "AAPL", Price: decimal.RequireFromString("227.52"), Volume: 41200300, TradingDay: "2025-10-16", Source: "csv"},
+		"MSFT": {Symbol: "MSFT", Price: decimal.RequireFromString("511.61"), TradingDay: "2025-10-16", Source: "csv"},
+		"IBM":  {Symbol: "IBM", Price: decimal.RequireFromString("287.15"), Source: "csv"},
+	}
+	if len(quotes) != len(want) {
+		t.Fatalf("got %d quotes, want %d: %+v", len(quotes), len(want), quotes)
+	}
+	for symbol, w := range want {
+		got, ok := quotes[symbol]
+		if !ok {
+			t.Fatalf("no quote for %s", symbol)
+		}
+		if got.Symbol != w.Symbol || !got.Price.Equal(w.Price) || got.Volume != w.Volume ||
+			got.TradingDay != w.TradingDay || got.Source != w.Source {
+			t.Errorf("%s = %+v, want %+v", symbol, got, w)
+		}
+	}
+}
+
+func TestParseCSVRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty file", "", "read header"},
+		{"no symbol column", "ticker,price\nAAPL,1\n", "no symbol column"},
+		{"no price column", "symbol,last\nAAPL,1\n", "no price column"},
+		{"price not a number", "symbol,price\nAAPL,1\nMSFT,n/a\n", `line 3: price "n/a"`},
+		{"price not positive", "symbol,price\nAAPL,0\n", `line 2: price "0"`},
+		{"volume not an integer", "symbol,price,volume\nAAPL,1,1.5\n", `line 2: volume "1.5"`},
+		{"date not a day", "symbol,price,date\nAAPL,1,16/10/2025\n", `line 2: date "16/10/2025"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCSV(strings.NewReader(tt.input), "csv")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.csv")
+	if err := os.WriteFile(path, []byte("symbol,price\nAAPL,227.52\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider := NewCSVProvider(path)
+
+	quote, err := provider.Quote(t.Context(), "aapl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !quote.Price.Equal(decimal.RequireFromString("227.52")) {
+		t.Fatalf("price = %s, want 227.52", quote.Price)
+	}
+	if _, err := provider.Quote(t.Context(), "MSFT"); !errors.Is(err, ErrUnknownSymbol) {
+		t.Fatalf("err = %v, want ErrUnknownSymbol", err)
+	}
+
+	// a file that stops parsing keeps serving the quotes read last
+	if err := os.WriteFile(path, []byte("symbol,price\nAAPL,broken,and longer\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if quote, err = provider.Quote(t.Context(), "AAPL"); err != nil || !quote.Price.Equal(decimal.RequireFromString("227.52")) {
+		t.Fatalf("quote = %+v, err = %v, want the last good quote", quote, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Quote(t.Context(), "AAPL"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+}
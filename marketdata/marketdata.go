@@ -0,0 +1,60 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrUnknownSymbol is returned by a provider that has no quote for a
+	// symbol.
+	ErrUnknownSymbol = errors.New("unknown symbol")
+	// ErrUnavailable is matched by every error of a provider that could not
+	// answer at all: it is down, throttled or out of budget.
+	ErrUnavailable = errors.New("market data is unavailable")
+)
+
+// Quote is the last trade of a symbol as every provider reports it.
+type Quote struct {
+	Symbol     string          `json:"symbol"`
+	Price      decimal.Decimal `json:"price"`
+	Volume     int64           `json:"volume,omitempty"`
+	TradingDay string          `json:"tradingDay,omitempty"` // YYYY-MM-DD
+	// Source is the provider that served the quote.
+	Source string `json:"source"`
+	// CachedAt is set when the provider could not be reached and answered
+	// with a quote it had received earlier.
+	CachedAt *time.Time `json:"cachedAt,omitempty"`
+}
+
+// Provider is a source of quotes. Quote returns an error matching
+// ErrUnknownSymbol for a symbol it does not know and one matching
+// ErrUnavailable when it cannot answer right now.
+type Provider interface {
+	Name() string
+	Quote(ctx context.Context, symbol string) (*Quote, error)
+}
+
+// Fallback is a Provider that keeps what it served, to answer with while it
+// cannot be reached.
+type Fallback interface {
+	CachedQuote(symbol string) (*Quote, bool)
+}
+
+type noFallbackCtxKey struct{}
+
+// WithoutFallback marks the quotes asked for with ctx as needing a live
+// answer, such as the price a trade executes at: providers fail rather than
+// answer from a cache.
+func WithoutFallback(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noFallbackCtxKey{}, true)
+}
+
+// FallbackAllowed reports whether a provider may answer ctx from a cache.
+func FallbackAllowed(ctx context.Context) bool {
+	noFallback, _ := ctx.Value(noFallbackCtxKey{}).(bool)
+	return !noFallback
+}
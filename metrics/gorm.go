@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+var (
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time spent in GORM statements, by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+
+	dbErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "GORM statements that failed, not counting record not found.",
+	}, []string{"operation", "table"})
+)
+
+const startedKey = "tradealpha:metrics_started"
+
+// GormPlugin times every statement and registers the connection pool stats
+// of the database it is used on.
+type GormPlugin struct {
+	DBName string
+}
+
+func (GormPlugin) Name() string {
+	return "tradealpha:metrics"
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	if sqlDB, err := db.DB(); err == nil {
+		if err := Registry.Register(collectors.NewDBStatsCollector(sqlDB, p.DBName)); err != nil {
+			return err
+		}
+	}
+
+	callbacks := db.Callback()
+	steps := []func() error{
+		func() error {
+			return callbacks.Create().Before("gorm:create").Register("tradealpha:metrics_create", start)
+		},
+		func() error {
+			return callbacks.Create().After("gorm:create").Register("tradealpha:metrics_create_done", observe("create"))
+		},
+		func() error {
+			return callbacks.Query().Before("gorm:query").Register("tradealpha:metrics_query", start)
+		},
+		func() error {
+			return callbacks.Query().After("gorm:query").Register("tradealpha:metrics_query_done", observe("query"))
+		},
+		func() error {
+			return callbacks.Update().Before("gorm:update").Register("tradealpha:metrics_update", start)
+		},
+		func() error {
+			return callbacks.Update().After("gorm:update").Register("tradealpha:metrics_update_done", observe("update"))
+		},
+		func() error {
+			return callbacks.Delete().Before("gorm:delete").Register("tradealpha:metrics_delete", start)
+		},
+		func() error {
+			return callbacks.Delete().After("gorm:delete").Register("tradealpha:metrics_delete_done", observe("delete"))
+		},
+		func() error {
+			return callbacks.Row().Before("gorm:row").Register("tradealpha:metrics_row", start)
+		},
+		func() error {
+			return callbacks.Row().After("gorm:row").Register("tradealpha:metrics_row_done", observe("row"))
+		},
+		func() error {
+			return callbacks.Raw().Before("gorm:raw").Register("tradealpha:metrics_raw", start)
+		},
+		func() error {
+			return callbacks.Raw().After("gorm:raw").Register("tradealpha:metrics_raw_done", observe("raw"))
+		},
+	}
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func start(tx *gorm.DB) {
+	tx.InstanceSet(startedKey, time.Now())
+}
+
+func observe(operation string) func(tx *gorm.DB) {
+	return func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(startedKey)
+		if !ok {
+			return
+		}
+		started := value.(time.Time)
+
+		table := tx.Statement.Table
+		if table == "" {
+			table = "none"
+		}
+
+		dbDuration.WithLabelValues(operation, table).Observe(time.Since(started).Seconds())
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			dbErrors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tradealpha"
+
+// gaugeTimeout bounds a business gauge that has to ask the database, a slow
+// scrape should not pile up queries.
+const gaugeTimeout = 2 * time.Second
+
+// Registry holds every metric the server exposes. It is separate from the
+// prometheus default registry so that only what is registered here, plus the
+// Go and process collectors, ends up on /metrics.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbDuration,
+		dbErrors,
+		upstreamRequests,
+		upstreamDuration,
+		upstreamRetries,
+		upstreamFallbacks,
+		quotesServed,
+		rateLimited,
+	)
+}
+
+// Handler serves the registry. With a token set, scrapers have to send it
+// as a bearer token.
+func Handler(token string) echo.HandlerFunc {
+	serve := echo.WrapHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+	return func(c echo.Context) error {
+		if token != "" {
+			given := c.Request().Header.Get("Authorization")
+			if subtle.ConstantTimeCompare([]byte(given), []byte("Bearer "+token)) != 1 {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+		}
+		return serve(c)
+	}
+}
+
+// Gauge registers a gauge read on every scrape. read gets a ctx that gives
+// up after a couple of seconds; an error leaves the sample at its last
+// value.
+func Gauge(name, help string, read func(ctx context.Context) (float64, error)) {
+	var (
+		mu   sync.Mutex
+		last float64
+	)
+
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), gaugeTimeout)
+		defer cancel()
+
+		value, err := read(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			last = value
+		}
+		return last
+	}))
+}
@@ -0,0 +1,14 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "rate_limited_requests_total",
+	Help:      "Requests turned away with a 429, by rate limit policy.",
+}, []string{"policy"})
+
+// RateLimited records one request rejected by policy.
+func RateLimited(policy string) {
+	rateLimited.WithLabelValues(policy).Inc()
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of an upstream call.
+const (
+	OutcomeOK          = "ok"
+	OutcomeThrottled   = "throttled"    // the provider answered with a rate limit note
+	OutcomeError       = "error"        // the provider answered with an error message
+	OutcomeHTTPFailure = "http_failure" // no answer, or a non 200 status
+)
+
+var (
+	upstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "Calls to market data providers, by provider, function and outcome.",
+	}, []string{"upstream", "function", "outcome"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Time spent on calls to market data providers, by provider and function.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"upstream", "function"})
+
+	upstreamRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Calls to market data providers made again after a transient failure, by provider and function.",
+	}, []string{"upstream", "function"})
+
+	upstreamFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_fallbacks_total",
+		Help:      "Failed calls to market data providers answered from cache instead, by provider and function.",
+	}, []string{"upstream", "function"})
+)
+
+// ObserveUpstream records one call to a provider.
+func ObserveUpstream(upstream, function, outcome string, took time.Duration) {
+	upstreamRequests.WithLabelValues(upstream, function, outcome).Inc()
+	upstreamDuration.WithLabelValues(upstream, function).Observe(took.Seconds())
+}
+
+// UpstreamRetried records a call to a provider being made again.
+func UpstreamRetried(upstream, function string) {
+	upstreamRetries.WithLabelValues(upstream, function).Inc()
+}
+
+// UpstreamFallback records a failed call answered from cache.
+func UpstreamFallback(upstream, function string) {
+	upstreamFallbacks.WithLabelValues(upstream, function).Inc()
+}
+
+var quotesServed = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "quotes_served_total",
+	Help:      "Quotes handed out, by the provider that served them.",
+}, []string{"source"})
+
+// QuoteServed records a quote served by source.
+func QuoteServed(source string) {
+	quotesServed.WithLabelValues(source).Inc()
+}
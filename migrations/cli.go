@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+const usage = `usage: tradealpha [flags] migrate <command>
+
+commands:
+  up [n]     apply pending migrations, all of them or the next n
+  down [n]   roll back the last n applied migrations (default 1)
+  redo       roll back the last applied migration and apply it again
+  status     list migrations and whether they are applied`
+
+// Run executes one migrate subcommand, args being what follows "migrate".
+func Run(m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", usage)
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return fmt.Errorf("%q is not a valid step count", args[1])
+		}
+		steps = n
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := m.Up(steps)
+		fmt.Fprintf(out, "applied %d migration(s)\n", ran)
+		return err
+	case "down":
+		ran, err := m.Down(steps)
+		fmt.Fprintf(out, "reverted %d migration(s)\n", ran)
+		return err
+	case "redo":
+		if err := m.Redo(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "redone last migration")
+		return nil
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			state, at := "pending", ""
+			if s.Applied {
+				state = "applied"
+				at = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
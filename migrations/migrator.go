@@ -0,0 +1,399 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Either the SQL pair or the Go
+// funcs are set; SQL migrations are checksummed by their text, Go migrations
+// by their version, name and Revision, so bump Revision whenever the body of
+// an applied Go migration has to change. The pins in registry_test.go fail
+// on an Up body edited without its Revision.
+type Migration struct {
+	Version  int64
+	Name     string
+	Revision int
+
+	UpSQL   string
+	DownSQL string
+
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+}
+
+func (m Migration) Checksum() string {
+	var source string
+	if m.Up != nil {
+		source = fmt.Sprintf("go:%d:%s:%d", m.Version, m.Name, m.Revision)
+	} else {
+		source = "sql:" + m.UpSQL + "\x00" + m.DownSQL
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m Migration) runUp(tx *gorm.DB) error {
+	if m.Up != nil {
+		return m.Up(tx)
+	}
+	return tx.Exec(m.UpSQL).Error
+}
+
+func (m Migration) runDown(tx *gorm.DB) error {
+	if m.Down != nil {
+		return m.Down(tx)
+	}
+	if m.DownSQL == "" {
+		return fmt.Errorf("migration %d_%s has no down step", m.Version, m.Name)
+	}
+	return tx.Exec(m.DownSQL).Error
+}
+
+// SchemaMigration is a row of the bookkeeping table.
+type SchemaMigration struct {
+	Version    int64     `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name       string    `gorm:"not null" json:"name"`
+	Checksum   string    `gorm:"not null;type:varchar(64)" json:"checksum"`
+	DurationMs int64     `json:"durationMs"`
+	AppliedAt  time.Time `json:"appliedAt"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// SchemaMigrationLock holds at most one row while an instance migrates.
+type SchemaMigrationLock struct {
+	Id       int       `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"not null;type:varchar(191)"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigrationLock) TableName() string {
+	return "schema_migration_lock"
+}
+
+var (
+	ErrLocked           = errors.New("another instance holds the migration lock")
+	ErrChecksumMismatch = errors.New("applied migration does not match its source")
+	ErrUnknownVersion   = errors.New("database has a migration this build does not know")
+)
+
+// Status is the state of one migration, as shown by the status command.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool
+}
+
+// Migrator applies an ordered set of migrations to one database. The lock
+// it holds while migrating is refreshed every LockStale/3, so only an
+// instance that stopped refreshing for LockStale loses it.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	owner      string
+	LockWait   time.Duration
+	LockStale  time.Duration
+	pollEvery  time.Duration
+}
+
+func New(db *gorm.DB, migrations []Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Up == nil && m.UpSQL == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up step", m.Version, m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+
+	host, _ := os.Hostname()
+	return &Migrator{
+		db:         db,
+		migrations: sorted,
+		owner:      fmt.Sprintf("%s/%d/%s", host, os.Getpid(), uuid.New().String()[:8]),
+		LockWait:   2 * time.Minute,
+		LockStale:  15 * time.Minute,
+		pollEvery:  time.Second,
+	}, nil
+}
+
+func (m *Migrator) ensureTables() error {
+	return m.db.AutoMigrate(&SchemaMigration{}, &SchemaMigrationLock{})
+}
+
+// lock takes the single lock row. A row not refreshed for LockStale is
+// assumed to belong to a crashed instance and is taken over.
+func (m *Migrator) lock() error {
+	deadline := time.Now().Add(m.LockWait)
+
+	for {
+		err := m.db.Create(&SchemaMigrationLock{Id: 1, Owner: m.owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			return nil
+		}
+
+		var held SchemaMigrationLock
+		if findErr := m.db.Where("id = ?", 1).First(&held).Error; findErr == nil && time.Since(held.LockedAt) > m.LockStale {
+			log.Warn().Str("owner", held.Owner).Time("lockedAt", held.LockedAt).Msg("taking over a stale migration lock")
+			m.db.Where("id = ? AND owner = ?", 1, held.Owner).Delete(&SchemaMigrationLock{})
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		time.Sleep(m.pollEvery)
+	}
+}
+
+// keepLock refreshes the lock row until the returned func is called, so a
+// migration running longer than LockStale is not taken over.
+func (m *Migrator) keepLock() (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(m.LockStale / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := m.db.Model(&SchemaMigrationLock{}).Where("id = ? AND owner = ?", 1, m.owner).Update("locked_at", time.Now()).Error; err != nil {
+					log.Error().Err(err).Msg("issue persist in migrations/keepLock")
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func (m *Migrator) unlock() {
+	if err := m.db.Where("id = ? AND owner = ?", 1, m.owner).Delete(&SchemaMigrationLock{}).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in migrations/unlock")
+	}
+}
+
+func (m *Migrator) withLock(fn func() error) error {
+	if err := m.ensureTables(); err != nil {
+		return err
+	}
+	if err := m.lock(); err != nil {
+		return err
+	}
+	defer m.unlock()
+	stop := m.keepLock()
+	defer stop()
+	return fn()
+}
+
+func (m *Migrator) applied() (map[int64]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := m.db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[int64]SchemaMigration, len(rows))
+	for _, r := range rows {
+		out[r.Version] = r
+	}
+	return out, nil
+}
+
+// verify refuses to go on when an applied migration was edited or when the
+// database is ahead of this build.
+func (m *Migrator) verify(applied map[int64]SchemaMigration) error {
+	known := make(map[int64]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		if row, ok := applied[mig.Version]; ok && row.Checksum != mig.Checksum() {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+	}
+	for version, row := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: %d_%s", ErrUnknownVersion, version, row.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies pending migrations in order, at most steps of them (0 = all),
+// and returns how many ran.
+func (m *Migrator) Up(steps int) (int, error) {
+	ran := 0
+	err := m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if steps > 0 && ran >= steps {
+				break
+			}
+			if err := m.apply(mig); err != nil {
+				return err
+			}
+			ran++
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down rolls back the last steps applied migrations (at least one).
+func (m *Migrator) Down(steps int) (int, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	ran := 0
+	err := m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && ran < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.revert(mig); err != nil {
+				return err
+			}
+			ran++
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Redo rolls back the latest applied migration and applies it again.
+func (m *Migrator) Redo() error {
+	return m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.revert(mig); err != nil {
+				return err
+			}
+			return m.apply(mig)
+		}
+		return errors.New("no applied migration to redo")
+	})
+}
+
+// Status lists every known migration and whether it is applied. It does not
+// take the lock, so it is safe to call while another instance migrates.
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.ensureTables(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if row, ok := applied[mig.Version]; ok {
+			at := row.AppliedAt
+			s.Applied = true
+			s.AppliedAt = &at
+			s.Modified = row.Checksum != mig.Checksum()
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Pending is the number of known migrations not yet applied.
+func (m *Migrator) Pending() (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) apply(mig Migration) error {
+	start := time.Now()
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := mig.runUp(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{
+			Version:    mig.Version,
+			Name:       mig.Name,
+			Checksum:   mig.Checksum(),
+			DurationMs: time.Since(start).Milliseconds(),
+			AppliedAt:  time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+	}
+	log.Info().Int64("version", mig.Version).Str("name", mig.Name).Dur("took", time.Since(start)).Msg("applied migration")
+	return nil
+}
+
+func (m *Migrator) revert(mig Migration) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := mig.runDown(tx); err != nil {
+			return err
+		}
+		return tx.Where("version = ?", mig.Version).Delete(&SchemaMigration{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+	}
+	log.Info().Int64("version", mig.Version).Str("name", mig.Name).Msg("reverted migration")
+	return nil
+}
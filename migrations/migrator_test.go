@@ -0,0 +1,227 @@
+package migrations
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func memoryDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	// the lock is expected to be contended, keep its failed inserts quiet
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db
+}
+
+var testMigrations = []Migration{
+	{Version: 3, Name: "create_c", UpSQL: "CREATE TABLE c (id INTEGER)", DownSQL: "DROP TABLE c"},
+	{Version: 1, Name: "create_a", UpSQL: "CREATE TABLE a (id INTEGER)", DownSQL: "DROP TABLE a"},
+	{
+		Version: 2,
+		Name:    "create_b",
+		Up:      func(tx *gorm.DB) error { return tx.Exec("CREATE TABLE b (id INTEGER)").Error },
+		Down:    func(tx *gorm.DB) error { return tx.Exec("DROP TABLE b").Error },
+	},
+}
+
+func testMigrator(t *testing.T, db *gorm.DB, migrations []Migration) *Migrator {
+	t.Helper()
+	m, err := New(db, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.LockWait = 0
+	m.pollEvery = time.Millisecond
+	return m
+}
+
+// tables lists which of a, b and c exist.
+func tables(db *gorm.DB) string {
+	var have []string
+	for _, name := range []string{"a", "b", "c"} {
+		if db.Migrator().HasTable(name) {
+			have = append(have, name)
+		}
+	}
+	return strings.Join(have, ",")
+}
+
+func appliedVersions(t *testing.T, m *Migrator) string {
+	t.Helper()
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Name)
+		}
+	}
+	return strings.Join(versions, ",")
+}
+
+func TestMigratorUpDownRedo(t *testing.T) {
+	db := memoryDB(t)
+	m := testMigrator(t, db, testMigrations)
+
+	steps := []struct {
+		name        string
+		run         func() (int, error)
+		wantRan     int
+		wantTables  string
+		wantApplied string
+	}{
+		{"up one", func() (int, error) { return m.Up(1) }, 1, "a", "create_a"},
+		{"up the rest", func() (int, error) { return m.Up(0) }, 2, "a,b,c", "create_a,create_b,create_c"},
+		{"up with nothing pending", func() (int, error) { return m.Up(0) }, 0, "a,b,c", "create_a,create_b,create_c"},
+		{"down defaults to one", func() (int, error) { return m.Down(0) }, 1, "a,b", "create_a,create_b"},
+		{"redo", func() (int, error) { return 1, m.Redo() }, 1, "a,b", "create_a,create_b"},
+		{"down past the first", func() (int, error) { return m.Down(5) }, 2, "", ""},
+	}
+	for _, step := range steps {
+		ran, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if ran != step.wantRan || tables(db) != step.wantTables || appliedVersions(t, m) != step.wantApplied {
+			t.Errorf("%s: ran %d, tables %q, applied %q, want %d, %q, %q", step.name, ran, tables(db), appliedVersions(t, m), step.wantRan, step.wantTables, step.wantApplied)
+		}
+	}
+
+	if err := m.Redo(); err == nil {
+		t.Error("redo with nothing applied succeeded")
+	}
+	if pending, err := m.Pending(); err != nil || pending != 3 {
+		t.Errorf("Pending = %d, %v, want 3", pending, err)
+	}
+}
+
+func TestMigratorFailedUpRollsBack(t *testing.T) {
+	db := memoryDB(t)
+	broken := append([]Migration{{Version: 4, Name: "broken", Up: func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE TABLE d (id INTEGER)").Error; err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}}}, testMigrations...)
+	m := testMigrator(t, db, broken)
+
+	ran, err := m.Up(0)
+	if err == nil || !strings.Contains(err.Error(), "migration 4_broken up: boom") {
+		t.Fatalf("err = %v, want the failing migration named", err)
+	}
+	if ran != 3 || db.Migrator().HasTable("d") || appliedVersions(t, m) != "create_a,create_b,create_c" {
+		t.Errorf("ran %d, applied %q, want the first three and no table d", ran, appliedVersions(t, m))
+	}
+	if _, err := m.Down(0); err != nil {
+		t.Errorf("down after a failed up: %v", err)
+	}
+}
+
+func TestMigratorStatusAndChecksums(t *testing.T) {
+	db := memoryDB(t)
+	if _, err := testMigrator(t, db, testMigrations).Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := append([]Migration(nil), testMigrations...)
+	edited[1].UpSQL = "CREATE TABLE a (id INTEGER, name TEXT)"
+	m := testMigrator(t, db, edited)
+
+	if _, err := m.Up(0); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("up with an edited migration: err = %v, want ErrChecksumMismatch", err)
+	}
+	var out bytes.Buffer
+	if err := Run(m, []string{"status"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(strings.Join(strings.Fields(lines[1]), " "), "0001 create_a modified ") || !strings.HasPrefix(strings.Join(strings.Fields(lines[2]), " "), "0002 create_b applied ") {
+		t.Errorf("status:\n%s", out.String())
+	}
+
+	// a bumped Revision is a modified Go migration too
+	revised := append([]Migration(nil), testMigrations...)
+	revised[2].Revision = 1
+	if _, err := testMigrator(t, db, revised).Down(1); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("down with a revised migration: err = %v, want ErrChecksumMismatch", err)
+	}
+
+	if _, err := testMigrator(t, db, testMigrations[1:]).Up(0); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("up on a newer database: err = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	db := memoryDB(t)
+	m := testMigrator(t, db, testMigrations)
+	if err := m.ensureTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	held := SchemaMigrationLock{Id: 1, Owner: "other", LockedAt: time.Now()}
+	if err := db.Create(&held).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("up while another instance migrates: err = %v, want ErrLocked", err)
+	}
+	if tables(db) != "" {
+		t.Errorf("tables %q created without the lock", tables(db))
+	}
+
+	// the other instance crashed a while ago
+	if err := db.Model(&held).Update("locked_at", time.Now().Add(-m.LockStale-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ran, err := m.Up(0); err != nil || ran != 3 {
+		t.Fatalf("up over a stale lock = %d, %v, want 3", ran, err)
+	}
+	var count int64
+	if err := db.Model(&SchemaMigrationLock{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("%d lock rows left, want the lock released", count)
+	}
+}
+
+func TestMigratorLockRefreshed(t *testing.T) {
+	db := memoryDB(t)
+	m := testMigrator(t, db, testMigrations)
+	m.LockStale = 30 * time.Millisecond
+	other := testMigrator(t, db, testMigrations)
+	other.LockStale = m.LockStale
+
+	var otherErr error
+	err := m.withLock(func() error {
+		// a long migration, several times the stale age
+		time.Sleep(4 * m.LockStale)
+		_, otherErr = other.Up(0)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(otherErr, ErrLocked) {
+		t.Errorf("second instance during a long migration: err = %v, want ErrLocked", otherErr)
+	}
+	if tables(db) != "" {
+		t.Errorf("second instance created %q under a held lock", tables(db))
+	}
+}
@@ -0,0 +1,638 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/money"
+	"gorm.io/gorm"
+)
+
+// All returns every migration this build knows about. Append new ones with
+// the next version number and never edit one that has shipped: add a new
+// migration instead (or bump Revision and its pin in registry_test.go if a
+// Go body really must change).
+//
+// Tables are created from the frozen structs in schema.go, never from the
+// current models, so every column change needs a migration of its own.
+func All() []Migration {
+	return []Migration{
+		createCoreTables,
+		createSecurityTables,
+		createHoldingLots,
+		backfillLifecycleColumns,
+		seedRoles,
+		seedReferenceStocks,
+		uniqueWatchListStock,
+		transactionPriceSource,
+		createPriceBars,
+		createCorporateActions,
+		multiCurrency,
+		decimalMoney,
+		fractionalQuantities,
+		tradeFees,
+		marginAccounts,
+		auditLogAppendOnly,
+		uniqueReversals,
+		uniqueHoldings,
+	}
+}
+
+// dropTables skips missing tables: MySQL commits DDL implicitly, so a failed
+// up step may have created only some of them.
+func dropTables(tx *gorm.DB, tables ...interface{}) error {
+	for _, table := range tables {
+		if !tx.Migrator().HasTable(table) {
+			continue
+		}
+		if err := tx.Migrator().DropTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var createCoreTables = Migration{
+	Version: 1,
+	Name:    "create_core_tables",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(
+			&v1Role{},
+			&v1User{},
+			&v1Address{},
+			&v1Stock{},
+			&v1PortFolio{},
+			&v1PortFolioStock{},
+			&v1Transaction{},
+			&v1WatchList{},
+			&v1WatchListStock{},
+			&v1Notification{},
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx,
+			&v1Notification{},
+			&v1WatchListStock{},
+			&v1WatchList{},
+			&v1Transaction{},
+			&v1PortFolioStock{},
+			&v1PortFolio{},
+			&v1Stock{},
+			&v1Address{},
+			&v1User{},
+			&v1Role{},
+		)
+	},
+}
+
+var createSecurityTables = Migration{
+	Version: 2,
+	Name:    "create_security_tables",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(
+			&v2APIKey{},
+			&v2APIKeyUsage{},
+			&v2RecoveryCode{},
+			&v2AuditLog{},
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx,
+			&v2AuditLog{},
+			&v2RecoveryCode{},
+			&v2APIKeyUsage{},
+			&v2APIKey{},
+		)
+	},
+}
+
+var createHoldingLots = Migration{
+	Version: 3,
+	Name:    "create_holding_lots",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v3HoldingLot{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &v3HoldingLot{})
+	},
+}
+
+// backfillLifecycleColumns fixes rows written before users got is_active on
+// create and before transactions carried a trade date.
+var backfillLifecycleColumns = Migration{
+	Version: 4,
+	Name:    "backfill_lifecycle_columns",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Model(&v1User{}).
+			Where("deactivated_at IS NULL AND is_active = ?", false).
+			Update("is_active", true).Error; err != nil {
+			return err
+		}
+		return tx.Model(&v1Transaction{}).
+			Session(&gorm.Session{SkipHooks: true}).
+			Where("trade_date IS NULL").
+			Update("trade_date", gorm.Expr("created_at")).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		// the old values carried no information, nothing to restore
+		return nil
+	},
+}
+
+// uniqueWatchListStock drops duplicate watchlist entries, keeping the oldest,
+// and adds the (watchlist_id, stock_id) unique index. The duplicates are
+// found in Go because MySQL cannot delete from a table it selects from.
+var uniqueWatchListStock = Migration{
+	Version: 7,
+	Name:    "unique_watchlist_stock",
+	Up: func(tx *gorm.DB) error {
+		var rows []v1WatchListStock
+		if err := tx.Order("created_at asc").Find(&rows).Error; err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool, len(rows))
+		var duplicates []string
+		for _, row := range rows {
+			key := row.WatchListId + "/" + row.StockId
+			if seen[key] {
+				duplicates = append(duplicates, row.Id)
+				continue
+			}
+			seen[key] = true
+		}
+		if len(duplicates) > 0 {
+			if err := tx.Where("id IN ?", duplicates).Delete(&v1WatchListStock{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if tx.Migrator().HasIndex(&v7WatchListStock{}, "idx_watchlist_stock") {
+			return nil
+		}
+		return tx.Migrator().CreateIndex(&v7WatchListStock{}, "idx_watchlist_stock")
+	},
+	Down: func(tx *gorm.DB) error {
+		if !tx.Migrator().HasIndex(&v7WatchListStock{}, "idx_watchlist_stock") {
+			return nil
+		}
+		return tx.Migrator().DropIndex(&v7WatchListStock{}, "idx_watchlist_stock")
+	},
+}
+
+// transactionPriceSource records which quote provider priced each trade.
+// Trades booked before it have no source.
+var transactionPriceSource = Migration{
+	Version: 8,
+	Name:    "transaction_price_source",
+	Up: func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&v8Transaction{}, "PriceSource") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&v8Transaction{}, "PriceSource")
+	},
+	Down: func(tx *gorm.DB) error {
+		if !tx.Migrator().HasColumn(&v8Transaction{}, "PriceSource") {
+			return nil
+		}
+		return tx.Migrator().DropColumn(&v8Transaction{}, "PriceSource")
+	},
+}
+
+// createPriceBars adds the daily price history that imports load.
+var createPriceBars = Migration{
+	Version: 9,
+	Name:    "create_price_bars",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&v9PriceBar{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &v9PriceBar{})
+	},
+}
+
+// createCorporateActions adds splits and dividends, and links the dividend
+// transactions they book.
+var createCorporateActions = Migration{
+	Version: 10,
+	Name:    "create_corporate_actions",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&v10CorporateAction{}); err != nil {
+			return err
+		}
+		if tx.Migrator().HasColumn(&v10Transaction{}, "CorporateActionId") {
+			return nil
+		}
+		if err := tx.Migrator().AddColumn(&v10Transaction{}, "CorporateActionId"); err != nil {
+			return err
+		}
+		return tx.Migrator().CreateIndex(&v10Transaction{}, "CorporateActionId")
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&v10Transaction{}, "CorporateActionId") {
+			if err := tx.Migrator().DropColumn(&v10Transaction{}, "CorporateActionId"); err != nil {
+				return err
+			}
+		}
+		return dropTables(tx, &v10CorporateAction{})
+	},
+}
+
+// currencyColumns are the columns multiCurrency adds, by model. Their
+// defaults make every existing amount a US dollar one at a rate of 1.
+var currencyColumns = []struct {
+	model  interface{}
+	fields []string
+}{
+	{&v11Stock{}, []string{"Currency"}},
+	{&v11User{}, []string{"BaseCurrency"}},
+	{&v11PortFolio{}, []string{"BaseCurrency"}},
+	{&v11Transaction{}, []string{"Currency", "FxRate"}},
+	{&v11HoldingLot{}, []string{"FxRate"}},
+	{&v11PortFolioStock{}, []string{"Currency", "AveragePriceBase", "RealizedGainsBase"}},
+}
+
+// multiCurrency adds the historical exchange rates and the currency of
+// stocks, trades, lots and holdings, and the base currency of users and
+// portfolios. Holdings had only US dollar amounts, so their base amounts
+// start out as copies.
+var multiCurrency = Migration{
+	Version: 11,
+	Name:    "multi_currency",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&v11FxRate{}); err != nil {
+			return err
+		}
+		added := false
+		for _, table := range currencyColumns {
+			for _, field := range table.fields {
+				if tx.Migrator().HasColumn(table.model, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(table.model, field); err != nil {
+					return err
+				}
+				added = true
+			}
+		}
+		if !added {
+			return nil
+		}
+		return tx.Model(&v1PortFolioStock{}).
+			Session(&gorm.Session{SkipHooks: true, AllowGlobalUpdate: true}).
+			Updates(map[string]interface{}{
+				"average_price_base":  gorm.Expr("average_price"),
+				"realized_gains_base": gorm.Expr("realized_gains"),
+			}).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, table := range currencyColumns {
+			for _, field := range table.fields {
+				if !tx.Migrator().HasColumn(table.model, field) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(table.model, field); err != nil {
+					return err
+				}
+			}
+		}
+		return dropTables(tx, &v11FxRate{})
+	},
+}
+
+// decimalColumns are the money, price and rate columns decimalMoney turns
+// from floating point into decimal(24,8), by model.
+var decimalColumns = []struct {
+	model  interface{}
+	fields []string
+}{
+	{&v12User{}, []string{"AccountBalance"}},
+	{&v12Stock{}, []string{"Price"}},
+	{&v12PortFolio{}, []string{"TotalValue", "UnRealizedGains", "RealizedGains"}},
+	{&v12PortFolioStock{}, []string{"AveragePrice", "RealizedGains", "AveragePriceBase", "RealizedGainsBase"}},
+	{&v12Transaction{}, []string{"Price", "FxRate"}},
+	{&v12HoldingLot{}, []string{"Price", "FxRate"}},
+	{&v12PriceBar{}, []string{"Open", "High", "Low", "Close"}},
+	{&v12CorporateAction{}, []string{"Ratio", "Amount"}},
+	{&v12FxRate{}, []string{"Rate"}},
+}
+
+// decimalMoney stores amounts as decimals rather than floats, then rounds
+// the cash balances and portfolio totals to the minor units of their
+// currency, which float arithmetic had left a fraction of a cent off.
+var decimalMoney = Migration{
+	Version: 12,
+	Name:    "decimal_money",
+	Up: func(tx *gorm.DB) error {
+		// SQLite has no fixed point type to change to, and altering a column
+		// rebuilds the table, which the foreign keys of other tables refuse
+		if tx.Dialector.Name() == config.DriverSQLite {
+			return roundAmounts(tx)
+		}
+		for _, table := range decimalColumns {
+			for _, field := range table.fields {
+				if err := tx.Migrator().AlterColumn(table.model, field); err != nil {
+					return err
+				}
+			}
+		}
+		return roundAmounts(tx)
+	},
+	Down: func(tx *gorm.DB) error {
+		// the decimal columns hold every value the float ones did, and the
+		// code before this migration reads them back as floats
+		return nil
+	},
+}
+
+func roundAmounts(tx *gorm.DB) error {
+	if err := roundByCurrency(tx, &v1User{}, "account_balance"); err != nil {
+		return err
+	}
+	return roundByCurrency(tx, &v1PortFolio{}, "total_value", "unrealized_gains", "realized_gains")
+}
+
+// roundByCurrency rounds columns of model, a table with a base_currency, to
+// the minor units of each row's currency.
+func roundByCurrency(tx *gorm.DB, model interface{}, columns ...string) error {
+	var currencies []string
+	if err := tx.Model(model).Distinct().Pluck("base_currency", &currencies).Error; err != nil {
+		return err
+	}
+	for _, currency := range currencies {
+		places := money.SpecOf(currency).Places
+		fields := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			fields[column] = gorm.Expr("ROUND("+column+", ?)", places)
+		}
+		if err := tx.Model(model).
+			Session(&gorm.Session{SkipHooks: true}).
+			Where("base_currency = ?", currency).
+			Updates(fields).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fractionalQuantities turns the integer share quantities into decimals.
+// Every whole number stored fits the new type as it is.
+var fractionalQuantities = Migration{
+	Version: 13,
+	Name:    "fractional_quantities",
+	Up: func(tx *gorm.DB) error {
+		// SQLite stores a fraction in an integer column as a real, see
+		// decimalMoney for why its tables are not rebuilt
+		if tx.Dialector.Name() == config.DriverSQLite {
+			return nil
+		}
+		for _, model := range []interface{}{&v13Transaction{}, &v13HoldingLot{}, &v13PortFolioStock{}} {
+			if err := tx.Migrator().AlterColumn(model, "Quantity"); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		var fractional int64
+		for _, model := range []interface{}{&v1Transaction{}, &v3HoldingLot{}, &v1PortFolioStock{}} {
+			if err := tx.Model(model).Where("quantity <> ROUND(quantity, 0)").Count(&fractional).Error; err != nil {
+				return err
+			}
+			if fractional > 0 {
+				return errors.New("fractional quantities are stored, an integer column cannot hold them")
+			}
+		}
+		// the integer columns are not restored, the code before this
+		// migration reads whole numbers back from the decimal ones
+		return nil
+	},
+}
+
+// tradeFees adds the fee schedules of portfolios and the fees charged on
+// each trade. Trades booked before were free.
+var tradeFees = Migration{
+	Version: 14,
+	Name:    "trade_fees",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&v14FeeSchedule{}); err != nil {
+			return err
+		}
+		for _, field := range []string{"Commission", "RegulatoryFee"} {
+			if tx.Migrator().HasColumn(&v14Transaction{}, field) {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(&v14Transaction{}, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, field := range []string{"Commission", "RegulatoryFee"} {
+			if !tx.Migrator().HasColumn(&v14Transaction{}, field) {
+				continue
+			}
+			if err := tx.Migrator().DropColumn(&v14Transaction{}, field); err != nil {
+				return err
+			}
+		}
+		return dropTables(tx, &v14FeeSchedule{})
+	},
+}
+
+// marginAccounts adds the margin flag of portfolios and marks the trades
+// that moved their owner's cash. No portfolio was a margin account before.
+var marginAccounts = Migration{
+	Version: 15,
+	Name:    "margin_accounts",
+	Up: func(tx *gorm.DB) error {
+		columns := []struct {
+			model interface{}
+			field string
+		}{
+			{&v15PortFolio{}, "Margin"},
+			{&v15Transaction{}, "CashSettled"},
+		}
+		for _, c := range columns {
+			if tx.Migrator().HasColumn(c.model, c.field) {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(c.model, c.field); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		var shorts int64
+		if err := tx.Model(&v1PortFolioStock{}).Where("quantity < 0").Count(&shorts).Error; err != nil {
+			return err
+		}
+		if shorts > 0 {
+			return errors.New("short positions are open, the code before this migration cannot hold them")
+		}
+		if tx.Migrator().HasColumn(&v15Transaction{}, "CashSettled") {
+			if err := tx.Migrator().DropColumn(&v15Transaction{}, "CashSettled"); err != nil {
+				return err
+			}
+		}
+		if tx.Migrator().HasColumn(&v15PortFolio{}, "Margin") {
+			return tx.Migrator().DropColumn(&v15PortFolio{}, "Margin")
+		}
+		return nil
+	},
+}
+
+// auditLogAppendOnly backs the model hooks with triggers, so that raw SQL
+// and sessions that skip hooks cannot rewrite or delete audit entries
+// either. Dropping the table is still possible, it takes a down migration.
+var auditLogAppendOnly = Migration{
+	Version: 16,
+	Name:    "audit_log_append_only",
+	Up: func(tx *gorm.DB) error {
+		var statements []string
+		switch tx.Dialector.Name() {
+		case config.DriverPostgres:
+			statements = []string{
+				`CREATE OR REPLACE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit log entries are append-only';
+END;
+$$ LANGUAGE plpgsql`,
+				`CREATE TRIGGER audit_log_no_change BEFORE UPDATE OR DELETE ON audit_log_models
+	FOR EACH ROW EXECUTE FUNCTION audit_log_immutable()`,
+				`CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log_models
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_log_immutable()`,
+			}
+		case config.DriverMySQL:
+			statements = []string{
+				`CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log_models
+	FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit log entries are append-only'`,
+				`CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log_models
+	FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit log entries are append-only'`,
+			}
+		default:
+			statements = []string{
+				`CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log_models
+BEGIN
+	SELECT RAISE(ABORT, 'audit log entries are append-only');
+END`,
+				`CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log_models
+BEGIN
+	SELECT RAISE(ABORT, 'audit log entries are append-only');
+END`,
+			}
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		var statements []string
+		switch tx.Dialector.Name() {
+		case config.DriverPostgres:
+			statements = []string{
+				`DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log_models`,
+				`DROP TRIGGER IF EXISTS audit_log_no_change ON audit_log_models`,
+				`DROP FUNCTION IF EXISTS audit_log_immutable()`,
+			}
+		default:
+			statements = []string{
+				`DROP TRIGGER IF EXISTS audit_log_no_delete`,
+				`DROP TRIGGER IF EXISTS audit_log_no_update`,
+			}
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// uniqueReversals makes the index on reversal_of_id unique, so two
+// concurrent corrections of one transaction cannot both book a reversal.
+// A database that already holds such a pair is left for an operator to
+// resolve: which reversal to keep is a financial decision.
+var uniqueReversals = Migration{
+	Version: 17,
+	Name:    "unique_reversals",
+	Up: func(tx *gorm.DB) error {
+		var reversed []string
+		if err := tx.Model(&v1Transaction{}).
+			Where("reversal_of_id IS NOT NULL").
+			Group("reversal_of_id").
+			Having("COUNT(*) > 1").
+			Pluck("reversal_of_id", &reversed).Error; err != nil {
+			return err
+		}
+		if len(reversed) > 0 {
+			return fmt.Errorf("transactions reversed more than once: %s", strings.Join(reversed, ", "))
+		}
+		if tx.Migrator().HasIndex(&v17Transaction{}, reversalIndex) {
+			if err := tx.Migrator().DropIndex(&v17Transaction{}, reversalIndex); err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().CreateIndex(&v17Transaction{}, reversalIndex)
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Migrator().HasIndex(&v17Transaction{}, reversalIndex) {
+			if err := tx.Migrator().DropIndex(&v17Transaction{}, reversalIndex); err != nil {
+				return err
+			}
+		}
+		return tx.Exec("CREATE INDEX " + reversalIndex + " ON transaction_models (reversal_of_id)").Error
+	},
+}
+
+const reversalIndex = "idx_transaction_models_reversal_of_id"
+
+// uniqueHoldings adds the (portfolio_id, stock_id) unique index, so two
+// concurrent trades in a new position cannot both create its row. Holdings
+// are rebuilt from the trade history on every write, so of duplicates the
+// row updated last is kept and the next trade in the stock rewrites it.
+var uniqueHoldings = Migration{
+	Version: 18,
+	Name:    "unique_holdings",
+	Up: func(tx *gorm.DB) error {
+		var rows []v1PortFolioStock
+		if err := tx.Order("updated_at desc").Find(&rows).Error; err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool, len(rows))
+		var duplicates []string
+		for _, row := range rows {
+			key := row.PortFolioId + "/" + row.StockId
+			if seen[key] {
+				duplicates = append(duplicates, row.Id)
+				continue
+			}
+			seen[key] = true
+		}
+		if len(duplicates) > 0 {
+			if err := tx.Where("id IN ?", duplicates).Delete(&v1PortFolioStock{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if tx.Migrator().HasIndex(&v18PortFolioStock{}, "idx_portfolio_stock") {
+			return nil
+		}
+		return tx.Migrator().CreateIndex(&v18PortFolioStock{}, "idx_portfolio_stock")
+	},
+	Down: func(tx *gorm.DB) error {
+		if !tx.Migrator().HasIndex(&v18PortFolioStock{}, "idx_portfolio_stock") {
+			return nil
+		}
+		return tx.Migrator().DropIndex(&v18PortFolioStock{}, "idx_portfolio_stock")
+	},
+}
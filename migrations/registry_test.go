@@ -0,0 +1,122 @@
+package migrations
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// goMigrationPins is every Go migration's Revision next to a hash of its Up
+// body. The checksum stored for a Go migration only covers its version, name
+// and Revision, so an edited body goes unnoticed unless its Revision is
+// bumped; this table is what catches the edit. When a test here fails, bump
+// the migration's Revision and update its pin together.
+var goMigrationPins = map[int64]struct {
+	revision int
+	up       string
+}{
+	1:  {0, "5cd50c84bd1a2258"},
+	2:  {0, "55149dae041d5d08"},
+	3:  {0, "2cfe1c63bbbcc571"},
+	4:  {0, "764a61bd6bdfaad0"},
+	5:  {0, "d713495610cb7c7c"},
+	6:  {0, "e4f7be03c58abed2"},
+	7:  {0, "d1788f0ece7a285a"},
+	8:  {0, "9158db72107b6e80"},
+	9:  {0, "1c5c0a0341da7d8c"},
+	10: {0, "c220ae4d470bab15"},
+	11: {0, "ed28b30c095ce436"},
+	12: {0, "98c83dc93b0a9348"},
+	13: {0, "746e896827619e5e"},
+	14: {0, "6a9218646c488c03"},
+	15: {0, "a724bdbf82d5b3f8"},
+	16: {0, "66fdf029e12f53a2"},
+	17: {0, "c09283cb314c98ed"},
+	18: {0, "a48e17fee872cd48"},
+}
+
+// upBodies hashes the Up func of every Migration literal declared in the
+// package, keyed by version. Comments and formatting do not count.
+func upBodies(t *testing.T) map[int64]string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(info fs.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := make(map[int64]string)
+	for _, pkg := range pkgs {
+		ast.Inspect(pkg, func(node ast.Node) bool {
+			lit, ok := node.(*ast.CompositeLit)
+			if !ok {
+				return true
+			}
+			if ident, ok := lit.Type.(*ast.Ident); !ok || ident.Name != "Migration" {
+				return true
+			}
+
+			var version int64
+			var up ast.Node
+			for _, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				switch kv.Key.(*ast.Ident).Name {
+				case "Version":
+					if version, err = strconv.ParseInt(kv.Value.(*ast.BasicLit).Value, 10, 64); err != nil {
+						t.Fatal(err)
+					}
+				case "Up":
+					up = kv.Value
+				}
+			}
+			if up == nil {
+				return false
+			}
+
+			var src bytes.Buffer
+			if err := format.Node(&src, fset, up); err != nil {
+				t.Fatal(err)
+			}
+			sum := sha256.Sum256(src.Bytes())
+			bodies[version] = hex.EncodeToString(sum[:8])
+			return false
+		})
+	}
+	return bodies
+}
+
+func TestGoMigrationsPinned(t *testing.T) {
+	bodies := upBodies(t)
+
+	for _, mig := range All() {
+		if mig.Up == nil {
+			continue
+		}
+		pin, ok := goMigrationPins[mig.Version]
+		if !ok {
+			t.Errorf("%d_%s: add it to goMigrationPins with up %q", mig.Version, mig.Name, bodies[mig.Version])
+			continue
+		}
+		if bodies[mig.Version] != pin.up {
+			t.Errorf("%d_%s: Up changed (hash %s, pinned %s), bump its Revision past %d and update the pin", mig.Version, mig.Name, bodies[mig.Version], pin.up, pin.revision)
+		}
+		if mig.Revision != pin.revision {
+			t.Errorf("%d_%s: Revision %d, pinned %d", mig.Version, mig.Name, mig.Revision, pin.revision)
+		}
+	}
+	if len(bodies) != len(goMigrationPins) {
+		t.Errorf("%d Go migrations in the source, %d pinned", len(bodies), len(goMigrationPins))
+	}
+}
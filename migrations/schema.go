@@ -0,0 +1,509 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// The structs below are frozen copies of the models as they were when the
+// migration creating each table shipped. Creating tables from the current
+// models would give a fresh database the latest columns at once and skip
+// the migrations that added them; from these, a fresh database goes
+// through the same steps as one upgraded release by release. Every column
+// change since goes in a migration of its own. Never edit these types.
+
+// v1 create_core_tables
+
+type v1Role struct {
+	Id          int
+	RoleName    string
+	Description string
+	CreatedAt   time.Time
+}
+
+func (v1Role) TableName() string { return "roles" }
+
+type v1User struct {
+	Id                 string `gorm:"primaryKey;type:varchar(151)"`
+	OAuthId            string
+	Provider           string
+	Name               string `gorm:"not null"`
+	Email              string `gorm:"not null;unique;type:varchar(191)"`
+	PhoneNumber        string
+	ProfileImage       string
+	AccountBalance     float64          `gorm:"default:0"`
+	RoleId             int              `gorm:"not null;default:1"`
+	WatchList          []v1WatchList    `gorm:"foreignKey:UserId"`
+	Address            []v1Address      `gorm:"foreignKey:UserId"`
+	PortFolio          []v1PortFolio    `gorm:"foreignKey:UserId"`
+	Transactions       []v1Transaction  `gorm:"foreignKey:UserId"`
+	Notification       []v1Notification `gorm:"foreignKey:UserId"`
+	Role               v1Role           `gorm:"not null;constraint:onUpdate:CASCADE,onDelete:CASCADE"`
+	VerificationStatus bool             `gorm:"default:false"`
+	IsActive           bool
+	TwoFactorEnabled   bool `gorm:"default:false"`
+	TwoFactorSecret    string
+	TwoFactorLastStep  int64 `gorm:"default:0"`
+	DeactivatedAt      *time.Time
+	SuspendedAt        *time.Time
+	SuspensionReason   string
+	DeletionScheduled  *time.Time
+	Referral           string
+	LastLogin          time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Address struct {
+	Id        string `gorm:"primaryKey;type:varchar(151)"`
+	UserId    string `gorm:"not null;index;type:varchar(151)"`
+	Street    string `gorm:"not null"`
+	ZipCode   string `gorm:"not null"`
+	City      string `gorm:"not null"`
+	State     string `gorm:"not null"`
+	Country   string `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v1Address) TableName() string { return "address_models" }
+
+type v1Stock struct {
+	Id             string `gorm:"primaryKey;type:varchar(151)"`
+	Name           string `gorm:"not null"`
+	Sector         string
+	Price          float64            `gorm:"not null"`
+	Symbol         string             `gorm:"index;type:varchar(32)"`
+	WatchListStock []v1WatchListStock `gorm:"foreignKey:StockId"`
+	PortFolioStock []v1PortFolioStock `gorm:"foreignKey:StockId"`
+	Transaction    []v1Transaction    `gorm:"foreignKey:StockId"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v1Stock) TableName() string { return "stocks" }
+
+type v1PortFolio struct {
+	Id              string             `gorm:"primaryKey;type:varchar(151)"`
+	UserId          string             `gorm:"not null;index;type:varchar(151)"`
+	Name            string             `gorm:"not null"`
+	Title           string             `gorm:"not null"`
+	TotalValue      float64            `gorm:"default:0"`
+	UnRealizedGains float64            `gorm:"column:unrealized_gains;default:0"`
+	RealizedGains   float64            `gorm:"default:0"`
+	Description     string             `gorm:"not null"`
+	Transaction     []v1Transaction    `gorm:"foreignKey:PortFolioId"`
+	PortFolioStock  []v1PortFolioStock `gorm:"foreignKey:PortFolioId"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (v1PortFolio) TableName() string { return "port_folios" }
+
+type v1PortFolioStock struct {
+	Id            string  `gorm:"primaryKey; type:varchar(151)"`
+	StockId       string  `gorm:"not null;index;type:varchar(151)"`
+	PortFolioId   string  `gorm:"column:portfolio_id;not null;index;type:varchar(151)"`
+	Quantity      int     `gorm:"default:0"`
+	AveragePrice  float64 `gorm:"default:0"`
+	RealizedGains float64 `gorm:"default:0"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (v1PortFolioStock) TableName() string { return "port_folio_stocks" }
+
+type v1Transaction struct {
+	Id             string  `gorm:"primaryKey;type:varchar(151)"`
+	UserId         string  `gorm:"not null;index;type:varchar(151)"`
+	PortFolioId    string  `gorm:"column:portfolio_id;not null;index;type:varchar(151)"`
+	StockId        string  `gorm:"not null;index;type:varchar(151)"`
+	Quantity       int     `gorm:"default:0"`
+	Price          float64 `gorm:"default:0"`
+	Type           string
+	Status         string
+	ReversalOfId   *string `gorm:"index;type:varchar(151)"`
+	ReplacesId     *string `gorm:"index;type:varchar(151)"`
+	CorrectionNote string
+	TradeDate      time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v1Transaction) TableName() string { return "transaction_models" }
+
+type v1WatchList struct {
+	Id             string             `gorm:"primaryKey;type:varchar(151)"`
+	UserId         string             `gorm:"not null;index;type:varchar(151)"`
+	Name           string             `gorm:"not null"`
+	Description    string             `gorm:"not null;size:1000"`
+	WatchListStock []v1WatchListStock `gorm:"foreignKey:WatchListId"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v1WatchList) TableName() string { return "watch_list_models" }
+
+type v1WatchListStock struct {
+	Id          string `gorm:"primaryKey;type:varchar(151)"`
+	Symbol      string
+	WatchListId string `gorm:"column:watchlist_id;not null;index;type:varchar(151)"`
+	StockId     string `gorm:"not null;index;type:varchar(151)"`
+	CreatedAt   time.Time
+}
+
+func (v1WatchListStock) TableName() string { return "watch_list_stock_models" }
+
+type v1Notification struct {
+	Id         string `gorm:"primaryKey;type:varchar(151)"`
+	UserId     string `gorm:"not null;index;type:varchar(151)"`
+	Message    string
+	ReadStatus bool `gorm:"default:false"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (v1Notification) TableName() string { return "notification_models" }
+
+// v2 create_security_tables
+
+type v2APIKey struct {
+	Id         string `gorm:"primaryKey;type:varchar(151)"`
+	UserId     string `gorm:"not null;index;type:varchar(151)"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null;type:varchar(32)"`
+	KeyHash    string `gorm:"not null;uniqueIndex;type:varchar(64)"`
+	Scopes     string
+	RateLimit  int `gorm:"default:60"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (v2APIKey) TableName() string { return "api_key_models" }
+
+type v2APIKeyUsage struct {
+	Id        string `gorm:"primaryKey;type:varchar(151)"`
+	APIKeyId  string `gorm:"not null;index;type:varchar(151)"`
+	Method    string
+	Path      string
+	Status    int
+	ClientIP  string
+	CreatedAt time.Time
+}
+
+func (v2APIKeyUsage) TableName() string { return "api_key_usage_models" }
+
+type v2RecoveryCode struct {
+	Id        string `gorm:"primaryKey;type:varchar(151)"`
+	UserId    string `gorm:"not null;index;type:varchar(151)"`
+	CodeHash  string `gorm:"not null;type:varchar(64)"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (v2RecoveryCode) TableName() string { return "recovery_code_models" }
+
+type v2AuditLog struct {
+	Id         string `gorm:"primaryKey;type:varchar(151)"`
+	ActorId    string `gorm:"index;type:varchar(151)"`
+	ActorRole  int
+	AuthVia    string    `gorm:"type:varchar(32)"`
+	Action     string    `gorm:"not null;index;type:varchar(64)"`
+	TargetType string    `gorm:"index;type:varchar(64)"`
+	TargetId   string    `gorm:"index;type:varchar(151)"`
+	Before     string    `gorm:"type:text"`
+	After      string    `gorm:"type:text"`
+	RequestId  string    `gorm:"index;type:varchar(64)"`
+	ClientIP   string    `gorm:"type:varchar(64)"`
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (v2AuditLog) TableName() string { return "audit_log_models" }
+
+// v3 create_holding_lots
+
+type v3HoldingLot struct {
+	Id            string  `gorm:"primaryKey;type:varchar(151)"`
+	PortFolioId   string  `gorm:"column:portfolio_id;not null;index;type:varchar(151)"`
+	StockId       string  `gorm:"not null;index;type:varchar(151)"`
+	TransactionId string  `gorm:"not null"`
+	Quantity      int     `gorm:"default:0"`
+	Price         float64 `gorm:"default:0"`
+	OpenedAt      time.Time
+	CreatedAt     time.Time
+}
+
+func (v3HoldingLot) TableName() string { return "holding_lot_models" }
+
+// v9 create_price_bars
+
+type v9PriceBar struct {
+	Id        string  `gorm:"primaryKey;type:varchar(151)"`
+	Symbol    string  `gorm:"not null;type:varchar(32);uniqueIndex:idx_price_bar_symbol_day"`
+	Day       string  `gorm:"not null;type:varchar(10);uniqueIndex:idx_price_bar_symbol_day"`
+	Open      float64 `gorm:"not null"`
+	High      float64 `gorm:"not null"`
+	Low       float64 `gorm:"not null"`
+	Close     float64 `gorm:"not null"`
+	Volume    int64   `gorm:"default:0"`
+	Source    string  `gorm:"type:varchar(32)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v9PriceBar) TableName() string { return "price_bar_models" }
+
+// v10 create_corporate_actions
+
+type v10CorporateAction struct {
+	Id          string    `gorm:"primaryKey;type:varchar(151)"`
+	StockId     string    `gorm:"not null;type:varchar(151);uniqueIndex:idx_corporate_action"`
+	Symbol      string    `gorm:"not null;index;type:varchar(32)"`
+	Type        string    `gorm:"not null;type:varchar(32);uniqueIndex:idx_corporate_action"`
+	ExDate      time.Time `gorm:"not null;uniqueIndex:idx_corporate_action"`
+	PayDate     *time.Time
+	Ratio       float64 `gorm:"default:0"`
+	Amount      float64 `gorm:"default:0"`
+	Source      string  `gorm:"type:varchar(32)"`
+	ProcessedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (v10CorporateAction) TableName() string { return "corporate_action_models" }
+
+// v11 multi_currency
+
+type v11FxRate struct {
+	Id        string  `gorm:"primaryKey;type:varchar(151)"`
+	From      string  `gorm:"column:from_currency;not null;type:varchar(3);uniqueIndex:idx_fx_rate_pair_day"`
+	To        string  `gorm:"column:to_currency;not null;type:varchar(3);uniqueIndex:idx_fx_rate_pair_day"`
+	Day       string  `gorm:"not null;type:varchar(10);uniqueIndex:idx_fx_rate_pair_day"`
+	Rate      float64 `gorm:"not null"`
+	Source    string  `gorm:"type:varchar(32)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v11FxRate) TableName() string { return "fx_rate_models" }
+
+// v14 trade_fees
+
+type v14FeeSchedule struct {
+	Id              string          `gorm:"primaryKey;type:varchar(151)"`
+	PortFolioId     string          `gorm:"column:portfolio_id;not null;uniqueIndex;type:varchar(151)"`
+	Flat            decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	PerShare        decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	Rate            decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	MinCommission   decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	MaxCommission   decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	SellRate        decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	SellPerShare    decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	SellPerShareMax decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (v14FeeSchedule) TableName() string { return "fee_schedule_models" }
+
+// The migrations that change columns of an existing table take them from
+// the structs below, which only hold those columns.
+
+// v7 unique_watchlist_stock
+
+type v7WatchListStock struct {
+	WatchListId string `gorm:"column:watchlist_id;not null;uniqueIndex:idx_watchlist_stock;type:varchar(151)"`
+	StockId     string `gorm:"not null;uniqueIndex:idx_watchlist_stock;type:varchar(151)"`
+}
+
+func (v7WatchListStock) TableName() string { return "watch_list_stock_models" }
+
+// v8 transaction_price_source
+
+type v8Transaction struct {
+	PriceSource string `gorm:"type:varchar(32)"`
+}
+
+func (v8Transaction) TableName() string { return "transaction_models" }
+
+// v10 create_corporate_actions
+
+type v10Transaction struct {
+	CorporateActionId *string `gorm:"index;type:varchar(151)"`
+}
+
+func (v10Transaction) TableName() string { return "transaction_models" }
+
+// v11 multi_currency
+
+type v11Stock struct {
+	Currency string `gorm:"type:varchar(3);default:USD"`
+}
+
+func (v11Stock) TableName() string { return "stocks" }
+
+type v11User struct {
+	BaseCurrency string `gorm:"type:varchar(3);default:USD"`
+}
+
+func (v11User) TableName() string { return "users" }
+
+type v11PortFolio struct {
+	BaseCurrency string `gorm:"type:varchar(3);default:USD"`
+}
+
+func (v11PortFolio) TableName() string { return "port_folios" }
+
+type v11Transaction struct {
+	Currency string  `gorm:"type:varchar(3);default:USD"`
+	FxRate   float64 `gorm:"default:1"`
+}
+
+func (v11Transaction) TableName() string { return "transaction_models" }
+
+type v11HoldingLot struct {
+	FxRate float64 `gorm:"default:1"`
+}
+
+func (v11HoldingLot) TableName() string { return "holding_lot_models" }
+
+type v11PortFolioStock struct {
+	Currency          string  `gorm:"type:varchar(3);default:USD"`
+	AveragePriceBase  float64 `gorm:"default:0"`
+	RealizedGainsBase float64 `gorm:"default:0"`
+}
+
+func (v11PortFolioStock) TableName() string { return "port_folio_stocks" }
+
+// v12 decimal_money
+
+type v12User struct {
+	AccountBalance decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+}
+
+func (v12User) TableName() string { return "users" }
+
+type v12Stock struct {
+	Price decimal.Decimal `gorm:"type:decimal(24,8);not null"`
+}
+
+func (v12Stock) TableName() string { return "stocks" }
+
+type v12PortFolio struct {
+	TotalValue      decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	UnRealizedGains decimal.Decimal `gorm:"type:decimal(24,8);column:unrealized_gains;default:0"`
+	RealizedGains   decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+}
+
+func (v12PortFolio) TableName() string { return "port_folios" }
+
+type v12PortFolioStock struct {
+	AveragePrice      decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	RealizedGains     decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	AveragePriceBase  decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	RealizedGainsBase decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+}
+
+func (v12PortFolioStock) TableName() string { return "port_folio_stocks" }
+
+type v12Transaction struct {
+	Price  decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	FxRate decimal.Decimal `gorm:"type:decimal(24,8);default:1"`
+}
+
+func (v12Transaction) TableName() string { return "transaction_models" }
+
+type v12HoldingLot struct {
+	Price  decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	FxRate decimal.Decimal `gorm:"type:decimal(24,8);default:1"`
+}
+
+func (v12HoldingLot) TableName() string { return "holding_lot_models" }
+
+type v12PriceBar struct {
+	Open  decimal.Decimal `gorm:"type:decimal(24,8);not null"`
+	High  decimal.Decimal `gorm:"type:decimal(24,8);not null"`
+	Low   decimal.Decimal `gorm:"type:decimal(24,8);not null"`
+	Close decimal.Decimal `gorm:"type:decimal(24,8);not null"`
+}
+
+func (v12PriceBar) TableName() string { return "price_bar_models" }
+
+type v12CorporateAction struct {
+	Ratio  decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	Amount decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+}
+
+func (v12CorporateAction) TableName() string { return "corporate_action_models" }
+
+type v12FxRate struct {
+	Rate decimal.Decimal `gorm:"type:decimal(24,8);not null"`
+}
+
+func (v12FxRate) TableName() string { return "fx_rate_models" }
+
+// v13 fractional_quantities
+
+type v13Transaction struct {
+	Quantity decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+}
+
+func (v13Transaction) TableName() string { return "transaction_models" }
+
+type v13HoldingLot struct {
+	Quantity decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+}
+
+func (v13HoldingLot) TableName() string { return "holding_lot_models" }
+
+type v13PortFolioStock struct {
+	Quantity decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+}
+
+func (v13PortFolioStock) TableName() string { return "port_folio_stocks" }
+
+// v14 trade_fees
+
+type v14Transaction struct {
+	Commission    decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+	RegulatoryFee decimal.Decimal `gorm:"type:decimal(24,8);default:0"`
+}
+
+func (v14Transaction) TableName() string { return "transaction_models" }
+
+// v15 margin_accounts
+
+type v15PortFolio struct {
+	Margin bool `gorm:"default:false"`
+}
+
+func (v15PortFolio) TableName() string { return "port_folios" }
+
+type v15Transaction struct {
+	CashSettled bool `gorm:"default:false"`
+}
+
+func (v15Transaction) TableName() string { return "transaction_models" }
+
+// v17 unique_reversals
+
+type v17Transaction struct {
+	ReversalOfId *string `gorm:"uniqueIndex;type:varchar(151)"`
+}
+
+func (v17Transaction) TableName() string { return "transaction_models" }
+
+// v18 unique_holdings
+
+type v18PortFolioStock struct {
+	StockId     string `gorm:"not null;uniqueIndex:idx_portfolio_stock,priority:2;type:varchar(151)"`
+	PortFolioId string `gorm:"column:portfolio_id;not null;uniqueIndex:idx_portfolio_stock,priority:1;type:varchar(151)"`
+}
+
+func (v18PortFolioStock) TableName() string { return "port_folio_stocks" }
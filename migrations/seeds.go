@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Role ids are referenced directly by the middlewares (2 = admin), so they are
+// seeded with fixed ids.
+var roleSeeds = []v1Role{
+	{Id: 1, RoleName: "user", Description: "regular trading account"},
+	{Id: 2, RoleName: "admin", Description: "can suspend users, change roles and read the audit log"},
+}
+
+// referenceStockSeeds are the symbols the UI offers before any search; the
+// price is filled in by the first quote refresh. They are v1 rows, the
+// columns added since take their defaults.
+var referenceStockSeeds = []v1Stock{
+	{Symbol: "AAPL", Name: "Apple Inc", Sector: "Technology"},
+	{Symbol: "MSFT", Name: "Microsoft Corporation", Sector: "Technology"},
+	{Symbol: "GOOGL", Name: "Alphabet Inc Class A", Sector: "Communication Services"},
+	{Symbol: "AMZN", Name: "Amazon.com Inc", Sector: "Consumer Cyclical"},
+	{Symbol: "NVDA", Name: "NVIDIA Corporation", Sector: "Technology"},
+	{Symbol: "META", Name: "Meta Platforms Inc", Sector: "Communication Services"},
+	{Symbol: "TSLA", Name: "Tesla Inc", Sector: "Consumer Cyclical"},
+	{Symbol: "JPM", Name: "JPMorgan Chase & Co", Sector: "Financial Services"},
+	{Symbol: "V", Name: "Visa Inc", Sector: "Financial Services"},
+	{Symbol: "JNJ", Name: "Johnson & Johnson", Sector: "Healthcare"},
+}
+
+var seedRoles = Migration{
+	Version: 5,
+	Name:    "seed_roles",
+	Up: func(tx *gorm.DB) error {
+		for _, role := range roleSeeds {
+			role := role
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&role).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		// roles still assigned to a user are kept
+		for _, role := range roleSeeds {
+			if err := tx.
+				Where("id = ? AND NOT EXISTS (SELECT 1 FROM users WHERE users.role_id = roles.id)", role.Id).
+				Delete(&v1Role{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var seedReferenceStocks = Migration{
+	Version: 6,
+	Name:    "seed_reference_stocks",
+	Up: func(tx *gorm.DB) error {
+		for _, seed := range referenceStockSeeds {
+			var count int64
+			if err := tx.Model(&v1Stock{}).Where("symbol = ?", seed.Symbol).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			stock := seed
+			stock.Id = uuid.New().String()
+			if err := tx.Create(&stock).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		// a seeded stock that somebody traded or watched is real data now
+		for _, seed := range referenceStockSeeds {
+			if err := tx.
+				Where("symbol = ?", seed.Symbol).
+				Where("NOT EXISTS (SELECT 1 FROM transaction_models t WHERE t.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM port_folio_stocks p WHERE p.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM watch_list_stock_models w WHERE w.stock_id = stocks.id)").
+				Delete(&v1Stock{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// AccountDeletionGrace is how long a deletion request can still be undone by
+// reactivating the account.
+const AccountDeletionGrace = 30 * 24 * time.Hour
+
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
+func (u *User) IsDeactivated() bool {
+	return u.DeactivatedAt != nil
+}
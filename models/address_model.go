@@ -4,14 +4,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/pratyush934/tradealpha/server/database"
-	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
 type AddressModel struct {
-	Id        string    `gorm:"primaryKey" json:"id"`
-	UserId    string    `gorm:"not null" json:"userId"`
+	Id        string    `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	UserId    string    `gorm:"not null;index;type:varchar(151)" json:"userId"`
 	Street    string    `gorm:"not null" json:"street"`
 	ZipCode   string    `gorm:"not null" json:"zipCode"`
 	City      string    `gorm:"not null" json:"city"`
@@ -33,46 +31,3 @@ func (a *AddressModel) BeforeUpdate(tx *gorm.DB) error {
 	a.UpdatedAt = time.Now()
 	return nil
 }
-
-func (a *AddressModel) CreateAddress() (*AddressModel, error) {
-
-	if err := database.DB.Create(a).Error; err != nil {
-		log.Error().Err(err).Msg("issue lie in address_model/CreateAddress")
-		return nil, err
-	}
-	return a, nil
-}
-
-func GetAddressByUserId(userId string) (*[]AddressModel, error) {
-	var address []AddressModel
-	if err := database.DB.Where("user_id = ?", userId).Find(&address).Error; err != nil {
-		log.Error().Err(err).Msg("issue lie in address_model/AddressByUserId")
-		return nil, err
-	}
-	return &address, nil
-}
-
-func GetAddressByAddressId(id string) (*AddressModel, error) {
-	var address AddressModel
-	if err := database.DB.Where("id = ?", id).Find(&address).Error; err != nil {
-		log.Error().Err(err).Msg("issue lie in address_model/GetAddressByAddressId")
-		return nil, err
-	}
-	return &address, nil
-}
-
-func UpdateAddress(address *AddressModel) (*AddressModel, error) {
-	if err := database.DB.Updates(address).Error; err != nil {
-		log.Error().Err(err).Msg("issue lie in address_model/UpdateAddress")
-		return nil, err
-	}
-	return address, nil
-}
-
-func DeleteAddress(userId string) error {
-	return database.DB.Where("user_id = ?", userId).Delete(&AddressModel{}).Error
-}
-
-func DeleteAddressByAddressId(id string) error {
-	return database.DB.Where("id = ?", id).Delete(&AddressModel{}).Error
-}
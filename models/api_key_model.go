@@ -0,0 +1,116 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pratyush934/tradealpha/server/util"
+	"gorm.io/gorm"
+)
+
+const (
+	APIKeyPrefix           = "ta"
+	DefaultAPIKeyRateLimit = 60
+)
+
+type APIKeyModel struct {
+	Id         string     `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	UserId     string     `gorm:"not null;index;type:varchar(151)" json:"userId"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null;type:varchar(32)" json:"prefix"`
+	KeyHash    string     `gorm:"not null;uniqueIndex;type:varchar(64)" json:"-"`
+	Scopes     string     `json:"scopes"`
+	RateLimit  int        `gorm:"default:60" json:"rateLimit"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+type APIKeyUsageModel struct {
+	Id        string    `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	APIKeyId  string    `gorm:"not null;index;type:varchar(151)" json:"apiKeyId"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	ClientIP  string    `json:"clientIp"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (a *APIKeyModel) BeforeCreate(tx *gorm.DB) error {
+	a.Id = uuid.New().String()
+	a.CreatedAt = time.Now()
+	a.UpdatedAt = time.Now()
+
+	return nil
+}
+
+func (a *APIKeyModel) BeforeUpdate(tx *gorm.DB) error {
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+func (u *APIKeyUsageModel) BeforeCreate(tx *gorm.DB) error {
+	u.Id = uuid.New().String()
+	u.CreatedAt = time.Now()
+	return nil
+}
+
+// GenerateAPIKey returns a new plaintext key of the form ta_<prefix>_<secret>
+// together with the prefix and the sha256 hash that is stored in the DB.
+func GenerateAPIKey() (plain, prefix, hash string, err error) {
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 24)
+
+	if _, err = rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+	if _, err = rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	plain = APIKeyPrefix + "_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	return plain, prefix, HashAPIKey(plain), nil
+}
+
+func HashAPIKey(plain string) string {
+	return util.SHA256Hex(plain)
+}
+
+// IsUsable reports whether the key is neither revoked nor expired.
+func (a *APIKeyModel) IsUsable(now time.Time) bool {
+	if a.RevokedAt != nil {
+		return false
+	}
+	if a.ExpiresAt != nil && now.After(*a.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+func (a *APIKeyModel) ScopeList() []string {
+	if a.Scopes == "" {
+		return nil
+	}
+	return strings.Split(a.Scopes, ",")
+}
+
+// HasScope treats "*" as a wildcard, and "read" as satisfied by "write".
+func (a *APIKeyModel) HasScope(scope string) bool {
+	for _, s := range a.ScopeList() {
+		if s == "*" || s == scope {
+			return true
+		}
+		if strings.HasSuffix(scope, ":read") && s == strings.TrimSuffix(scope, ":read")+":write" {
+			return true
+		}
+	}
+	return false
+}
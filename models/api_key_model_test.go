@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyHasScope(t *testing.T) {
+	tests := []struct {
+		scopes string
+		scope  string
+		want   bool
+	}{
+		{"portfolio:read", "portfolio:read", true},
+		{"portfolio:write", "portfolio:write", true},
+		{"portfolio:write", "portfolio:read", true},
+		{"portfolio:read", "portfolio:write", false},
+		{"watchlist:write", "portfolio:read", false},
+		{"trade:write,market:read", "market:read", true},
+		{"trade:write,market:read", "trade:read", true},
+		{"trade:write,market:read", "notification:read", false},
+		{"*", "trade:write", true},
+		{"portfolio", "portfolio:read", false},
+		{"", "portfolio:read", false},
+	}
+	for _, tt := range tests {
+		key := APIKeyModel{Scopes: tt.scopes}
+		if got := key.HasScope(tt.scope); got != tt.want {
+			t.Errorf("key with %q HasScope(%q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyIsUsable(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier, later := now.Add(-time.Second), now.Add(time.Second)
+
+	tests := []struct {
+		name string
+		key  APIKeyModel
+		want bool
+	}{
+		{"no expiry", APIKeyModel{}, true},
+		{"expires later", APIKeyModel{ExpiresAt: &later}, true},
+		{"expires right now", APIKeyModel{ExpiresAt: &now}, true},
+		{"expired", APIKeyModel{ExpiresAt: &earlier}, false},
+		{"revoked", APIKeyModel{RevokedAt: &earlier}, false},
+		{"revoked before its expiry", APIKeyModel{RevokedAt: &earlier, ExpiresAt: &later}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.key.IsUsable(now); got != tt.want {
+			t.Errorf("%s: IsUsable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	plain, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(plain, APIKeyPrefix+"_"+prefix+"_") || len(prefix) != 8 {
+		t.Errorf("plain %q does not carry its prefix %q", plain, prefix)
+	}
+	if hash != HashAPIKey(plain) || len(hash) != 64 {
+		t.Errorf("hash %q is not the sha256 of the key", hash)
+	}
+}
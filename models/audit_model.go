@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrAuditLogImmutable = errors.New("audit log entries are append-only")
+
+type AuditLogModel struct {
+	Id         string    `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	ActorId    string    `gorm:"index;type:varchar(151)" json:"actorId"`
+	ActorRole  int       `json:"actorRole"`
+	AuthVia    string    `gorm:"type:varchar(32)" json:"authVia"`
+	Action     string    `gorm:"not null;index;type:varchar(64)" json:"action"`
+	TargetType string    `gorm:"index;type:varchar(64)" json:"targetType"`
+	TargetId   string    `gorm:"index;type:varchar(151)" json:"targetId"`
+	Before     string    `gorm:"type:text" json:"before"`
+	After      string    `gorm:"type:text" json:"after"`
+	RequestId  string    `gorm:"index;type:varchar(64)" json:"requestId"`
+	ClientIP   string    `gorm:"type:varchar(64)" json:"clientIp"`
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+}
+
+type AuditLogFilter struct {
+	ActorId    string
+	Action     string
+	TargetType string
+	TargetId   string
+	RequestId  string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+func (a *AuditLogModel) BeforeCreate(tx *gorm.DB) error {
+	a.Id = uuid.New().String()
+	a.CreatedAt = time.Now()
+	return nil
+}
+
+func (a *AuditLogModel) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (a *AuditLogModel) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	CorporateActionSplit        = "split"
+	CorporateActionCashDividend = "cash_dividend"
+
+	// CorporateActionSourceAdmin marks an action entered by an admin rather
+	// than read from a market data provider.
+	CorporateActionSourceAdmin = "admin"
+)
+
+// CorporateActionModel is a split or cash dividend of a stock. A split is
+// applied to every holding when it goes ex, by replaying the trade history
+// with it; a dividend is booked as a dividend transaction in every
+// portfolio that held the stock before its ex date once it is paid.
+type CorporateActionModel struct {
+	Id      string    `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	StockId string    `gorm:"not null;type:varchar(151);uniqueIndex:idx_corporate_action" json:"stockId"`
+	Symbol  string    `gorm:"not null;index;type:varchar(32)" json:"symbol"`
+	Type    string    `gorm:"not null;type:varchar(32);uniqueIndex:idx_corporate_action" json:"type"`
+	ExDate  time.Time `gorm:"not null;uniqueIndex:idx_corporate_action" json:"exDate"`
+	// PayDate is when a dividend is paid, the ex date when it is not known.
+	PayDate *time.Time `json:"payDate,omitempty"`
+	// Ratio is the shares a split turns one share into: 4 for a 4:1 split,
+	// 0.1 for a 1:10 reverse split.
+	Ratio decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"ratio,omitempty"`
+	// Amount is the cash a dividend pays per share.
+	Amount      decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"amount,omitempty"`
+	Source      string          `gorm:"type:varchar(32)" json:"source"`
+	ProcessedAt *time.Time      `json:"processedAt,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// EffectiveAt is when the action takes effect on holdings: the ex date of a
+// split, the pay date of a dividend.
+func (a *CorporateActionModel) EffectiveAt() time.Time {
+	if a.Type == CorporateActionCashDividend && a.PayDate != nil {
+		return *a.PayDate
+	}
+	return a.ExDate
+}
+
+func (a *CorporateActionModel) BeforeCreate(tx *gorm.DB) error {
+	a.Id = uuid.New().String()
+	a.CreatedAt = time.Now()
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+func (a *CorporateActionModel) BeforeUpdate(tx *gorm.DB) error {
+	a.UpdatedAt = time.Now()
+	return nil
+}
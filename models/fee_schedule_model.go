@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// FeeScheduleModel is what a trade in a portfolio costs. Amounts are in the
+// portfolio's base currency and rates are fractions of the trade's value,
+// 0.001 is 0.1%. A portfolio without a schedule trades for free.
+type FeeScheduleModel struct {
+	Id          string `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	PortFolioId string `gorm:"column:portfolio_id;not null;uniqueIndex;type:varchar(151)" json:"portFolioId"`
+	// the commission of every trade, Flat plus PerShare plus Rate of the
+	// value, then held between MinCommission and MaxCommission; a zero
+	// bound does not apply
+	Flat          decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"flat"`
+	PerShare      decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"perShare"`
+	Rate          decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"rate"`
+	MinCommission decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"minCommission"`
+	MaxCommission decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"maxCommission"`
+	// the regulatory fees of sells only, SellRate of the proceeds plus
+	// SellPerShare held to at most SellPerShareMax when it is not zero
+	SellRate        decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"sellRate"`
+	SellPerShare    decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"sellPerShare"`
+	SellPerShareMax decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"sellPerShareMax"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+}
+
+// Fees returns the commission and regulatory fee of a trade of quantity
+// shares worth value, both in the base currency and not rounded.
+func (f *FeeScheduleModel) Fees(side string, quantity, value decimal.Decimal) (commission, regulatory decimal.Decimal) {
+	commission = f.Flat.Add(f.PerShare.Mul(quantity)).Add(f.Rate.Mul(value))
+	if f.MaxCommission.IsPositive() {
+		commission = decimal.Min(commission, f.MaxCommission)
+	}
+	if f.MinCommission.IsPositive() {
+		commission = decimal.Max(commission, f.MinCommission)
+	}
+
+	if side != TransactionTypeSell {
+		return commission, decimal.Zero
+	}
+	perShare := f.SellPerShare.Mul(quantity)
+	if f.SellPerShareMax.IsPositive() {
+		perShare = decimal.Min(perShare, f.SellPerShareMax)
+	}
+	return commission, f.SellRate.Mul(value).Add(perShare)
+}
+
+func (f *FeeScheduleModel) BeforeCreate(tx *gorm.DB) error {
+	f.Id = uuid.New().String()
+	f.CreatedAt = time.Now()
+	f.UpdatedAt = time.Now()
+	return nil
+}
+
+func (f *FeeScheduleModel) BeforeUpdate(tx *gorm.DB) error {
+	f.UpdatedAt = time.Now()
+	return nil
+}
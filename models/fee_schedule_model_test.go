@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestFees(t *testing.T) {
+	d := decimal.RequireFromString
+	broker := FeeScheduleModel{
+		Flat:          d("1"),
+		PerShare:      d("0.01"),
+		Rate:          d("0.001"),
+		MinCommission: d("2"),
+		MaxCommission: d("10"),
+	}
+	regulated := FeeScheduleModel{
+		SellRate:        d("0.0000278"),
+		SellPerShare:    d("0.000166"),
+		SellPerShareMax: d("8.30"),
+	}
+
+	tests := []struct {
+		name           string
+		schedule       FeeScheduleModel
+		side           string
+		quantity       string
+		value          string
+		wantCommission string
+		wantRegulatory string
+	}{
+		{"no schedule", FeeScheduleModel{}, TransactionTypeSell, "100", "10000", "0", "0"},
+		{"between the bounds", broker, TransactionTypeBuy, "100", "3000", "5", "0"},
+		{"raised to the minimum", broker, TransactionTypeBuy, "10", "100", "2", "0"},
+		{"capped at the maximum", broker, TransactionTypeBuy, "1000", "50000", "10", "0"},
+		{"zero bounds do not apply", FeeScheduleModel{Flat: d("0.5")}, TransactionTypeBuy, "1", "10", "0.5", "0"},
+		{"minimum above a zero commission", FeeScheduleModel{MinCommission: d("1")}, TransactionTypeBuy, "1", "10", "1", "0"},
+		{"buys pay no regulatory fee", regulated, TransactionTypeBuy, "1000", "50000", "0", "0"},
+		{"sell fees by value and share", regulated, TransactionTypeSell, "1000", "50000", "0", "1.556"},
+		{"sell per share fee capped", regulated, TransactionTypeSell, "100000", "1000000", "0", "36.1"},
+		{"fractional quantity", broker, TransactionTypeSell, "2.5", "5000", "6.025", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commission, regulatory := tt.schedule.Fees(tt.side, d(tt.quantity), d(tt.value))
+			if !commission.Equal(d(tt.wantCommission)) {
+				t.Errorf("commission = %s, want %s", commission, tt.wantCommission)
+			}
+			if !regulatory.Equal(d(tt.wantRegulatory)) {
+				t.Errorf("regulatory fee = %s, want %s", regulatory, tt.wantRegulatory)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// DefaultCurrency is the currency of every amount stored before amounts had
+// one, and of stocks, users and portfolios that do not say otherwise.
+const DefaultCurrency = "USD"
+
+// FxRateModel is what one unit of From bought in To on a day. Rates are
+// kept for every day they were fetched, so that trades and dividends are
+// converted at the rate of their own date.
+type FxRateModel struct {
+	Id        string          `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	From      string          `gorm:"column:from_currency;not null;type:varchar(3);uniqueIndex:idx_fx_rate_pair_day" json:"from"`
+	To        string          `gorm:"column:to_currency;not null;type:varchar(3);uniqueIndex:idx_fx_rate_pair_day" json:"to"`
+	Day       string          `gorm:"not null;type:varchar(10);uniqueIndex:idx_fx_rate_pair_day" json:"day"`
+	Rate      decimal.Decimal `gorm:"type:decimal(24,8);not null" json:"rate"`
+	Source    string          `gorm:"type:varchar(32)" json:"source"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+func (f *FxRateModel) BeforeCreate(tx *gorm.DB) error {
+	f.Id = uuid.New().String()
+	f.CreatedAt = time.Now()
+	f.UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// HoldingLotModel is an open FIFO lot. Lots are derived data: they are
+// rebuilt from the transaction history whenever a trade is booked and never
+// edited.
+type HoldingLotModel struct {
+	Id            string          `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	PortFolioId   string          `gorm:"column:portfolio_id;not null;index;type:varchar(151)" json:"portFolioId"`
+	StockId       string          `gorm:"not null;index;type:varchar(151)" json:"stockId"`
+	TransactionId string          `gorm:"not null" json:"transactionId"`
+	Quantity      decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"quantity"`
+	Price         decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"price"`
+	FxRate        decimal.Decimal `gorm:"type:decimal(24,8);default:1" json:"fxRate"` // of the trade that opened it
+	OpenedAt      time.Time       `json:"openedAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
+
+func (h *HoldingLotModel) BeforeCreate(tx *gorm.DB) error {
+	h.Id = uuid.New().String()
+	h.CreatedAt = time.Now()
+	return nil
+}
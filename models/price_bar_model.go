@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// PriceBarModel is the daily open, high, low, close and volume of a symbol.
+// Bars are keyed by symbol rather than stock so that history can be loaded
+// for symbols no one has looked up yet.
+type PriceBarModel struct {
+	Id        string          `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	Symbol    string          `gorm:"not null;type:varchar(32);uniqueIndex:idx_price_bar_symbol_day" json:"symbol"`
+	Day       string          `gorm:"not null;type:varchar(10);uniqueIndex:idx_price_bar_symbol_day" json:"day"`
+	Open      decimal.Decimal `gorm:"type:decimal(24,8);not null" json:"open"`
+	High      decimal.Decimal `gorm:"type:decimal(24,8);not null" json:"high"`
+	Low       decimal.Decimal `gorm:"type:decimal(24,8);not null" json:"low"`
+	Close     decimal.Decimal `gorm:"type:decimal(24,8);not null" json:"close"`
+	Volume    int64           `gorm:"default:0" json:"volume"`
+	Source    string          `gorm:"type:varchar(32)" json:"source"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+func (p *PriceBarModel) BeforeCreate(tx *gorm.DB) error {
+	p.Id = uuid.New().String()
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = time.Now()
+	return nil
+}
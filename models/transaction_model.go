@@ -1,119 +1,81 @@
 package models
 
 import (
-	"strconv"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/pratyush934/tradealpha/server/alphavantage"
-	"github.com/pratyush934/tradealpha/server/database"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
+const (
+	TransactionTypeBuy      = "buy"
+	TransactionTypeSell     = "sell"
+	TransactionTypeReversal = "reversal"
+	// TransactionTypeDividend credits a cash dividend: Quantity is the
+	// shares held before the ex date, negative for a short position that
+	// pays it, Price the amount paid per share.
+	TransactionTypeDividend = "dividend"
+	// TransactionTypeBorrowFee charges a day of borrowing the shares of a
+	// short position: Quantity is the shares short, Price the fee per share.
+	TransactionTypeBorrowFee = "borrow_fee"
+
+	TransactionStatusExecuted = "executed"
+
+	// PriceSourceManual marks a price typed in with a correction rather
+	// than served by a quote provider.
+	PriceSourceManual = "manual"
+)
+
+var ErrTransactionImmutable = errors.New("executed transactions cannot be changed, post a correction instead")
+
+// TransactionModel rows are never updated or deleted once written. A
+// correction is a reversal entry (ReversalOfId set) optionally followed by a
+// replacement entry (ReplacesId set) that inherits the original TradeDate.
+// Commission and RegulatoryFee are in Currency, added to the cost of a buy
+// and taken from the proceeds of a sell.
 type TransactionModel struct {
-	Id          string    `gorm:"primaryKey;type:varchar(151)" json:"id"`
-	UserId      string    `gorm:"not null" json:"userId"`
-	PortFolioId string    `gorm:"not null" json:"portFolioId"`
-	StockId     string    `gorm:"not null" json:"stockId"`
-	Quantity    int       `gorm:"default:0" json:"quantity"`
-	Price       float64   `gorm:"default:0" json:"price"`
-	Type        string    `json:"type"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	Id                string          `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	UserId            string          `gorm:"not null;index;type:varchar(151)" json:"userId"`
+	PortFolioId       string          `gorm:"column:portfolio_id;not null;index;type:varchar(151)" json:"portFolioId"`
+	StockId           string          `gorm:"not null;index;type:varchar(151)" json:"stockId"`
+	Quantity          decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"quantity"`
+	Price             decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"price"`
+	PriceSource       string          `gorm:"type:varchar(32)" json:"priceSource,omitempty"` // the quote provider, or manual
+	Currency          string          `gorm:"type:varchar(3);default:USD" json:"currency"`   // of Price, the stock's
+	FxRate            decimal.Decimal `gorm:"type:decimal(24,8);default:1" json:"fxRate"`    // converts Price to the portfolio's base currency on the trade date
+	Commission        decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"commission"`
+	RegulatoryFee     decimal.Decimal `gorm:"type:decimal(24,8);default:0" json:"regulatoryFee"`
+	CashSettled       bool            `gorm:"default:false" json:"cashSettled,omitempty"` // moved the owner's cash by its value, in a margin portfolio
+	Type              string          `json:"type"`
+	Status            string          `json:"status"`
+	ReversalOfId      *string         `gorm:"uniqueIndex;type:varchar(151)" json:"reversalOfId,omitempty"`
+	ReplacesId        *string         `gorm:"index;type:varchar(151)" json:"replacesId,omitempty"`
+	CorrectionNote    string          `json:"correctionNote,omitempty"`
+	CorporateActionId *string         `gorm:"index;type:varchar(151)" json:"corporateActionId,omitempty"` // the dividend a dividend transaction pays
+	TradeDate         time.Time       `json:"tradeDate"`
+	CreatedAt         time.Time       `json:"createdAt"`
+	UpdatedAt         time.Time       `json:"updatedAt"`
 }
 
 func (t *TransactionModel) BeforeCreate(tx *gorm.DB) error {
 	t.Id = uuid.New().String()
 	t.CreatedAt = time.Now()
 	t.UpdatedAt = time.Now()
-	return nil
-}
-
-func (t *TransactionModel) BeforeUpdate(tx *gorm.DB) error {
-	t.UpdatedAt = time.Now()
-	return nil
-}
-
-func GetTransactionById(id string) (*TransactionModel, error) {
-	var tx TransactionModel
-	if err := database.DB.Where("id = ?", id).First(&tx).Error; err != nil {
-		log.Error().Err(err).Msg("issue in transaction_model/GetTransactionById")
-		return nil, err
-	}
-	return &tx, nil
-}
-
-func GetTransactionsByUserId(userId string) ([]TransactionModel, error) {
-	var txs []TransactionModel
-	if err := database.DB.Where("user_id = ?", userId).Find(&txs).Error; err != nil {
-		log.Error().Err(err).Msg("issue in transaction_model/GetTransactionsByUserId")
-		return nil, err
+	if t.TradeDate.IsZero() {
+		t.TradeDate = t.CreatedAt
 	}
-	return txs, nil
-}
-
-func GetTransactionsByPortfolioId(portfolioId string) ([]TransactionModel, error) {
-	var txs []TransactionModel
-	if err := database.DB.Where("portfolio_id = ?", portfolioId).Find(&txs).Error; err != nil {
-		log.Error().Err(err).Msg("issue in transaction_model/GetTransactionsByPortfolioId")
-		return nil, err
-	}
-	return txs, nil
-}
-
-func GetTransactionsByStockId(stockId string) ([]TransactionModel, error) {
-	var txs []TransactionModel
-	if err := database.DB.Where("stock_id = ?", stockId).Find(&txs).Error; err != nil {
-		log.Error().Err(err).Msg("issue in transaction_model/GetTransactionsByStockId")
-		return nil, err
-	}
-	return txs, nil
-}
-
-func UpdateTransaction(tx *TransactionModel) error {
-	if err := database.DB.Updates(tx).Error; err != nil {
-		log.Error().Err(err).Msg("issue in transaction_model/UpdateTransaction")
-		return err
+	if t.Status == "" {
+		t.Status = TransactionStatusExecuted
 	}
 	return nil
 }
 
-func DeleteTransactionById(id string) error {
-	if err := database.DB.Where("transaction_id = ?", id).Delete(&TransactionModel{}).Error; err != nil {
-		log.Error().Err(err).Msg("issue in transaction_model/DeleteTransactionById")
-		return err
-	}
-	return nil
+func (t *TransactionModel) BeforeUpdate(tx *gorm.DB) error {
+	return ErrTransactionImmutable
 }
 
-func (t *TransactionModel) CreateTransaction(logger *zerolog.Logger) (*TransactionModel, error) {
-	quote, err := alphavantage.FetchQuote(t.StockId, logger)
-	if err != nil {
-		logger.Error().Err(err).Str("stock_id", t.StockId).Msg("Failed to fetch stock quote")
-		return nil, err
-	}
-
-	price, err := strconv.ParseFloat(quote.GlobalQuote.Price, 64)
-	if err != nil {
-		logger.Error().Err(err).Str("stock_id", t.StockId).Msg("Failed to parse stock price")
-		return nil, err
-	}
-	t.Price = price
-
-	if err := database.DB.Create(t).Error; err != nil {
-		logger.Error().Err(err).Msg("issue in transaction_model/CreateTransaction")
-		return nil, err
-	}
-
-	// Update portfolio metrics after transaction
-	if err := UpdateTotalValue(t.PortFolioId); err != nil {
-		logger.Error().Err(err).Str("portfolio_id", t.PortFolioId).Msg("Failed to update portfolio metrics after transaction")
-		// Log error but don’t fail transaction
-		return nil, err
-	}
-
-	return t, nil
+func (t *TransactionModel) BeforeDelete(tx *gorm.DB) error {
+	return ErrTransactionImmutable
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const RecoveryCodeCount = 10
+
+type RecoveryCodeModel struct {
+	Id        string     `gorm:"primaryKey;type:varchar(151)" json:"id"`
+	UserId    string     `gorm:"not null;index;type:varchar(151)" json:"userId"`
+	CodeHash  string     `gorm:"not null;type:varchar(64)" json:"-"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (r *RecoveryCodeModel) BeforeCreate(tx *gorm.DB) error {
+	r.Id = uuid.New().String()
+	r.CreatedAt = time.Now()
+	return nil
+}
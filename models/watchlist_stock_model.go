@@ -4,16 +4,14 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/pratyush934/tradealpha/server/database"
-	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
 type WatchListStockModel struct {
-	Id          string `gorm:"primaryKey" json:"id"`
+	Id          string `gorm:"primaryKey;type:varchar(151)" json:"id"`
 	Symbol      string `json:"symbol"`
-	WatchListId string `gorm:"not null" json:"watchListId"`
-	StockId     string `gorm:"not null" json:"stockId"`
+	WatchListId string `gorm:"column:watchlist_id;not null;uniqueIndex:idx_watchlist_stock;type:varchar(151)" json:"watchListId"`
+	StockId     string `gorm:"not null;index;uniqueIndex:idx_watchlist_stock;type:varchar(151)" json:"stockId"`
 	CreatedAt   time.Time
 }
 
@@ -23,32 +21,3 @@ func (w *WatchListStockModel) BeforeCreate(tx *gorm.DB) error {
 
 	return nil
 }
-
-func (w *WatchListStockModel) CreateWatchListStockModel() (*WatchListStockModel, error) {
-	if err := database.DB.Create(w).Error; err != nil {
-		log.Error().Err(err).Msg("issue exist in watchlist_stock_model/CreateWatchListStockModel")
-		return nil, err
-	}
-	return w, nil
-}
-
-func DeleteWatchListStockModel(id string) error {
-	return database.DB.Where("id = ?", id).Delete(&WatchListStockModel{}).Error
-}
-
-func GetWatchListStockModelByStockId(stockId string) (*WatchListStockModel, error) {
-	var watchListStockModel WatchListStockModel
-	if err := database.DB.Where("stock_id = ?", stockId).First(&watchListStockModel).Error; err != nil {
-		log.Error().Err(err).Msg("Issue exist in the watchlist_stock_model/GetWatchListStockModel")
-		return nil, err
-	}
-	return &watchListStockModel, nil
-}
-func GetWatchListStockModelById(id string) (*WatchListStockModel, error) {
-	var watchListStockModel WatchListStockModel
-	if err := database.DB.Where("id = ?", id).First(&watchListStockModel).Error; err != nil {
-		log.Error().Err(err).Msg("Issue exist in the watchlist_stock_model/GetWatchListStockModel")
-		return nil, err
-	}
-	return &watchListStockModel, nil
-}
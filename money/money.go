@@ -0,0 +1,97 @@
+package money
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Scale is the number of decimal places stored for unit prices, average
+// prices, quantities and exchange rates, the columns' decimal(24,8).
+const Scale = 8
+
+// ColumnType is the SQL type of every money and rate column.
+const ColumnType = "decimal(24,8)"
+
+// RoundingMode says which way an amount that falls between two
+// representable values goes.
+type RoundingMode int
+
+const (
+	// HalfEven rounds ties to the even neighbour, so that rounding many
+	// amounts does not drift one way. It is the default for cash.
+	HalfEven RoundingMode = iota
+	// HalfUp rounds ties away from zero.
+	HalfUp
+	// Down truncates towards zero.
+	Down
+)
+
+// Spec is how amounts of a currency are written: the minor units it has
+// and how amounts are rounded to them.
+type Spec struct {
+	Places int32
+	Mode   RoundingMode
+}
+
+// specs are the ISO 4217 currencies whose minor units are not hundredths,
+// or that round cash other than half to even.
+var specs = map[string]Spec{
+	"BHD": {Places: 3, Mode: HalfEven},
+	"CLP": {Places: 0, Mode: HalfUp},
+	"HUF": {Places: 2, Mode: HalfUp},
+	"IDR": {Places: 2, Mode: HalfUp},
+	"ISK": {Places: 0, Mode: HalfUp},
+	"JOD": {Places: 3, Mode: HalfEven},
+	"JPY": {Places: 0, Mode: HalfUp},
+	"KRW": {Places: 0, Mode: HalfUp},
+	"KWD": {Places: 3, Mode: HalfEven},
+	"OMR": {Places: 3, Mode: HalfEven},
+	"TND": {Places: 3, Mode: HalfEven},
+	"VND": {Places: 0, Mode: HalfUp},
+}
+
+// defaultSpec covers every currency not in specs.
+var defaultSpec = Spec{Places: 2, Mode: HalfEven}
+
+// SpecOf returns how amounts of currency are rounded.
+func SpecOf(currency string) Spec {
+	if spec, ok := specs[currency]; ok {
+		return spec
+	}
+	return defaultSpec
+}
+
+// Round rounds a cash amount of currency to its minor units with the
+// currency's rounding mode.
+func Round(amount decimal.Decimal, currency string) decimal.Decimal {
+	spec := SpecOf(currency)
+	return RoundTo(amount, spec.Places, spec.Mode)
+}
+
+// Format writes a cash amount rounded to the minor units of currency,
+// followed by its code: "10.50 EUR".
+func Format(amount decimal.Decimal, currency string) string {
+	return Round(amount, currency).StringFixed(SpecOf(currency).Places) + " " + currency
+}
+
+// RoundTo rounds amount to places with mode.
+func RoundTo(amount decimal.Decimal, places int32, mode RoundingMode) decimal.Decimal {
+	switch mode {
+	case HalfUp:
+		return amount.Round(places)
+	case Down:
+		return amount.Truncate(places)
+	default:
+		return amount.RoundBank(places)
+	}
+}
+
+// RoundScale rounds a unit price, average or rate to Scale places, half to
+// even.
+func RoundScale(value decimal.Decimal) decimal.Decimal {
+	return value.RoundBank(Scale)
+}
+
+// Div divides a by b to Scale places, half to even. b must not be zero.
+func Div(a, b decimal.Decimal) decimal.Decimal {
+	return RoundScale(a.DivRound(b, Scale+2))
+}
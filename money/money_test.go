@@ -0,0 +1,103 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     string
+	}{
+		// hundredths, ties to even
+		{"10.125", "USD", "10.12"},
+		{"10.135", "USD", "10.14"},
+		{"-10.125", "USD", "-10.12"},
+		{"10.1251", "EUR", "10.13"},
+		{"0.005", "GBP", "0"},
+		// an unknown code is rounded like USD
+		{"10.125", "XYZ", "10.12"},
+		// no minor units, ties away from zero
+		{"1234.5", "JPY", "1235"},
+		{"-1234.5", "JPY", "-1235"},
+		{"1234.49", "KRW", "1234"},
+		{"99.5", "CLP", "100"},
+		// thousandths, ties to even
+		{"1.2345", "KWD", "1.234"},
+		{"1.2355", "BHD", "1.236"},
+		// hundredths, ties away from zero
+		{"10.125", "HUF", "10.13"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.amount, func(t *testing.T) {
+			got := Round(decimal.RequireFromString(tt.amount), tt.currency)
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("Round(%s, %s) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTo(t *testing.T) {
+	tests := []struct {
+		amount string
+		places int32
+		mode   RoundingMode
+		want   string
+	}{
+		{"2.5", 0, HalfEven, "2"},
+		{"3.5", 0, HalfEven, "4"},
+		{"2.5", 0, HalfUp, "3"},
+		{"-2.5", 0, HalfUp, "-3"},
+		{"2.99", 0, Down, "2"},
+		{"-2.99", 0, Down, "-2"},
+		{"1.23456789", 4, Down, "1.2345"},
+	}
+	for _, tt := range tests {
+		got := RoundTo(decimal.RequireFromString(tt.amount), tt.places, tt.mode)
+		if !got.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("RoundTo(%s, %d, %d) = %s, want %s", tt.amount, tt.places, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     string
+	}{
+		{"10.5", "EUR", "10.50 EUR"},
+		{"1234.5", "JPY", "1235 JPY"},
+		{"1.2", "KWD", "1.200 KWD"},
+		{"-0.004", "USD", "0.00 USD"},
+	}
+	for _, tt := range tests {
+		if got := Format(decimal.RequireFromString(tt.amount), tt.currency); got != tt.want {
+			t.Errorf("Format(%s, %s) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"1", "3", "0.33333333"},
+		{"2", "3", "0.66666667"},
+		{"100", "7", "14.28571429"},
+		// the tie at Scale places goes to even
+		{"0.000000025", "1", "0.00000002"},
+		{"0.000000035", "1", "0.00000004"},
+	}
+	for _, tt := range tests {
+		got := Div(decimal.RequireFromString(tt.a), decimal.RequireFromString(tt.b))
+		if !got.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("Div(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	bucket BucketState
+	window WindowState
+	idleAt time.Time
+}
+
+// MemoryStore keeps every key in process. Keys whose allowance is back to
+// full are dropped by Evict, so the map only holds recently active callers.
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys map[string]*memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]*memoryEntry)}
+}
+
+func (m *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.keys[key]
+	if !ok {
+		entry = &memoryEntry{}
+		m.keys[key] = entry
+	}
+
+	var decision Decision
+	if limit.Algorithm == SlidingWindow {
+		decision = entry.window.Take(limit, now)
+		entry.idleAt = entry.window.IdleAt(limit)
+	} else {
+		decision = entry.bucket.Take(limit, now)
+		entry.idleAt = entry.bucket.IdleAt(limit)
+	}
+	return decision, nil
+}
+
+// Evict drops the keys that have been idle long enough to be back to their
+// full allowance and returns how many went.
+func (m *MemoryStore) Evict(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	evicted := 0
+	for key, entry := range m.keys {
+		if !entry.idleAt.After(now) {
+			delete(m.keys, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Len is the number of keys being tracked.
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.keys)
+}
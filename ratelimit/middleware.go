@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/metrics"
+	"github.com/pratyush934/tradealpha/server/types"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog"
+)
+
+// KeyFunc names who a request is counted against.
+type KeyFunc func(c echo.Context) string
+
+// ByIP counts requests against the client IP, which is only as good as the
+// echo IPExtractor: behind a proxy it has to trust that proxy's
+// X-Forwarded-For and nothing else.
+func ByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// ByUser counts requests against the signed in user, so one account gets
+// the same allowance from every device and key. Anonymous requests fall
+// back to the client IP.
+func ByUser(c echo.Context) string {
+	if userId, ok := c.Get("userId").(string); ok && userId != "" {
+		return "user:" + userId
+	}
+	return ByIP(c)
+}
+
+// Policy is a Limit applied per key. Every policy has buckets of its own,
+// so a tight limit on one route does not eat into the default.
+type Policy struct {
+	Name  string
+	Limit Limit
+	Key   KeyFunc
+}
+
+// Limiter applies the policy of the matched route, or the default one, to
+// every request it sees. It has to run after the auth middleware for ByUser
+// to see who is calling.
+type Limiter struct {
+	store    Store
+	disabled bool
+	fallback Policy
+	routes   map[string]Policy
+	now      func() time.Time
+}
+
+// New builds a Limiter from its policies. routes is keyed by method and
+// route template, "POST /login".
+func New(store Store, fallback Policy, routes map[string]Policy) *Limiter {
+	return &Limiter{store: store, fallback: fallback, routes: routes, now: time.Now}
+}
+
+// FromConfig builds the Limiter described by cfg. A disabled one lets every
+// request through.
+func FromConfig(cfg config.RateLimitConfig, store Store) *Limiter {
+	routes := make(map[string]Policy, len(cfg.Routes))
+	for route, policy := range cfg.Routes {
+		routes[route] = policyFromConfig(route, policy)
+	}
+
+	limiter := New(store, policyFromConfig("default", cfg.Default), routes)
+	limiter.disabled = !cfg.Enabled
+	return limiter
+}
+
+func policyFromConfig(name string, cfg config.RateLimitPolicy) Policy {
+	key := ByUser
+	if cfg.Key == config.RateLimitByIP {
+		key = ByIP
+	}
+	return Policy{
+		Name: name,
+		Limit: Limit{
+			Algorithm: cfg.Algorithm,
+			Requests:  cfg.Requests,
+			Window:    cfg.Window.Std(),
+			Burst:     cfg.Burst,
+		},
+		Key: key,
+	}
+}
+
+func (l *Limiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			policy, ok := l.routes[c.Request().Method+" "+c.Path()]
+			if !ok {
+				policy = l.fallback
+			}
+
+			if err := l.Allow(c, policy.Name, policy.Name+":"+policy.Key(c), policy.Limit); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// Allow counts the request against key under limit and sets the
+// RateLimit-* headers. It returns the 429 to send once key is out of
+// allowance. A store that fails lets the request through: a shared store
+// being down should not take the API with it.
+func (l *Limiter) Allow(c echo.Context, policy, key string, limit Limit) error {
+	if l.disabled {
+		return nil
+	}
+
+	ctx := c.Request().Context()
+	decision, err := l.store.Take(ctx, key, limit, l.now())
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("policy", policy).Msg("rate limit store failed, letting the request through")
+		return nil
+	}
+
+	setHeaders(c.Response().Header(), decision, limit)
+	if decision.Allowed {
+		return nil
+	}
+
+	metrics.RateLimited(policy)
+	c.Response().Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+	return util.NewAppError(http.StatusTooManyRequests, types.StatusTooManyRequests, "rate limit exceeded, retry later", nil)
+}
+
+// setHeaders writes the RateLimit-* headers of the IETF draft. A request
+// checked against more than one policy, an API key and its route, reports
+// whichever has the least left.
+func setHeaders(header http.Header, decision Decision, limit Limit) {
+	if current := header.Get("RateLimit-Remaining"); current != "" {
+		if remaining, err := strconv.Atoi(current); err == nil && remaining <= decision.Remaining {
+			return
+		}
+	}
+
+	header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+	header.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.Itoa(ceilSeconds(limit.Window)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog"
+)
+
+// clock is what the Limiter under test takes as the time.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func testRouter(limiter *Limiter) *echo.Echo {
+	logger := zerolog.Nop()
+	e := echo.New()
+	e.Use(util.ErrorHandleMiddleWare(&logger), limiter.Middleware())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/portfolios", ok)
+	e.POST("/login", ok)
+	return e
+}
+
+func request(e *echo.Echo, method, path, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = ip + ":40000"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	limiter := New(NewMemoryStore(),
+		Policy{Name: "default", Limit: Limit{Algorithm: TokenBucket, Requests: 2, Window: time.Minute}, Key: ByIP},
+		map[string]Policy{
+			"POST /login": {Name: "login", Limit: Limit{Algorithm: SlidingWindow, Requests: 1, Window: time.Minute}, Key: ByIP},
+		})
+	now := &clock{now: epoch}
+	limiter.now = now.Now
+	e := testRouter(limiter)
+
+	tests := []struct {
+		name          string
+		advance       time.Duration
+		method        string
+		path          string
+		ip            string
+		wantCode      int
+		wantRemaining string
+		wantReset     string
+		wantRetry     string
+	}{
+		{"first", 0, http.MethodGet, "/portfolios", "10.0.0.1", http.StatusOK, "1", "30", ""},
+		{"second", 0, http.MethodGet, "/portfolios", "10.0.0.1", http.StatusOK, "0", "60", ""},
+		{"out of tokens", 0, http.MethodGet, "/portfolios", "10.0.0.1", http.StatusTooManyRequests, "0", "60", "30"},
+		{"another client", 0, http.MethodGet, "/portfolios", "10.0.0.2", http.StatusOK, "1", "30", ""},
+		{"a route with its own policy", 0, http.MethodPost, "/login", "10.0.0.1", http.StatusOK, "0", "60", ""},
+		{"its own policy used up", 10 * time.Second, http.MethodPost, "/login", "10.0.0.1", http.StatusTooManyRequests, "0", "50", "50"},
+		{"a token refilled", 20 * time.Second, http.MethodGet, "/portfolios", "10.0.0.1", http.StatusOK, "0", "60", ""},
+	}
+	for _, tt := range tests {
+		now.now = now.now.Add(tt.advance)
+		rec := request(e, tt.method, tt.path, tt.ip)
+		header := rec.Header()
+		if rec.Code != tt.wantCode || header.Get("RateLimit-Remaining") != tt.wantRemaining || header.Get("RateLimit-Reset") != tt.wantReset || header.Get("Retry-After") != tt.wantRetry {
+			t.Errorf("%s: %d remaining %q reset %q retry after %q, want %d %q %q %q", tt.name, rec.Code,
+				header.Get("RateLimit-Remaining"), header.Get("RateLimit-Reset"), header.Get("Retry-After"),
+				tt.wantCode, tt.wantRemaining, tt.wantReset, tt.wantRetry)
+		}
+	}
+
+	rec := request(e, http.MethodGet, "/portfolios", "10.0.0.3")
+	if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("RateLimit-Limit = %q, want 2", got)
+	}
+	if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want 2;w=60", got)
+	}
+}
+
+func TestLimiterReportsTightestPolicy(t *testing.T) {
+	limiter := New(NewMemoryStore(), Policy{}, nil)
+	limiter.now = (&clock{now: epoch}).Now
+
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	key := Limit{Algorithm: SlidingWindow, Requests: 2, Window: time.Minute}
+	route := Limit{Algorithm: SlidingWindow, Requests: 10, Window: time.Minute}
+
+	if err := limiter.Allow(c, "api_key", "key", key); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Allow(c, "route", "route", route); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Response().Header().Get("RateLimit-Remaining"); got != "1" {
+		t.Errorf("RateLimit-Remaining = %q, want the key's 1", got)
+	}
+	if got := c.Response().Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("RateLimit-Limit = %q, want the key's 2", got)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (Decision, error) {
+	return Decision{}, errors.New("store is down")
+}
+
+func TestLimiterLetsThrough(t *testing.T) {
+	disabled := config.Defaults().RateLimit
+	disabled.Enabled = false
+	disabled.Default.Requests = 1
+
+	for name, limiter := range map[string]*Limiter{
+		"disabled":     FromConfig(disabled, NewMemoryStore()),
+		"store failed": New(failingStore{}, Policy{Name: "default", Limit: Limit{Algorithm: TokenBucket, Requests: 1, Window: time.Minute}, Key: ByIP}, nil),
+	} {
+		e := testRouter(limiter)
+		for i := 0; i < 3; i++ {
+			if rec := request(e, http.MethodGet, "/portfolios", "10.0.0.1"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+				t.Errorf("%s: request %d = %d with limit %q, want 200 and no headers", name, i+1, rec.Code, rec.Header().Get("RateLimit-Limit"))
+			}
+		}
+	}
+}
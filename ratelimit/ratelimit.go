@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+)
+
+const (
+	TokenBucket   = config.RateLimitTokenBucket
+	SlidingWindow = config.RateLimitSlidingWindow
+)
+
+// Limit is how many requests a key may make per Window. A token bucket also
+// lets Burst requests through at once, it defaults to Requests.
+type Limit struct {
+	Algorithm string
+	Requests  int
+	Window    time.Duration
+	Burst     int
+}
+
+func (l Limit) capacity() int {
+	if l.Algorithm == TokenBucket && l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Decision is the outcome of one request against a key, with what the
+// RateLimit-* and Retry-After headers report.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the key is back to its full allowance
+	RetryAfter time.Duration // zero when Allowed
+}
+
+// Store keeps the state of every key. MemoryStore serves a single instance;
+// a store shared between instances, such as Redis, has to make Take atomic
+// per key and can keep BucketState or WindowState as the value.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// BucketState is a token bucket: tokens refill continuously at
+// Requests/Window up to the capacity and every request takes one.
+type BucketState struct {
+	Tokens float64
+	Last   time.Time
+}
+
+func (b *BucketState) Take(limit Limit, now time.Time) Decision {
+	capacity := float64(limit.capacity())
+	perSecond := float64(limit.Requests) / limit.Window.Seconds()
+
+	if b.Last.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.Last).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*perSecond)
+	}
+	b.Last = now
+
+	decision := Decision{Limit: limit.capacity()}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = seconds((1 - b.Tokens) / perSecond)
+	}
+	decision.Remaining = int(b.Tokens)
+	decision.Reset = seconds((capacity - b.Tokens) / perSecond)
+	return decision
+}
+
+// IdleAt is when the bucket is full again and can be forgotten.
+func (b *BucketState) IdleAt(limit Limit) time.Time {
+	perSecond := float64(limit.Requests) / limit.Window.Seconds()
+	return b.Last.Add(seconds((float64(limit.capacity()) - b.Tokens) / perSecond))
+}
+
+// WindowState is a sliding window counter: the count of the previous fixed
+// window is weighted by how much of it still overlaps the sliding one. It
+// needs two counters per key instead of a timestamp per request.
+type WindowState struct {
+	Start    time.Time
+	Current  int
+	Previous int
+}
+
+func (w *WindowState) Take(limit Limit, now time.Time) Decision {
+	start := now.Truncate(limit.Window)
+	if !start.Equal(w.Start) {
+		if start.Sub(w.Start) == limit.Window {
+			w.Previous = w.Current
+		} else {
+			w.Previous = 0
+		}
+		w.Current = 0
+		w.Start = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(limit.Window)
+	used := float64(w.Previous)*weight + float64(w.Current)
+
+	decision := Decision{Limit: limit.Requests, Reset: limit.Window - elapsed}
+	if used+1 <= float64(limit.Requests) {
+		w.Current++
+		used++
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = w.retryAfter(limit, elapsed)
+	}
+	decision.Remaining = max(0, limit.Requests-int(math.Ceil(used)))
+	return decision
+}
+
+// retryAfter is how long until one more request fits, which only happens
+// as the previous window slides out.
+func (w *WindowState) retryAfter(limit Limit, elapsed time.Duration) time.Duration {
+	room := float64(limit.Requests - w.Current - 1)
+	if room < 0 || w.Previous == 0 {
+		// the current window alone is full, wait for the next one
+		return limit.Window - elapsed
+	}
+	// Previous*(1 - t/Window) <= room
+	at := time.Duration(float64(limit.Window) * (1 - room/float64(w.Previous)))
+	return max(at-elapsed, time.Second)
+}
+
+// IdleAt is when neither window counts any more and the key can be
+// forgotten.
+func (w *WindowState) IdleAt(limit Limit) time.Time {
+	return w.Start.Add(2 * limit.Window)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
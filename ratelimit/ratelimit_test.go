@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// epoch starts a fixed window of a minute, so the sliding window cases can
+// say how far into it they are.
+var epoch = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+type take struct {
+	at            time.Duration // since epoch
+	wantAllowed   bool
+	wantRemaining int
+	wantRetry     time.Duration
+	wantReset     time.Duration
+}
+
+func checkTakes(t *testing.T, takes []take, fn func(now time.Time) Decision) {
+	t.Helper()
+	for i, tt := range takes {
+		got := fn(epoch.Add(tt.at))
+		if got.Allowed != tt.wantAllowed || got.Remaining != tt.wantRemaining || got.RetryAfter != tt.wantRetry || got.Reset != tt.wantReset {
+			t.Errorf("take %d at %v = %+v, want allowed %v, remaining %d, retry after %v, reset %v",
+				i+1, tt.at, got, tt.wantAllowed, tt.wantRemaining, tt.wantRetry, tt.wantReset)
+		}
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	// a token a second, three at once
+	limit := Limit{Algorithm: TokenBucket, Requests: 60, Window: time.Minute, Burst: 3}
+	var bucket BucketState
+
+	checkTakes(t, []take{
+		{0, true, 2, 0, time.Second},
+		{0, true, 1, 0, 2 * time.Second},
+		{0, true, 0, 0, 3 * time.Second},
+		{0, false, 0, time.Second, 3 * time.Second},
+		{500 * time.Millisecond, false, 0, 500 * time.Millisecond, 2500 * time.Millisecond},
+		{time.Second, true, 0, 0, 3 * time.Second},
+		// refilled to the burst and no further
+		{time.Hour, true, 2, 0, time.Second},
+	}, func(now time.Time) Decision {
+		decision := bucket.Take(limit, now)
+		if decision.Limit != 3 {
+			t.Errorf("Limit = %d, want the burst", decision.Limit)
+		}
+		return decision
+	})
+
+	if idle := bucket.IdleAt(limit); !idle.Equal(epoch.Add(time.Hour + time.Second)) {
+		t.Errorf("IdleAt = %v, want a second after the last take", idle.Sub(epoch))
+	}
+}
+
+func TestBucketBurstDefaultsToRequests(t *testing.T) {
+	limit := Limit{Algorithm: TokenBucket, Requests: 2, Window: time.Minute}
+	var bucket BucketState
+
+	checkTakes(t, []take{
+		{0, true, 1, 0, 30 * time.Second},
+		{0, true, 0, 0, time.Minute},
+		{0, false, 0, 30 * time.Second, time.Minute},
+	}, func(now time.Time) Decision { return bucket.Take(limit, now) })
+}
+
+func TestWindowTake(t *testing.T) {
+	limit := Limit{Algorithm: SlidingWindow, Requests: 4, Window: time.Minute}
+	var window WindowState
+
+	checkTakes(t, []take{
+		{0, true, 3, 0, time.Minute},
+		{10 * time.Second, true, 2, 0, 50 * time.Second},
+		{20 * time.Second, true, 1, 0, 40 * time.Second},
+		{30 * time.Second, true, 0, 0, 30 * time.Second},
+		// nothing slides out of an empty previous window
+		{40 * time.Second, false, 0, 20 * time.Second, 20 * time.Second},
+		// a quarter into the next minute three of the four still count
+		{75 * time.Second, true, 0, 0, 45 * time.Second},
+		{75 * time.Second, false, 0, 15 * time.Second, 45 * time.Second},
+		{90 * time.Second, true, 0, 0, 30 * time.Second},
+		// a whole window without requests forgets both
+		{3 * time.Minute, true, 3, 0, time.Minute},
+	}, func(now time.Time) Decision { return window.Take(limit, now) })
+
+	if idle := window.IdleAt(limit); !idle.Equal(epoch.Add(5 * time.Minute)) {
+		t.Errorf("IdleAt = %v, want two windows after the current one started", idle.Sub(epoch))
+	}
+}
+
+func TestWindowRetryAfter(t *testing.T) {
+	limit := Limit{Algorithm: SlidingWindow, Requests: 4, Window: time.Minute}
+
+	tests := []struct {
+		name     string
+		current  int
+		previous int
+		elapsed  time.Duration
+		want     time.Duration
+	}{
+		{"current window full", 4, 4, 15 * time.Second, 45 * time.Second},
+		{"no previous window", 3, 0, 15 * time.Second, 45 * time.Second},
+		{"previous slides out", 1, 4, 15 * time.Second, 15 * time.Second},
+		{"previous slides out sooner", 0, 4, 5 * time.Second, 10 * time.Second},
+		{"at least a second", 1, 4, 29*time.Second + 500*time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		window := WindowState{Current: tt.current, Previous: tt.previous}
+		if got := window.retryAfter(limit, tt.elapsed); got != tt.want {
+			t.Errorf("%s: retryAfter = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreEvict(t *testing.T) {
+	store := NewMemoryStore()
+	bucket := Limit{Algorithm: TokenBucket, Requests: 60, Window: time.Minute}
+	window := Limit{Algorithm: SlidingWindow, Requests: 60, Window: time.Minute}
+
+	for _, take := range []struct {
+		key   string
+		limit Limit
+	}{{"a", bucket}, {"a", bucket}, {"b", window}} {
+		if _, err := store.Take(context.Background(), take.key, take.limit, epoch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the bucket is full two seconds on, the window once two minutes passed
+	steps := []struct {
+		at          time.Duration
+		wantEvicted int
+		wantLen     int
+	}{
+		{0, 0, 2},
+		{time.Second, 0, 2},
+		{2 * time.Second, 1, 1},
+		{2*time.Minute - time.Nanosecond, 0, 1},
+		{2 * time.Minute, 1, 0},
+	}
+	for _, step := range steps {
+		if got := store.Evict(epoch.Add(step.at)); got != step.wantEvicted || store.Len() != step.wantLen {
+			t.Errorf("Evict at %v = %d leaving %d, want %d leaving %d", step.at, got, store.Len(), step.wantEvicted, step.wantLen)
+		}
+	}
+
+	// an evicted key starts over with its full allowance
+	decision, err := store.Take(context.Background(), "a", bucket, epoch.Add(3*time.Minute))
+	if err != nil || decision.Remaining != 59 {
+		t.Errorf("take after eviction = %+v, %v, want 59 remaining", decision, err)
+	}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, k *models.APIKeyModel) error
+	GetById(ctx context.Context, id string) (*models.APIKeyModel, error)
+	GetByHash(ctx context.Context, hash string) (*models.APIKeyModel, error)
+	ListByUserId(ctx context.Context, userId string) ([]models.APIKeyModel, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+	Touch(ctx context.Context, id string, at time.Time) error
+	RecordUsage(ctx context.Context, u *models.APIKeyUsageModel) error
+	ListUsage(ctx context.Context, apiKeyId string, limit, offset int) ([]models.APIKeyUsageModel, error)
+	CountUsable(ctx context.Context, now time.Time) (int64, error)
+}
+
+type gormAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &gormAPIKeyRepository{db: db}
+}
+
+func (r *gormAPIKeyRepository) Create(ctx context.Context, k *models.APIKeyModel) error {
+	if err := conn(ctx, r.db).Create(k).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in api_key_repository/Create")
+		return err
+	}
+	return nil
+}
+
+func (r *gormAPIKeyRepository) GetById(ctx context.Context, id string) (*models.APIKeyModel, error) {
+	var key models.APIKeyModel
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&key).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in api_key_repository/GetById")
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *gormAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKeyModel, error) {
+	var key models.APIKeyModel
+	if err := conn(ctx, r.db).Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in api_key_repository/GetByHash")
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *gormAPIKeyRepository) ListByUserId(ctx context.Context, userId string) ([]models.APIKeyModel, error) {
+	var keys []models.APIKeyModel
+	if err := conn(ctx, r.db).Where("user_id = ?", userId).Order("created_at desc").Find(&keys).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in api_key_repository/ListByUserId")
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *gormAPIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	if err := conn(ctx, r.db).Model(&models.APIKeyModel{}).Where("id = ?", id).Update("revoked_at", at).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in api_key_repository/Revoke")
+		return err
+	}
+	return nil
+}
+
+func (r *gormAPIKeyRepository) Touch(ctx context.Context, id string, at time.Time) error {
+	return conn(ctx, r.db).Model(&models.APIKeyModel{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+func (r *gormAPIKeyRepository) RecordUsage(ctx context.Context, u *models.APIKeyUsageModel) error {
+	if err := conn(ctx, r.db).Create(u).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in api_key_repository/RecordUsage")
+		return err
+	}
+	return nil
+}
+
+func (r *gormAPIKeyRepository) ListUsage(ctx context.Context, apiKeyId string, limit, offset int) ([]models.APIKeyUsageModel, error) {
+	var usage []models.APIKeyUsageModel
+	if err := conn(ctx, r.db).
+		Where("api_key_id = ?", apiKeyId).
+		Order("created_at desc").
+		Limit(limit).
+		Offset(offset).
+		Find(&usage).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in api_key_repository/ListUsage")
+		return nil, err
+	}
+	return usage, nil
+}
+
+// CountUsable counts keys that are neither revoked nor expired at now.
+func (r *gormAPIKeyRepository) CountUsable(ctx context.Context, now time.Time) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).Model(&models.APIKeyModel{}).
+		Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", now).
+		Count(&count).Error; err != nil {
+		log.Error().Err(err).Msg("issue lies in the api_key_repository/CountUsable")
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// AuditRepository is append-only, the model hooks and the triggers of the
+// audit_log_append_only migration refuse updates and deletes.
+type AuditRepository interface {
+	Create(ctx context.Context, a *models.AuditLogModel) error
+	Query(ctx context.Context, filter models.AuditLogFilter) ([]models.AuditLogModel, int64, error)
+	// Each calls fn with every entry filter matches, newest first, reading
+	// them batch by batch rather than all at once. Limit and Offset are
+	// ignored. It stops at the first error fn returns.
+	Each(ctx context.Context, filter models.AuditLogFilter, fn func(models.AuditLogModel) error) error
+}
+
+// auditExportBatch is how many entries Each reads per query.
+const auditExportBatch = 500
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &gormAuditRepository{db: db}
+}
+
+func (r *gormAuditRepository) Create(ctx context.Context, a *models.AuditLogModel) error {
+	if err := conn(ctx, r.db).Create(a).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in audit_repository/Create")
+		return err
+	}
+	return nil
+}
+
+func (r *gormAuditRepository) Query(ctx context.Context, filter models.AuditLogFilter) ([]models.AuditLogModel, int64, error) {
+	// each finisher starts from its own session, Count leaves its select
+	// and ordering behind on the statement it ran
+	query := filterAudit(conn(ctx, r.db).Model(&models.AuditLogModel{}), filter).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in audit_repository/Query")
+		return nil, 0, err
+	}
+
+	page := query.Order("created_at desc").Order("id desc").Offset(filter.Offset)
+	if filter.Limit > 0 {
+		page = page.Limit(filter.Limit)
+	}
+
+	var logs []models.AuditLogModel
+	if err := page.Find(&logs).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in audit_repository/Query")
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// Each pages by the key of the last entry read rather than by offset, so
+// entries recorded while it runs do not shift the pages.
+func (r *gormAuditRepository) Each(ctx context.Context, filter models.AuditLogFilter, fn func(models.AuditLogModel) error) error {
+	var last *models.AuditLogModel
+	for {
+		query := filterAudit(conn(ctx, r.db).Model(&models.AuditLogModel{}), filter)
+		if last != nil {
+			query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", last.CreatedAt, last.CreatedAt, last.Id)
+		}
+
+		var batch []models.AuditLogModel
+		if err := query.Order("created_at desc").Order("id desc").Limit(auditExportBatch).Find(&batch).Error; err != nil {
+			log.Error().Err(err).Msg("issue persist in audit_repository/Each")
+			return err
+		}
+		for _, entry := range batch {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(batch) < auditExportBatch {
+			return nil
+		}
+		last = &batch[len(batch)-1]
+	}
+}
+
+func filterAudit(query *gorm.DB, filter models.AuditLogFilter) *gorm.DB {
+	if filter.ActorId != "" {
+		query = query.Where("actor_id = ?", filter.ActorId)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetId != "" {
+		query = query.Where("target_id = ?", filter.TargetId)
+	}
+	if filter.RequestId != "" {
+		query = query.Where("request_id = ?", filter.RequestId)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	return query
+}
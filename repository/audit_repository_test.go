@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/database"
+	"github.com/pratyush934/tradealpha/server/migrations"
+	"github.com/pratyush934/tradealpha/server/models"
+	"gorm.io/gorm"
+)
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// migratedDB is an in-memory SQLite database brought up by the migrations,
+// with the query timeout the server runs with. It is opened once for the
+// package, the database metrics can only be registered once per process.
+func migratedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	migrateOnce.Do(func() {
+		if migrateErr = database.InitDB(config.DatabaseConfig{Driver: config.DriverSQLite, QueryTimeout: config.Duration(5 * time.Second)}); migrateErr != nil {
+			return
+		}
+		var migrator *migrations.Migrator
+		if migrator, migrateErr = migrations.New(database.DB, migrations.All()); migrateErr == nil {
+			_, migrateErr = migrator.Up(0)
+		}
+	})
+	if migrateErr != nil {
+		t.Fatal(migrateErr)
+	}
+	return database.DB
+}
+
+// auditTrail records count entries on targets of kind, alternating between
+// two actors and naming each target after its position. The log cannot be
+// emptied between tests, so each test filters on a kind of its own.
+func auditTrail(t *testing.T, repo AuditRepository, kind string, count int) []models.AuditLogModel {
+	t.Helper()
+
+	entries := make([]models.AuditLogModel, count)
+	for i := range entries {
+		entries[i] = models.AuditLogModel{
+			ActorId:    []string{"u1", "u2"}[i%2],
+			Action:     "portfolio.delete",
+			TargetType: kind,
+			TargetId:   fmt.Sprintf("p%d", i),
+		}
+		if err := repo.Create(context.Background(), &entries[i]); err != nil {
+			t.Fatal(err)
+		}
+		// one apart at least, so the newest first order is the creation order
+		time.Sleep(time.Millisecond)
+	}
+	return entries
+}
+
+func targets(logs []models.AuditLogModel) string {
+	ids := make([]string, len(logs))
+	for i, entry := range logs {
+		ids[i] = entry.TargetId
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestAuditQuery(t *testing.T) {
+	repo := NewAuditRepository(migratedDB(t))
+	entries := auditTrail(t, repo, "query", 7)
+	since := entries[3].CreatedAt
+
+	tests := []struct {
+		name      string
+		filter    models.AuditLogFilter
+		wantTotal int64
+		want      string
+	}{
+		{"everything newest first", models.AuditLogFilter{}, 7, "[p6 p5 p4 p3 p2 p1 p0]"},
+		{"one actor", models.AuditLogFilter{ActorId: "u1"}, 4, "[p6 p4 p2 p0]"},
+		{"first page", models.AuditLogFilter{ActorId: "u1", Limit: 3}, 4, "[p6 p4 p2]"},
+		{"second page", models.AuditLogFilter{ActorId: "u1", Limit: 3, Offset: 3}, 4, "[p0]"},
+		{"past the end", models.AuditLogFilter{ActorId: "u1", Limit: 3, Offset: 6}, 4, "[]"},
+		{"from a time on", models.AuditLogFilter{From: &since, Limit: 2}, 4, "[p6 p5]"},
+		{"before a time", models.AuditLogFilter{To: &since}, 3, "[p2 p1 p0]"},
+		{"one target", models.AuditLogFilter{TargetId: "p5"}, 1, "[p5]"},
+		{"no match", models.AuditLogFilter{Action: "user.login"}, 0, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.TargetType = "query"
+			logs, total, err := repo.Query(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.wantTotal || targets(logs) != tt.want {
+				t.Errorf("got %d %s, want %d %s", total, targets(logs), tt.wantTotal, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditEach(t *testing.T) {
+	repo := NewAuditRepository(migratedDB(t))
+	auditTrail(t, repo, "export", 5)
+
+	var read []models.AuditLogModel
+	err := repo.Each(context.Background(), models.AuditLogFilter{ActorId: "u2", TargetType: "export", Limit: 1}, func(entry models.AuditLogModel) error {
+		read = append(read, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the limit is for listings, an export reads every match
+	if targets(read) != "[p3 p1]" {
+		t.Errorf("read %s, want [p3 p1]", targets(read))
+	}
+
+	// past one batch, entries created within the same instant included
+	for i := 0; i <= auditExportBatch; i++ {
+		if err := repo.Create(context.Background(), &models.AuditLogModel{Action: "user.login", TargetType: "batch"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := make(map[string]bool)
+	err = repo.Each(context.Background(), models.AuditLogFilter{TargetType: "batch"}, func(entry models.AuditLogModel) error {
+		seen[entry.Id] = true
+		return nil
+	})
+	if err != nil || len(seen) != auditExportBatch+1 {
+		t.Errorf("read %d distinct entries, err = %v, want %d", len(seen), err, auditExportBatch+1)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = repo.Each(context.Background(), models.AuditLogFilter{TargetType: "export"}, func(models.AuditLogModel) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want the callback's error after 1", err, calls)
+	}
+}
+
+func TestAuditAppendOnly(t *testing.T) {
+	db := migratedDB(t)
+	repo := NewAuditRepository(db)
+	entry := auditTrail(t, repo, "immutable", 1)[0]
+
+	// the hooks refuse through the model, the triggers refuse everything else
+	if err := db.Model(&entry).Update("action", "changed").Error; !errors.Is(err, models.ErrAuditLogImmutable) {
+		t.Errorf("model update: err = %v, want ErrAuditLogImmutable", err)
+	}
+	if err := db.Delete(&entry).Error; !errors.Is(err, models.ErrAuditLogImmutable) {
+		t.Errorf("model delete: err = %v, want ErrAuditLogImmutable", err)
+	}
+	if err := db.Exec("UPDATE audit_log_models SET action = ? WHERE id = ?", "changed", entry.Id).Error; err == nil {
+		t.Error("raw update went through")
+	}
+	if err := db.Exec("DELETE FROM audit_log_models WHERE id = ?", entry.Id).Error; err == nil {
+		t.Error("raw delete went through")
+	}
+
+	logs, total, err := repo.Query(context.Background(), models.AuditLogFilter{TargetType: "immutable"})
+	if err != nil || total != 1 || logs[0].Action != entry.Action {
+		t.Errorf("after the attempts: %v %d %v, want the entry unchanged", logs, total, err)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CorporateActionRepository interface {
+	// CreateIfMissing stores a unless an action of the same type already
+	// goes ex on the same day for the stock, and reports whether it did.
+	CreateIfMissing(ctx context.Context, a *models.CorporateActionModel) (bool, error)
+	ListByStock(ctx context.Context, stockId string) ([]models.CorporateActionModel, error)
+	ListBySymbol(ctx context.Context, symbol string) ([]models.CorporateActionModel, error)
+	// ListUnprocessed returns the actions not applied yet that went ex by
+	// now, oldest first.
+	ListUnprocessed(ctx context.Context, now time.Time) ([]models.CorporateActionModel, error)
+	MarkProcessed(ctx context.Context, id string, at time.Time) error
+}
+
+type gormCorporateActionRepository struct {
+	db *gorm.DB
+}
+
+func NewCorporateActionRepository(db *gorm.DB) CorporateActionRepository {
+	return &gormCorporateActionRepository{db: db}
+}
+
+func (r *gormCorporateActionRepository) CreateIfMissing(ctx context.Context, a *models.CorporateActionModel) (bool, error) {
+	result := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("issue persist in corporate_action_repository/CreateIfMissing")
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormCorporateActionRepository) ListByStock(ctx context.Context, stockId string) ([]models.CorporateActionModel, error) {
+	var actions []models.CorporateActionModel
+	if err := conn(ctx, r.db).Where("stock_id = ?", stockId).Order("ex_date asc").Find(&actions).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in corporate_action_repository/ListByStock")
+		return nil, err
+	}
+	return actions, nil
+}
+
+func (r *gormCorporateActionRepository) ListBySymbol(ctx context.Context, symbol string) ([]models.CorporateActionModel, error) {
+	var actions []models.CorporateActionModel
+	if err := conn(ctx, r.db).Where("symbol = ?", symbol).Order("ex_date asc").Find(&actions).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in corporate_action_repository/ListBySymbol")
+		return nil, err
+	}
+	return actions, nil
+}
+
+func (r *gormCorporateActionRepository) ListUnprocessed(ctx context.Context, now time.Time) ([]models.CorporateActionModel, error) {
+	var actions []models.CorporateActionModel
+	if err := conn(ctx, r.db).
+		Where("processed_at IS NULL AND ex_date <= ?", now).
+		Order("ex_date asc").
+		Find(&actions).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in corporate_action_repository/ListUnprocessed")
+		return nil, err
+	}
+	return actions, nil
+}
+
+func (r *gormCorporateActionRepository) MarkProcessed(ctx context.Context, id string, at time.Time) error {
+	return conn(ctx, r.db).Model(&models.CorporateActionModel{}).
+		Where("id = ?", id).
+		Update("processed_at", at).Error
+}
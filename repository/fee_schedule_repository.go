@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FeeScheduleRepository interface {
+	GetByPortfolioId(ctx context.Context, portfolioId string) (*models.FeeScheduleModel, error)
+	// Save stores f as the schedule of its portfolio, replacing the one it
+	// had.
+	Save(ctx context.Context, f *models.FeeScheduleModel) error
+	DeleteByPortfolioId(ctx context.Context, portfolioId string) error
+}
+
+type gormFeeScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewFeeScheduleRepository(db *gorm.DB) FeeScheduleRepository {
+	return &gormFeeScheduleRepository{db: db}
+}
+
+func (r *gormFeeScheduleRepository) GetByPortfolioId(ctx context.Context, portfolioId string) (*models.FeeScheduleModel, error) {
+	var schedule models.FeeScheduleModel
+	if err := conn(ctx, r.db).Where("portfolio_id = ?", portfolioId).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *gormFeeScheduleRepository) Save(ctx context.Context, f *models.FeeScheduleModel) error {
+	err := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "portfolio_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"flat", "per_share", "rate", "min_commission", "max_commission",
+			"sell_rate", "sell_per_share", "sell_per_share_max", "updated_at",
+		}),
+	}).Create(f).Error
+	if err != nil {
+		log.Error().Err(err).Msg("issue persist in fee_schedule_repository/Save")
+		return err
+	}
+	return nil
+}
+
+func (r *gormFeeScheduleRepository) DeleteByPortfolioId(ctx context.Context, portfolioId string) error {
+	if err := conn(ctx, r.db).Where("portfolio_id = ?", portfolioId).Delete(&models.FeeScheduleModel{}).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in fee_schedule_repository/DeleteByPortfolioId")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fxRateBatch keeps each insert under the bind parameter limits of the
+// drivers.
+const fxRateBatch = 500
+
+type FxRateRepository interface {
+	// Upsert stores rates, replacing any rate already stored for the same
+	// pair and day.
+	Upsert(ctx context.Context, rates []models.FxRateModel) error
+	// Latest returns the most recent rate of from in to on a YYYY-MM-DD day
+	// between since and day, both inclusive.
+	Latest(ctx context.Context, from, to, since, day string) (*models.FxRateModel, error)
+}
+
+type gormFxRateRepository struct {
+	db *gorm.DB
+}
+
+func NewFxRateRepository(db *gorm.DB) FxRateRepository {
+	return &gormFxRateRepository{db: db}
+}
+
+func (r *gormFxRateRepository) Upsert(ctx context.Context, rates []models.FxRateModel) error {
+	err := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "from_currency"}, {Name: "to_currency"}, {Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(rates, fxRateBatch).Error
+	if err != nil {
+		log.Error().Err(err).Msg("issue persist in fx_rate_repository/Upsert")
+		return err
+	}
+	return nil
+}
+
+func (r *gormFxRateRepository) Latest(ctx context.Context, from, to, since, day string) (*models.FxRateModel, error) {
+	var rate models.FxRateModel
+	if err := conn(ctx, r.db).
+		Where("from_currency = ? AND to_currency = ? AND day >= ? AND day <= ?", from, to, since, day).
+		Order("day desc").
+		First(&rate).Error; err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
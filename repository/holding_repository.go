@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HoldingRepository stores the positions derived from the trade history: one
+// PortFolioStock per (portfolio, stock) and its open FIFO lots.
+type HoldingRepository interface {
+	ListByPortfolioId(ctx context.Context, portfolioId string) ([]models.PortFolioStock, error)
+	Get(ctx context.Context, portfolioId, stockId string) (*models.PortFolioStock, error)
+	// Save writes the quantity, average price and realized gains of h, in
+	// both currencies, creating the row if the position is new. It is one
+	// upsert on the (portfolio_id, stock_id) unique index, so concurrent
+	// saves of a new position cannot create it twice.
+	Save(ctx context.Context, h *models.PortFolioStock) error
+	// SumRealizedGains adds up the realized gains of a portfolio in its base
+	// currency.
+	SumRealizedGains(ctx context.Context, portfolioId string) (decimal.Decimal, error)
+	DeleteByPortfolioId(ctx context.Context, portfolioId string) error
+
+	ListLots(ctx context.Context, portfolioId, stockId string) ([]models.HoldingLotModel, error)
+	ReplaceLots(ctx context.Context, portfolioId, stockId string, lots []models.HoldingLotModel) error
+}
+
+type gormHoldingRepository struct {
+	db *gorm.DB
+}
+
+func NewHoldingRepository(db *gorm.DB) HoldingRepository {
+	return &gormHoldingRepository{db: db}
+}
+
+func (r *gormHoldingRepository) ListByPortfolioId(ctx context.Context, portfolioId string) ([]models.PortFolioStock, error) {
+	var holdings []models.PortFolioStock
+	if err := conn(ctx, r.db).Where("portfolio_id = ?", portfolioId).Find(&holdings).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in holding_repository/ListByPortfolioId")
+		return nil, err
+	}
+	return holdings, nil
+}
+
+func (r *gormHoldingRepository) Get(ctx context.Context, portfolioId, stockId string) (*models.PortFolioStock, error) {
+	var holding models.PortFolioStock
+	if err := conn(ctx, r.db).
+		Where("portfolio_id = ? AND stock_id = ?", portfolioId, stockId).
+		First(&holding).Error; err != nil {
+		return nil, err
+	}
+	return &holding, nil
+}
+
+func (r *gormHoldingRepository) Save(ctx context.Context, h *models.PortFolioStock) error {
+	err := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "portfolio_id"}, {Name: "stock_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"quantity", "average_price", "realized_gains", "currency",
+			"average_price_base", "realized_gains_base", "updated_at",
+		}),
+	}).Create(h).Error
+	if err != nil {
+		log.Error().Err(err).Msg("issue persist in holding_repository/Save")
+		return err
+	}
+	return nil
+}
+
+// SumRealizedGains uses COALESCE and SUM, which behave the same on every
+// driver we support.
+func (r *gormHoldingRepository) SumRealizedGains(ctx context.Context, portfolioId string) (decimal.Decimal, error) {
+	var realized decimal.Decimal
+	if err := conn(ctx, r.db).Model(&models.PortFolioStock{}).
+		Select("COALESCE(SUM(realized_gains_base), 0)").
+		Where("portfolio_id = ?", portfolioId).
+		Scan(&realized).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in holding_repository/SumRealizedGains")
+		return decimal.Zero, err
+	}
+	return realized, nil
+}
+
+func (r *gormHoldingRepository) DeleteByPortfolioId(ctx context.Context, portfolioId string) error {
+	db := conn(ctx, r.db)
+	if err := db.Where("portfolio_id = ?", portfolioId).Delete(&models.HoldingLotModel{}).Error; err != nil {
+		return err
+	}
+	return db.Where("portfolio_id = ?", portfolioId).Delete(&models.PortFolioStock{}).Error
+}
+
+// ListLots returns the open lots of a portfolio, of one stock when stockId is
+// set, oldest first.
+func (r *gormHoldingRepository) ListLots(ctx context.Context, portfolioId, stockId string) ([]models.HoldingLotModel, error) {
+	var lots []models.HoldingLotModel
+	query := conn(ctx, r.db).Where("portfolio_id = ?", portfolioId)
+	if stockId != "" {
+		query = query.Where("stock_id = ?", stockId)
+	}
+	if err := query.Order("opened_at asc").Find(&lots).Error; err != nil {
+		return nil, err
+	}
+	return lots, nil
+}
+
+func (r *gormHoldingRepository) ReplaceLots(ctx context.Context, portfolioId, stockId string, lots []models.HoldingLotModel) error {
+	db := conn(ctx, r.db)
+	if err := db.Where("portfolio_id = ? AND stock_id = ?", portfolioId, stockId).Delete(&models.HoldingLotModel{}).Error; err != nil {
+		return err
+	}
+	if len(lots) == 0 {
+		return nil
+	}
+	return db.Create(&lots).Error
+}
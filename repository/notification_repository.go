@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type NotificationRepository interface {
+	Create(ctx context.Context, n *models.NotificationModel) error
+	GetById(ctx context.Context, id string) (*models.NotificationModel, error)
+	ListByUserId(ctx context.Context, userId string) ([]models.NotificationModel, error)
+	MarkRead(ctx context.Context, id string) error
+	CountUnread(ctx context.Context, userId string) (int64, error)
+	CountAllUnread(ctx context.Context) (int64, error)
+	Delete(ctx context.Context, id string) error
+	DeleteByUserId(ctx context.Context, userId string) error
+}
+
+type gormNotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &gormNotificationRepository{db: db}
+}
+
+func (r *gormNotificationRepository) Create(ctx context.Context, n *models.NotificationModel) error {
+	if err := conn(ctx, r.db).Create(n).Error; err != nil {
+		log.Error().Err(err).Msg("issue lies at notification_repository/Create")
+		return err
+	}
+	return nil
+}
+
+func (r *gormNotificationRepository) GetById(ctx context.Context, id string) (*models.NotificationModel, error) {
+	var notification models.NotificationModel
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&notification).Error; err != nil {
+		log.Error().Err(err).Msg("issue lies at notification_repository/GetById")
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *gormNotificationRepository) ListByUserId(ctx context.Context, userId string) ([]models.NotificationModel, error) {
+	var notifications []models.NotificationModel
+	if err := conn(ctx, r.db).Where("user_id = ?", userId).Order("created_at desc").Find(&notifications).Error; err != nil {
+		log.Error().Err(err).Msg("issue lies at notification_repository/ListByUserId")
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *gormNotificationRepository) MarkRead(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Model(&models.NotificationModel{}).Where("id = ?", id).Update("read_status", true).Error
+}
+
+func (r *gormNotificationRepository) CountUnread(ctx context.Context, userId string) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).
+		Model(&models.NotificationModel{}).
+		Where("user_id = ? AND read_status = ?", userId, false).
+		Count(&count).Error; err != nil {
+		log.Error().Err(err).Msg("issue lies in the notification_repository/CountUnread")
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountAllUnread counts unread notifications across every user.
+func (r *gormNotificationRepository) CountAllUnread(ctx context.Context) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).
+		Model(&models.NotificationModel{}).
+		Where("read_status = ?", false).
+		Count(&count).Error; err != nil {
+		log.Error().Err(err).Msg("issue lies in the notification_repository/CountAllUnread")
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *gormNotificationRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Where("id = ?", id).Delete(&models.NotificationModel{}).Error
+}
+
+func (r *gormNotificationRepository) DeleteByUserId(ctx context.Context, userId string) error {
+	return conn(ctx, r.db).Where("user_id = ?", userId).Delete(&models.NotificationModel{}).Error
+}
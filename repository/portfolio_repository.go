@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PortfolioRepository interface {
+	Create(ctx context.Context, p *models.PortFolio) error
+	GetById(ctx context.Context, id string) (*models.PortFolio, error)
+	// Lock reads the portfolio without its relations and, in a transaction,
+	// holds a row lock on it until the transaction ends, so the writes that
+	// depend on its holdings and cash take turns.
+	Lock(ctx context.Context, id string) (*models.PortFolio, error)
+	ListByUserId(ctx context.Context, userId string) ([]models.PortFolio, error)
+	// ListMargin returns every margin portfolio, without its relations.
+	ListMargin(ctx context.Context) ([]models.PortFolio, error)
+	UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error
+	Delete(ctx context.Context, id string) error
+}
+
+type gormPortfolioRepository struct {
+	db *gorm.DB
+}
+
+func NewPortfolioRepository(db *gorm.DB) PortfolioRepository {
+	return &gormPortfolioRepository{db: db}
+}
+
+func (r *gormPortfolioRepository) Create(ctx context.Context, p *models.PortFolio) error {
+	if err := conn(ctx, r.db).Create(p).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the portfolio_repository/Create")
+		return err
+	}
+	return nil
+}
+
+func (r *gormPortfolioRepository) GetById(ctx context.Context, id string) (*models.PortFolio, error) {
+	var portfolio models.PortFolio
+	if err := conn(ctx, r.db).
+		Preload("Transaction").
+		Preload("PortFolioStock").
+		Where("id = ?", id).
+		First(&portfolio).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the portfolio_repository/GetById")
+		return nil, err
+	}
+	return &portfolio, nil
+}
+
+func (r *gormPortfolioRepository) Lock(ctx context.Context, id string) (*models.PortFolio, error) {
+	var portfolio models.PortFolio
+	if err := conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		First(&portfolio).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the portfolio_repository/Lock")
+		return nil, err
+	}
+	return &portfolio, nil
+}
+
+func (r *gormPortfolioRepository) ListByUserId(ctx context.Context, userId string) ([]models.PortFolio, error) {
+	var portfolios []models.PortFolio
+	if err := conn(ctx, r.db).
+		Where("user_id = ?", userId).
+		Preload("Transaction").
+		Preload("PortFolioStock").
+		Find(&portfolios).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the portfolio_repository/ListByUserId")
+		return nil, err
+	}
+	return portfolios, nil
+}
+
+func (r *gormPortfolioRepository) ListMargin(ctx context.Context) ([]models.PortFolio, error) {
+	var portfolios []models.PortFolio
+	if err := conn(ctx, r.db).Where("margin = ?", true).Order("created_at asc").Find(&portfolios).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the portfolio_repository/ListMargin")
+		return nil, err
+	}
+	return portfolios, nil
+}
+
+func (r *gormPortfolioRepository) UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error {
+	if err := conn(ctx, r.db).Model(&models.PortFolio{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the portfolio_repository/UpdateFields")
+		return err
+	}
+	return nil
+}
+
+func (r *gormPortfolioRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Where("id = ?", id).Delete(&models.PortFolio{}).Error
+}
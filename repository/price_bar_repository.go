@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// priceBarBatch keeps each insert under the bind parameter limits of the
+// drivers.
+const priceBarBatch = 500
+
+type PriceBarRepository interface {
+	// Upsert stores bars, replacing the values of any bar already stored for
+	// the same symbol and day.
+	Upsert(ctx context.Context, bars []models.PriceBarModel) error
+	// List returns the bars of symbol from one day to another, both
+	// YYYY-MM-DD and inclusive, oldest first. An empty bound is open.
+	List(ctx context.Context, symbol, from, to string) ([]models.PriceBarModel, error)
+}
+
+type gormPriceBarRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceBarRepository(db *gorm.DB) PriceBarRepository {
+	return &gormPriceBarRepository{db: db}
+}
+
+func (r *gormPriceBarRepository) Upsert(ctx context.Context, bars []models.PriceBarModel) error {
+	err := conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "source", "updated_at"}),
+	}).CreateInBatches(bars, priceBarBatch).Error
+	if err != nil {
+		log.Error().Err(err).Msg("issue persist in price_bar_repository/Upsert")
+		return err
+	}
+	return nil
+}
+
+func (r *gormPriceBarRepository) List(ctx context.Context, symbol, from, to string) ([]models.PriceBarModel, error) {
+	query := conn(ctx, r.db).Where("symbol = ?", symbol)
+	if from != "" {
+		query = query.Where("day >= ?", from)
+	}
+	if to != "" {
+		query = query.Where("day <= ?", to)
+	}
+
+	var bars []models.PriceBarModel
+	if err := query.Order("day asc").Find(&bars).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in price_bar_repository/List")
+		return nil, err
+	}
+	return bars, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// WithTx returns a ctx carrying tx. Every repository method called with it
+// runs on tx instead of the pool, which is how a service spans several
+// repositories with one DB transaction.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// conn is the handle a repository method should use: the tx in ctx if there
+// is one, the pool otherwise, bound to ctx either way.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok && tx != nil {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// Transactor runs fn in a DB transaction, committing when fn returns nil.
+// Repositories called with the ctx handed to fn join the transaction, and a
+// nested InTx becomes a savepoint of the outer one.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type gormTransactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) Transactor {
+	return &gormTransactor{db: db}
+}
+
+func (t *gormTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return conn(ctx, t.db).Transaction(func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	})
+}
+
+// Repositories bundles one implementation of every repository, all sharing
+// the same pool.
+type Repositories struct {
+	Tx            Transactor
+	Users         UserRepository
+	Portfolios    PortfolioRepository
+	Holdings      HoldingRepository
+	Transactions  TransactionRepository
+	WatchLists    WatchListRepository
+	Notifications NotificationRepository
+	Stocks        StockRepository
+	PriceBars     PriceBarRepository
+	FxRates       FxRateRepository
+	FeeSchedules  FeeScheduleRepository
+	Actions       CorporateActionRepository
+	APIKeys       APIKeyRepository
+	Audit         AuditRepository
+}
+
+func NewGorm(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Tx:            NewTransactor(db),
+		Users:         NewUserRepository(db),
+		Portfolios:    NewPortfolioRepository(db),
+		Holdings:      NewHoldingRepository(db),
+		Transactions:  NewTransactionRepository(db),
+		WatchLists:    NewWatchListRepository(db),
+		Notifications: NewNotificationRepository(db),
+		Stocks:        NewStockRepository(db),
+		PriceBars:     NewPriceBarRepository(db),
+		FxRates:       NewFxRateRepository(db),
+		FeeSchedules:  NewFeeScheduleRepository(db),
+		Actions:       NewCorporateActionRepository(db),
+		APIKeys:       NewAPIKeyRepository(db),
+		Audit:         NewAuditRepository(db),
+	}
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type StockRepository interface {
+	Create(ctx context.Context, s *models.Stock) error
+	GetById(ctx context.Context, id string) (*models.Stock, error)
+	GetBySymbol(ctx context.Context, symbol string) (*models.Stock, error)
+	List(ctx context.Context, limit, offset int) ([]models.Stock, error)
+	ListBySector(ctx context.Context, sector string, limit, offset int) ([]models.Stock, error)
+	Update(ctx context.Context, s *models.Stock) error
+	Delete(ctx context.Context, id string) error
+}
+
+type gormStockRepository struct {
+	db *gorm.DB
+}
+
+func NewStockRepository(db *gorm.DB) StockRepository {
+	return &gormStockRepository{db: db}
+}
+
+func (r *gormStockRepository) Create(ctx context.Context, s *models.Stock) error {
+	if err := conn(ctx, r.db).Create(s).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in stock_repository/Create")
+		return err
+	}
+	return nil
+}
+
+func (r *gormStockRepository) GetById(ctx context.Context, id string) (*models.Stock, error) {
+	var stock models.Stock
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&stock).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in stock_repository/GetById")
+		return nil, err
+	}
+	return &stock, nil
+}
+
+func (r *gormStockRepository) GetBySymbol(ctx context.Context, symbol string) (*models.Stock, error) {
+	var stock models.Stock
+	if err := conn(ctx, r.db).Where("symbol = ?", symbol).First(&stock).Error; err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
+
+func (r *gormStockRepository) List(ctx context.Context, limit, offset int) ([]models.Stock, error) {
+	var stocks []models.Stock
+	if err := conn(ctx, r.db).Order("symbol asc").Limit(limit).Offset(offset).Find(&stocks).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in stock_repository/List")
+		return nil, err
+	}
+	return stocks, nil
+}
+
+func (r *gormStockRepository) ListBySector(ctx context.Context, sector string, limit, offset int) ([]models.Stock, error) {
+	var stocks []models.Stock
+	if err := conn(ctx, r.db).Where("sector = ?", sector).Order("symbol asc").Limit(limit).Offset(offset).Find(&stocks).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in stock_repository/ListBySector")
+		return nil, err
+	}
+	return stocks, nil
+}
+
+func (r *gormStockRepository) Update(ctx context.Context, s *models.Stock) error {
+	return conn(ctx, r.db).Updates(s).Error
+}
+
+func (r *gormStockRepository) Delete(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Where("id = ?", id).Delete(&models.Stock{}).Error
+}
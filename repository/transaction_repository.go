@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// TransactionRepository is append-only, the model hooks refuse updates and
+// deletes.
+type TransactionRepository interface {
+	Create(ctx context.Context, t *models.TransactionModel) error
+	GetById(ctx context.Context, id string) (*models.TransactionModel, error)
+	ListByUserId(ctx context.Context, userId string) ([]models.TransactionModel, error)
+	ListByPortfolioId(ctx context.Context, portfolioId string) ([]models.TransactionModel, error)
+	ListByUserAndStock(ctx context.Context, userId, stockId string) ([]models.TransactionModel, error)
+	ListByPortfolioAndStock(ctx context.Context, portfolioId, stockId string) ([]models.TransactionModel, error)
+	CountByPortfolioId(ctx context.Context, portfolioId string) (int64, error)
+	CountReversalsOf(ctx context.Context, id string) (int64, error)
+	// CountByTypeSince counts the entries of type in a portfolio and stock
+	// traded at or after since.
+	CountByTypeSince(ctx context.Context, portfolioId, stockId, txType string, since time.Time) (int64, error)
+	// ListChildren returns the reversal and replacement entries booked
+	// against id, oldest first.
+	ListChildren(ctx context.Context, id string) ([]models.TransactionModel, error)
+	// ListPortfoliosByStock returns one entry per portfolio that ever traded
+	// stockId, with only UserId and PortFolioId set.
+	ListPortfoliosByStock(ctx context.Context, stockId string) ([]models.TransactionModel, error)
+}
+
+type gormTransactionRepository struct {
+	db *gorm.DB
+}
+
+func NewTransactionRepository(db *gorm.DB) TransactionRepository {
+	return &gormTransactionRepository{db: db}
+}
+
+func (r *gormTransactionRepository) Create(ctx context.Context, t *models.TransactionModel) error {
+	return conn(ctx, r.db).Create(t).Error
+}
+
+func (r *gormTransactionRepository) GetById(ctx context.Context, id string) (*models.TransactionModel, error) {
+	var tx models.TransactionModel
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&tx).Error; err != nil {
+		log.Error().Err(err).Msg("issue in transaction_repository/GetById")
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (r *gormTransactionRepository) ListByUserId(ctx context.Context, userId string) ([]models.TransactionModel, error) {
+	var txs []models.TransactionModel
+	if err := conn(ctx, r.db).Where("user_id = ?", userId).Find(&txs).Error; err != nil {
+		log.Error().Err(err).Msg("issue in transaction_repository/ListByUserId")
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (r *gormTransactionRepository) ListByPortfolioId(ctx context.Context, portfolioId string) ([]models.TransactionModel, error) {
+	var txs []models.TransactionModel
+	if err := conn(ctx, r.db).Where("portfolio_id = ?", portfolioId).Find(&txs).Error; err != nil {
+		log.Error().Err(err).Msg("issue in transaction_repository/ListByPortfolioId")
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (r *gormTransactionRepository) ListByUserAndStock(ctx context.Context, userId, stockId string) ([]models.TransactionModel, error) {
+	var txs []models.TransactionModel
+	if err := conn(ctx, r.db).
+		Where("user_id = ? AND stock_id = ?", userId, stockId).
+		Find(&txs).Error; err != nil {
+		log.Error().Err(err).Msg("issue in transaction_repository/ListByUserAndStock")
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (r *gormTransactionRepository) ListByPortfolioAndStock(ctx context.Context, portfolioId, stockId string) ([]models.TransactionModel, error) {
+	var txs []models.TransactionModel
+	if err := conn(ctx, r.db).
+		Where("portfolio_id = ? AND stock_id = ?", portfolioId, stockId).
+		Find(&txs).Error; err != nil {
+		log.Error().Err(err).Msg("issue in transaction_repository/ListByPortfolioAndStock")
+		return nil, err
+	}
+	return txs, nil
+}
+
+func (r *gormTransactionRepository) CountByPortfolioId(ctx context.Context, portfolioId string) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).Model(&models.TransactionModel{}).Where("portfolio_id = ?", portfolioId).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *gormTransactionRepository) CountReversalsOf(ctx context.Context, id string) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).Model(&models.TransactionModel{}).Where("reversal_of_id = ?", id).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *gormTransactionRepository) CountByTypeSince(ctx context.Context, portfolioId, stockId, txType string, since time.Time) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).Model(&models.TransactionModel{}).
+		Where("portfolio_id = ? AND stock_id = ? AND type = ? AND trade_date >= ?", portfolioId, stockId, txType, since).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *gormTransactionRepository) ListChildren(ctx context.Context, id string) ([]models.TransactionModel, error) {
+	var next []models.TransactionModel
+	if err := conn(ctx, r.db).
+		Where("reversal_of_id = ? OR replaces_id = ?", id, id).
+		Order("created_at asc").
+		Find(&next).Error; err != nil {
+		log.Error().Err(err).Msg("issue in transaction_repository/ListChildren")
+		return nil, err
+	}
+	return next, nil
+}
+
+func (r *gormTransactionRepository) ListPortfoliosByStock(ctx context.Context, stockId string) ([]models.TransactionModel, error) {
+	var portfolios []models.TransactionModel
+	if err := conn(ctx, r.db).Model(&models.TransactionModel{}).
+		Distinct("user_id", "portfolio_id").
+		Where("stock_id = ?", stockId).
+		Find(&portfolios).Error; err != nil {
+		log.Error().Err(err).Msg("issue in transaction_repository/ListPortfoliosByStock")
+		return nil, err
+	}
+	return portfolios, nil
+}
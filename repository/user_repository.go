@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserRepository covers the user row and what only exists through it:
+// addresses and 2FA recovery codes.
+type UserRepository interface {
+	Create(ctx context.Context, u *models.User) error
+	GetById(ctx context.Context, id string) (*models.User, error)
+	GetSummaryById(ctx context.Context, id string) (*models.User, error)
+	// Lock reads the user row like GetSummaryById and, in a transaction,
+	// holds a row lock on it until the transaction ends.
+	Lock(ctx context.Context, id string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	List(ctx context.Context, limit, offset int) ([]models.User, error)
+	UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error
+	UpdateLastLogin(ctx context.Context, email string, at time.Time) error
+	MarkVerified(ctx context.Context, id, email string) (bool, error)
+	Debit(ctx context.Context, id string, amount decimal.Decimal) (bool, error)
+	Credit(ctx context.Context, id string, amount decimal.Decimal) error
+	// Charge takes amount from the balance even when that leaves it
+	// negative, a loan to a margin account.
+	Charge(ctx context.Context, id string, amount decimal.Decimal) error
+	AdvanceTOTPStep(ctx context.Context, id string, step int64) (bool, error)
+	ListDueForDeletion(ctx context.Context, now time.Time) ([]string, error)
+	CountActive(ctx context.Context) (int64, error)
+	HardDelete(ctx context.Context, id string) error
+
+	ReplaceRecoveryCodes(ctx context.Context, userId string, hashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userId, hash string) (bool, error)
+	DeleteRecoveryCodes(ctx context.Context, userId string) error
+	CountUnusedRecoveryCodes(ctx context.Context, userId string) (int64, error)
+
+	CreateAddress(ctx context.Context, a *models.AddressModel) error
+	GetAddressById(ctx context.Context, id string) (*models.AddressModel, error)
+	GetAddressesByUserId(ctx context.Context, userId string) ([]models.AddressModel, error)
+	UpdateAddress(ctx context.Context, a *models.AddressModel) error
+	DeleteAddress(ctx context.Context, id string) error
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) withRelations(ctx context.Context) *gorm.DB {
+	return conn(ctx, r.db).
+		Preload("Address").
+		Preload("PortFolio").
+		Preload("Transactions").
+		Preload("Notification")
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, u *models.User) error {
+	if err := conn(ctx, r.db).Create(u).Error; err != nil {
+		log.Error().Err(err).Msg("Issue lie in the user_repository/Create")
+		return err
+	}
+	return nil
+}
+
+func (r *gormUserRepository) GetById(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	if err := r.withRelations(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in user_repository/GetById")
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetSummaryById loads the user row without any of its relations.
+func (r *gormUserRepository) GetSummaryById(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&user).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in user_repository/GetSummaryById")
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) Lock(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	if err := conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		First(&user).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in user_repository/Lock")
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.withRelations(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) List(ctx context.Context, limit, offset int) ([]models.User, error) {
+	var users []models.User
+	if err := r.withRelations(ctx).Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in user_repository/List")
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *gormUserRepository) UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error {
+	if err := conn(ctx, r.db).Model(&models.User{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		log.Error().Err(err).Msg("issue lie in the user_repository/UpdateFields")
+		return err
+	}
+	return nil
+}
+
+func (r *gormUserRepository) UpdateLastLogin(ctx context.Context, email string, at time.Time) error {
+	if err := conn(ctx, r.db).Model(&models.User{}).Where("email = ?", email).Update("last_login", at).Error; err != nil {
+		log.Error().Err(err).Msg("issue lie in the user_repository/UpdateLastLogin")
+		return err
+	}
+	return nil
+}
+
+// MarkVerified only flips the flag while the email still matches, it
+// reports false when it does not.
+func (r *gormUserRepository) MarkVerified(ctx context.Context, id, email string) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.User{}).
+		Where("id = ? AND email = ?", id, email).
+		Update("verification_status", true)
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("issue lie in the user_repository/MarkVerified")
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Debit only takes the amount when the balance covers it, it reports false
+// when the funds are insufficient.
+func (r *gormUserRepository) Debit(ctx context.Context, id string, amount decimal.Decimal) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.User{}).
+		Where("id = ? AND account_balance >= ?", id, amount).
+		Update("account_balance", gorm.Expr("account_balance - ?", amount))
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("issue persist in user_repository/Debit")
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormUserRepository) Credit(ctx context.Context, id string, amount decimal.Decimal) error {
+	result := conn(ctx, r.db).Model(&models.User{}).
+		Where("id = ?", id).
+		Update("account_balance", gorm.Expr("account_balance + ?", amount))
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("issue persist in user_repository/Credit")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *gormUserRepository) Charge(ctx context.Context, id string, amount decimal.Decimal) error {
+	result := conn(ctx, r.db).Model(&models.User{}).
+		Where("id = ?", id).
+		Update("account_balance", gorm.Expr("account_balance - ?", amount))
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("issue persist in user_repository/Charge")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AdvanceTOTPStep records the last accepted step so the same code cannot be
+// replayed inside its validity window. It reports false on a replay.
+func (r *gormUserRepository) AdvanceTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", id, step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("issue persist in user_repository/AdvanceTOTPStep")
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormUserRepository) ListDueForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	var ids []string
+	if err := conn(ctx, r.db).Model(&models.User{}).
+		Where("deletion_scheduled IS NOT NULL AND deletion_scheduled <= ?", now).
+		Pluck("id", &ids).Error; err != nil {
+		log.Error().Err(err).Msg("issue lie in the user_repository/ListDueForDeletion")
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CountActive counts accounts that are not deactivated, suspended or
+// waiting for deletion.
+func (r *gormUserRepository) CountActive(ctx context.Context) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).Model(&models.User{}).
+		Where("deactivated_at IS NULL AND suspended_at IS NULL AND deletion_scheduled IS NULL").
+		Count(&count).Error; err != nil {
+		log.Error().Err(err).Msg("issue lie in the user_repository/CountActive")
+		return 0, err
+	}
+	return count, nil
+}
+
+// HardDelete removes the user and every row hanging off it. Callers wrap it
+// in a transaction so a failure leaves the account intact.
+func (r *gormUserRepository) HardDelete(ctx context.Context, userId string) error {
+	db := conn(ctx, r.db)
+
+	portfolioIds := db.Model(&models.PortFolio{}).Select("id").Where("user_id = ?", userId)
+	watchListIds := db.Model(&models.WatchListModel{}).Select("id").Where("user_id = ?", userId)
+	apiKeyIds := db.Model(&models.APIKeyModel{}).Select("id").Where("user_id = ?", userId)
+
+	steps := []func() error{
+		func() error {
+			return db.Where("portfolio_id IN (?)", portfolioIds).Delete(&models.PortFolioStock{}).Error
+		},
+		func() error {
+			return db.Where("watchlist_id IN (?)", watchListIds).Delete(&models.WatchListStockModel{}).Error
+		},
+		func() error { return db.Where("api_key_id IN (?)", apiKeyIds).Delete(&models.APIKeyUsageModel{}).Error },
+		// transactions refuse deletes through their hooks, erasure is the one exception
+		func() error {
+			return db.Session(&gorm.Session{SkipHooks: true}).Where("user_id = ?", userId).Delete(&models.TransactionModel{}).Error
+		},
+		func() error {
+			return db.Where("portfolio_id IN (?)", portfolioIds).Delete(&models.HoldingLotModel{}).Error
+		},
+		func() error { return db.Where("user_id = ?", userId).Delete(&models.PortFolio{}).Error },
+		func() error { return db.Where("user_id = ?", userId).Delete(&models.WatchListModel{}).Error },
+		func() error { return db.Where("user_id = ?", userId).Delete(&models.NotificationModel{}).Error },
+		func() error { return db.Where("user_id = ?", userId).Delete(&models.AddressModel{}).Error },
+		func() error { return db.Where("user_id = ?", userId).Delete(&models.APIKeyModel{}).Error },
+		func() error { return db.Where("user_id = ?", userId).Delete(&models.RecoveryCodeModel{}).Error },
+		func() error { return db.Where("id = ?", userId).Delete(&models.User{}).Error },
+	}
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			log.Error().Err(err).Str("user_id", userId).Msg("issue lie in the user_repository/HardDelete")
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gormUserRepository) ReplaceRecoveryCodes(ctx context.Context, userId string, hashes []string) error {
+	rows := make([]models.RecoveryCodeModel, 0, len(hashes))
+	for _, hash := range hashes {
+		rows = append(rows, models.RecoveryCodeModel{UserId: userId, CodeHash: hash})
+	}
+
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&models.RecoveryCodeModel{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("issue persist in user_repository/ReplaceRecoveryCodes")
+		return err
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode marks a matching unused code as used.
+func (r *gormUserRepository) ConsumeRecoveryCode(ctx context.Context, userId, hash string) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.RecoveryCodeModel{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("issue persist in user_repository/ConsumeRecoveryCode")
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormUserRepository) DeleteRecoveryCodes(ctx context.Context, userId string) error {
+	return conn(ctx, r.db).Where("user_id = ?", userId).Delete(&models.RecoveryCodeModel{}).Error
+}
+
+func (r *gormUserRepository) CountUnusedRecoveryCodes(ctx context.Context, userId string) (int64, error) {
+	var count int64
+	if err := conn(ctx, r.db).Model(&models.RecoveryCodeModel{}).
+		Where("user_id = ? AND used_at IS NULL", userId).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *gormUserRepository) CreateAddress(ctx context.Context, a *models.AddressModel) error {
+	if err := conn(ctx, r.db).Create(a).Error; err != nil {
+		log.Error().Err(err).Msg("issue lie in user_repository/CreateAddress")
+		return err
+	}
+	return nil
+}
+
+func (r *gormUserRepository) GetAddressById(ctx context.Context, id string) (*models.AddressModel, error) {
+	var address models.AddressModel
+	if err := conn(ctx, r.db).Where("id = ?", id).First(&address).Error; err != nil {
+		log.Error().Err(err).Msg("issue lie in user_repository/GetAddressById")
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (r *gormUserRepository) GetAddressesByUserId(ctx context.Context, userId string) ([]models.AddressModel, error) {
+	var addresses []models.AddressModel
+	if err := conn(ctx, r.db).Where("user_id = ?", userId).Find(&addresses).Error; err != nil {
+		log.Error().Err(err).Msg("issue lie in user_repository/GetAddressesByUserId")
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (r *gormUserRepository) UpdateAddress(ctx context.Context, a *models.AddressModel) error {
+	if err := conn(ctx, r.db).Updates(a).Error; err != nil {
+		log.Error().Err(err).Msg("issue lie in user_repository/UpdateAddress")
+		return err
+	}
+	return nil
+}
+
+func (r *gormUserRepository) DeleteAddress(ctx context.Context, id string) error {
+	return conn(ctx, r.db).Where("id = ?", id).Delete(&models.AddressModel{}).Error
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WatchListRepository interface {
+	Create(ctx context.Context, w *models.WatchListModel) error
+	GetById(ctx context.Context, id string) (*models.WatchListModel, error)
+	ListByUserId(ctx context.Context, userId string) ([]models.WatchListModel, error)
+	Update(ctx context.Context, w *models.WatchListModel) error
+	Delete(ctx context.Context, id string) error
+
+	// AddStock reports false when the stock is already in the watchlist.
+	AddStock(ctx context.Context, s *models.WatchListStockModel) (bool, error)
+	RemoveStock(ctx context.Context, watchListId, symbol string) error
+	ListStocksByUserId(ctx context.Context, userId string) ([]models.WatchListStockModel, error)
+}
+
+type gormWatchListRepository struct {
+	db *gorm.DB
+}
+
+func NewWatchListRepository(db *gorm.DB) WatchListRepository {
+	return &gormWatchListRepository{db: db}
+}
+
+func (r *gormWatchListRepository) Create(ctx context.Context, w *models.WatchListModel) error {
+	if err := conn(ctx, r.db).Create(w).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the watchlist_repository/Create")
+		return err
+	}
+	return nil
+}
+
+func (r *gormWatchListRepository) GetById(ctx context.Context, id string) (*models.WatchListModel, error) {
+	var watchList models.WatchListModel
+	if err := conn(ctx, r.db).Preload("WatchListStock").Where("id = ?", id).First(&watchList).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the watchlist_repository/GetById")
+		return nil, err
+	}
+	return &watchList, nil
+}
+
+func (r *gormWatchListRepository) ListByUserId(ctx context.Context, userId string) ([]models.WatchListModel, error) {
+	var watchLists []models.WatchListModel
+	if err := conn(ctx, r.db).Where("user_id = ?", userId).Find(&watchLists).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the watchlist_repository/ListByUserId")
+		return nil, err
+	}
+	return watchLists, nil
+}
+
+func (r *gormWatchListRepository) Update(ctx context.Context, w *models.WatchListModel) error {
+	return conn(ctx, r.db).Updates(w).Error
+}
+
+func (r *gormWatchListRepository) Delete(ctx context.Context, id string) error {
+	db := conn(ctx, r.db)
+	if err := db.Where("watchlist_id = ?", id).Delete(&models.WatchListStockModel{}).Error; err != nil {
+		return err
+	}
+	return db.Where("id = ?", id).Delete(&models.WatchListModel{}).Error
+}
+
+// AddStock lets the unique (watchlist_id, stock_id) index decide duplicates
+// instead of a check-then-insert; each driver renders DoNothing in its own
+// syntax.
+func (r *gormWatchListRepository) AddStock(ctx context.Context, s *models.WatchListStockModel) (bool, error) {
+	result := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(s)
+	if result.Error != nil {
+		log.Error().Err(result.Error).Str("watchlist_id", s.WatchListId).Msg("issue persist in the watchlist_repository/AddStock")
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormWatchListRepository) RemoveStock(ctx context.Context, watchListId, symbol string) error {
+	if err := conn(ctx, r.db).
+		Where("watchlist_id = ? AND symbol = ?", watchListId, symbol).
+		Delete(&models.WatchListStockModel{}).Error; err != nil {
+		log.Error().Err(err).Str("watchlist_id", watchListId).Msg("issue persist in the watchlist_repository/RemoveStock")
+		return err
+	}
+	return nil
+}
+
+func (r *gormWatchListRepository) ListStocksByUserId(ctx context.Context, userId string) ([]models.WatchListStockModel, error) {
+	db := conn(ctx, r.db)
+	watchListIds := db.Model(&models.WatchListModel{}).Select("id").Where("user_id = ?", userId)
+
+	var stocks []models.WatchListStockModel
+	if err := db.Where("watchlist_id IN (?)", watchListIds).Find(&stocks).Error; err != nil {
+		log.Error().Err(err).Msg("issue persist in the watchlist_repository/ListStocksByUserId")
+		return nil, err
+	}
+	return stocks, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+)
+
+var (
+	ErrAPIKeyInvalid  = errors.New("invalid api key")
+	ErrAPIKeyUnusable = errors.New("api key is revoked or expired")
+)
+
+var validAPIKeyScopes = map[string]bool{
+	"*":                 true,
+	"portfolio:read":    true,
+	"portfolio:write":   true,
+	"trade:write":       true,
+	"watchlist:read":    true,
+	"watchlist:write":   true,
+	"market:read":       true,
+	"notification:read": true,
+}
+
+type APIKeyService struct {
+	keys repository.APIKeyRepository
+}
+
+func NewAPIKeyService(repos *repository.Repositories) *APIKeyService {
+	return &APIKeyService{keys: repos.APIKeys}
+}
+
+// Create issues a key for userId and returns its plaintext, which is only
+// ever shown once, together with the stored row.
+func (s *APIKeyService) Create(ctx context.Context, userId, name string, scopes []string, rateLimit, expiresInDays int) (string, *models.APIKeyModel, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "APIKeyService.Create")
+	defer span.End()
+
+	if name == "" {
+		return "", nil, invalid("api key name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, invalid("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !validAPIKeyScopes[scope] {
+			return "", nil, invalid("unknown scope " + scope)
+		}
+	}
+
+	plain, prefix, hash, err := models.GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if rateLimit <= 0 {
+		rateLimit = models.DefaultAPIKeyRateLimit
+	}
+
+	key := &models.APIKeyModel{
+		UserId:    userId,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    strings.Join(scopes, ","),
+		RateLimit: rateLimit,
+	}
+
+	if expiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, expiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.keys.Create(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return plain, key, nil
+}
+
+func (s *APIKeyService) ListByUser(ctx context.Context, userId string) ([]models.APIKeyModel, error) {
+	return s.keys.ListByUserId(ctx, userId)
+}
+
+// Get returns the key only when it belongs to userId.
+func (s *APIKeyService) Get(ctx context.Context, userId, id string) (*models.APIKeyModel, error) {
+	key, err := s.keys.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.UserId != userId {
+		return nil, ErrNotOwner
+	}
+	return key, nil
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, id string) error {
+	return s.keys.Revoke(ctx, id, time.Now())
+}
+
+func (s *APIKeyService) Usage(ctx context.Context, id string, limit, offset int) ([]models.APIKeyUsageModel, error) {
+	return s.keys.ListUsage(ctx, id, limit, offset)
+}
+
+func (s *APIKeyService) CountUsable(ctx context.Context, now time.Time) (int64, error) {
+	return s.keys.CountUsable(ctx, now)
+}
+
+// Authenticate resolves a plaintext key to a usable stored key.
+func (s *APIKeyService) Authenticate(ctx context.Context, plain string, now time.Time) (*models.APIKeyModel, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "APIKeyService.Authenticate")
+	defer span.End()
+
+	key, err := s.keys.GetByHash(ctx, models.HashAPIKey(plain))
+	if err != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	if !key.IsUsable(now) {
+		return nil, ErrAPIKeyUnusable
+	}
+	return key, nil
+}
+
+// RecordUsage logs one request made with a key and bumps its last use.
+func (s *APIKeyService) RecordUsage(ctx context.Context, usage *models.APIKeyUsageModel, now time.Time) error {
+	if err := s.keys.RecordUsage(ctx, usage); err != nil {
+		return err
+	}
+	return s.keys.Touch(ctx, usage.APIKeyId, now)
+}
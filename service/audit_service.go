@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/repository"
+)
+
+type AuditService struct {
+	audit repository.AuditRepository
+}
+
+func NewAuditService(repos *repository.Repositories) *AuditService {
+	return &AuditService{audit: repos.Audit}
+}
+
+func (s *AuditService) Record(ctx context.Context, entry *models.AuditLogModel) error {
+	return s.audit.Create(ctx, entry)
+}
+
+func (s *AuditService) Query(ctx context.Context, filter models.AuditLogFilter) ([]models.AuditLogModel, int64, error) {
+	return s.audit.Query(ctx, filter)
+}
+
+// Export calls fn with every entry filter matches, newest first, without
+// holding them all in memory.
+func (s *AuditService) Export(ctx context.Context, filter models.AuditLogFilter, fn func(models.AuditLogModel) error) error {
+	return s.audit.Each(ctx, filter, fn)
+}
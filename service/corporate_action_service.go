@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/money"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/shopspring/decimal"
+)
+
+// CorporateActionService records splits and dividends and applies them to
+// the portfolios holding the stock once they take effect: a split by
+// rebuilding every holding with it, a dividend by booking a dividend
+// transaction and crediting the cash to the owner.
+type CorporateActionService struct {
+	tx            repository.Transactor
+	actions       repository.CorporateActionRepository
+	stocks        repository.StockRepository
+	transactions  repository.TransactionRepository
+	users         repository.UserRepository
+	fx            *FxService
+	trades        *TradeService
+	portfolios    *PortfolioService
+	notifications *NotificationService
+	market        *alphavantage.Client
+}
+
+func NewCorporateActionService(repos *repository.Repositories, fx *FxService, trades *TradeService, portfolios *PortfolioService, notifications *NotificationService, market *alphavantage.Client) *CorporateActionService {
+	return &CorporateActionService{
+		tx:            repos.Tx,
+		actions:       repos.Actions,
+		stocks:        repos.Stocks,
+		transactions:  repos.Transactions,
+		users:         repos.Users,
+		fx:            fx,
+		trades:        trades,
+		portfolios:    portfolios,
+		notifications: notifications,
+		market:        market,
+	}
+}
+
+func (s *CorporateActionService) List(ctx context.Context, symbol string) ([]models.CorporateActionModel, error) {
+	return s.actions.ListBySymbol(ctx, strings.ToUpper(symbol))
+}
+
+// Create records an action for the stock action.Symbol names and, when it
+// has already taken effect, applies it in the same DB transaction: an
+// action that cannot be applied is not recorded.
+func (s *CorporateActionService) Create(ctx context.Context, action *models.CorporateActionModel) error {
+	ctx, span := tracing.Tracer().Start(ctx, "CorporateActionService.Create")
+	defer span.End()
+
+	if err := validateCorporateAction(action); err != nil {
+		return err
+	}
+	stock, err := s.stocks.GetBySymbol(ctx, strings.ToUpper(action.Symbol))
+	if err != nil {
+		return err
+	}
+	action.StockId, action.Symbol = stock.Id, stock.Symbol
+	if action.Source == "" {
+		action.Source = models.CorporateActionSourceAdmin
+	}
+
+	added, err := s.add(ctx, action, time.Now())
+	if err != nil {
+		return err
+	}
+	if !added {
+		return ErrCorporateActionExists
+	}
+	return nil
+}
+
+// Sync records the splits and dividends of symbol in the provider's
+// adjusted history that are not recorded yet, applies those that have taken
+// effect and returns them.
+func (s *CorporateActionService) Sync(ctx context.Context, symbol string) ([]models.CorporateActionModel, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "CorporateActionService.Sync")
+	defer span.End()
+
+	stock, err := s.stocks.GetBySymbol(ctx, strings.ToUpper(symbol))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = alphavantage.WithPriority(ctx, alphavantage.PriorityBackfill)
+	fetched, err := s.market.FetchCorporateActions(ctx, stock.Symbol, loggerFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	added := []models.CorporateActionModel{}
+	now := time.Now()
+	for _, f := range fetched {
+		exDate, err := time.Parse(time.DateOnly, f.ExDate)
+		if err != nil {
+			continue
+		}
+		var actions []models.CorporateActionModel
+		if !f.Split.IsZero() {
+			actions = append(actions, models.CorporateActionModel{Type: models.CorporateActionSplit, Ratio: f.Split})
+		}
+		if !f.Dividend.IsZero() {
+			actions = append(actions, models.CorporateActionModel{Type: models.CorporateActionCashDividend, Amount: f.Dividend})
+		}
+		for _, action := range actions {
+			action.StockId, action.Symbol = stock.Id, stock.Symbol
+			action.ExDate = exDate
+			action.Source = s.market.Name()
+
+			ok, err := s.add(ctx, &action, now)
+			if err != nil {
+				return added, err
+			}
+			if ok {
+				added = append(added, action)
+			}
+		}
+	}
+	return added, nil
+}
+
+// ProcessDue applies the recorded actions that have taken effect by now but
+// were not applied yet, such as a dividend whose pay date has come, and
+// returns how many it applied. One that fails is left for the next run.
+func (s *CorporateActionService) ProcessDue(ctx context.Context, now time.Time) (int, error) {
+	actions, err := s.actions.ListUnprocessed(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	var firstErr error
+	for i := range actions {
+		action := &actions[i]
+		if action.EffectiveAt().After(now) {
+			continue
+		}
+		var booked []models.TransactionModel
+		err := s.tx.InTx(ctx, func(ctx context.Context) error {
+			var err error
+			booked, err = s.apply(ctx, action, now)
+			return err
+		})
+		if err != nil {
+			loggerFrom(ctx).Error().Err(err).Str("corporate_action_id", action.Id).Msg("Failed to apply corporate action")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		s.announce(ctx, action, booked)
+		applied++
+	}
+	return applied, firstErr
+}
+
+// add records action unless one like it exists, reporting whether it did,
+// and applies it when it has taken effect by now.
+func (s *CorporateActionService) add(ctx context.Context, action *models.CorporateActionModel, now time.Time) (bool, error) {
+	action.ExDate = time.Date(action.ExDate.Year(), action.ExDate.Month(), action.ExDate.Day(), 0, 0, 0, 0, time.UTC)
+
+	var added bool
+	var booked []models.TransactionModel
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if added, err = s.actions.CreateIfMissing(ctx, action); err != nil || !added {
+			return err
+		}
+		if action.EffectiveAt().After(now) {
+			return nil
+		}
+		booked, err = s.apply(ctx, action, now)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if added && action.ProcessedAt != nil {
+		s.announce(ctx, action, booked)
+	}
+	return added, nil
+}
+
+// apply rebuilds the holdings a split changes, or books a dividend in every
+// portfolio that held the stock before the ex date, and marks action
+// processed. It returns the entries whose owners should hear about it:
+// the portfolios rebuilt or the dividends booked.
+func (s *CorporateActionService) apply(ctx context.Context, action *models.CorporateActionModel, now time.Time) ([]models.TransactionModel, error) {
+	holders, err := s.transactions.ListPortfoliosByStock(ctx, action.StockId)
+	if err != nil {
+		return nil, err
+	}
+
+	var affected []models.TransactionModel
+	for _, holder := range holders {
+		switch action.Type {
+		case models.CorporateActionSplit:
+			// the trades before the split were accepted, short sales too
+			if err := s.trades.rebuildHoldings(ctx, holder.PortFolioId, action.StockId); err != nil {
+				return nil, fmt.Errorf("portfolio %s: %w", holder.PortFolioId, err)
+			}
+			affected = append(affected, holder)
+		case models.CorporateActionCashDividend:
+			dividend, err := s.bookDividend(ctx, action, holder)
+			if err != nil {
+				return nil, fmt.Errorf("portfolio %s: %w", holder.PortFolioId, err)
+			}
+			if dividend != nil {
+				affected = append(affected, *dividend)
+			}
+		}
+	}
+
+	if err := s.actions.MarkProcessed(ctx, action.Id, now); err != nil {
+		return nil, err
+	}
+	action.ProcessedAt = &now
+	return affected, nil
+}
+
+// bookDividend pays the dividend on the shares holder's portfolio held at
+// the close before the ex date, or returns nil when it held none. The
+// dividend is booked in the stock's currency and credited in the owner's,
+// both converted at the rates of the day it is paid. A short position pays
+// the dividend to the lender of its shares instead: the entry has a negative
+// quantity and the owner's cash is charged.
+func (s *CorporateActionService) bookDividend(ctx context.Context, action *models.CorporateActionModel, holder models.TransactionModel) (*models.TransactionModel, error) {
+	txs, err := s.transactions.ListByPortfolioAndStock(ctx, holder.PortFolioId, action.StockId)
+	if err != nil {
+		return nil, err
+	}
+	before := txs[:0]
+	for _, t := range txs {
+		if t.TradeDate.Before(action.ExDate) {
+			before = append(before, t)
+		}
+	}
+	splits, err := s.trades.splits(ctx, action.StockId, action.ExDate)
+	if err != nil {
+		return nil, err
+	}
+	lots, _, _, err := replayHoldings(holder.PortFolioId, action.StockId, before, splits, s.trades.places, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var quantity decimal.Decimal
+	for _, lot := range lots {
+		quantity = quantity.Add(lot.Quantity)
+	}
+	if quantity.IsZero() {
+		return nil, nil
+	}
+
+	currency, err := s.fx.StockCurrency(ctx, action.StockId)
+	if err != nil {
+		return nil, err
+	}
+	portfolio, err := s.portfolios.Get(ctx, holder.UserId, holder.PortFolioId)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetSummaryById(ctx, holder.UserId)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := s.fx.Rate(ctx, currency, portfolio.BaseCurrency, action.EffectiveAt())
+	if err != nil {
+		return nil, err
+	}
+	credit, err := s.fx.Convert(ctx, action.Amount.Mul(quantity), currency, user.BaseCurrency, action.EffectiveAt())
+	if err != nil {
+		return nil, err
+	}
+
+	dividend := &models.TransactionModel{
+		UserId:            holder.UserId,
+		PortFolioId:       holder.PortFolioId,
+		StockId:           action.StockId,
+		Quantity:          quantity,
+		Price:             action.Amount,
+		PriceSource:       action.Source,
+		Currency:          currency,
+		FxRate:            rate,
+		Type:              models.TransactionTypeDividend,
+		Status:            models.TransactionStatusExecuted,
+		CorporateActionId: &action.Id,
+		TradeDate:         action.EffectiveAt(),
+	}
+	if err := s.transactions.Create(ctx, dividend); err != nil {
+		return nil, err
+	}
+	if err := s.users.Credit(ctx, holder.UserId, credit); err != nil {
+		return nil, err
+	}
+	return dividend, nil
+}
+
+// announce revalues the portfolios an applied action changed and tells
+// their owners. The action is committed, so failures are only logged.
+func (s *CorporateActionService) announce(ctx context.Context, action *models.CorporateActionModel, affected []models.TransactionModel) {
+	ctx = context.WithoutCancel(ctx)
+	logger := loggerFrom(ctx)
+
+	for _, entry := range affected {
+		var message string
+		switch action.Type {
+		case models.CorporateActionSplit:
+			if err := s.portfolios.Revalue(ctx, entry.PortFolioId); err != nil {
+				logger.Error().Err(err).Str("portfolio_id", entry.PortFolioId).Msg("Failed to update portfolio metrics after split")
+			}
+			message = fmt.Sprintf("%s split %s on %s, your holding was adjusted", action.Symbol, splitRatio(action.Ratio), action.ExDate.Format(time.DateOnly))
+		case models.CorporateActionCashDividend:
+			if entry.Quantity.IsNegative() {
+				message = fmt.Sprintf("Dividend from %s: %s charged for %s shares short at %s", action.Symbol,
+					money.Format(entry.Price.Mul(entry.Quantity.Neg()), entry.Currency), entry.Quantity.Neg(), entry.Price)
+				break
+			}
+			message = fmt.Sprintf("Dividend from %s: %s credited for %s shares at %s", action.Symbol,
+				money.Format(entry.Price.Mul(entry.Quantity), entry.Currency), entry.Quantity, entry.Price)
+		}
+		if err := s.notifications.Notify(ctx, entry.UserId, message); err != nil {
+			logger.Error().Err(err).Msg("Failed to create notification")
+		}
+	}
+	logger.Info().Str("corporate_action_id", action.Id).Str("symbol", action.Symbol).Str("type", action.Type).
+		Int("affected", len(affected)).Msg("corporate action applied")
+}
+
+func validateCorporateAction(action *models.CorporateActionModel) error {
+	if strings.TrimSpace(action.Symbol) == "" {
+		return invalid("symbol is required")
+	}
+	if action.ExDate.IsZero() {
+		return invalid("exDate is required")
+	}
+	switch action.Type {
+	case models.CorporateActionSplit:
+		if !action.Ratio.IsPositive() || action.Ratio.Equal(decimal.NewFromInt(1)) {
+			return invalid("a split needs a positive ratio other than 1")
+		}
+		action.Amount, action.PayDate = decimal.Zero, nil
+	case models.CorporateActionCashDividend:
+		if !action.Amount.IsPositive() {
+			return invalid("a dividend needs a positive amount")
+		}
+		if action.PayDate != nil && action.PayDate.Before(action.ExDate) {
+			return invalid("payDate cannot be before exDate")
+		}
+		action.Ratio = decimal.Zero
+	default:
+		return invalid(fmt.Sprintf("type must be %s or %s", models.CorporateActionSplit, models.CorporateActionCashDividend))
+	}
+	return nil
+}
+
+// splitRatio writes 4 as 4:1 and 0.1 as 1:10.
+func splitRatio(ratio decimal.Decimal) string {
+	one := decimal.NewFromInt(1)
+	if ratio.GreaterThanOrEqual(one) {
+		return ratio.String() + ":1"
+	}
+	return "1:" + one.DivRound(ratio, 4).String()
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/money"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// fxRateMaxAge is how far back a stored rate may stand in for a day
+// without one, enough to span weekends and market holidays.
+const fxRateMaxAge = 7 * 24 * time.Hour
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// FxService converts amounts between currencies at the daily rates of the
+// market data provider. Every rate fetched is stored by day, so an amount of
+// a given day converts the same way however often it is asked for.
+type FxService struct {
+	rates  repository.FxRateRepository
+	stocks repository.StockRepository
+	market *alphavantage.Client
+}
+
+func NewFxService(repos *repository.Repositories, market *alphavantage.Client) *FxService {
+	return &FxService{rates: repos.FxRates, stocks: repos.Stocks, market: market}
+}
+
+// Rate returns what one unit of from bought in to on day. A stored rate of
+// the pair, or of its inverse, is used when there is one from that day or
+// shortly before it; otherwise today's rate is fetched live and an earlier
+// day's from the daily history, and stored.
+func (s *FxService) Rate(ctx context.Context, from, to string, day time.Time) (decimal.Decimal, error) {
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	on := day.UTC().Format(time.DateOnly)
+	if on > today {
+		on = today
+	}
+	since := day.UTC().Add(-fxRateMaxAge).Format(time.DateOnly)
+
+	// today's rate must be today's, an older stored one would only be reused
+	// until the next fetch
+	fresh := func(rate *models.FxRateModel) bool {
+		return on < today || rate.Day == today
+	}
+	if rate, err := s.rates.Latest(ctx, from, to, since, on); err == nil && fresh(rate) {
+		return rate.Rate, nil
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return decimal.Zero, err
+	}
+	if rate, err := s.rates.Latest(ctx, to, from, since, on); err == nil && fresh(rate) {
+		return money.Div(decimal.NewFromInt(1), rate.Rate), nil
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return decimal.Zero, err
+	}
+
+	source := s.market.Name()
+	if on == today {
+		rate, err := s.market.FetchExchangeRate(ctx, from, to, loggerFrom(ctx))
+		if err != nil {
+			return decimal.Zero, err
+		}
+		if err := s.rates.Upsert(ctx, []models.FxRateModel{{From: from, To: to, Day: today, Rate: rate, Source: source}}); err != nil {
+			return decimal.Zero, err
+		}
+		return rate, nil
+	}
+
+	daily, err := s.market.FetchFXDaily(ctx, from, to, loggerFrom(ctx))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	rates := make([]models.FxRateModel, 0, len(daily))
+	var found models.FxRateModel
+	for d, rate := range daily {
+		rates = append(rates, models.FxRateModel{From: from, To: to, Day: d, Rate: rate, Source: source})
+		if d >= since && d <= on && d > found.Day {
+			found = rates[len(rates)-1]
+		}
+	}
+	if err := s.rates.Upsert(ctx, rates); err != nil {
+		return decimal.Zero, err
+	}
+	if found.Day == "" {
+		return decimal.Zero, invalid(fmt.Sprintf("no %s/%s exchange rate for %s", from, to, on))
+	}
+	return found.Rate, nil
+}
+
+// Convert returns a cash amount in from as an amount in to at the rate of
+// day, rounded to the minor units of to.
+func (s *FxService) Convert(ctx context.Context, amount decimal.Decimal, from, to string, day time.Time) (decimal.Decimal, error) {
+	rate, err := s.Rate(ctx, from, to, day)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return money.Round(amount.Mul(rate), to), nil
+}
+
+// StockCurrency returns the currency stockId trades in, the default one
+// for a stock that is not stored.
+func (s *FxService) StockCurrency(ctx context.Context, stockId string) (string, error) {
+	stock, err := s.stocks.GetById(ctx, stockId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultCurrency, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if stock.Currency == "" {
+		return models.DefaultCurrency, nil
+	}
+	return stock.Currency, nil
+}
+
+// normalizeCurrency upper-cases code and checks it is an ISO 4217 style
+// three letter code. An empty code is returned as fallback.
+func normalizeCurrency(code, fallback string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return fallback, nil
+	}
+	if !currencyCode.MatchString(code) {
+		return "", invalid("currency must be a three letter code such as USD")
+	}
+	return code, nil
+}
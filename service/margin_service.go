@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/money"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/shopspring/decimal"
+)
+
+// MarginService runs the daily checks of margin accounts: it charges the
+// borrow fees of short positions and notifies the owners of the accounts
+// whose equity no longer meets the maintenance requirement, closing their
+// positions when autoLiquidate is set.
+type MarginService struct {
+	tx                repository.Transactor
+	portfolios        repository.PortfolioRepository
+	transactions      repository.TransactionRepository
+	users             repository.UserRepository
+	fx                *FxService
+	trades            *TradeService
+	portfolioService  *PortfolioService
+	notifications     *NotificationService
+	borrowRate        decimal.Decimal // yearly, of the value of the shares borrowed
+	maintenanceMargin decimal.Decimal
+	autoLiquidate     bool
+}
+
+func NewMarginService(repos *repository.Repositories, fx *FxService, trades *TradeService, portfolios *PortfolioService, notifications *NotificationService, trading config.TradingConfig) *MarginService {
+	return &MarginService{
+		tx:                repos.Tx,
+		portfolios:        repos.Portfolios,
+		transactions:      repos.Transactions,
+		users:             repos.Users,
+		fx:                fx,
+		trades:            trades,
+		portfolioService:  portfolios,
+		notifications:     notifications,
+		borrowRate:        decimal.NewFromFloat(trading.BorrowRate),
+		maintenanceMargin: decimal.NewFromFloat(trading.MaintenanceMargin),
+		autoLiquidate:     trading.AutoLiquidate,
+	}
+}
+
+// CheckDue charges every margin account the borrow fees of the day now falls
+// on, unless it was charged them already, and checks its margin. It returns
+// how many accounts are in a margin call. An account that fails is logged
+// and checked again on the next run.
+func (s *MarginService) CheckDue(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "MarginService.CheckDue")
+	defer span.End()
+
+	portfolios, err := s.portfolios.ListMargin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	calls := 0
+	var firstErr error
+	for i := range portfolios {
+		called, err := s.check(ctx, &portfolios[i], now)
+		if err != nil {
+			loggerFrom(ctx).Error().Err(err).Str("portfolio_id", portfolios[i].Id).Msg("Failed to check margin account")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if called {
+			calls++
+		}
+	}
+	return calls, firstErr
+}
+
+// check charges the borrow fees of portfolio and reports whether it is in a
+// margin call.
+func (s *MarginService) check(ctx context.Context, portfolio *models.PortFolio, now time.Time) (bool, error) {
+	status, err := s.portfolioService.margin(ctx, portfolio)
+	if err != nil {
+		return false, err
+	}
+
+	charged := false
+	for _, h := range status.Holdings {
+		if !h.Quantity.IsNegative() {
+			continue
+		}
+		fee, err := s.chargeBorrowFee(ctx, portfolio, h, now)
+		if err != nil {
+			return false, err
+		}
+		charged = charged || fee != nil
+	}
+	if charged {
+		// the fees came out of the cash
+		if status, err = s.portfolioService.margin(ctx, portfolio); err != nil {
+			return false, err
+		}
+	}
+	if !status.MarginCall {
+		return false, nil
+	}
+
+	message := fmt.Sprintf("Margin call on portfolio %s: equity %s is below the maintenance requirement of %s, deposit %s or close positions",
+		portfolio.Name, money.Format(status.Equity, status.BaseCurrency), money.Format(status.MaintenanceRequirement, status.BaseCurrency),
+		money.Format(status.Excess.Neg(), status.BaseCurrency))
+	if err := s.notifications.Notify(ctx, portfolio.UserId, message); err != nil {
+		loggerFrom(ctx).Error().Err(err).Msg("Failed to create notification")
+	}
+	loggerFrom(ctx).Warn().Str("portfolio_id", portfolio.Id).Stringer("equity", status.Equity).
+		Stringer("maintenance_requirement", status.MaintenanceRequirement).Msg("margin call")
+
+	if !s.autoLiquidate {
+		return true, nil
+	}
+	// without every price the positions to close cannot be sized
+	if len(status.Unpriced) > 0 {
+		loggerFrom(ctx).Warn().Str("portfolio_id", portfolio.Id).Strs("unpriced", status.Unpriced).Msg("margin call not liquidated")
+		return true, nil
+	}
+	return true, s.liquidate(ctx, portfolio, status)
+}
+
+// chargeBorrowFee books a day of the borrow fee of the short holding h and
+// charges it to the owner's cash, or returns nil when the day was charged
+// already. The fee is the yearly borrow rate of the shares' value over 365.
+func (s *MarginService) chargeBorrowFee(ctx context.Context, portfolio *models.PortFolio, h HoldingValue, now time.Time) (*models.TransactionModel, error) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	perShare := money.RoundScale(h.Price.Mul(s.borrowRate).Div(decimal.NewFromInt(365)))
+	if !perShare.IsPositive() {
+		return nil, nil
+	}
+	fee := &models.TransactionModel{
+		UserId:      portfolio.UserId,
+		PortFolioId: portfolio.Id,
+		StockId:     h.StockId,
+		Quantity:    h.Quantity.Neg(),
+		Price:       perShare,
+		Currency:    h.Currency,
+		FxRate:      h.FxRate,
+		Type:        models.TransactionTypeBorrowFee,
+		Status:      models.TransactionStatusExecuted,
+		CashSettled: true,
+		TradeDate:   now,
+	}
+
+	booked := false
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		count, err := s.transactions.CountByTypeSince(ctx, portfolio.Id, h.StockId, models.TransactionTypeBorrowFee, day)
+		if err != nil || count > 0 {
+			return err
+		}
+		user, err := s.users.GetSummaryById(ctx, portfolio.UserId)
+		if err != nil {
+			return err
+		}
+		charge, err := s.fx.Convert(ctx, money.Round(fee.Quantity.Mul(fee.Price).Mul(fee.FxRate), portfolio.BaseCurrency),
+			portfolio.BaseCurrency, user.BaseCurrency, now)
+		if err != nil {
+			return err
+		}
+		if err := s.transactions.Create(ctx, fee); err != nil {
+			return err
+		}
+		if err := s.trades.rebuildHoldings(ctx, portfolio.Id, h.StockId); err != nil {
+			return err
+		}
+		booked = true
+		return s.users.Charge(ctx, portfolio.UserId, charge)
+	})
+	if err != nil || !booked {
+		return nil, err
+	}
+	return fee, nil
+}
+
+// liquidate closes positions of portfolio, the largest first, until the
+// maintenance requirement they leave is met by the equity. Every closing
+// trade lowers the requirement by the maintenance margin of its value, so
+// deficit over that margin is the value to close; partial positions are
+// rounded up to the quantity places. Trades that fail are logged and the
+// next position is tried.
+func (s *MarginService) liquidate(ctx context.Context, portfolio *models.PortFolio, status *MarginStatus) error {
+	holdings := append([]HoldingValue(nil), status.Holdings...)
+	sort.SliceStable(holdings, func(i, j int) bool {
+		return holdings[i].ValueBase.Abs().GreaterThan(holdings[j].ValueBase.Abs())
+	})
+
+	remaining := money.Div(status.Excess.Neg(), s.maintenanceMargin)
+	var closed []string
+	var firstErr error
+	for _, h := range holdings {
+		if !remaining.IsPositive() {
+			break
+		}
+		quantity, side := closing(h, remaining, s.trades.places)
+		if quantity.IsZero() {
+			continue
+		}
+
+		trade, err := s.trades.place(ctx, portfolio, h.StockId, quantity, decimal.Zero, side)
+		if err != nil {
+			loggerFrom(ctx).Error().Err(err).Str("portfolio_id", portfolio.Id).Str("stock_id", h.StockId).Msg("Failed to liquidate position")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		remaining = remaining.Sub(h.ValueBase.Abs().Mul(quantity).Div(h.Quantity.Abs()))
+		verb := "sold"
+		if side == models.TransactionTypeBuy {
+			verb = "bought back"
+		}
+		closed = append(closed, fmt.Sprintf("%s %s %s", verb, trade.Quantity, trade.StockId))
+	}
+
+	if len(closed) > 0 {
+		message := fmt.Sprintf("Positions in portfolio %s were closed to meet the margin call: %s", portfolio.Name, strings.Join(closed, ", "))
+		if err := s.notifications.Notify(ctx, portfolio.UserId, message); err != nil {
+			loggerFrom(ctx).Error().Err(err).Msg("Failed to create notification")
+		}
+	}
+	return firstErr
+}
+
+// closing returns how many shares of h to trade, and which way, to close
+// positions worth remaining: all of it when it is worth no more, otherwise
+// its share of remaining rounded up to places. It is zero for a position
+// without value.
+func closing(h HoldingValue, remaining decimal.Decimal, places int32) (decimal.Decimal, string) {
+	value := h.ValueBase.Abs()
+	held := h.Quantity.Abs()
+	side := models.TransactionTypeSell
+	if h.Quantity.IsNegative() {
+		side = models.TransactionTypeBuy
+	}
+	if !value.IsPositive() {
+		return decimal.Zero, side
+	}
+	if value.GreaterThan(remaining) {
+		return decimal.Min(held, held.Mul(remaining).Div(value).RoundUp(places)), side
+	}
+	return held, side
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/pratyush934/tradealpha/server/models"
+)
+
+func TestClosing(t *testing.T) {
+	tests := []struct {
+		name      string
+		quantity  string
+		value     string
+		remaining string
+		places    int32
+		want      string
+		wantSide  string
+	}{
+		{"whole position worth less", "10", "1000", "2000", 2, "10", models.TransactionTypeSell},
+		{"whole position worth exactly", "10", "1000", "1000", 2, "10", models.TransactionTypeSell},
+		{"part of a long", "10", "1000", "250", 2, "2.5", models.TransactionTypeSell},
+		{"part rounded up", "10", "1000", "333.3", 2, "3.34", models.TransactionTypeSell},
+		{"part in whole shares", "10", "1000", "101", 0, "2", models.TransactionTypeSell},
+		{"rounding up stops at the position", "1", "100", "99.999", 0, "1", models.TransactionTypeSell},
+		{"part of a short is bought back", "-10", "-1000", "500", 2, "5", models.TransactionTypeBuy},
+		{"whole short", "-10", "-1000", "5000", 2, "10", models.TransactionTypeBuy},
+		{"no value", "10", "0", "500", 2, "0", models.TransactionTypeSell},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := HoldingValue{Quantity: d(tt.quantity), ValueBase: d(tt.value)}
+			quantity, side := closing(h, d(tt.remaining), tt.places)
+			if !quantity.Equal(d(tt.want)) || side != tt.wantSide {
+				t.Fatalf("closing = %s %s, want %s %s", side, quantity, tt.wantSide, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/repository"
+)
+
+type NotificationService struct {
+	notifications repository.NotificationRepository
+}
+
+func NewNotificationService(repos *repository.Repositories) *NotificationService {
+	return &NotificationService{notifications: repos.Notifications}
+}
+
+func (s *NotificationService) Create(ctx context.Context, notification *models.NotificationModel) error {
+	return s.notifications.Create(ctx, notification)
+}
+
+// Notify sends userId an unread notification.
+func (s *NotificationService) Notify(ctx context.Context, userId, message string) error {
+	return s.notifications.Create(ctx, &models.NotificationModel{
+		UserId:  userId,
+		Message: message,
+	})
+}
+
+func (s *NotificationService) ListByUser(ctx context.Context, userId string) ([]models.NotificationModel, error) {
+	return s.notifications.ListByUserId(ctx, userId)
+}
+
+func (s *NotificationService) CountUnread(ctx context.Context, userId string) (int64, error) {
+	return s.notifications.CountUnread(ctx, userId)
+}
+
+// CountAllUnread is the unread backlog across every user.
+func (s *NotificationService) CountAllUnread(ctx context.Context) (int64, error) {
+	return s.notifications.CountAllUnread(ctx)
+}
+
+// Get returns the notification only when it belongs to userId.
+func (s *NotificationService) Get(ctx context.Context, userId, id string) (*models.NotificationModel, error) {
+	notification, err := s.notifications.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if notification.UserId != userId {
+		return nil, ErrNotOwner
+	}
+	return notification, nil
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, userId, id string) error {
+	if _, err := s.Get(ctx, userId, id); err != nil {
+		return err
+	}
+	return s.notifications.MarkRead(ctx, id)
+}
+
+func (s *NotificationService) Delete(ctx context.Context, userId, id string) error {
+	if _, err := s.Get(ctx, userId, id); err != nil {
+		return err
+	}
+	return s.notifications.Delete(ctx, id)
+}
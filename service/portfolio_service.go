@@ -0,0 +1,534 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/money"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type PortfolioService struct {
+	tx           repository.Transactor
+	portfolios   repository.PortfolioRepository
+	holdings     repository.HoldingRepository
+	transactions repository.TransactionRepository
+	users        repository.UserRepository
+	schedules    repository.FeeScheduleRepository
+	quotes       marketdata.Provider
+	fx           *FxService
+	// of the positions' value in a margin account
+	initialMargin     decimal.Decimal
+	maintenanceMargin decimal.Decimal
+}
+
+func NewPortfolioService(repos *repository.Repositories, quotes marketdata.Provider, fx *FxService, trading config.TradingConfig) *PortfolioService {
+	return &PortfolioService{
+		tx:           repos.Tx,
+		portfolios:   repos.Portfolios,
+		holdings:     repos.Holdings,
+		transactions: repos.Transactions,
+		users:        repos.Users,
+		schedules:    repos.FeeSchedules,
+		quotes:       quotes,
+		fx:           fx,
+
+		initialMargin:     decimal.NewFromFloat(trading.InitialMargin),
+		maintenanceMargin: decimal.NewFromFloat(trading.MaintenanceMargin),
+	}
+}
+
+// Create reports the portfolio in the owner's base currency unless it names
+// one.
+func (s *PortfolioService) Create(ctx context.Context, portfolio *models.PortFolio) error {
+	user, err := s.users.GetSummaryById(ctx, portfolio.UserId)
+	if err != nil {
+		return err
+	}
+	if portfolio.BaseCurrency, err = normalizeCurrency(portfolio.BaseCurrency, user.BaseCurrency); err != nil {
+		return err
+	}
+	return s.portfolios.Create(ctx, portfolio)
+}
+
+func (s *PortfolioService) ListByUser(ctx context.Context, userId string) ([]models.PortFolio, error) {
+	return s.portfolios.ListByUserId(ctx, userId)
+}
+
+// Get returns the portfolio only when it belongs to userId.
+func (s *PortfolioService) Get(ctx context.Context, userId, id string) (*models.PortFolio, error) {
+	portfolio, err := s.portfolios.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if portfolio.UserId != userId {
+		return nil, ErrNotOwner
+	}
+	return portfolio, nil
+}
+
+// UpdateDetails changes the base currency too when baseCurrency is set, but
+// only while the portfolio has no trades: they were booked at rates to the
+// old one.
+func (s *PortfolioService) UpdateDetails(ctx context.Context, userId, id, name, title, description, baseCurrency string) error {
+	portfolio, err := s.Get(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+	if baseCurrency, err = normalizeCurrency(baseCurrency, portfolio.BaseCurrency); err != nil {
+		return err
+	}
+	fields := map[string]interface{}{
+		"name":        name,
+		"title":       title,
+		"description": description,
+	}
+	if baseCurrency == portfolio.BaseCurrency {
+		return s.portfolios.UpdateFields(ctx, id, fields)
+	}
+
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.lock(ctx, id); err != nil {
+			return err
+		}
+		count, err := s.transactions.CountByPortfolioId(ctx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPortfolioHasTransactions
+		}
+		fields["base_currency"] = baseCurrency
+		return s.portfolios.UpdateFields(ctx, id, fields)
+	})
+}
+
+// Delete refuses a portfolio that still has trades, since transactions are
+// immutable and would be left pointing nowhere. It returns the deleted row.
+func (s *PortfolioService) Delete(ctx context.Context, userId, id string) (*models.PortFolio, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PortfolioService.Delete")
+	defer span.End()
+
+	portfolio, err := s.Get(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.lock(ctx, id); err != nil {
+			return err
+		}
+		count, err := s.transactions.CountByPortfolioId(ctx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPortfolioHasTransactions
+		}
+		if err := s.holdings.DeleteByPortfolioId(ctx, id); err != nil {
+			return err
+		}
+		if err := s.schedules.DeleteByPortfolioId(ctx, id); err != nil {
+			return err
+		}
+		return s.portfolios.Delete(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return portfolio, nil
+}
+
+// lock holds the row lock of the portfolio for the rest of the transaction
+// in ctx and returns the row as it is now.
+func (s *PortfolioService) lock(ctx context.Context, id string) (*models.PortFolio, error) {
+	return s.portfolios.Lock(ctx, id)
+}
+
+// FeeSchedule returns what trades in the caller's portfolio cost, a schedule
+// of zeros when it has none.
+func (s *PortfolioService) FeeSchedule(ctx context.Context, userId, id string) (*models.FeeScheduleModel, error) {
+	if _, err := s.Get(ctx, userId, id); err != nil {
+		return nil, err
+	}
+	return s.feeSchedule(ctx, id)
+}
+
+func (s *PortfolioService) feeSchedule(ctx context.Context, id string) (*models.FeeScheduleModel, error) {
+	schedule, err := s.schedules.GetByPortfolioId(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.FeeScheduleModel{PortFolioId: id}, nil
+	}
+	return schedule, err
+}
+
+// SetFeeSchedule replaces the fees of the caller's portfolio, trades already
+// booked keep the fees they were charged. It returns the schedule before.
+func (s *PortfolioService) SetFeeSchedule(ctx context.Context, userId, id string, schedule *models.FeeScheduleModel) (*models.FeeScheduleModel, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PortfolioService.SetFeeSchedule")
+	defer span.End()
+
+	before, err := s.FeeSchedule(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts := []decimal.Decimal{schedule.Flat, schedule.PerShare, schedule.Rate, schedule.MinCommission,
+		schedule.MaxCommission, schedule.SellRate, schedule.SellPerShare, schedule.SellPerShareMax}
+	for _, amount := range amounts {
+		if amount.IsNegative() {
+			return nil, invalid("fees must not be negative")
+		}
+	}
+	one := decimal.NewFromInt(1)
+	if schedule.Rate.GreaterThanOrEqual(one) || schedule.SellRate.GreaterThanOrEqual(one) {
+		return nil, invalid("rates are fractions of the trade value and must be below 1")
+	}
+	if schedule.MaxCommission.IsPositive() && schedule.MinCommission.GreaterThan(schedule.MaxCommission) {
+		return nil, invalid("minCommission must not exceed maxCommission")
+	}
+
+	schedule.PortFolioId = id
+	if err := s.schedules.Save(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return before, nil
+}
+
+// SetMargin makes the caller's portfolio a margin account or an ordinary
+// one and returns it as it was. The owner's cash backs a margin account, so
+// a user has at most one, and a portfolio only becomes one while it holds
+// no position: the trades of a margin account settle in cash, and those
+// booked before did not. One is only turned back while it holds no short
+// position and the cash it borrowed has been repaid.
+func (s *PortfolioService) SetMargin(ctx context.Context, userId, id string, margin bool) (*models.PortFolio, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PortfolioService.SetMargin")
+	defer span.End()
+
+	portfolio, err := s.Get(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+	if portfolio.Margin == margin {
+		return portfolio, nil
+	}
+
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		locked, err := s.lock(ctx, id)
+		if err != nil {
+			return err
+		}
+		if locked.Margin == margin {
+			return nil
+		}
+		holdings, err := s.holdings.ListByPortfolioId(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if margin {
+			for _, h := range holdings {
+				if !h.Quantity.IsZero() {
+					return ErrHoldingsOpen
+				}
+			}
+			portfolios, err := s.portfolios.ListByUserId(ctx, userId)
+			if err != nil {
+				return err
+			}
+			for _, other := range portfolios {
+				if other.Margin && other.Id != id {
+					return ErrMarginAccountExists
+				}
+			}
+			return s.portfolios.UpdateFields(ctx, id, map[string]interface{}{"margin": true})
+		}
+
+		for _, h := range holdings {
+			if h.Quantity.IsNegative() {
+				return ErrMarginPositionsOpen
+			}
+		}
+		user, err := s.users.GetSummaryById(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if user.AccountBalance.IsNegative() {
+			return ErrMarginPositionsOpen
+		}
+		return s.portfolios.UpdateFields(ctx, id, map[string]interface{}{"margin": false})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return portfolio, nil
+}
+
+// MarginStatus is a margin account priced at the latest quotes, in its base
+// currency. Equity is the owner's cash, negative while it is borrowed, plus
+// the long positions less the short ones. The requirements are the shares
+// of the positions' value the equity must cover to open a position and to
+// keep it, BuyingPower the value of positions that may still be opened and
+// Excess the equity above the maintenance requirement, negative in a margin
+// call. Unpriced lists the stocks left out, as in Valuation.
+type MarginStatus struct {
+	PortfolioId            string          `json:"portfolioId"`
+	BaseCurrency           string          `json:"baseCurrency"`
+	Cash                   decimal.Decimal `json:"cash"`
+	LongValue              decimal.Decimal `json:"longValue"`
+	ShortValue             decimal.Decimal `json:"shortValue"`
+	Equity                 decimal.Decimal `json:"equity"`
+	InitialRequirement     decimal.Decimal `json:"initialRequirement"`
+	MaintenanceRequirement decimal.Decimal `json:"maintenanceRequirement"`
+	BuyingPower            decimal.Decimal `json:"buyingPower"`
+	Excess                 decimal.Decimal `json:"excess"`
+	MarginCall             bool            `json:"marginCall"`
+	Unpriced               []string        `json:"unpriced"`
+	Holdings               []HoldingValue  `json:"holdings"`
+}
+
+// Margin prices the caller's margin account.
+func (s *PortfolioService) Margin(ctx context.Context, userId, id string) (*MarginStatus, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PortfolioService.Margin")
+	defer span.End()
+
+	portfolio, err := s.Get(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+	if !portfolio.Margin {
+		return nil, invalid("the portfolio is not a margin account")
+	}
+	return s.margin(ctx, portfolio)
+}
+
+func (s *PortfolioService) margin(ctx context.Context, portfolio *models.PortFolio) (*MarginStatus, error) {
+	valuation, err := s.value(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetSummaryById(ctx, portfolio.UserId)
+	if err != nil {
+		return nil, err
+	}
+	cash, err := s.fx.Convert(ctx, user.AccountBalance, user.BaseCurrency, portfolio.BaseCurrency, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MarginStatus{
+		PortfolioId:  portfolio.Id,
+		BaseCurrency: portfolio.BaseCurrency,
+		Cash:         cash,
+		Unpriced:     valuation.Unpriced,
+		Holdings:     valuation.Holdings,
+	}
+	s.requirements(status)
+	return status, nil
+}
+
+// requirements fills in the position values, equity, requirements, buying
+// power and excess of status from its cash and holdings.
+func (s *PortfolioService) requirements(status *MarginStatus) {
+	for _, h := range status.Holdings {
+		if h.ValueBase.IsNegative() {
+			status.ShortValue = status.ShortValue.Sub(h.ValueBase)
+		} else {
+			status.LongValue = status.LongValue.Add(h.ValueBase)
+		}
+	}
+	gross := status.LongValue.Add(status.ShortValue)
+	status.Equity = status.Cash.Add(status.LongValue).Sub(status.ShortValue)
+	status.InitialRequirement = money.Round(gross.Mul(s.initialMargin), status.BaseCurrency)
+	status.MaintenanceRequirement = money.Round(gross.Mul(s.maintenanceMargin), status.BaseCurrency)
+	status.Excess = status.Equity.Sub(status.MaintenanceRequirement)
+	status.MarginCall = status.Excess.IsNegative()
+	if power := status.Equity.Sub(status.InitialRequirement); power.IsPositive() {
+		// rounded down, a position of the whole buying power must fit
+		status.BuyingPower = money.Div(power, s.initialMargin).RoundDown(money.SpecOf(status.BaseCurrency).Places)
+	}
+}
+
+func (s *PortfolioService) Holdings(ctx context.Context, userId, id string) ([]models.PortFolioStock, error) {
+	if _, err := s.Get(ctx, userId, id); err != nil {
+		return nil, err
+	}
+	return s.holdings.ListByPortfolioId(ctx, id)
+}
+
+// HoldingValue is an open holding priced at the latest quote, in the
+// stock's currency and in the portfolio's base currency: its cost at the
+// rates it was bought at, its value at today's.
+type HoldingValue struct {
+	StockId             string          `json:"stockId"`
+	Currency            string          `json:"currency"`
+	Quantity            decimal.Decimal `json:"quantity"`
+	Price               decimal.Decimal `json:"price"`
+	FxRate              decimal.Decimal `json:"fxRate"`
+	Cost                decimal.Decimal `json:"cost"`
+	Value               decimal.Decimal `json:"value"`
+	UnrealizedGains     decimal.Decimal `json:"unrealizedGains"`
+	RealizedGains       decimal.Decimal `json:"realizedGains"`
+	CostBase            decimal.Decimal `json:"costBase"`
+	ValueBase           decimal.Decimal `json:"valueBase"`
+	UnrealizedGainsBase decimal.Decimal `json:"unrealizedGainsBase"`
+	RealizedGainsBase   decimal.Decimal `json:"realizedGainsBase"`
+}
+
+// Valuation is a portfolio priced holding by holding, with totals in its
+// base currency. Unpriced lists the stocks whose quote or exchange rate
+// could not be fetched, they are left out of the totals.
+type Valuation struct {
+	PortfolioId     string          `json:"portfolioId"`
+	BaseCurrency    string          `json:"baseCurrency"`
+	Holdings        []HoldingValue  `json:"holdings"`
+	Unpriced        []string        `json:"unpriced"`
+	TotalValue      decimal.Decimal `json:"totalValue"`
+	UnrealizedGains decimal.Decimal `json:"unrealizedGains"`
+	RealizedGains   decimal.Decimal `json:"realizedGains"`
+}
+
+// Valuation prices the caller's portfolio without storing the totals.
+func (s *PortfolioService) Valuation(ctx context.Context, userId, id string) (*Valuation, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PortfolioService.Valuation")
+	defer span.End()
+
+	portfolio, err := s.Get(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.value(ctx, portfolio)
+}
+
+// Revalue prices every holding at the latest quote and stores the total
+// value, unrealized and realized gains in the base currency. A holding whose
+// quote or exchange rate cannot be fetched is left out of the totals, but
+// once the market data budget is spent, or no provider can be reached, the
+// stored totals are kept rather than replaced by partial ones.
+// Quotes are fetched at refresh priority, behind the ones users wait on.
+func (s *PortfolioService) Revalue(ctx context.Context, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "PortfolioService.Revalue")
+	defer span.End()
+
+	logger := loggerFrom(ctx)
+
+	portfolio, err := s.portfolios.GetById(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("portfolio_id", id).Msg("Failed to fetch portfolio")
+		return err
+	}
+	valuation, err := s.value(ctx, portfolio)
+	if err != nil {
+		return err
+	}
+
+	if err := s.portfolios.UpdateFields(ctx, id, map[string]interface{}{
+		"total_value":      valuation.TotalValue,
+		"unrealized_gains": valuation.UnrealizedGains,
+		"realized_gains":   valuation.RealizedGains,
+	}); err != nil {
+		return err
+	}
+
+	logger.Info().Str("portfolio_id", id).
+		Stringer("total_value", valuation.TotalValue).
+		Stringer("unrealized_gains", valuation.UnrealizedGains).
+		Stringer("realized_gains", valuation.RealizedGains).
+		Str("base_currency", valuation.BaseCurrency).
+		Msg("portfolio revalued")
+
+	return nil
+}
+
+func (s *PortfolioService) value(ctx context.Context, portfolio *models.PortFolio) (*Valuation, error) {
+	ctx = alphavantage.WithPriority(ctx, alphavantage.PriorityRefresh)
+
+	holdings, err := s.holdings.ListByPortfolioId(ctx, portfolio.Id)
+	if err != nil {
+		loggerFrom(ctx).Error().Err(err).Str("portfolio_id", portfolio.Id).Msg("Failed to fetch holdings")
+		return nil, err
+	}
+
+	valuation := &Valuation{
+		PortfolioId:  portfolio.Id,
+		BaseCurrency: portfolio.BaseCurrency,
+		Holdings:     []HoldingValue{},
+		Unpriced:     []string{},
+	}
+	now := time.Now()
+	for _, ps := range holdings {
+		if ps.Quantity.IsZero() {
+			continue
+		}
+		quote, err := latestQuote(ctx, s.quotes, ps.StockId)
+		if errors.Is(err, marketdata.ErrUnavailable) {
+			return nil, err
+		}
+		if err != nil {
+			valuation.Unpriced = append(valuation.Unpriced, ps.StockId)
+			continue
+		}
+		rate, err := s.fx.Rate(ctx, ps.Currency, portfolio.BaseCurrency, now)
+		if errors.Is(err, marketdata.ErrUnavailable) {
+			return nil, err
+		}
+		if err != nil {
+			loggerFrom(ctx).Error().Err(err).Str("currency", ps.Currency).Msg("Failed to fetch exchange rate")
+			valuation.Unpriced = append(valuation.Unpriced, ps.StockId)
+			continue
+		}
+
+		quantity := ps.Quantity
+		h := HoldingValue{
+			StockId:           ps.StockId,
+			Currency:          ps.Currency,
+			Quantity:          ps.Quantity,
+			Price:             quote.Price,
+			FxRate:            rate,
+			Cost:              money.Round(quantity.Mul(ps.AveragePrice), ps.Currency),
+			Value:             money.Round(quantity.Mul(quote.Price), ps.Currency),
+			RealizedGains:     money.Round(ps.RealizedGains, ps.Currency),
+			CostBase:          money.Round(quantity.Mul(ps.AveragePriceBase), portfolio.BaseCurrency),
+			ValueBase:         money.Round(quantity.Mul(quote.Price).Mul(rate), portfolio.BaseCurrency),
+			RealizedGainsBase: money.Round(ps.RealizedGainsBase, portfolio.BaseCurrency),
+		}
+		h.UnrealizedGains = h.Value.Sub(h.Cost)
+		h.UnrealizedGainsBase = h.ValueBase.Sub(h.CostBase)
+		valuation.Holdings = append(valuation.Holdings, h)
+
+		valuation.TotalValue = valuation.TotalValue.Add(h.ValueBase)
+		valuation.UnrealizedGains = valuation.UnrealizedGains.Add(h.UnrealizedGainsBase)
+	}
+
+	// realized gains are kept per holding when the holding is rebuilt, and
+	// count for holdings that have been sold off too
+	realized, err := s.holdings.SumRealizedGains(ctx, portfolio.Id)
+	if err != nil {
+		return nil, err
+	}
+	valuation.RealizedGains = money.Round(realized, portfolio.BaseCurrency)
+	return valuation, nil
+}
+
+// Metrics revalues the caller's portfolio and returns it as stored
+// afterwards.
+func (s *PortfolioService) Metrics(ctx context.Context, userId, id string) (*models.PortFolio, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PortfolioService.Metrics")
+	defer span.End()
+
+	if _, err := s.Get(ctx, userId, id); err != nil {
+		return nil, err
+	}
+	if err := s.Revalue(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.portfolios.GetById(ctx, id)
+}
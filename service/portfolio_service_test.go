@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func checkDecimal(t *testing.T, name string, got decimal.Decimal, want string) {
+	t.Helper()
+	if !got.Equal(d(want)) {
+		t.Errorf("%s = %s, want %s", name, got, want)
+	}
+}
+
+func TestRequirements(t *testing.T) {
+	s := &PortfolioService{initialMargin: d("0.5"), maintenanceMargin: d("0.25")}
+
+	tests := []struct {
+		name        string
+		currency    string
+		cash        string
+		values      []string // ValueBase of each holding, negative for shorts
+		equity      string
+		initial     string
+		maintenance string
+		buyingPower string
+		excess      string
+		marginCall  bool
+	}{
+		{
+			name: "cash only", currency: "USD", cash: "10000",
+			equity: "10000", initial: "0", maintenance: "0", buyingPower: "20000", excess: "10000",
+		},
+		{
+			name: "long on borrowed cash", currency: "USD", cash: "-5000", values: []string{"15000"},
+			equity: "10000", initial: "7500", maintenance: "3750", buyingPower: "5000", excess: "6250",
+		},
+		{
+			name: "short with its proceeds in cash", currency: "USD", cash: "20000", values: []string{"-10000"},
+			equity: "10000", initial: "5000", maintenance: "2500", buyingPower: "10000", excess: "7500",
+		},
+		{
+			name: "long and short count gross", currency: "USD", cash: "10000", values: []string{"6000", "-4000"},
+			equity: "12000", initial: "5000", maintenance: "2500", buyingPower: "14000", excess: "9500",
+		},
+		{
+			name: "margin call", currency: "USD", cash: "-9000", values: []string{"10000"},
+			equity: "1000", initial: "5000", maintenance: "2500", buyingPower: "0", excess: "-1500", marginCall: true,
+		},
+		{
+			name: "requirements rounded to the currency", currency: "JPY", cash: "0", values: []string{"1001"},
+			equity: "1001", initial: "501", maintenance: "250", buyingPower: "1000", excess: "751",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &MarginStatus{BaseCurrency: tt.currency, Cash: d(tt.cash)}
+			for _, value := range tt.values {
+				status.Holdings = append(status.Holdings, HoldingValue{ValueBase: d(value)})
+			}
+			s.requirements(status)
+
+			checkDecimal(t, "equity", status.Equity, tt.equity)
+			checkDecimal(t, "initial requirement", status.InitialRequirement, tt.initial)
+			checkDecimal(t, "maintenance requirement", status.MaintenanceRequirement, tt.maintenance)
+			checkDecimal(t, "buying power", status.BuyingPower, tt.buyingPower)
+			checkDecimal(t, "excess", status.Excess, tt.excess)
+			if status.MarginCall != tt.marginCall {
+				t.Errorf("margin call = %v, want %v", status.MarginCall, tt.marginCall)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/money"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Formats of a price history import.
+const (
+	ImportFormatCSV          = "csv"
+	ImportFormatAlphaVantage = "alphavantage" // a saved TIME_SERIES_DAILY body
+)
+
+// importErrorLimit caps the rejected rows a report lists, the counts stay
+// exact.
+const importErrorLimit = 100
+
+// PriceImport says how to read a price history file.
+type PriceImport struct {
+	Format string
+	// CSV is how a csv file is laid out, unused for other formats.
+	CSV marketdata.BarCSV
+	// Source is recorded on every bar stored, "import" when empty.
+	Source string
+}
+
+// ImportReport is what became of the rows of an imported file. Rows is
+// Accepted + Duplicates + Rejected.
+type ImportReport struct {
+	Rows     int `json:"rows"`
+	Accepted int `json:"accepted"`
+	// Duplicates are rows for a symbol and day that a later row of the same
+	// file replaced.
+	Duplicates int                   `json:"duplicates"`
+	Rejected   int                   `json:"rejected"`
+	Symbols    []string              `json:"symbols"`
+	Errors     []marketdata.RowError `json:"errors"`
+	// ErrorsOmitted counts the rejected rows left out of Errors.
+	ErrorsOmitted int `json:"errorsOmitted,omitempty"`
+}
+
+func (r *ImportReport) reject(rows ...marketdata.RowError) {
+	r.Rejected += len(rows)
+	for _, row := range rows {
+		if len(r.Errors) == importErrorLimit {
+			r.ErrorsOmitted++
+			continue
+		}
+		r.Errors = append(r.Errors, row)
+	}
+}
+
+type PriceService struct {
+	tx      repository.Transactor
+	bars    repository.PriceBarRepository
+	actions repository.CorporateActionRepository
+}
+
+func NewPriceService(repos *repository.Repositories) *PriceService {
+	return &PriceService{tx: repos.Tx, bars: repos.PriceBars, actions: repos.Actions}
+}
+
+// History returns the stored daily bars of symbol from one day to another,
+// both YYYY-MM-DD and inclusive. When adjusted is set, bars before a split
+// are restated in the shares after it, so that prices compare across it.
+func (s *PriceService) History(ctx context.Context, symbol, from, to string, adjusted bool) ([]marketdata.Bar, error) {
+	for _, day := range []string{from, to} {
+		if _, err := time.Parse(time.DateOnly, day); day != "" && err != nil {
+			return nil, invalid("from and to must be YYYY-MM-DD")
+		}
+	}
+	symbol = strings.ToUpper(symbol)
+
+	stored, err := s.bars.List(ctx, symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+	bars := make([]marketdata.Bar, len(stored))
+	for i, bar := range stored {
+		bars[i] = marketdata.Bar{
+			Symbol: bar.Symbol, Day: bar.Day,
+			Open: bar.Open, High: bar.High, Low: bar.Low, Close: bar.Close, Volume: bar.Volume,
+		}
+	}
+	if !adjusted || len(bars) == 0 {
+		return bars, nil
+	}
+
+	actions, err := s.actions.ListBySymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return adjustForSplits(bars, actions, time.Now()), nil
+}
+
+// adjustForSplits divides the prices of every bar by the ratio of each split
+// that went ex after it, by now, and multiplies its volume by it. Bars are
+// oldest first.
+func adjustForSplits(bars []marketdata.Bar, actions []models.CorporateActionModel, now time.Time) []marketdata.Bar {
+	one := decimal.NewFromInt(1)
+	factor := one
+	next := len(actions) - 1
+	for i := len(bars) - 1; i >= 0; i-- {
+		for ; next >= 0 && actions[next].ExDate.Format(time.DateOnly) > bars[i].Day; next-- {
+			if actions[next].Type == models.CorporateActionSplit && !actions[next].ExDate.After(now) {
+				factor = factor.Mul(actions[next].Ratio)
+			}
+		}
+		if factor.Equal(one) {
+			continue
+		}
+		bar := &bars[i]
+		bar.Open, bar.High, bar.Low, bar.Close = money.Div(bar.Open, factor), money.Div(bar.High, factor), money.Div(bar.Low, factor), money.Div(bar.Close, factor)
+		bar.Volume = decimal.NewFromInt(bar.Volume).Mul(factor).Round(0).IntPart()
+	}
+	return bars
+}
+
+// Import stores the daily bars read from r, replacing those already stored
+// for the same symbol and day. Rows that do not parse or validate are
+// rejected and the rest still stored, all of them in one DB transaction.
+// A file that cannot be read at all, such as one missing a required column,
+// is a ValidationError.
+func (s *PriceService) Import(ctx context.Context, r io.Reader, opts PriceImport) (report *ImportReport, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "PriceService.Import")
+	defer func() { tracing.End(span, err) }()
+
+	var bars []marketdata.Bar
+	var rejected []marketdata.RowError
+	switch opts.Format {
+	case ImportFormatCSV:
+		bars, rejected, err = marketdata.ParseBarsCSV(r, opts.CSV)
+	case ImportFormatAlphaVantage:
+		bars, rejected, err = alphavantage.ParseDaily(r)
+	default:
+		return nil, invalid(fmt.Sprintf("format must be %s or %s", ImportFormatCSV, ImportFormatAlphaVantage))
+	}
+	if err != nil {
+		return nil, invalid(err.Error())
+	}
+
+	source := opts.Source
+	if source == "" {
+		source = "import"
+	}
+
+	report = &ImportReport{Symbols: []string{}, Errors: []marketdata.RowError{}}
+	report.reject(rejected...)
+
+	// the last row for a symbol and day wins, as a later upsert would
+	type key struct{ symbol, day string }
+	position := make(map[key]int, len(bars))
+	rows := make([]models.PriceBarModel, 0, len(bars))
+	symbols := make(map[string]bool)
+	for _, bar := range bars {
+		row := models.PriceBarModel{
+			Symbol: bar.Symbol, Day: bar.Day,
+			Open: bar.Open, High: bar.High, Low: bar.Low, Close: bar.Close, Volume: bar.Volume,
+			Source: source,
+		}
+		k := key{bar.Symbol, bar.Day}
+		if at, ok := position[k]; ok {
+			rows[at] = row
+			report.Duplicates++
+			continue
+		}
+		position[k] = len(rows)
+		rows = append(rows, row)
+		symbols[bar.Symbol] = true
+	}
+	report.Accepted = len(rows)
+	report.Rows = report.Accepted + report.Duplicates + report.Rejected
+	for symbol := range symbols {
+		report.Symbols = append(report.Symbols, symbol)
+	}
+	sort.Strings(report.Symbols)
+
+	span.SetAttributes(
+		attribute.Int("import.accepted", report.Accepted),
+		attribute.Int("import.rejected", report.Rejected),
+	)
+	if len(rows) == 0 {
+		return report, nil
+	}
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		return s.bars.Upsert(ctx, rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
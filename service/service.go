@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/mailer"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Errors the services return for broken business rules. Controllers map them
+// to HTTP statuses, everything else is an infrastructure failure.
+var (
+	ErrNotOwner                 = errors.New("resource belongs to another user")
+	ErrSuspended                = errors.New("account is suspended")
+	ErrInsufficientBalance      = errors.New("insufficient balance")
+	ErrInsufficientHoldings     = errors.New("insufficient stock quantity")
+	ErrVerificationMismatch     = errors.New("verification link no longer matches the account")
+	ErrAlreadyReversed          = errors.New("transaction has already been reversed")
+	ErrReversalNotCorrectable   = errors.New("a reversal entry cannot itself be corrected")
+	ErrDividendNotCorrectable   = errors.New("dividends are booked from corporate actions and cannot be corrected")
+	ErrBorrowFeeNotCorrectable  = errors.New("borrow fees are charged daily on short positions and cannot be corrected")
+	ErrInsufficientBuyingPower  = errors.New("insufficient buying power")
+	ErrMarginAccountExists      = errors.New("another portfolio is already the margin account")
+	ErrMarginPositionsOpen      = errors.New("close the short positions and repay the margin loan first")
+	ErrHoldingsOpen             = errors.New("close the open positions first")
+	ErrPortfolioChanged         = errors.New("the portfolio changed meanwhile, try again")
+	ErrCorporateActionExists    = errors.New("an action of this type already goes ex on that day")
+	ErrPortfolioHasTransactions = errors.New("portfolio has transactions")
+	ErrAlreadyInWatchList       = errors.New("stock already in watchlist")
+	ErrSelfSuspend              = errors.New("admins cannot suspend themselves")
+	ErrTwoFactorEnabled         = errors.New("two factor is already enabled")
+	ErrTwoFactorDisabled        = errors.New("two factor is not enabled")
+	ErrTwoFactorNotEnrolled     = errors.New("start the enrollment first")
+	ErrInvalidTOTP              = errors.New("invalid two factor code")
+	ErrTOTPReplayed             = errors.New("two factor code already used")
+	ErrInvalidRecoveryCode      = errors.New("invalid recovery code")
+)
+
+// ValidationError is returned for input a service refuses to act on, its
+// message is safe to show to the caller.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func invalid(message string) error {
+	return &ValidationError{Message: message}
+}
+
+// Services bundles every service, built once in main and handed to the
+// layers that need them.
+type Services struct {
+	Users         *UserService
+	Portfolios    *PortfolioService
+	Trades        *TradeService
+	WatchLists    *WatchListService
+	Notifications *NotificationService
+	Stocks        *StockService
+	Prices        *PriceService
+	Actions       *CorporateActionService
+	Margin        *MarginService
+	APIKeys       *APIKeyService
+	Audit         *AuditService
+}
+
+// New builds the services on repos, pricing trades and holdings with quotes
+// and booking trades as trading sets. Company data, exchange rates and
+// corporate actions come from market, and mail goes out through mail with
+// links to server's base URL.
+func New(repos *repository.Repositories, quotes marketdata.Provider, market *alphavantage.Client, mail mailer.Mailer, trading config.TradingConfig, server config.ServerConfig) *Services {
+	notifications := NewNotificationService(repos)
+	fx := NewFxService(repos, market)
+	portfolios := NewPortfolioService(repos, quotes, fx, trading)
+	trades := NewTradeService(repos, quotes, fx, portfolios, notifications, trading)
+
+	return &Services{
+		Users:         NewUserService(repos, fx, mail, server),
+		Portfolios:    portfolios,
+		Trades:        trades,
+		WatchLists:    NewWatchListService(repos),
+		Notifications: notifications,
+		Stocks:        NewStockService(repos, quotes, market),
+		Prices:        NewPriceService(repos),
+		Actions:       NewCorporateActionService(repos, fx, trades, portfolios, notifications, market),
+		Margin:        NewMarginService(repos, fx, trades, portfolios, notifications, trading),
+		APIKeys:       NewAPIKeyService(repos),
+		Audit:         NewAuditService(repos),
+	}
+}
+
+// loggerFrom returns the request logger ErrorHandleMiddleWare put in ctx,
+// or the global one for work that does not come from a request.
+func loggerFrom(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
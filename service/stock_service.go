@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pratyush934/tradealpha/server/alphavantage"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type StockService struct {
+	stocks repository.StockRepository
+	quotes marketdata.Provider
+	market *alphavantage.Client
+}
+
+func NewStockService(repos *repository.Repositories, quotes marketdata.Provider, market *alphavantage.Client) *StockService {
+	return &StockService{stocks: repos.Stocks, quotes: quotes, market: market}
+}
+
+// Create prices the stock in the default currency unless it names one.
+func (s *StockService) Create(ctx context.Context, stock *models.Stock) error {
+	var err error
+	if stock.Currency, err = normalizeCurrency(stock.Currency, models.DefaultCurrency); err != nil {
+		return err
+	}
+	return s.stocks.Create(ctx, stock)
+}
+
+func (s *StockService) Get(ctx context.Context, id string) (*models.Stock, error) {
+	return s.stocks.GetById(ctx, id)
+}
+
+func (s *StockService) GetBySymbol(ctx context.Context, symbol string) (*models.Stock, error) {
+	return s.stocks.GetBySymbol(ctx, symbol)
+}
+
+func (s *StockService) List(ctx context.Context, limit, offset int) ([]models.Stock, error) {
+	return s.stocks.List(ctx, limit, offset)
+}
+
+func (s *StockService) ListBySector(ctx context.Context, sector string, limit, offset int) ([]models.Stock, error) {
+	return s.stocks.ListBySector(ctx, sector, limit, offset)
+}
+
+func (s *StockService) Update(ctx context.Context, id, name, sector string, price decimal.Decimal) error {
+	stock, err := s.stocks.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+	stock.Name = name
+	stock.Sector = sector
+	stock.Price = price
+	return s.stocks.Update(ctx, stock)
+}
+
+func (s *StockService) Delete(ctx context.Context, id string) error {
+	return s.stocks.Delete(ctx, id)
+}
+
+// FetchAndCache refreshes the name, sector and currency of symbol from the market data
+// provider, creating the stock row the first time it is seen. While the
+// provider cannot be reached a stock already stored is returned as it is.
+func (s *StockService) FetchAndCache(ctx context.Context, symbol string) (*models.Stock, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "StockService.FetchAndCache")
+	defer span.End()
+
+	overview, err := s.market.FetchOverview(ctx, symbol, loggerFrom(ctx))
+	if err != nil {
+		if !errors.Is(err, marketdata.ErrUnavailable) {
+			return nil, err
+		}
+		stock, getErr := s.stocks.GetBySymbol(ctx, symbol)
+		if getErr != nil {
+			return nil, err
+		}
+		loggerFrom(ctx).Warn().Err(err).Str("symbol", symbol).Msg("Market data unavailable, returning the stored stock")
+		return stock, nil
+	}
+
+	currency, err := normalizeCurrency(overview.Currency, models.DefaultCurrency)
+	if err != nil {
+		loggerFrom(ctx).Warn().Str("symbol", symbol).Str("currency", overview.Currency).Msg("Unknown currency in overview, using the default")
+		currency = models.DefaultCurrency
+	}
+
+	stock, err := s.stocks.GetBySymbol(ctx, symbol)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		stock = &models.Stock{
+			Symbol:   symbol,
+			Name:     overview.Name,
+			Sector:   overview.Sector,
+			Currency: currency,
+		}
+		if err := s.stocks.Create(ctx, stock); err != nil {
+			return nil, err
+		}
+		return stock, nil
+	}
+	if err != nil {
+		loggerFrom(ctx).Error().Err(err).Str("symbol", symbol).Msg("Failed to check existing stock")
+		return nil, err
+	}
+
+	stock.Name = overview.Name
+	stock.Sector = overview.Sector
+	stock.Currency = currency
+	if err := s.stocks.Update(ctx, stock); err != nil {
+		loggerFrom(ctx).Error().Err(err).Str("symbol", symbol).Msg("Failed to update stock")
+		return nil, err
+	}
+	return stock, nil
+}
+
+// DailyMovers is the change of the last daily close of the popular stocks,
+// largest rise first.
+func (s *StockService) DailyMovers(ctx context.Context) ([]alphavantage.DailyMover, error) {
+	return s.market.FetchDailyMovers(ctx, loggerFrom(ctx))
+}
+
+// Quote returns the latest quote of symbol from the first provider that has
+// one.
+func (s *StockService) Quote(ctx context.Context, symbol string) (*marketdata.Quote, error) {
+	return latestQuote(ctx, s.quotes, symbol)
+}
+
+// latestQuote returns the last trade of symbol from quotes.
+func latestQuote(ctx context.Context, quotes marketdata.Provider, symbol string) (*marketdata.Quote, error) {
+	quote, err := quotes.Quote(ctx, symbol)
+	if err != nil {
+		loggerFrom(ctx).Error().Err(err).Str("stock_id", symbol).Msg("Failed to fetch stock quote")
+		return nil, err
+	}
+	return quote, nil
+}
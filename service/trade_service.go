@@ -0,0 +1,797 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/marketdata"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/money"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// TradeService books trades and corrections. Holdings are derived data: every
+// write replays the trade history of the affected stock in the same DB
+// transaction, so an oversell never leaves a trade without its position.
+// Trades are charged the fees of their portfolio's schedule from the owner's
+// cash, and a reversal refunds the fees of the entry it cancels. The trades
+// of a margin portfolio settle in the owner's cash too, which a buy may take
+// below zero as a loan, and may sell short.
+type TradeService struct {
+	tx            repository.Transactor
+	transactions  repository.TransactionRepository
+	holdings      repository.HoldingRepository
+	actions       repository.CorporateActionRepository
+	users         repository.UserRepository
+	quotes        marketdata.Provider
+	fx            *FxService
+	portfolios    *PortfolioService
+	notifications *NotificationService
+	places        int32 // of share quantities
+}
+
+func NewTradeService(repos *repository.Repositories, quotes marketdata.Provider, fx *FxService, portfolios *PortfolioService, notifications *NotificationService, trading config.TradingConfig) *TradeService {
+	return &TradeService{
+		tx:            repos.Tx,
+		transactions:  repos.Transactions,
+		holdings:      repos.Holdings,
+		actions:       repos.Actions,
+		users:         repos.Users,
+		quotes:        quotes,
+		fx:            fx,
+		portfolios:    portfolios,
+		notifications: notifications,
+		places:        int32(trading.QuantityPlaces),
+	}
+}
+
+// Place executes a buy or sell at the latest quote in one of the caller's
+// portfolios. The trade is booked in the stock's currency with the rate
+// that converts it to the portfolio's base currency today. Either quantity
+// or amount is given: an amount in the base currency trades as many shares
+// as it pays for at that price, rounded down to the quantity places, with
+// the fees charged on top. In a margin portfolio the part of a trade that
+// opens a position, long or short, must be covered by the buying power.
+func (s *TradeService) Place(ctx context.Context, userId, portfolioId, stockId string, quantity, amount decimal.Decimal, side string) (*models.TransactionModel, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TradeService.Place")
+	defer span.End()
+
+	if side != models.TransactionTypeBuy && side != models.TransactionTypeSell {
+		return nil, invalid("invalid quantity or type")
+	}
+	if amount.IsZero() {
+		if err := s.checkQuantity(quantity); err != nil {
+			return nil, err
+		}
+	} else if !quantity.IsZero() {
+		return nil, invalid("give either a quantity or an amount, not both")
+	} else if !amount.IsPositive() {
+		return nil, invalid("amount must be positive")
+	}
+
+	portfolio, err := s.portfolios.Get(ctx, userId, portfolioId)
+	if err != nil {
+		return nil, err
+	}
+	return s.place(ctx, portfolio, stockId, quantity, amount, side)
+}
+
+func (s *TradeService) place(ctx context.Context, portfolio *models.PortFolio, stockId string, quantity, amount decimal.Decimal, side string) (*models.TransactionModel, error) {
+	currency, err := s.fx.StockCurrency(ctx, stockId)
+	if err != nil {
+		return nil, err
+	}
+
+	// a trade executes at a live price or not at all
+	quote, err := latestQuote(marketdata.WithoutFallback(ctx), s.quotes, stockId)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rate, err := s.fx.Rate(ctx, currency, portfolio.BaseCurrency, now)
+	if err != nil {
+		return nil, err
+	}
+	if !amount.IsZero() {
+		perShare := quote.Price.Mul(rate)
+		if !perShare.IsPositive() {
+			return nil, invalid("the stock has no price to size the order by")
+		}
+		quantity = amount.DivRound(perShare, s.places+2).RoundDown(s.places)
+		if !quantity.IsPositive() {
+			return nil, invalid(fmt.Sprintf("%s %s does not pay for the smallest quantity of %s", amount, portfolio.BaseCurrency, stockId))
+		}
+	}
+
+	trade := &models.TransactionModel{
+		UserId:      portfolio.UserId,
+		PortFolioId: portfolio.Id,
+		StockId:     stockId,
+		Quantity:    quantity,
+		Price:       quote.Price,
+		PriceSource: quote.Source,
+		Currency:    currency,
+		FxRate:      rate,
+		Type:        side,
+		Status:      models.TransactionStatusExecuted,
+		TradeDate:   now,
+	}
+	if err := s.chargeFees(ctx, trade, portfolio.BaseCurrency); err != nil {
+		return nil, err
+	}
+	fees, err := s.feeCash(ctx, trade, portfolio.BaseCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	// the row lock makes concurrent trades in the portfolio take turns, so
+	// each is checked against the holdings and cash the one before left
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		locked, err := s.portfolios.lock(ctx, portfolio.Id)
+		if err != nil {
+			return err
+		}
+		if locked.BaseCurrency != portfolio.BaseCurrency {
+			return ErrPortfolioChanged
+		}
+		portfolio := locked
+		trade.CashSettled = portfolio.Margin
+		var cash decimal.Decimal
+		if portfolio.Margin {
+			if err := s.checkBuyingPower(ctx, portfolio, trade); err != nil {
+				return err
+			}
+			if cash, err = s.settlement(ctx, trade, portfolio.BaseCurrency); err != nil {
+				return err
+			}
+		}
+
+		if err := s.transactions.Create(ctx, trade); err != nil {
+			return err
+		}
+		if err := s.rebuildHoldings(ctx, portfolio.Id, stockId, booking(portfolio, trade)...); err != nil {
+			return err
+		}
+		if portfolio.Margin {
+			return s.moveCash(ctx, portfolio.UserId, cash.Sub(fees))
+		}
+		return s.debitFees(ctx, portfolio.UserId, fees)
+	})
+	if err != nil {
+		loggerFrom(ctx).Error().Err(err).Msg("issue in trade_service/Place")
+		return nil, err
+	}
+
+	s.afterBooking(ctx, trade)
+
+	return trade, nil
+}
+
+// Get returns the transaction only when it belongs to userId.
+func (s *TradeService) Get(ctx context.Context, userId, id string) (*models.TransactionModel, error) {
+	trade, err := s.transactions.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if trade.UserId != userId {
+		return nil, ErrNotOwner
+	}
+	return trade, nil
+}
+
+func (s *TradeService) ListByUser(ctx context.Context, userId string) ([]models.TransactionModel, error) {
+	return s.transactions.ListByUserId(ctx, userId)
+}
+
+func (s *TradeService) ListByPortfolio(ctx context.Context, userId, portfolioId string) ([]models.TransactionModel, error) {
+	if _, err := s.portfolios.Get(ctx, userId, portfolioId); err != nil {
+		return nil, err
+	}
+	return s.transactions.ListByPortfolioId(ctx, portfolioId)
+}
+
+func (s *TradeService) ListByStock(ctx context.Context, userId, stockId string) ([]models.TransactionModel, error) {
+	return s.transactions.ListByUserAndStock(ctx, userId, stockId)
+}
+
+// Reverse books a reversal entry that cancels the caller's transaction.
+func (s *TradeService) Reverse(ctx context.Context, userId, id, note string) (*models.TransactionModel, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TradeService.Reverse")
+	defer span.End()
+
+	original, err := s.Get(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+	reversal, _, err := s.correct(ctx, original, nil, note)
+	return reversal, err
+}
+
+// Correct reverses the caller's transaction and books a replacement at the
+// original trade date. A price of zero keeps the original price. It returns
+// the original, the reversal and the replacement.
+func (s *TradeService) Correct(ctx context.Context, userId, id string, quantity, price decimal.Decimal, side, note string) (original, reversal, replacement *models.TransactionModel, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TradeService.Correct")
+	defer span.End()
+
+	if side != models.TransactionTypeBuy && side != models.TransactionTypeSell {
+		return nil, nil, nil, invalid("invalid quantity or type")
+	}
+	if err := s.checkQuantity(quantity); err != nil {
+		return nil, nil, nil, err
+	}
+
+	original, err = s.Get(ctx, userId, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	source := models.PriceSourceManual
+	if !price.IsPositive() {
+		price, source = original.Price, original.PriceSource
+	}
+
+	replacement = &models.TransactionModel{
+		Quantity:    quantity,
+		Price:       price,
+		PriceSource: source,
+		Type:        side,
+	}
+
+	reversal, replacement, err = s.correct(ctx, original, replacement, note)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return original, reversal, replacement, nil
+}
+
+// correct reverses original and, when replacement is not nil, books it in
+// the original's place, in its currency and at its rate. The original's fees
+// are refunded and the replacement charged its own; a cash settled original
+// has its settlement undone, and in a margin portfolio the replacement is
+// settled. A correction that would oversell, or whose fees the cash does not
+// cover, is rejected as a whole. Corrections are not held to the buying
+// power: they restate trades that already happened.
+func (s *TradeService) correct(ctx context.Context, original, replacement *models.TransactionModel, note string) (*models.TransactionModel, *models.TransactionModel, error) {
+	if original.Type == models.TransactionTypeReversal {
+		return nil, nil, ErrReversalNotCorrectable
+	}
+	if original.Type == models.TransactionTypeDividend {
+		return nil, nil, ErrDividendNotCorrectable
+	}
+	if original.Type == models.TransactionTypeBorrowFee {
+		return nil, nil, ErrBorrowFeeNotCorrectable
+	}
+
+	reversal := &models.TransactionModel{
+		UserId:         original.UserId,
+		PortFolioId:    original.PortFolioId,
+		StockId:        original.StockId,
+		Quantity:       original.Quantity,
+		Price:          original.Price,
+		PriceSource:    original.PriceSource,
+		Currency:       original.Currency,
+		FxRate:         original.FxRate,
+		Commission:     original.Commission,
+		RegulatoryFee:  original.RegulatoryFee,
+		CashSettled:    original.CashSettled,
+		Type:           models.TransactionTypeReversal,
+		ReversalOfId:   &original.Id,
+		CorrectionNote: note,
+		TradeDate:      original.TradeDate,
+	}
+
+	portfolio, err := s.portfolios.Get(ctx, original.UserId, original.PortFolioId)
+	if err != nil {
+		return nil, nil, err
+	}
+	cash, err := s.feeCash(ctx, reversal, portfolio.BaseCurrency)
+	if err != nil {
+		return nil, nil, err
+	}
+	if original.CashSettled {
+		settled, err := s.settlement(ctx, original, portfolio.BaseCurrency)
+		if err != nil {
+			return nil, nil, err
+		}
+		cash = cash.Sub(settled)
+	}
+	fees := decimal.Zero
+	if replacement != nil {
+		replacement.UserId = original.UserId
+		replacement.PortFolioId = original.PortFolioId
+		replacement.StockId = original.StockId
+		replacement.Currency = original.Currency
+		replacement.FxRate = original.FxRate
+		replacement.ReplacesId = &original.Id
+		replacement.CorrectionNote = note
+		replacement.TradeDate = original.TradeDate
+		replacement.Status = models.TransactionStatusExecuted
+		replacement.CashSettled = portfolio.Margin
+		if err := s.chargeFees(ctx, replacement, portfolio.BaseCurrency); err != nil {
+			return nil, nil, err
+		}
+		if fees, err = s.feeCash(ctx, replacement, portfolio.BaseCurrency); err != nil {
+			return nil, nil, err
+		}
+		if portfolio.Margin {
+			settled, err := s.settlement(ctx, replacement, portfolio.BaseCurrency)
+			if err != nil {
+				return nil, nil, err
+			}
+			cash = cash.Add(settled).Sub(fees)
+			fees = decimal.Zero
+		}
+	}
+
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		locked, err := s.portfolios.lock(ctx, portfolio.Id)
+		if err != nil {
+			return err
+		}
+		if locked.Margin != portfolio.Margin {
+			return ErrPortfolioChanged
+		}
+
+		count, err := s.transactions.CountReversalsOf(ctx, original.Id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyReversed
+		}
+
+		// the count is only a fast path: a concurrent reversal that got
+		// past it trips the unique index on reversal_of_id here
+		if err := s.transactions.Create(ctx, reversal); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrAlreadyReversed
+			}
+			return err
+		}
+		if err := s.moveCash(ctx, original.UserId, cash); err != nil {
+			return err
+		}
+
+		if replacement != nil {
+			if err := s.transactions.Create(ctx, replacement); err != nil {
+				return err
+			}
+		}
+
+		if err := s.rebuildHoldings(ctx, original.PortFolioId, original.StockId, booking(portfolio, reversal, replacement)...); err != nil {
+			return err
+		}
+		return s.debitFees(ctx, original.UserId, fees)
+	})
+	if err != nil {
+		loggerFrom(ctx).Error().Err(err).Str("transaction_id", original.Id).Msg("issue in trade_service/correct")
+		return nil, nil, err
+	}
+
+	if err := s.portfolios.Revalue(ctx, original.PortFolioId); err != nil {
+		loggerFrom(ctx).Error().Err(err).Str("portfolio_id", original.PortFolioId).Msg("Failed to update portfolio metrics after correction")
+	}
+
+	return reversal, replacement, nil
+}
+
+// chargeFees sets the commission and regulatory fee of trade from its
+// portfolio's schedule, in the trade's currency.
+func (s *TradeService) chargeFees(ctx context.Context, trade *models.TransactionModel, baseCurrency string) error {
+	schedule, err := s.portfolios.feeSchedule(ctx, trade.PortFolioId)
+	if err != nil {
+		return err
+	}
+	value := trade.Quantity.Mul(trade.Price).Mul(trade.FxRate)
+	commission, regulatory := schedule.Fees(trade.Type, trade.Quantity, value)
+	trade.Commission = money.Div(money.Round(commission, baseCurrency), trade.FxRate)
+	trade.RegulatoryFee = money.Div(money.Round(regulatory, baseCurrency), trade.FxRate)
+	return nil
+}
+
+// feeCash returns the fees of entry in its owner's cash currency, at the
+// rate of its trade date.
+func (s *TradeService) feeCash(ctx context.Context, entry *models.TransactionModel, baseCurrency string) (decimal.Decimal, error) {
+	fees := entry.Commission.Add(entry.RegulatoryFee)
+	if fees.IsZero() {
+		return decimal.Zero, nil
+	}
+	user, err := s.users.GetSummaryById(ctx, entry.UserId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return s.fx.Convert(ctx, money.Round(fees.Mul(entry.FxRate), baseCurrency), baseCurrency, user.BaseCurrency, entry.TradeDate)
+}
+
+func (s *TradeService) debitFees(ctx context.Context, userId string, fees decimal.Decimal) error {
+	if !fees.IsPositive() {
+		return nil
+	}
+	ok, err := s.users.Debit(ctx, userId, fees)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
+
+// settlement returns the cash entry moves in its owner's currency at the
+// rate of its trade date, the proceeds of a sell and the negative cost of a
+// buy, fees aside.
+func (s *TradeService) settlement(ctx context.Context, entry *models.TransactionModel, baseCurrency string) (decimal.Decimal, error) {
+	user, err := s.users.GetSummaryById(ctx, entry.UserId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	value := money.Round(entry.Quantity.Mul(entry.Price).Mul(entry.FxRate), baseCurrency)
+	cash, err := s.fx.Convert(ctx, value, baseCurrency, user.BaseCurrency, entry.TradeDate)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if entry.Type == models.TransactionTypeBuy {
+		return cash.Neg(), nil
+	}
+	return cash, nil
+}
+
+// moveCash credits a positive amount to the user and charges a negative
+// one, even below zero.
+func (s *TradeService) moveCash(ctx context.Context, userId string, amount decimal.Decimal) error {
+	if amount.IsPositive() {
+		return s.users.Credit(ctx, userId, amount)
+	}
+	if amount.IsNegative() {
+		return s.users.Charge(ctx, userId, amount.Neg())
+	}
+	return nil
+}
+
+// checkBuyingPower accepts a trade in a margin portfolio when the value and
+// fees of the part of it that opens a position are within the buying power.
+// The part that closes one lowers the requirements and is always accepted.
+func (s *TradeService) checkBuyingPower(ctx context.Context, portfolio *models.PortFolio, trade *models.TransactionModel) error {
+	var held decimal.Decimal
+	holding, err := s.holdings.Get(ctx, portfolio.Id, trade.StockId)
+	if err == nil {
+		held = holding.Quantity
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if trade.Type == models.TransactionTypeSell {
+		held = held.Neg()
+	}
+	// held is now negative by the shares the trade closes
+	opening := trade.Quantity
+	if held.IsNegative() {
+		opening = decimal.Max(decimal.Zero, opening.Add(held))
+	}
+	if opening.IsZero() {
+		return nil
+	}
+
+	status, err := s.portfolios.margin(ctx, portfolio)
+	if err != nil {
+		return err
+	}
+	if len(status.Unpriced) > 0 {
+		return invalid(fmt.Sprintf("the buying power is unknown while %s cannot be priced", strings.Join(status.Unpriced, ", ")))
+	}
+	value := opening.Mul(trade.Price).Add(trade.Commission).Add(trade.RegulatoryFee).Mul(trade.FxRate)
+	if money.Round(value, portfolio.BaseCurrency).GreaterThan(status.BuyingPower) {
+		return ErrInsufficientBuyingPower
+	}
+	return nil
+}
+
+// checkQuantity accepts a positive quantity of at most the configured
+// decimal places.
+func (s *TradeService) checkQuantity(quantity decimal.Decimal) error {
+	if !quantity.IsPositive() {
+		return invalid("invalid quantity or type")
+	}
+	if !quantity.Equal(quantity.Truncate(s.places)) {
+		if s.places == 0 {
+			return invalid("quantity must be a whole number of shares")
+		}
+		return invalid(fmt.Sprintf("quantity may have at most %d decimal places", s.places))
+	}
+	return nil
+}
+
+// Chain walks back to the first entry of the correction chain of the
+// caller's transaction and returns every entry of it in booking order.
+func (s *TradeService) Chain(ctx context.Context, userId, id string) ([]models.TransactionModel, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TradeService.Chain")
+	defer span.End()
+
+	root, err := s.Get(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var parentId *string
+		if root.ReversalOfId != nil {
+			parentId = root.ReversalOfId
+		} else if root.ReplacesId != nil {
+			parentId = root.ReplacesId
+		}
+		if parentId == nil {
+			break
+		}
+		if root, err = s.transactions.GetById(ctx, *parentId); err != nil {
+			return nil, err
+		}
+	}
+
+	chain := []models.TransactionModel{*root}
+	seen := map[string]bool{root.Id: true}
+
+	for i := 0; i < len(chain); i++ {
+		next, err := s.transactions.ListChildren(ctx, chain[i].Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range next {
+			if !seen[n.Id] {
+				seen[n.Id] = true
+				chain = append(chain, n)
+			}
+		}
+	}
+
+	return chain, nil
+}
+
+func (s *TradeService) Lots(ctx context.Context, userId, portfolioId, stockId string) ([]models.HoldingLotModel, error) {
+	if _, err := s.portfolios.Get(ctx, userId, portfolioId); err != nil {
+		return nil, err
+	}
+	return s.holdings.ListLots(ctx, portfolioId, stockId)
+}
+
+// afterBooking refreshes the portfolio metrics and notifies the user. The
+// trade is already committed, so failures here are only logged, and a client
+// that hangs up now does not get to skip them; each call is still bounded by
+// its own upstream timeout.
+func (s *TradeService) afterBooking(ctx context.Context, trade *models.TransactionModel) {
+	ctx = context.WithoutCancel(ctx)
+	logger := loggerFrom(ctx)
+
+	if err := s.portfolios.Revalue(ctx, trade.PortFolioId); err != nil {
+		logger.Error().Err(err).Str("portfolio_id", trade.PortFolioId).Msg("Failed to update portfolio metrics after transaction")
+	}
+
+	message := fmt.Sprintf("Transaction %s for %s: %s shares at %s", trade.Type, trade.StockId, trade.Quantity, money.Format(trade.Price, trade.Currency))
+	if fees := trade.Commission.Add(trade.RegulatoryFee); fees.IsPositive() {
+		message += fmt.Sprintf(", fees %s", money.Format(fees, trade.Currency))
+	}
+	if err := s.notifications.Notify(ctx, trade.UserId, message); err != nil {
+		logger.Error().Err(err).Msg("Failed to create notification")
+	}
+}
+
+// booking returns the ids of entries, nil ones left out, for rebuildHoldings
+// to check when portfolio is not a margin account.
+func booking(portfolio *models.PortFolio, entries ...*models.TransactionModel) []string {
+	if portfolio.Margin {
+		return nil
+	}
+	var ids []string
+	for _, entry := range entries {
+		if entry != nil {
+			ids = append(ids, entry.Id)
+		}
+	}
+	return ids
+}
+
+// rebuildHoldings replays the effective trade history of one stock in one
+// portfolio, with the splits that have gone ex, and rewrites its
+// PortFolioStock row and open lots. booking names the entries being booked
+// in a portfolio that may not sell short: a sell among them must not open a
+// short lot, and they must not leave the position short. The entries booked
+// before are replayed as they were accepted, short sales of a portfolio that
+// used to be a margin account too.
+func (s *TradeService) rebuildHoldings(ctx context.Context, portfolioId, stockId string, booking ...string) error {
+	txs, err := s.transactions.ListByPortfolioAndStock(ctx, portfolioId, stockId)
+	if err != nil {
+		return err
+	}
+	splits, err := s.splits(ctx, stockId, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var checked map[string]bool
+	if len(booking) > 0 {
+		checked = make(map[string]bool, len(booking))
+		for _, id := range booking {
+			checked[id] = true
+		}
+	}
+	lots, realized, realizedBase, err := replayHoldings(portfolioId, stockId, txs, splits, s.places, checked)
+	if err != nil {
+		return err
+	}
+	currency, err := s.fx.StockCurrency(ctx, stockId)
+	if err != nil {
+		return err
+	}
+
+	var quantity, cost, costBase decimal.Decimal
+	for _, lot := range lots {
+		quantity = quantity.Add(lot.Quantity)
+		cost = cost.Add(lot.Price.Mul(lot.Quantity))
+		costBase = costBase.Add(lot.Price.Mul(lot.FxRate).Mul(lot.Quantity))
+	}
+
+	if checked != nil && quantity.IsNegative() {
+		return ErrInsufficientHoldings
+	}
+
+	var averagePrice, averagePriceBase decimal.Decimal
+	if !quantity.IsZero() {
+		averagePrice = money.Div(cost, quantity)
+		averagePriceBase = money.Div(costBase, quantity)
+	}
+
+	if err := s.holdings.ReplaceLots(ctx, portfolioId, stockId, lots); err != nil {
+		return err
+	}
+
+	return s.holdings.Save(ctx, &models.PortFolioStock{
+		StockId:           stockId,
+		PortFolioId:       portfolioId,
+		Quantity:          quantity,
+		AveragePrice:      averagePrice,
+		RealizedGains:     money.RoundScale(realized),
+		Currency:          currency,
+		AveragePriceBase:  averagePriceBase,
+		RealizedGainsBase: money.RoundScale(realizedBase),
+		UpdatedAt:         time.Now(),
+	})
+}
+
+// splits returns the splits of stockId that went ex by until, oldest first.
+func (s *TradeService) splits(ctx context.Context, stockId string, until time.Time) ([]models.CorporateActionModel, error) {
+	actions, err := s.actions.ListByStock(ctx, stockId)
+	if err != nil {
+		return nil, err
+	}
+	var splits []models.CorporateActionModel
+	for _, action := range actions {
+		if action.Type == models.CorporateActionSplit && !action.ExDate.After(until) {
+			splits = append(splits, action)
+		}
+	}
+	return splits, nil
+}
+
+// replayHoldings replays txs (reversed entries and reversals cancel out) and
+// returns the open lots and realized gains they leave, the gains both in the
+// stock's currency and in the portfolio's base currency at the rates the
+// trades were booked at. A short position is held in lots of negative
+// quantity priced at the proceeds of their sale: a buy covers short lots
+// before it opens a long one and a sell closes long lots before it opens a
+// short one, first in, first out. A sell in checked may not open one. The fees of
+// a trade come off the gains of the shares it closes and are part of the
+// cost of those it opens, split by quantity, and borrow fees come off the
+// gains. Each split is applied to the lots open when it goes ex, before the
+// trades of its ex date, so later trades are in post-split shares; splits
+// must be sorted by ex date. places is how many decimal places the
+// quantities a split leaves are rounded down to.
+func replayHoldings(portfolioId, stockId string, txs []models.TransactionModel, splits []models.CorporateActionModel, places int32, checked map[string]bool) ([]models.HoldingLotModel, decimal.Decimal, decimal.Decimal, error) {
+	reversed := make(map[string]bool)
+	for _, t := range txs {
+		if t.Type == models.TransactionTypeReversal && t.ReversalOfId != nil {
+			reversed[*t.ReversalOfId] = true
+		}
+	}
+
+	sort.SliceStable(txs, func(i, j int) bool {
+		if !txs[i].TradeDate.Equal(txs[j].TradeDate) {
+			return txs[i].TradeDate.Before(txs[j].TradeDate)
+		}
+		return txs[i].CreatedAt.Before(txs[j].CreatedAt)
+	})
+
+	var lots []models.HoldingLotModel
+	var realized, realizedBase decimal.Decimal
+
+	for _, t := range txs {
+		for len(splits) > 0 && !splits[0].ExDate.After(t.TradeDate) {
+			lots = splitLots(lots, splits[0].Ratio, places)
+			splits = splits[1:]
+		}
+
+		if t.Type == models.TransactionTypeReversal || reversed[t.Id] {
+			continue
+		}
+
+		switch t.Type {
+		case models.TransactionTypeBuy, models.TransactionTypeSell:
+			// a buy closes short lots, a sell long ones
+			sign := decimal.NewFromInt(1)
+			if t.Type == models.TransactionTypeSell {
+				sign = sign.Neg()
+			}
+			remaining := t.Quantity
+			for remaining.IsPositive() && len(lots) > 0 && lots[0].Quantity.Sign() == -sign.Sign() {
+				used := decimal.Min(lots[0].Quantity.Abs(), remaining)
+				// a long lot gains when sold above its price, a short
+				// one when bought back below it
+				gain := used.Mul(t.Price.Sub(lots[0].Price)).Mul(sign.Neg())
+				gainBase := used.Mul(t.Price.Mul(t.FxRate).Sub(lots[0].Price.Mul(lots[0].FxRate))).Mul(sign.Neg())
+				realized = realized.Add(gain)
+				realizedBase = realizedBase.Add(gainBase)
+				lots[0].Quantity = lots[0].Quantity.Add(used.Mul(sign))
+				remaining = remaining.Sub(used)
+				if lots[0].Quantity.IsZero() {
+					lots = lots[1:]
+				}
+			}
+
+			fees := t.Commission.Add(t.RegulatoryFee)
+			openFees := money.Div(fees.Mul(remaining), t.Quantity)
+			closeFees := fees.Sub(openFees)
+			realized = realized.Sub(closeFees)
+			realizedBase = realizedBase.Sub(closeFees.Mul(t.FxRate))
+
+			if !remaining.IsPositive() {
+				continue
+			}
+			if t.Type == models.TransactionTypeSell && checked[t.Id] {
+				return nil, decimal.Zero, decimal.Zero, ErrInsufficientHoldings
+			}
+			// a long lot costs its price plus the fees, a short one
+			// brought in its proceeds less them
+			lots = append(lots, models.HoldingLotModel{
+				PortFolioId:   portfolioId,
+				StockId:       stockId,
+				TransactionId: t.Id,
+				Quantity:      remaining.Mul(sign),
+				Price:         money.Div(remaining.Mul(t.Price).Add(openFees.Mul(sign)), remaining),
+				FxRate:        t.FxRate,
+				OpenedAt:      t.TradeDate,
+			})
+		case models.TransactionTypeBorrowFee:
+			fee := t.Quantity.Mul(t.Price)
+			realized = realized.Sub(fee)
+			realizedBase = realizedBase.Sub(fee.Mul(t.FxRate))
+		}
+	}
+	for _, split := range splits {
+		lots = splitLots(lots, split.Ratio, places)
+	}
+
+	return lots, realized, realizedBase, nil
+}
+
+// splitLots turns every lot into ratio times its shares at the same cost.
+// The fraction of a share finer than places that a split leaves, long or
+// short, is dropped and its cost carried by the shares that remain.
+func splitLots(lots []models.HoldingLotModel, ratio decimal.Decimal, places int32) []models.HoldingLotModel {
+	split := lots[:0]
+	for _, lot := range lots {
+		cost := lot.Quantity.Mul(lot.Price)
+		lot.Quantity = lot.Quantity.Mul(ratio).RoundDown(places)
+		if lot.Quantity.IsZero() {
+			continue
+		}
+		lot.Price = money.Div(cost, lot.Quantity)
+		split = append(split, lot)
+	}
+	return split
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/shopspring/decimal"
+)
+
+var d = decimal.RequireFromString
+
+var day0 = time.Date(2025, 1, 6, 15, 0, 0, 0, time.UTC)
+
+// entry is a trade booked day days after day0 at a rate of 1 to the base
+// currency, with fees of commission.
+func entry(id, side string, day int, quantity, price, commission string) models.TransactionModel {
+	return models.TransactionModel{
+		Id:         id,
+		Type:       side,
+		Quantity:   d(quantity),
+		Price:      d(price),
+		FxRate:     d("1"),
+		Commission: d(commission),
+		TradeDate:  day0.AddDate(0, 0, day),
+		CreatedAt:  day0.AddDate(0, 0, day),
+	}
+}
+
+type wantLot struct {
+	transactionId string
+	quantity      string
+	price         string
+}
+
+func checkLots(t *testing.T, lots []models.HoldingLotModel, want []wantLot) {
+	t.Helper()
+	if len(lots) != len(want) {
+		t.Fatalf("got %d lots %+v, want %d", len(lots), lots, len(want))
+	}
+	for i, w := range want {
+		lot := lots[i]
+		if lot.TransactionId != w.transactionId || !lot.Quantity.Equal(d(w.quantity)) || !lot.Price.Equal(d(w.price)) {
+			t.Errorf("lot %d = %s %s @ %s, want %s %s @ %s", i, lot.TransactionId, lot.Quantity, lot.Price, w.transactionId, w.quantity, w.price)
+		}
+	}
+}
+
+func TestReplayHoldingsFees(t *testing.T) {
+	tests := []struct {
+		name         string
+		txs          []models.TransactionModel
+		wantLots     []wantLot
+		wantRealized string
+	}{
+		{
+			name:         "a buy's fees are part of its cost",
+			txs:          []models.TransactionModel{entry("b1", models.TransactionTypeBuy, 0, "10", "100", "5")},
+			wantLots:     []wantLot{{"b1", "10", "100.5"}},
+			wantRealized: "0",
+		},
+		{
+			name: "a sell's fees come off the gains",
+			txs: []models.TransactionModel{
+				entry("b1", models.TransactionTypeBuy, 0, "10", "100", "0"),
+				entry("s1", models.TransactionTypeSell, 1, "4", "110", "2"),
+			},
+			wantLots:     []wantLot{{"b1", "6", "100"}},
+			wantRealized: "38",
+		},
+		{
+			name: "split by quantity between the shares closed and opened",
+			txs: []models.TransactionModel{
+				entry("b1", models.TransactionTypeBuy, 0, "10", "100", "0"),
+				// closes 10 for 2 of the fees, opens a short of 5 that
+				// brought in its proceeds less the other 1
+				entry("s1", models.TransactionTypeSell, 1, "15", "110", "3"),
+			},
+			wantLots:     []wantLot{{"s1", "-5", "109.8"}},
+			wantRealized: "98",
+		},
+		{
+			name: "covering a short pays its fees out of the gains",
+			txs: []models.TransactionModel{
+				entry("s1", models.TransactionTypeSell, 0, "10", "50", "0"),
+				entry("b1", models.TransactionTypeBuy, 1, "10", "40", "4"),
+			},
+			wantRealized: "96",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lots, realized, realizedBase, err := replayHoldings("p1", "AAPL", tt.txs, nil, 6, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLots(t, lots, tt.wantLots)
+			if !realized.Equal(d(tt.wantRealized)) || !realizedBase.Equal(d(tt.wantRealized)) {
+				t.Errorf("realized = %s, base %s, want %s", realized, realizedBase, tt.wantRealized)
+			}
+		})
+	}
+}
+
+func split(day int, ratio string) models.CorporateActionModel {
+	return models.CorporateActionModel{Type: models.CorporateActionSplit, ExDate: day0.AddDate(0, 0, day).Truncate(24 * time.Hour), Ratio: d(ratio)}
+}
+
+func TestReplayHoldings(t *testing.T) {
+	reversal := entry("r1", models.TransactionTypeReversal, 1, "10", "120", "0")
+	reversedId := "b2"
+	reversal.ReversalOfId = &reversedId
+
+	tests := []struct {
+		name         string
+		txs          []models.TransactionModel
+		splits       []models.CorporateActionModel
+		wantLots     []wantLot
+		wantRealized string
+	}{
+		{
+			name: "sells close the oldest lots first",
+			txs: []models.TransactionModel{
+				entry("b1", models.TransactionTypeBuy, 0, "10", "100", "0"),
+				entry("b2", models.TransactionTypeBuy, 1, "10", "120", "0"),
+				entry("s1", models.TransactionTypeSell, 2, "15", "130", "0"),
+			},
+			wantLots:     []wantLot{{"b2", "5", "120"}},
+			wantRealized: "350",
+		},
+		{
+			name: "replayed in trade date order",
+			txs: []models.TransactionModel{
+				entry("s1", models.TransactionTypeSell, 2, "15", "130", "0"),
+				entry("b2", models.TransactionTypeBuy, 1, "10", "120", "0"),
+				entry("b1", models.TransactionTypeBuy, 0, "10", "100", "0"),
+			},
+			wantLots:     []wantLot{{"b2", "5", "120"}},
+			wantRealized: "350",
+		},
+		{
+			name: "reversed entries and reversals cancel out",
+			txs: []models.TransactionModel{
+				entry("b1", models.TransactionTypeBuy, 0, "10", "100", "0"),
+				entry("b2", models.TransactionTypeBuy, 1, "10", "120", "0"),
+				reversal,
+			},
+			wantLots:     []wantLot{{"b1", "10", "100"}},
+			wantRealized: "0",
+		},
+		{
+			name: "a short is covered before a long is opened",
+			txs: []models.TransactionModel{
+				entry("s1", models.TransactionTypeSell, 0, "10", "50", "0"),
+				entry("b1", models.TransactionTypeBuy, 1, "4", "40", "0"),
+				entry("b2", models.TransactionTypeBuy, 2, "10", "45", "0"),
+			},
+			wantLots:     []wantLot{{"b2", "4", "45"}},
+			wantRealized: "70",
+		},
+		{
+			name: "a short bought back above its price loses",
+			txs: []models.TransactionModel{
+				entry("s1", models.TransactionTypeSell, 0, "10", "50", "0"),
+				entry("b1", models.TransactionTypeBuy, 1, "5", "60", "0"),
+			},
+			wantLots:     []wantLot{{"s1", "-5", "50"}},
+			wantRealized: "-50",
+		},
+		{
+			name: "a split applies to the lots open on its ex date",
+			txs: []models.TransactionModel{
+				entry("b1", models.TransactionTypeBuy, 0, "10", "100", "0"),
+				entry("s1", models.TransactionTypeSell, 2, "5", "60", "0"),
+			},
+			splits:       []models.CorporateActionModel{split(1, "2")},
+			wantLots:     []wantLot{{"b1", "15", "50"}},
+			wantRealized: "50",
+		},
+		{
+			name: "a split after the last trade still applies",
+			txs: []models.TransactionModel{
+				entry("s1", models.TransactionTypeSell, 0, "10", "90", "0"),
+			},
+			splits:       []models.CorporateActionModel{split(3, "3")},
+			wantLots:     []wantLot{{"s1", "-30", "30"}},
+			wantRealized: "0",
+		},
+		{
+			name: "a reverse split drops the fraction and keeps the cost",
+			txs: []models.TransactionModel{
+				entry("b1", models.TransactionTypeBuy, 0, "3", "10", "0"),
+			},
+			splits:       []models.CorporateActionModel{split(1, "0.5")},
+			wantLots:     []wantLot{{"b1", "1", "30"}},
+			wantRealized: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lots, realized, _, err := replayHoldings("p1", "AAPL", tt.txs, tt.splits, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLots(t, lots, tt.wantLots)
+			checkDecimal(t, "realized", realized, tt.wantRealized)
+		})
+	}
+}
+
+func TestReplayHoldingsRates(t *testing.T) {
+	buy := entry("b1", models.TransactionTypeBuy, 0, "10", "100", "0")
+	buy.FxRate = d("1.1")
+	sell := entry("s1", models.TransactionTypeSell, 1, "10", "100", "1")
+	sell.FxRate = d("1.2")
+
+	lots, realized, realizedBase, err := replayHoldings("p1", "SAP", []models.TransactionModel{buy, sell}, nil, 6, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLots(t, lots, nil)
+	checkDecimal(t, "realized", realized, "-1")
+	// the gain is the rate's, less the fee at the sell's rate
+	checkDecimal(t, "realized in the base currency", realizedBase, "98.8")
+}
+
+func TestReplayHoldingsChecked(t *testing.T) {
+	// sold short while the portfolio was a margin account, then covered
+	history := []models.TransactionModel{
+		entry("s1", models.TransactionTypeSell, 0, "10", "50", "0"),
+		entry("b1", models.TransactionTypeBuy, 1, "10", "40", "0"),
+		entry("b2", models.TransactionTypeBuy, 2, "5", "45", "0"),
+	}
+
+	tests := []struct {
+		name    string
+		booking models.TransactionModel
+		wantErr bool
+	}{
+		{"a sell of held shares", entry("s2", models.TransactionTypeSell, 3, "5", "48", "0"), false},
+		{"a sell opening a short", entry("s2", models.TransactionTypeSell, 3, "6", "48", "0"), true},
+		{"a buy", entry("b3", models.TransactionTypeBuy, 3, "1", "48", "0"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs := append(append([]models.TransactionModel(nil), history...), tt.booking)
+			_, _, _, err := replayHoldings("p1", "AAPL", txs, nil, 6, map[string]bool{tt.booking.Id: true})
+			if tt.wantErr != errors.Is(err, ErrInsufficientHoldings) || (!tt.wantErr && err != nil) {
+				t.Fatalf("err = %v, want ErrInsufficientHoldings: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
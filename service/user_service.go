@@ -0,0 +1,463 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/mailer"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/money"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleUser  = 1
+	RoleAdmin = 2
+)
+
+type UserService struct {
+	tx      repository.Transactor
+	users   repository.UserRepository
+	fx      *FxService
+	mail    mailer.Mailer
+	baseURL string // public address of the server, for links in mail
+}
+
+func NewUserService(repos *repository.Repositories, fx *FxService, mail mailer.Mailer, server config.ServerConfig) *UserService {
+	return &UserService{
+		tx:      repos.Tx,
+		users:   repos.Users,
+		fx:      fx,
+		mail:    mail,
+		baseURL: strings.TrimRight(server.BaseURL, "/"),
+	}
+}
+
+// SendVerification mails email the link that verifies it with token, a
+// purpose token the caller signed.
+func (s *UserService) SendVerification(ctx context.Context, email, token string) error {
+	link := s.baseURL + "/api/verify-email?token=" + url.QueryEscape(token)
+
+	return s.mail.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your TradeAlpha email",
+		Body:    "Open this link within 24 hours to verify your email:\n\n" + link + "\n",
+	})
+}
+
+// Login finds the user behind an OAuth identity, creating it on first
+// login. created reports which of the two happened. A suspended account is
+// returned together with ErrSuspended so the caller can show the reason.
+func (s *UserService) Login(ctx context.Context, profile models.User) (user *models.User, created bool, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "UserService.Login")
+	defer span.End()
+
+	user, err = s.users.GetByEmail(ctx, profile.Email)
+	if err == nil {
+		if user.IsSuspended() {
+			return user, false, ErrSuspended
+		}
+		if err := s.users.UpdateLastLogin(ctx, user.Email, time.Now()); err != nil {
+			return nil, false, err
+		}
+		return user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	newUser := models.User{
+		Name:         profile.Name,
+		Email:        profile.Email,
+		ProfileImage: profile.ProfileImage,
+		OAuthId:      profile.OAuthId,
+		Provider:     profile.Provider,
+	}
+	if err := s.users.Create(ctx, &newUser); err != nil {
+		return nil, false, err
+	}
+	return &newUser, true, nil
+}
+
+func (s *UserService) Get(ctx context.Context, id string) (*models.User, error) {
+	return s.users.GetById(ctx, id)
+}
+
+func (s *UserService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.users.GetByEmail(ctx, email)
+}
+
+// GetSummary loads the user row without any of its relations.
+func (s *UserService) GetSummary(ctx context.Context, id string) (*models.User, error) {
+	return s.users.GetSummaryById(ctx, id)
+}
+
+func (s *UserService) List(ctx context.Context, limit, offset int) ([]models.User, error) {
+	return s.users.List(ctx, limit, offset)
+}
+
+func (s *UserService) MarkVerified(ctx context.Context, id, email string) error {
+	ok, err := s.users.MarkVerified(ctx, id, email)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrVerificationMismatch
+	}
+	return nil
+}
+
+// Withdraw debits amount, which may not be finer than the minor units of the
+// user's currency.
+func (s *UserService) Withdraw(ctx context.Context, id string, amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return invalid("amount must be positive")
+	}
+	user, err := s.users.GetSummaryById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !money.Round(amount, user.BaseCurrency).Equal(amount) {
+		return invalid(fmt.Sprintf("amount has more decimal places than %s allows", user.BaseCurrency))
+	}
+	ok, err := s.users.Debit(ctx, id, amount)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
+
+// ChangeBaseCurrency moves the cash balance to currency at today's rate,
+// rounded to its minor units with its rounding mode. The portfolios keep
+// their own base currency. It returns the user as it was.
+func (s *UserService) ChangeBaseCurrency(ctx context.Context, id, currency string) (*models.User, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "UserService.ChangeBaseCurrency")
+	defer span.End()
+
+	currency, err := normalizeCurrency(currency, "")
+	if err != nil {
+		return nil, err
+	}
+	if currency == "" {
+		return nil, invalid("currency is required")
+	}
+
+	// the row lock keeps a trade settling meanwhile from being converted
+	// at the old currency or lost
+	var user *models.User
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if user, err = s.users.Lock(ctx, id); err != nil {
+			return err
+		}
+		balance, err := s.fx.Convert(ctx, user.AccountBalance, user.BaseCurrency, currency, time.Now())
+		if err != nil {
+			return err
+		}
+		return s.users.UpdateFields(ctx, id, map[string]interface{}{
+			"base_currency":   currency,
+			"account_balance": balance,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) Deactivate(ctx context.Context, id string) error {
+	return s.users.UpdateFields(ctx, id, map[string]interface{}{
+		"is_active":      false,
+		"deactivated_at": time.Now(),
+	})
+}
+
+// Reactivate also cancels a pending deletion.
+func (s *UserService) Reactivate(ctx context.Context, id string) error {
+	return s.users.UpdateFields(ctx, id, map[string]interface{}{
+		"is_active":          true,
+		"deactivated_at":     nil,
+		"deletion_scheduled": nil,
+	})
+}
+
+// ScheduleDeletion deactivates the account and returns when it will be hard
+// deleted, reactivating before then cancels it.
+func (s *UserService) ScheduleDeletion(ctx context.Context, id string) (time.Time, error) {
+	deleteAt := time.Now().Add(models.AccountDeletionGrace)
+	err := s.users.UpdateFields(ctx, id, map[string]interface{}{
+		"is_active":          false,
+		"deactivated_at":     time.Now(),
+		"deletion_scheduled": deleteAt,
+	})
+	return deleteAt, err
+}
+
+func (s *UserService) Suspend(ctx context.Context, adminId, targetId, reason string) error {
+	if reason == "" {
+		return invalid("a suspension reason is required")
+	}
+	if adminId == targetId {
+		return ErrSelfSuspend
+	}
+	return s.users.UpdateFields(ctx, targetId, map[string]interface{}{
+		"suspended_at":      time.Now(),
+		"suspension_reason": reason,
+	})
+}
+
+func (s *UserService) Unsuspend(ctx context.Context, targetId string) error {
+	return s.users.UpdateFields(ctx, targetId, map[string]interface{}{
+		"suspended_at":      nil,
+		"suspension_reason": "",
+	})
+}
+
+// ChangeRole returns the role the user had before.
+func (s *UserService) ChangeRole(ctx context.Context, targetId string, roleId int) (int, error) {
+	if roleId != RoleUser && roleId != RoleAdmin {
+		return 0, invalid("unknown role")
+	}
+	target, err := s.users.GetSummaryById(ctx, targetId)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.users.UpdateFields(ctx, targetId, map[string]interface{}{"role_id": roleId}); err != nil {
+		return 0, err
+	}
+	return target.RoleId, nil
+}
+
+// CountActive counts accounts that can currently sign in and trade.
+func (s *UserService) CountActive(ctx context.Context) (int64, error) {
+	return s.users.CountActive(ctx)
+}
+
+// PurgeDue hard deletes every account whose grace period has passed and
+// returns how many were removed.
+func (s *UserService) PurgeDue(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "UserService.PurgeDue")
+	defer span.End()
+
+	ids, err := s.users.ListDueForDeletion(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		err := s.tx.InTx(ctx, func(ctx context.Context) error {
+			return s.users.HardDelete(ctx, id)
+		})
+		if err != nil {
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (s *UserService) Addresses(ctx context.Context, userId string) ([]models.AddressModel, error) {
+	return s.users.GetAddressesByUserId(ctx, userId)
+}
+
+func (s *UserService) AddAddress(ctx context.Context, address *models.AddressModel) error {
+	return s.users.CreateAddress(ctx, address)
+}
+
+// UpdateAddress copies the editable fields of update onto the caller's
+// address with the same id.
+func (s *UserService) UpdateAddress(ctx context.Context, userId string, update models.AddressModel) (*models.AddressModel, error) {
+	existing, err := s.ownedAddress(ctx, userId, update.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Street = update.Street
+	existing.ZipCode = update.ZipCode
+	existing.City = update.City
+	existing.State = update.State
+	existing.Country = update.Country
+	existing.UpdatedAt = time.Now()
+
+	if err := s.users.UpdateAddress(ctx, existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (s *UserService) DeleteAddress(ctx context.Context, userId, id string) error {
+	if _, err := s.ownedAddress(ctx, userId, id); err != nil {
+		return err
+	}
+	return s.users.DeleteAddress(ctx, id)
+}
+
+func (s *UserService) ownedAddress(ctx context.Context, userId, id string) (*models.AddressModel, error) {
+	address, err := s.users.GetAddressById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if address.UserId != userId {
+		return nil, ErrNotOwner
+	}
+	return address, nil
+}
+
+// EnrollTwoFactor stores a pending secret and returns it, 2FA stays
+// disabled until the user confirms it with a valid code.
+func (s *UserService) EnrollTwoFactor(ctx context.Context, user *models.User) (string, error) {
+	if user.TwoFactorEnabled {
+		return "", ErrTwoFactorEnabled
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.users.UpdateFields(ctx, user.Id, map[string]interface{}{
+		"two_factor_secret":    secret,
+		"two_factor_enabled":   false,
+		"two_factor_last_step": 0,
+	}); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the first code checks out and returns
+// the recovery codes, which are only ever shown once.
+func (s *UserService) ConfirmTwoFactor(ctx context.Context, user *models.User, code string) ([]string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "UserService.ConfirmTwoFactor")
+	defer span.End()
+
+	if user.TwoFactorSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	step, ok := util.ValidateTOTP(user.TwoFactorSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTP
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.users.UpdateFields(ctx, user.Id, map[string]interface{}{
+			"two_factor_enabled":   true,
+			"two_factor_last_step": step,
+		}); err != nil {
+			return err
+		}
+		return s.users.ReplaceRecoveryCodes(ctx, user.Id, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.TwoFactorEnabled = true
+	return codes, nil
+}
+
+// CheckSecondFactor accepts either a TOTP code (rejecting replays) or an
+// unused recovery code.
+func (s *UserService) CheckSecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "UserService.CheckSecondFactor")
+	defer span.End()
+
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorDisabled
+	}
+
+	if recoveryCode != "" {
+		ok, err := s.users.ConsumeRecoveryCode(ctx, user.Id, util.SHA256Hex(recoveryCode))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidRecoveryCode
+		}
+		return nil
+	}
+
+	step, ok := util.ValidateTOTP(user.TwoFactorSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidTOTP
+	}
+
+	fresh, err := s.users.AdvanceTOTPStep(ctx, user.Id, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrTOTPReplayed
+	}
+	return nil
+}
+
+func (s *UserService) DisableTwoFactor(ctx context.Context, userId string) error {
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.users.UpdateFields(ctx, userId, map[string]interface{}{
+			"two_factor_enabled":   false,
+			"two_factor_secret":    "",
+			"two_factor_last_step": 0,
+		}); err != nil {
+			log.Error().Err(err).Msg("issue persist in user_service/DisableTwoFactor")
+			return err
+		}
+		return s.users.DeleteRecoveryCodes(ctx, userId)
+	})
+}
+
+// RegenerateRecoveryCodes drops any previous codes and returns the new ones.
+func (s *UserService) RegenerateRecoveryCodes(ctx context.Context, userId string) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.ReplaceRecoveryCodes(ctx, userId, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes = make([]string, 0, models.RecoveryCodeCount)
+	hashes = make([]string, 0, models.RecoveryCodeCount)
+
+	for i := 0; i < models.RecoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(raw)
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, util.SHA256Hex(code))
+	}
+	return codes, hashes, nil
+}
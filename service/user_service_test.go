@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/pratyush934/tradealpha/server/config"
+	"github.com/pratyush934/tradealpha/server/migrations"
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/util"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// migratedRepos are repositories on an in-memory SQLite database brought up
+// by the migrations, for the services that are mostly queries.
+func migratedRepos(t *testing.T) *repository.Repositories {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	migrator, err := migrations.New(db, migrations.All())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	return repository.NewGorm(db)
+}
+
+func signUp(t *testing.T, users *UserService, email string) *models.User {
+	t.Helper()
+	user, _, err := users.Login(context.Background(), models.User{Name: "Ada", Email: email, Provider: "google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func totpAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := util.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// twoFactorUser signs a user up and enables two factor, returning the user
+// as loaded after confirmation and its recovery codes.
+func twoFactorUser(t *testing.T, users *UserService) (*models.User, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	user := signUp(t, users, "ada@example.com")
+	secret, err := users.EnrollTwoFactor(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err = users.Get(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.ConfirmTwoFactor(ctx, user, "000000x"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("confirm with a bad code: err = %v, want ErrInvalidTOTP", err)
+	}
+	codes, err := users.ConfirmTwoFactor(ctx, user, totpAt(t, secret, util.TOTPStep(time.Now())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err = users.Get(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	return user, codes
+}
+
+func TestCheckSecondFactorTOTP(t *testing.T) {
+	users := NewUserService(migratedRepos(t), nil, nil, config.ServerConfig{})
+	user, _ := twoFactorUser(t, users)
+	ctx := context.Background()
+	now := util.TOTPStep(time.Now())
+
+	// the confirmation used the current step
+	if err := users.CheckSecondFactor(ctx, user, totpAt(t, user.TwoFactorSecret, now), ""); !errors.Is(err, ErrTOTPReplayed) {
+		t.Errorf("the confirmation's code again: err = %v, want ErrTOTPReplayed", err)
+	}
+	if err := users.CheckSecondFactor(ctx, user, totpAt(t, user.TwoFactorSecret, now+1), ""); err != nil {
+		t.Errorf("the next step's code: %v", err)
+	}
+	if err := users.CheckSecondFactor(ctx, user, totpAt(t, user.TwoFactorSecret, now+1), ""); !errors.Is(err, ErrTOTPReplayed) {
+		t.Errorf("a code used twice: err = %v, want ErrTOTPReplayed", err)
+	}
+	if err := users.CheckSecondFactor(ctx, user, totpAt(t, user.TwoFactorSecret, now-1), ""); !errors.Is(err, ErrTOTPReplayed) {
+		t.Errorf("a code older than the last one used: err = %v, want ErrTOTPReplayed", err)
+	}
+	if err := users.CheckSecondFactor(ctx, user, totpAt(t, user.TwoFactorSecret, now+5), ""); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("a code outside the window: err = %v, want ErrInvalidTOTP", err)
+	}
+}
+
+func TestCheckSecondFactorRecoveryCodes(t *testing.T) {
+	users := NewUserService(migratedRepos(t), nil, nil, config.ServerConfig{})
+	user, codes := twoFactorUser(t, users)
+	ctx := context.Background()
+
+	if len(codes) != models.RecoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), models.RecoveryCodeCount)
+	}
+	if err := users.CheckSecondFactor(ctx, user, "", codes[0]); err != nil {
+		t.Fatalf("unused recovery code: %v", err)
+	}
+	if err := users.CheckSecondFactor(ctx, user, "", codes[0]); !errors.Is(err, ErrInvalidRecoveryCode) {
+		t.Errorf("reused recovery code: err = %v, want ErrInvalidRecoveryCode", err)
+	}
+	if err := users.CheckSecondFactor(ctx, user, "", "not-a-code"); !errors.Is(err, ErrInvalidRecoveryCode) {
+		t.Errorf("unknown recovery code: err = %v, want ErrInvalidRecoveryCode", err)
+	}
+
+	// regenerating drops the codes that were left
+	fresh, err := users.RegenerateRecoveryCodes(ctx, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.CheckSecondFactor(ctx, user, "", codes[1]); !errors.Is(err, ErrInvalidRecoveryCode) {
+		t.Errorf("code from before regenerating: err = %v, want ErrInvalidRecoveryCode", err)
+	}
+	if err := users.CheckSecondFactor(ctx, user, "", fresh[0]); err != nil {
+		t.Errorf("regenerated code: %v", err)
+	}
+}
+
+func TestCheckSecondFactorDisabled(t *testing.T) {
+	users := NewUserService(migratedRepos(t), nil, nil, config.ServerConfig{})
+	user := signUp(t, users, "grace@example.com")
+
+	if err := users.CheckSecondFactor(context.Background(), user, "123456", ""); !errors.Is(err, ErrTwoFactorDisabled) {
+		t.Errorf("err = %v, want ErrTwoFactorDisabled", err)
+	}
+}
+
+func TestChangeBaseCurrencyRoundsTies(t *testing.T) {
+	ctx := context.Background()
+	repos := migratedRepos(t)
+	users := NewUserService(repos, NewFxService(repos, nil), nil, config.ServerConfig{})
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	if err := repos.FxRates.Upsert(ctx, []models.FxRateModel{
+		{From: "USD", To: "EUR", Day: today, Rate: decimal.RequireFromString("0.5"), Source: "test"},
+		{From: "USD", To: "JPY", Day: today, Rate: decimal.RequireFromString("2"), Source: "test"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1.25 USD converts to an exact tie in both
+	tests := []struct {
+		email    string
+		currency string
+		want     string
+	}{
+		{"half-even@example.com", "EUR", "0.62"},
+		{"half-up@example.com", "JPY", "3"},
+	}
+	for _, tt := range tests {
+		user := signUp(t, users, tt.email)
+		if err := repos.Users.UpdateFields(ctx, user.Id, map[string]interface{}{"account_balance": decimal.RequireFromString("1.25")}); err != nil {
+			t.Fatal(err)
+		}
+
+		before, err := users.ChangeBaseCurrency(ctx, user.Id, tt.currency)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.currency, err)
+		}
+		if before.BaseCurrency != "USD" || !before.AccountBalance.Equal(decimal.RequireFromString("1.25")) {
+			t.Errorf("%s: returned %s %s, want the user as it was", tt.currency, before.AccountBalance, before.BaseCurrency)
+		}
+
+		after, err := users.GetSummary(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.BaseCurrency != tt.currency || !after.AccountBalance.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("balance = %s %s, want %s %s", after.AccountBalance, after.BaseCurrency, tt.want, tt.currency)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pratyush934/tradealpha/server/models"
+	"github.com/pratyush934/tradealpha/server/repository"
+	"github.com/pratyush934/tradealpha/server/tracing"
+)
+
+type WatchListService struct {
+	tx         repository.Transactor
+	watchLists repository.WatchListRepository
+	stocks     repository.StockRepository
+}
+
+func NewWatchListService(repos *repository.Repositories) *WatchListService {
+	return &WatchListService{tx: repos.Tx, watchLists: repos.WatchLists, stocks: repos.Stocks}
+}
+
+func (s *WatchListService) Create(ctx context.Context, watchList *models.WatchListModel) error {
+	return s.watchLists.Create(ctx, watchList)
+}
+
+// Get returns the watchlist only when it belongs to userId.
+func (s *WatchListService) Get(ctx context.Context, userId, id string) (*models.WatchListModel, error) {
+	watchList, err := s.watchLists.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if watchList.UserId != userId {
+		return nil, ErrNotOwner
+	}
+	return watchList, nil
+}
+
+func (s *WatchListService) ListByUser(ctx context.Context, userId string) ([]models.WatchListModel, error) {
+	return s.watchLists.ListByUserId(ctx, userId)
+}
+
+func (s *WatchListService) StocksByUser(ctx context.Context, userId string) ([]models.WatchListStockModel, error) {
+	return s.watchLists.ListStocksByUserId(ctx, userId)
+}
+
+// Delete removes the caller's watchlist with its stocks and returns the
+// deleted row.
+func (s *WatchListService) Delete(ctx context.Context, userId, id string) (*models.WatchListModel, error) {
+	watchList, err := s.Get(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		return s.watchLists.Delete(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return watchList, nil
+}
+
+func (s *WatchListService) AddStock(ctx context.Context, userId, id, symbol string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "WatchListService.AddStock")
+	defer span.End()
+
+	if _, err := s.Get(ctx, userId, id); err != nil {
+		return err
+	}
+
+	stock, err := s.stocks.GetBySymbol(ctx, symbol)
+	if err != nil {
+		loggerFrom(ctx).Error().Err(err).Str("symbol", symbol).Msg("Failed to find stock")
+		return err
+	}
+
+	added, err := s.watchLists.AddStock(ctx, &models.WatchListStockModel{
+		WatchListId: id,
+		Symbol:      symbol,
+		StockId:     stock.Id,
+	})
+	if err != nil {
+		return err
+	}
+	if !added {
+		return ErrAlreadyInWatchList
+	}
+	return nil
+}
+
+func (s *WatchListService) RemoveStock(ctx context.Context, userId, id, symbol string) error {
+	if _, err := s.Get(ctx, userId, id); err != nil {
+		return err
+	}
+	return s.watchLists.RemoveStock(ctx, id, symbol)
+}
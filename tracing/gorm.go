@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"errors"
+
+	"github.com/pratyush934/tradealpha/server/config"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tradealpha:tracing_span"
+
+// GormPlugin puts a client span around every statement, as a child of the
+// span in the ctx handed to WithContext. The SQL is recorded with its
+// placeholders, never the values. Row and Raw spans end once the statement
+// has run, reading the rows afterwards is not part of them.
+type GormPlugin struct {
+	Driver string
+}
+
+func (GormPlugin) Name() string {
+	return "tradealpha:tracing"
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	system := semconv.DBSystemNameKey.String(p.Driver)
+	switch p.Driver {
+	case config.DriverMySQL:
+		system = semconv.DBSystemNameMySQL
+	case config.DriverPostgres:
+		system = semconv.DBSystemNamePostgreSQL
+	case config.DriverSQLite:
+		system = semconv.DBSystemNameSQLite
+	}
+
+	callbacks := db.Callback()
+	steps := []func() error{
+		func() error {
+			return callbacks.Create().Before("gorm:create").Register("tradealpha:tracing_create", startSpan("create", system))
+		},
+		func() error {
+			return callbacks.Create().After("gorm:create").Register("tradealpha:tracing_create_done", endSpan)
+		},
+		func() error {
+			return callbacks.Query().Before("gorm:query").Register("tradealpha:tracing_query", startSpan("query", system))
+		},
+		func() error {
+			return callbacks.Query().After("gorm:query").Register("tradealpha:tracing_query_done", endSpan)
+		},
+		func() error {
+			return callbacks.Update().Before("gorm:update").Register("tradealpha:tracing_update", startSpan("update", system))
+		},
+		func() error {
+			return callbacks.Update().After("gorm:update").Register("tradealpha:tracing_update_done", endSpan)
+		},
+		func() error {
+			return callbacks.Delete().Before("gorm:delete").Register("tradealpha:tracing_delete", startSpan("delete", system))
+		},
+		func() error {
+			return callbacks.Delete().After("gorm:delete").Register("tradealpha:tracing_delete_done", endSpan)
+		},
+		func() error {
+			return callbacks.Row().Before("gorm:row").Register("tradealpha:tracing_row", startSpan("row", system))
+		},
+		func() error {
+			return callbacks.Row().After("gorm:row").Register("tradealpha:tracing_row_done", endSpan)
+		},
+		func() error {
+			return callbacks.Raw().Before("gorm:raw").Register("tradealpha:tracing_raw", startSpan("raw", system))
+		},
+		func() error {
+			return callbacks.Raw().After("gorm:raw").Register("tradealpha:tracing_raw_done", endSpan)
+		},
+	}
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSpan leaves Statement.Context alone: the query timeout wraps it too,
+// and swapping it back and forth here would drop that deadline.
+func startSpan(operation string, system attribute.KeyValue) func(tx *gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx := tx.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			// statements outside any request or job, such as the pool's own
+			// housekeeping, would each start a trace of their own
+			return
+		}
+
+		name := "db." + operation
+		if tx.Statement.Table != "" {
+			name += " " + tx.Statement.Table
+		}
+
+		_, span := Tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(system, semconv.DBOperationName(operation)),
+		)
+		if tx.Statement.Table != "" {
+			span.SetAttributes(semconv.DBCollectionName(tx.Statement.Table))
+		}
+		tx.InstanceSet(spanKey, span)
+	}
+}
+
+func endSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	span.SetAttributes(
+		semconv.DBQueryText(tx.Statement.SQL.String()),
+		attribute.Int64("db.response.rows_affected", tx.Statement.RowsAffected),
+	)
+
+	err := tx.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// a lookup that finds nothing is an answer, not a failure
+		err = nil
+	}
+	End(span, err)
+}
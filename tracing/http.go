@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDKey links a span to the X-Request-Id ErrorHandleMiddleWare hands
+// out, the request logger carries the trace id the other way round.
+const RequestIDKey = attribute.Key("tradealpha.request_id")
+
+// Middleware starts a server span for every request, continuing the trace
+// of the caller when it sent a traceparent header. Like the metrics
+// middleware it sits outside ErrorHandleMiddleWare so it sees the status
+// that was sent and the request id that was handed out.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			name := req.Method + " " + route
+			if route == "" {
+				name = req.Method
+			}
+
+			ctx, span := Tracer().Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.URLPath(req.URL.Path),
+				),
+			)
+			defer span.End()
+			if route != "" {
+				span.SetAttributes(semconv.HTTPRoute(route))
+			}
+
+			c.SetRequest(req.WithContext(ctx))
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				var httpError *echo.HTTPError
+				if errors.As(err, &httpError) {
+					status = httpError.Code
+				}
+				span.RecordError(err)
+			}
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if requestId := c.Response().Header().Get(echo.HeaderXRequestID); requestId != "" {
+				span.SetAttributes(RequestIDKey.String(requestId))
+			}
+			// client errors are the caller's problem, not a failed span
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			return err
+		}
+	}
+}
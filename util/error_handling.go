@@ -2,20 +2,25 @@ package util
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/pratyush934/tradealpha/server/tracing"
 	"github.com/pratyush934/tradealpha/server/types"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 )
 
 type AppError struct {
-	Status        int    `json:"status"`
-	Code          string `json:"code"`
-	Message       string `json:"message"`
-	InternalError error  `json:"-"`
+	Status        int           `json:"status"`
+	Code          string        `json:"code"`
+	Message       string        `json:"message"`
+	InternalError error         `json:"-"`
+	RetryAfter    time.Duration `json:"-"` // sent as the Retry-After header when set
 }
 
 func (a *AppError) Error() string {
@@ -25,6 +30,11 @@ func (a *AppError) Error() string {
 	return a.Message
 }
 
+// Unwrap lets errors.Is and errors.As see the cause.
+func (a *AppError) Unwrap() error {
+	return a.InternalError
+}
+
 func NewAppError(status int, code, message string, internalError error) *AppError {
 	return &AppError{
 		Status:        status,
@@ -48,14 +58,22 @@ func ErrorHandleMiddleWare(logger *zerolog.Logger) echo.MiddlewareFunc {
 				reqId = uuid.New().String()
 				c.Request().Header.Set(echo.HeaderXRequestID, reqId)
 			}
+			c.Response().Header().Set(echo.HeaderXRequestID, reqId)
+			c.Set("requestId", reqId)
 
-			log := logger.With().
+			logCtx := logger.With().
 				Str("request_id", reqId).
 				Str("method", c.Request().Method).
-				Str("path", c.Request().URL.Path).
-				Logger()
+				Str("path", c.Request().URL.Path)
+			// the span carries the request id, the logs carry the trace id
+			if traceId := tracing.TraceID(c.Request().Context()); traceId != "" {
+				logCtx = logCtx.Str("trace_id", traceId)
+			}
+			log := logCtx.Logger()
 
 			c.Set("logger", &log)
+			// services below the controllers read it back with zerolog.Ctx
+			c.SetRequest(c.Request().WithContext(log.WithContext(c.Request().Context())))
 
 			defer func() {
 				if r := recover(); r != nil {
@@ -93,6 +111,9 @@ func ErrorHandleMiddleWare(logger *zerolog.Logger) echo.MiddlewareFunc {
 					Err(appError.InternalError).
 					Msg("Handle Application Message Error")
 
+				if appError.RetryAfter > 0 {
+					c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(appError.RetryAfter.Seconds()))))
+				}
 				return c.JSON(appError.Status, ErrorResponse{
 					Message: appError.Message,
 					Status:  appError.Status,
@@ -101,7 +122,7 @@ func ErrorHandleMiddleWare(logger *zerolog.Logger) echo.MiddlewareFunc {
 
 			}
 
-			if errors.As(err, &gorm.ErrRecordNotFound) {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
 				log.Warn().
 					Err(err).
 					Msg("not able to find the record")